@@ -0,0 +1,13 @@
+// Package api - спецификация HTTP API в формате OpenAPI 3.1
+// Файл openapi.json поддерживается вручную вместе с обработчиками:
+// тест internal/server/openapi_test.go сверяет реальные ответы со схемами
+package api
+
+import (
+	_ "embed"
+)
+
+// Spec - содержимое openapi.json (отдается по GET /openapi.json)
+//
+//go:embed openapi.json
+var Spec []byte
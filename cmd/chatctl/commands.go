@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-chat-app/pkg/chatclient"
+)
+
+// app - общее состояние команд chatctl
+type app struct {
+	client  *chatclient.Client
+	timeout time.Duration // таймаут одного запроса
+	out     *printer
+}
+
+// call ограничивает запрос таймаутом из настроек
+func (a *app) call(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, a.timeout)
+}
+
+// list - chatctl list [-limit N] [-all]
+func (a *app) list(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	limit := flags.Int("limit", 50, "сколько чатов показать")
+	all := flags.Bool("all", false, "показать все чаты (постранично запрашивает весь список)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var chats []chatclient.Chat
+	var after uint
+	for {
+		callCtx, cancel := a.call(ctx)
+		page, err := a.client.ListChats(callCtx, after, min(*limit, 100))
+		cancel()
+		if err != nil {
+			return err
+		}
+		chats = append(chats, page.Chats...)
+		if page.NextAfter == 0 || (!*all && len(chats) >= *limit) {
+			break
+		}
+		after = page.NextAfter
+	}
+	if !*all && len(chats) > *limit {
+		chats = chats[:*limit]
+	}
+	return a.out.chats(chats)
+}
+
+// create - chatctl create TITLE
+func (a *app) create(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("использование: chatctl create TITLE")
+	}
+	ctx, cancel := a.call(ctx)
+	defer cancel()
+	chat, err := a.client.CreateChat(ctx, strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	return a.out.chat(chat)
+}
+
+// delete - chatctl delete ID
+func (a *app) delete(ctx context.Context, args []string) error {
+	chatID, err := parseChatID(args, "chatctl delete ID")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := a.call(ctx)
+	defer cancel()
+	if err := a.client.DeleteChat(ctx, chatID); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "чат %d удален\n", chatID)
+	return nil
+}
+
+// get - chatctl get ID [-limit N]
+func (a *app) get(ctx context.Context, args []string) error {
+	chatID, rest, err := parseChatIDFlags(args, "chatctl get ID [-limit N]")
+	if err != nil {
+		return err
+	}
+	flags := flag.NewFlagSet("get", flag.ContinueOnError)
+	limit := flags.Int("limit", 20, "сколько последних сообщений показать (максимум 100)")
+	if err := flags.Parse(rest); err != nil {
+		return err
+	}
+
+	ctx, cancel := a.call(ctx)
+	defer cancel()
+	res, err := a.client.GetChat(ctx, chatID, *limit)
+	if err != nil {
+		return err
+	}
+	if a.out.json {
+		return a.out.encode(res)
+	}
+	if err := a.out.chat(&res.Chat); err != nil {
+		return err
+	}
+	fmt.Fprintln(a.out.w)
+	return a.out.messages(reverse(res.Messages))
+}
+
+// send - chatctl send ID [-ttl D] [-md] TEXT...
+func (a *app) send(ctx context.Context, args []string) error {
+	const usage = "chatctl send ID [-ttl D] [-md] TEXT..."
+	chatID, rest, err := parseChatIDFlags(args, usage)
+	if err != nil {
+		return err
+	}
+	flags := flag.NewFlagSet("send", flag.ContinueOnError)
+	ttl := flags.Duration("ttl", 0, "исчезающее сообщение: удалить через D (например 30s, 1h)")
+	md := flags.Bool("md", false, "текст с markdown разметкой")
+	if err := flags.Parse(rest); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("использование: " + usage)
+	}
+	var opts []chatclient.SendOption
+	if *ttl != 0 {
+		opts = append(opts, chatclient.MessageTTL(*ttl))
+	}
+	if *md {
+		opts = append(opts, chatclient.Markdown())
+	}
+
+	ctx, cancel := a.call(ctx)
+	defer cancel()
+	msg, err := a.client.SendMessage(ctx, chatID, strings.Join(flags.Args(), " "), opts...)
+	if err != nil {
+		return err
+	}
+	if a.out.json {
+		return a.out.encode(msg)
+	}
+	return a.out.messages([]chatclient.Message{*msg})
+}
+
+// export - chatctl export ID [-format json|csv|html|txt] [-from T] [-to T] [-file PATH]
+// Выгрузка идет потоком без общего таймаута: история может быть большой, прервать - Ctrl+C
+func (a *app) export(ctx context.Context, args []string) error {
+	const usage = "chatctl export ID [-format json|csv|html|txt] [-from T] [-to T] [-file PATH]"
+	chatID, rest, err := parseChatIDFlags(args, usage)
+	if err != nil {
+		return err
+	}
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", chatclient.ExportJSON, "формат: json, csv, html или txt")
+	fromFlag := flags.String("from", "", "начало периода: RFC 3339 или дата ГГГГ-ММ-ДД (местное время)")
+	toFlag := flags.String("to", "", "конец периода, не включительно: RFC 3339 или дата ГГГГ-ММ-ДД")
+	path := flags.String("file", "", "файл для выгрузки (по умолчанию - стандартный вывод)")
+	if err := flags.Parse(rest); err != nil {
+		return err
+	}
+	opts := chatclient.ExportOptions{Format: *format}
+	if opts.From, err = parseTime(*fromFlag); err != nil {
+		return fmt.Errorf("неверный -from: %w", err)
+	}
+	if opts.To, err = parseTime(*toFlag); err != nil {
+		return fmt.Errorf("неверный -to: %w", err)
+	}
+
+	body, err := a.client.ExportChat(ctx, chatID, opts)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if *path == "" {
+		_, err = io.Copy(os.Stdout, body)
+		return err
+	}
+	f, err := os.Create(*path)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*path) // незавершенный файл хуже, чем никакого
+		return fmt.Errorf("выгрузка прервана: %w", err)
+	}
+	return nil
+}
+
+// parseTime разбирает время RFC 3339 или дату (полночь по местному времени); пусто - нулевое время
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, s, time.Local)
+}
+
+// parseChatID разбирает единственный аргумент - ID чата
+func parseChatID(args []string, usage string) (uint, error) {
+	if len(args) != 1 {
+		return 0, errors.New("использование: " + usage)
+	}
+	id, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("неверный ID чата %q", args[0])
+	}
+	return uint(id), nil
+}
+
+// parseChatIDFlags разбирает ID чата первым аргументом и возвращает оставшиеся флаги команды
+func parseChatIDFlags(args []string, usage string) (uint, []string, error) {
+	if len(args) == 0 {
+		return 0, nil, errors.New("использование: " + usage)
+	}
+	id, err := parseChatID(args[:1], usage)
+	return id, args[1:], err
+}
@@ -0,0 +1,118 @@
+// Команда chatctl - консольный клиент API чатов для эксплуатации и отладки
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// usage - справка по командам chatctl
+const usage = `Использование:
+  chatctl [-profile NAME] [-url URL] [-user ID] [-o table|json] КОМАНДА
+
+Команды:
+  chatctl list [-limit N] [-all]        список чатов
+  chatctl create TITLE                  создать чат
+  chatctl delete ID                     удалить чат вместе с сообщениями
+  chatctl get ID [-limit N]             чат и его последние сообщения
+  chatctl send ID [-ttl D] [-md] TEXT...
+                                        отправить сообщение (-ttl - исчезающее, удалится через D,
+                                        -md - с markdown разметкой)
+  chatctl tail ID [-n N]                показать последние сообщения и следить за новыми
+  chatctl export ID [-format json|csv|html|txt] [-from T] [-to T] [-file PATH]
+                                        выгрузить историю чата за период (T - RFC 3339 или ГГГГ-ММ-ДД)
+  chatctl tui ID                        интерактивный режим: лента сообщений и строка ввода
+
+Настройки (по убыванию приоритета): флаги, переменные окружения
+CHATCTL_URL, CHATCTL_USER, CHATCTL_USER_HEADER, CHATCTL_PROFILE и профиль
+из файла CHATCTL_CONFIG (по умолчанию ~/.config/chatctl/config.yaml):
+
+  default: local
+  profiles:
+    local:
+      url: http://localhost:8080
+    prod:
+      url: https://chat.example.com
+      user: alice
+      user_header: X-User-ID
+      timeout: 10s
+`
+
+func main() {
+	// Ctrl+C отменяет текущий запрос или поток событий
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fmt.Print(usage)
+			return
+		}
+		fmt.Fprintln(os.Stderr, "chatctl:", err)
+		os.Exit(1)
+	}
+}
+
+// run разбирает общие флаги и выполняет команду
+func run(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("chatctl", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	profile := flags.String("profile", "", "профиль из файла настроек")
+	baseURL := flags.String("url", "", "адрес API, например http://localhost:8080")
+	user := flags.String("user", "", "ID пользователя (передается в заголовке user_header)")
+	output := flags.String("o", "table", "формат вывода: table или json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return flag.ErrHelp
+	}
+	if *output != "table" && *output != "json" {
+		return fmt.Errorf("неизвестный формат вывода %q (table или json)", *output)
+	}
+
+	settings, err := loadSettings(*profile)
+	if err != nil {
+		return err
+	}
+	if *baseURL != "" {
+		settings.URL = *baseURL
+	}
+	if *user != "" {
+		settings.User = *user
+	}
+
+	app := &app{
+		client:  settings.client(),
+		timeout: settings.Timeout,
+		out:     newPrinter(os.Stdout, *output),
+	}
+	command, cmdArgs := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "list":
+		return app.list(ctx, cmdArgs)
+	case "create":
+		return app.create(ctx, cmdArgs)
+	case "delete":
+		return app.delete(ctx, cmdArgs)
+	case "get":
+		return app.get(ctx, cmdArgs)
+	case "send":
+		return app.send(ctx, cmdArgs)
+	case "tail":
+		return app.tail(ctx, cmdArgs)
+	case "export":
+		return app.export(ctx, cmdArgs)
+	case "tui":
+		return app.tui(ctx, cmdArgs)
+	case "help":
+		return flag.ErrHelp
+	default:
+		return fmt.Errorf("неизвестная команда: %s", command)
+	}
+}
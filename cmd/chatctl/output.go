@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go-chat-app/pkg/chatclient"
+)
+
+// timeFormat - формат времени в таблицах и ленте сообщений
+const timeFormat = "2006-01-02 15:04:05"
+
+// printer выводит результаты команд таблицей или JSON
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+// newPrinter создает printer для формата format (table или json)
+func newPrinter(w io.Writer, format string) *printer {
+	return &printer{w: w, json: format == "json"}
+}
+
+// chats выводит список чатов
+func (p *printer) chats(chats []chatclient.Chat) error {
+	if p.json {
+		return p.encode(chats)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tНАЗВАНИЕ\tМЕДЛЕННЫЙ РЕЖИМ\tХРАНЕНИЕ\tСОЗДАН")
+	for _, c := range chats {
+		slowMode := "-"
+		if c.SlowModeSeconds > 0 {
+			slowMode = (time.Duration(c.SlowModeSeconds) * time.Second).String()
+		}
+		// nil - срок по умолчанию сервера, 0 - хранить всегда
+		retention := "по умолчанию"
+		if c.RetentionDays != nil {
+			retention = "всегда"
+			if *c.RetentionDays > 0 {
+				retention = fmt.Sprintf("%d дн.", *c.RetentionDays)
+			}
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", c.ID, c.Title, slowMode, retention, c.CreatedAt.Local().Format(timeFormat))
+	}
+	return tw.Flush()
+}
+
+// chat выводит один чат
+func (p *printer) chat(chat *chatclient.Chat) error {
+	if p.json {
+		return p.encode(chat)
+	}
+	return p.chats([]chatclient.Chat{*chat})
+}
+
+// messages выводит сообщения в хронологическом порядке
+func (p *printer) messages(messages []chatclient.Message) error {
+	if p.json {
+		return p.encode(messages)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tВРЕМЯ\tТЕКСТ")
+	for _, m := range messages {
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", m.ID, m.CreatedAt.Local().Format(timeFormat), oneLine(m.Text))
+	}
+	return tw.Flush()
+}
+
+// message выводит сообщение одной строкой ленты (tail) или JSON строкой
+func (p *printer) message(m chatclient.Message) error {
+	if p.json {
+		return p.encode(m)
+	}
+	_, err := fmt.Fprintln(p.w, formatMessage(m))
+	return err
+}
+
+// encode выводит значение JSON
+// В ленте (tail) каждый объект занимает одну строку - удобно для jq и grep
+func (p *printer) encode(v any) error {
+	return json.NewEncoder(p.w).Encode(v)
+}
+
+// formatMessage - строка ленты: "[15:04:05] #12 текст"
+func formatMessage(m chatclient.Message) string {
+	return fmt.Sprintf("[%s] #%d %s", m.CreatedAt.Local().Format("15:04:05"), m.ID, m.Text)
+}
+
+// oneLine заменяет переводы строк, чтобы многострочное сообщение не ломало таблицу
+func oneLine(s string) string {
+	return strings.NewReplacer("\r\n", " ⏎ ", "\n", " ⏎ ", "\t", " ").Replace(s)
+}
+
+// reverse возвращает сообщения в обратном порядке (сервер отдает новые первыми)
+func reverse(messages []chatclient.Message) []chatclient.Message {
+	out := make([]chatclient.Message, len(messages))
+	for i, m := range messages {
+		out[len(messages)-1-i] = m
+	}
+	return out
+}
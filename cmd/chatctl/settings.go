@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go-chat-app/pkg/chatclient"
+
+	"gopkg.in/yaml.v3"
+)
+
+// settings - настройки подключения к API
+type settings struct {
+	URL        string        `yaml:"url"`
+	User       string        `yaml:"user"`        // ID пользователя для шлюза
+	UserHeader string        `yaml:"user_header"` // заголовок с ID пользователя (как AUTH_USER_HEADER сервера)
+	Timeout    time.Duration `yaml:"timeout"`     // таймаут обычного запроса (поток событий не ограничен)
+}
+
+// profileFile - файл профилей chatctl
+type profileFile struct {
+	Default  string              `yaml:"default"`
+	Profiles map[string]settings `yaml:"profiles"`
+}
+
+// loadSettings собирает настройки: значения по умолчанию, профиль, переменные окружения
+// Флаги командной строки применяет вызывающий
+func loadSettings(profile string) (settings, error) {
+	s := settings{
+		URL:        "http://localhost:8080",
+		UserHeader: "X-User-ID",
+		Timeout:    30 * time.Second,
+	}
+
+	if profile == "" {
+		profile = os.Getenv("CHATCTL_PROFILE")
+	}
+	file, err := readProfileFile()
+	if err != nil {
+		return s, err
+	}
+	if profile == "" {
+		profile = file.Default
+	}
+	if profile != "" {
+		p, ok := file.Profiles[profile]
+		if !ok {
+			return s, fmt.Errorf("профиль %q не найден в %s", profile, profilePath())
+		}
+		s.merge(p)
+	}
+
+	s.merge(settings{
+		URL:        os.Getenv("CHATCTL_URL"),
+		User:       os.Getenv("CHATCTL_USER"),
+		UserHeader: os.Getenv("CHATCTL_USER_HEADER"),
+	})
+	return s, nil
+}
+
+// merge переносит непустые значения из other
+func (s *settings) merge(other settings) {
+	if other.URL != "" {
+		s.URL = other.URL
+	}
+	if other.User != "" {
+		s.User = other.User
+	}
+	if other.UserHeader != "" {
+		s.UserHeader = other.UserHeader
+	}
+	if other.Timeout > 0 {
+		s.Timeout = other.Timeout
+	}
+}
+
+// client создает клиент API по настройкам
+// Таймаут задается через ctx у каждого запроса (app.call), а не у http.Client:
+// иначе он оборвал бы поток событий
+func (s settings) client() *chatclient.Client {
+	opts := []chatclient.Option{chatclient.WithHTTPClient(&http.Client{})}
+	if s.User != "" {
+		opts = append(opts, chatclient.WithHeader(s.UserHeader, s.User))
+	}
+	return chatclient.New(s.URL, opts...)
+}
+
+// profilePath - путь к файлу профилей
+func profilePath() string {
+	if path := os.Getenv("CHATCTL_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "chatctl", "config.yaml")
+}
+
+// readProfileFile читает файл профилей; отсутствие файла - не ошибка
+func readProfileFile() (profileFile, error) {
+	var file profileFile
+	path := profilePath()
+	if path == "" {
+		return file, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return file, nil
+	}
+	if err != nil {
+		return file, err
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return file, fmt.Errorf("файл профилей %s: %w", path, err)
+	}
+	return file, nil
+}
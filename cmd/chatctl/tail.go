@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go-chat-app/pkg/chatclient"
+)
+
+// errChatDeleted - чат удален, следить больше не за чем
+var errChatDeleted = errors.New("чат удален")
+
+// tail - chatctl tail ID [-n N]
+func (a *app) tail(ctx context.Context, args []string) error {
+	chatID, rest, err := parseChatIDFlags(args, "chatctl tail ID [-n N]")
+	if err != nil {
+		return err
+	}
+	flags := flag.NewFlagSet("tail", flag.ContinueOnError)
+	n := flags.Int("n", 10, "сколько последних сообщений показать перед новыми (максимум 100)")
+	if err := flags.Parse(rest); err != nil {
+		return err
+	}
+
+	callCtx, cancel := a.call(ctx)
+	res, err := a.client.GetChat(callCtx, chatID, max(*n, 1))
+	cancel()
+	if err != nil {
+		return err
+	}
+	var lastID uint
+	if len(res.Messages) > 0 {
+		lastID = res.Messages[0].ID
+	}
+	if *n > 0 {
+		for _, m := range reverse(res.Messages) {
+			a.out.message(m)
+		}
+	}
+
+	err = a.follow(ctx, chatID, lastID,
+		func(m chatclient.Message) { a.out.message(m) },
+		func(status string) { fmt.Fprintln(os.Stderr, status) },
+	)
+	if errors.Is(err, errChatDeleted) {
+		fmt.Fprintln(os.Stderr, "чат удален")
+		return nil
+	}
+	return err
+}
+
+// follow передает handle новые сообщения чата с ID больше lastID до отмены ctx
+// Обрывы соединения переживает: переподключается с растущей паузой и дочитывает
+// пропущенное через GetChat. Возвращает errChatDeleted, когда чат удален
+// notify получает сообщения о состоянии соединения
+func (a *app) follow(ctx context.Context, chatID, lastID uint, handle func(chatclient.Message), notify func(string)) error {
+	deliver := func(m chatclient.Message) {
+		if m.ID > lastID {
+			lastID = m.ID
+			handle(m)
+		}
+	}
+
+	pause := time.Second
+	for {
+		started := time.Now()
+		err := a.followOnce(ctx, chatID, deliver)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, errChatDeleted) || errors.Is(err, chatclient.ErrNotFound) {
+			return errChatDeleted
+		}
+		// Поток долго работал - это не серия неудачных подключений, начинаем паузы заново
+		if time.Since(started) > time.Minute {
+			pause = time.Second
+		}
+		reason := "сервер закрыл поток"
+		if err != nil {
+			reason = err.Error()
+		}
+		notify(fmt.Sprintf("соединение потеряно (%s), переподключение через %s", reason, pause))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(pause):
+		}
+		pause = min(pause*2, 30*time.Second)
+	}
+}
+
+// followOnce подписывается на поток и читает его до обрыва
+// nil - сервер закрыл поток штатно
+func (a *app) followOnce(ctx context.Context, chatID uint, deliver func(chatclient.Message)) error {
+	sub, err := a.client.Subscribe(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	// Сообщения, отправленные до подписки (пока нас не было), дочитываем после нее:
+	// так между историей и потоком не остается пропуска, а дубликаты отсекает deliver
+	callCtx, cancel := a.call(ctx)
+	res, err := a.client.GetChat(callCtx, chatID, 100)
+	cancel()
+	if err != nil {
+		return err
+	}
+	for _, m := range reverse(res.Messages) {
+		deliver(m)
+	}
+
+	for {
+		event, err := sub.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		switch event.Type {
+		case chatclient.EventMessageCreated:
+			if event.Message != nil {
+				deliver(*event.Message)
+			}
+		case chatclient.EventChatDeleted:
+			return errChatDeleted
+		}
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// terminalSize на системах без TIOCGWINSZ: размер из LINES/COLUMNS или 24x80
+func terminalSize(f *os.File) (rows, cols int, ok bool) {
+	rows, cols = envInt("LINES", 24), envInt("COLUMNS", 80)
+	return rows, cols, isTerminal(f)
+}
+
+// isTerminal сообщает, подключен ли f к символьному устройству (консоли)
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// notifyResize: изменение размера окна не отслеживается
+func notifyResize(ch chan<- struct{}) func() {
+	return func() {}
+}
+
+// envInt читает положительное число из переменной окружения
+func envInt(name string, def int) int {
+	var n int
+	if _, err := fmt.Sscan(os.Getenv(name), &n); err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
@@ -0,0 +1,50 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+
+	"golang.org/x/sys/unix"
+)
+
+// terminalSize возвращает размер окна терминала; ok == false - f не терминал
+func terminalSize(f *os.File) (rows, cols int, ok bool) {
+	ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Row == 0 {
+		return 0, 0, false
+	}
+	return int(ws.Row), int(ws.Col), true
+}
+
+// isTerminal сообщает, подключен ли f к терминалу
+func isTerminal(f *os.File) bool {
+	_, _, ok := terminalSize(f)
+	return ok
+}
+
+// notifyResize сообщает в ch об изменении размера окна (SIGWINCH)
+// Возвращает функцию отписки
+func notifyResize(ch chan<- struct{}) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, unix.SIGWINCH)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-signals:
+				select {
+				case ch <- struct{}{}:
+				default: // перерисовка уже запрошена
+				}
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"go-chat-app/pkg/chatclient"
+)
+
+// Управляющие последовательности ANSI/VT100
+const (
+	escAltScreenOn  = "\x1b[?1049h" // альтернативный экран: после выхода терминал вернется как был
+	escAltScreenOff = "\x1b[?1049l"
+	escClearScreen  = "\x1b[2J"
+	escClearLine    = "\x1b[2K"
+	escResetRegion  = "\x1b[r"
+	escSaveCursor   = "\x1b7"
+	escRestore      = "\x1b8"
+	escReverse      = "\x1b[7m"
+	escNormal       = "\x1b[0m"
+)
+
+// maxPaneLines - сколько строк ленты хранить для перерисовки при изменении размера окна
+const maxPaneLines = 1000
+
+// prompt - приглашение строки ввода
+const prompt = "> "
+
+// screen - экран интерактивного режима:
+// строки 1..rows-2 - лента сообщений (область прокрутки), rows-1 - строка состояния, rows - ввод
+// Ввод читается в обычном (каноническом) режиме терминала: редактирование строки и эхо
+// делает сам терминал, а новые сообщения печатаются в область прокрутки с сохранением курсора
+type screen struct {
+	mu     sync.Mutex
+	w      io.Writer
+	rows   int
+	cols   int
+	header string
+	lines  []string
+}
+
+// tui - chatctl tui ID
+func (a *app) tui(ctx context.Context, args []string) error {
+	chatID, err := parseChatID(args, "chatctl tui ID")
+	if err != nil {
+		return err
+	}
+	rows, cols, ok := terminalSize(os.Stdout)
+	if !ok || !isTerminal(os.Stdin) {
+		return errors.New("интерактивный режим работает только в терминале, используйте tail и send")
+	}
+	if rows < 5 {
+		return errors.New("окно терминала слишком маленькое")
+	}
+
+	callCtx, cancel := a.call(ctx)
+	res, err := a.client.GetChat(callCtx, chatID, 100)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	s := &screen{
+		w:      os.Stdout,
+		rows:   rows,
+		cols:   cols,
+		header: fmt.Sprintf(" %s (#%d) | Enter - отправить, /quit или Ctrl+D - выход ", res.Chat.Title, res.Chat.ID),
+	}
+	var lastID uint
+	for _, m := range reverse(res.Messages) {
+		s.lines = append(s.lines, formatMessage(m))
+		lastID = m.ID
+	}
+	s.open()
+	defer s.close()
+
+	// Изменение размера окна - перерисовываем экран
+	resized := make(chan struct{}, 1)
+	stopResize := notifyResize(resized)
+	defer stopResize()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-resized:
+				if rows, cols, ok := terminalSize(os.Stdout); ok && rows >= 5 {
+					s.resize(rows, cols)
+				}
+			}
+		}
+	}()
+
+	// Новые сообщения
+	followErr := make(chan error, 1)
+	go func() {
+		followErr <- a.follow(ctx, chatID, lastID,
+			func(m chatclient.Message) { s.add(formatMessage(m)) },
+			func(status string) { s.add("* " + status) },
+		)
+	}()
+
+	// Строки ввода; горутина чтения stdin не останавливается, но процесс завершится после выхода
+	input := make(chan string)
+	go func() {
+		defer close(input)
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			input <- scanner.Text()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-followErr:
+			if errors.Is(err, errChatDeleted) {
+				s.close()
+				fmt.Fprintln(os.Stderr, "чат удален")
+				return nil
+			}
+			return err
+		case line, ok := <-input:
+			if !ok {
+				return nil // Ctrl+D
+			}
+			s.prompt()
+			text := strings.TrimSpace(line)
+			switch {
+			case text == "":
+				continue
+			case text == "/quit" || text == "/exit":
+				return nil
+			}
+			callCtx, cancel := a.call(ctx)
+			_, err := a.client.SendMessage(callCtx, chatID, text)
+			cancel()
+			if err != nil {
+				s.add("! не отправлено: " + err.Error())
+			}
+			// Отправленное сообщение придет из потока событий вместе с остальными
+		}
+	}
+}
+
+// open переключает терминал на альтернативный экран и рисует его
+func (s *screen) open() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	io.WriteString(s.w, escAltScreenOn)
+	s.redraw()
+}
+
+// close возвращает терминал в исходное состояние (повторный вызов безопасен)
+func (s *screen) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w == nil {
+		return
+	}
+	io.WriteString(s.w, escResetRegion+escAltScreenOff)
+	s.w = nil
+}
+
+// resize перерисовывает экран под новый размер окна
+func (s *screen) resize(rows, cols int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w == nil {
+		return
+	}
+	s.rows, s.cols = rows, cols
+	s.redraw()
+}
+
+// add добавляет строку в ленту, не трогая набираемый текст
+func (s *screen) add(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lines = append(s.lines, line)
+	if len(s.lines) > maxPaneLines {
+		s.lines = s.lines[len(s.lines)-maxPaneLines:]
+	}
+	if s.w == nil {
+		return
+	}
+	// Курсор сохраняется и восстанавливается: пользователь может быть посреди ввода
+	// Перевод строки на нижней строке области прокрутки сдвигает только ленту
+	fmt.Fprintf(s.w, "%s\x1b[%d;1H\n%s%s", escSaveCursor, s.paneRows(), s.fit(line), escRestore)
+}
+
+// prompt очищает строку ввода после Enter
+func (s *screen) prompt() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w == nil {
+		return
+	}
+	fmt.Fprintf(s.w, "\x1b[%d;1H%s%s", s.rows, escClearLine, prompt)
+}
+
+// redraw рисует экран целиком (вызывается под s.mu)
+func (s *screen) redraw() {
+	var b strings.Builder
+	b.WriteString(escResetRegion + escClearScreen)
+	// Область прокрутки - лента; строки состояния и ввода остаются на месте
+	fmt.Fprintf(&b, "\x1b[1;%dr", s.paneRows())
+
+	visible := s.lines
+	if len(visible) > s.paneRows() {
+		visible = visible[len(visible)-s.paneRows():]
+	}
+	for i, line := range visible {
+		fmt.Fprintf(&b, "\x1b[%d;1H%s", s.paneRows()-len(visible)+1+i, s.fit(line))
+	}
+
+	header := s.fit(s.header)
+	header += strings.Repeat(" ", max(s.cols-len([]rune(header)), 0))
+	fmt.Fprintf(&b, "\x1b[%d;1H%s%s%s", s.rows-1, escReverse, header, escNormal)
+	fmt.Fprintf(&b, "\x1b[%d;1H%s", s.rows, prompt)
+	io.WriteString(s.w, b.String())
+}
+
+// paneRows - высота ленты
+func (s *screen) paneRows() int {
+	return s.rows - 2
+}
+
+// fit обрезает строку по ширине окна: перенос сломал бы подсчет строк ленты
+func (s *screen) fit(line string) string {
+	line = oneLine(line)
+	runes := []rune(line)
+	if s.cols > 1 && len(runes) > s.cols {
+		return string(runes[:s.cols-1]) + "…"
+	}
+	return line
+}
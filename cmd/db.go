@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"go-chat-app/internal/config"
+	"go-chat-app/internal/db/postgres"
+	"go-chat-app/internal/db/service"
+	"go-chat-app/internal/db/sqlite"
+
+	"gorm.io/gorm"
+)
+
+// openDB подключается к базе данных драйвера из конфигурации (DB_DRIVER)
+func openDB(cfg config.DBConfig) (*gorm.DB, error) {
+	switch cfg.Driver {
+	case "postgres":
+		return postgres.InitDB(cfg)
+	case "sqlite":
+		return sqlite.InitDB(cfg)
+	default:
+		return nil, fmt.Errorf("неизвестный драйвер БД: %q", cfg.Driver)
+	}
+}
+
+// newPubSub выбирает доставку событий чатов (см. config.EventsConfig)
+// Для postgres запускает LISTEN в фоне до отмены ctx
+func newPubSub(ctx context.Context, cfg *config.Config, db *gorm.DB) service.PubSub {
+	if cfg.EventsBackend() != "postgres" {
+		slog.Info("События чатов доставляются в пределах инстанса")
+		return service.NewLocalPubSub()
+	}
+	events := postgres.NewPubSub(db, postgres.DSN(cfg.DB))
+	go events.Listen(ctx)
+	slog.Info("События чатов доставляются через PostgreSQL LISTEN/NOTIFY")
+	return events
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"go-chat-app/internal/config"
+	"go-chat-app/internal/db/migrate"
+	"go-chat-app/internal/importer"
+	"go-chat-app/internal/repository"
+)
+
+// runImport переносит историю из выгрузки Slack (ZIP) или Telegram (result.json)
+// import [-dry-run] [-report FILE] slack|telegram FILE
+func runImport(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "только разобрать выгрузку и показать отчет, ничего не записывая")
+	reportPath := flags.String("report", "", "сохранить полный отчет в JSON файл")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		return errors.New("использование: import [-dry-run] [-report FILE] slack|telegram FILE")
+	}
+	source, path := flags.Arg(0), flags.Arg(1)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	src, err := importer.Open(source, f, info.Size())
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Пробному запуску база не нужна: хранилища не вызываются
+	im := importer.New(nil, nil)
+	if !*dryRun {
+		db, err := openDB(cfg.DB)
+		if err != nil {
+			return fmt.Errorf("ошибка БД: %w", err)
+		}
+		// Схема должна быть актуальной: иначе вставка упадет на середине импорта
+		if err := migrate.CheckVersion(ctx, db); err != nil {
+			return fmt.Errorf("%w (выполните migrate up)", err)
+		}
+		im = importer.New(repository.NewChatRepository(db), repository.NewMessageRepository(db))
+	}
+	report, importErr := im.Import(ctx, src, *dryRun)
+	printImportReport(os.Stdout, report)
+	if *reportPath != "" {
+		if err := writeImportReport(*reportPath, report); err != nil {
+			return err
+		}
+	}
+	if importErr != nil {
+		return fmt.Errorf("импорт прерван: %w", importErr)
+	}
+	return nil
+}
+
+// printImportReport выводит краткий отчет: чаты, число сообщений и причины пропусков
+func printImportReport(w io.Writer, r *importer.Report) {
+	if r.DryRun {
+		fmt.Fprintln(w, "Пробный запуск: в базу ничего не записано")
+	}
+	for _, chat := range r.Chats {
+		if chat.ID != 0 {
+			fmt.Fprintf(w, "Чат %d %q (%s %s): сообщений %d\n", chat.ID, chat.Title, r.Source, chat.SourceID, chat.Messages)
+		} else {
+			fmt.Fprintf(w, "Чат %q (%s %s): сообщений %d\n", chat.Title, r.Source, chat.SourceID, chat.Messages)
+		}
+	}
+	fmt.Fprintf(w, "Итого: чатов %d, сообщений %d, разбито на части %d, пропущено %d\n",
+		len(r.Chats), r.Messages, r.Split, r.SkippedTotal)
+
+	reasons := make([]string, 0, len(r.Skipped))
+	for reason := range r.Skipped {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "  %s: %d\n", reason, r.Skipped[reason])
+	}
+}
+
+// writeImportReport сохраняет отчет в JSON
+func writeImportReport(path string, r *importer.Report) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -1,47 +1,93 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"net/http"
+	"log/slog"
+	"os"
 
 	"go-chat-app/internal/config"
-	"go-chat-app/internal/db/postgres"
-	"go-chat-app/internal/db/service"
-	"go-chat-app/internal/handler"
-	"go-chat-app/internal/repository"
+	"go-chat-app/internal/logger"
 )
 
+// usage - справка по командам бинарника
+const usage = `Использование:
+  main [-config app.yaml] [флаги настроек] КОМАНДА
+
+Команды:
+  main [serve] [-auto-migrate=false]   запуск HTTP сервера (команда по умолчанию)
+  main migrate up                      применить все новые миграции
+  main migrate down                    откатить последнюю миграцию
+  main migrate redo                    откатить и заново применить последнюю миграцию
+  main migrate status                  показать список миграций и их статус
+  main migrate version                 показать текущую версию схемы БД
+  main migrate create [-dir migrations] NAME
+                                       создать новый файл миграции
+  main import [-dry-run] [-report FILE] slack|telegram FILE
+                                       перенести историю из выгрузки Slack (ZIP) или Telegram (result.json)
+  main purge [-dry-run]                удалить сообщения старше срока хранения чатов
+  main config print                    показать итоговую конфигурацию (секреты скрыты)
+
+Список флагов настроек: main -h
+`
+
 func main() {
 
-	// Конфигурация
-	cfg := config.LoadConfig()
+	// Конфигурация: значения по умолчанию, YAML файл, окружение, флаги
+	// Флаги настроек указываются до команды: main -port 9090 serve
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fmt.Print(usage)
+			return
+		}
+		log.Fatal(err)
+	}
 
-	// Подключение к БД
-	db, err := postgres.InitDB()
+	// Логирование: slog становится логгером по умолчанию,
+	// стандартный пакет log тоже пишет через него
+	appLogger, err := logger.New(os.Stdout, cfg.Log.Format, cfg.Log.Level)
 	if err != nil {
-		log.Fatal("Ошибка БД:", err)
+		log.Fatal("Ошибка логгера:", err)
 	}
+	slog.SetDefault(appLogger)
 
-	// ВСЕГДА применяем миграции при запуске
-	// goose сам проверяет, какие миграции уже применены
-	log.Println("Проверка и применение миграций...")
-	if err := postgres.RunMigrations(db, "migrations"); err != nil {
-		log.Fatal("Ошибка миграций:", err)
+	// Первый аргумент - команда, без аргументов запускаем сервер
+	command := "serve"
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		command, args = args[0], args[1:]
 	}
-	log.Println("Миграции проверены/применены.")
 
-	// Инициализация зависимостей
-	chatRepo := repository.NewChatRepository(db)
-	messageRepo := repository.NewMessageRepository(db)
-	chatService := service.NewChatService(chatRepo, messageRepo)
-	chatHandler := handler.NewChatHandler(chatService)
+	switch command {
+	case "serve":
+		err = runServe(cfg, args)
+	case "migrate":
+		err = runMigrate(cfg, args)
+	case "import":
+		err = runImport(cfg, args)
+	case "purge":
+		err = runPurge(cfg, args)
+	case "config":
+		err = runConfig(cfg, args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		log.Fatalf("Неизвестная команда: %s", command)
+	}
 
-	// Запуск сервера
-	addr := fmt.Sprintf(":%s", cfg.Port)
-	log.Printf("Сервер запущен на http://localhost%s", addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
 
-	if err := http.ListenAndServe(addr, chatHandler); err != nil {
-		log.Fatal("Ошибка сервера:", err)
+// runConfig выполняет подкоманды config: print
+func runConfig(cfg *config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "print" {
+		return errors.New("использование: config print")
 	}
+	return cfg.Print(os.Stdout)
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"go-chat-app/internal/config"
+	"go-chat-app/internal/db/migrate"
+	"os"
+)
+
+// runMigrate выполняет подкоманды migrate: up, down, redo, status, version, create
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("не указана подкоманда migrate (up, down, redo, status, version, create)")
+	}
+	sub, args := args[0], args[1:]
+
+	// create работает только с файлами на диске, подключение к БД не нужно
+	if sub == "create" {
+		flags := flag.NewFlagSet("migrate create", flag.ExitOnError)
+		dir := flags.String("dir", "migrations", "папка с миграциями (внутри postgres/ и sqlite/)")
+		flags.Parse(args)
+		if flags.NArg() != 1 {
+			return errors.New("использование: migrate create [-dir migrations] NAME")
+		}
+		return migrate.Create(*dir, flags.Arg(0))
+	}
+
+	db, err := openDB(cfg.DB)
+	if err != nil {
+		return fmt.Errorf("ошибка БД: %w", err)
+	}
+	ctx := context.Background()
+
+	switch sub {
+	case "up":
+		return migrate.Up(ctx, db)
+	case "down":
+		return migrate.Down(ctx, db)
+	case "redo":
+		return migrate.Redo(ctx, db)
+	case "status":
+		return migrate.Status(ctx, db, os.Stdout)
+	case "version":
+		version, err := migrate.Version(ctx, db)
+		if err != nil {
+			return err
+		}
+		fmt.Println(version)
+		return nil
+	default:
+		return fmt.Errorf("неизвестная подкоманда migrate: %s", sub)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go-chat-app/internal/config"
+	"go-chat-app/internal/db/migrate"
+	"go-chat-app/internal/repository"
+	"go-chat-app/internal/retention"
+)
+
+// runPurge однократно удаляет сообщения старше срока хранения чатов
+// purge [-dry-run]
+// Аренда не берется: разовый запуск не ждет фоновую очистку сервера (повторное удаление безвредно)
+func runPurge(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("purge", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", cfg.Retention.DryRun, "только показать, сколько сообщений было бы удалено")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		return errors.New("использование: purge [-dry-run]")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := openDB(cfg.DB)
+	if err != nil {
+		return fmt.Errorf("ошибка БД: %w", err)
+	}
+	// Без колонки retention_days сроки чатов не прочитать
+	if err := migrate.CheckVersion(ctx, db); err != nil {
+		return fmt.Errorf("%w (выполните migrate up)", err)
+	}
+
+	purger := retention.NewPurger(repository.NewChatRepository(db), repository.NewMessageRepository(db), nil, retention.Config{
+		DefaultDays: cfg.Retention.DefaultDays,
+		BatchSize:   cfg.Retention.BatchSize,
+		DryRun:      *dryRun,
+	})
+	report, err := purger.RunOnce(ctx)
+	printPurgeReport(os.Stdout, report)
+	if err != nil {
+		return fmt.Errorf("очистка прервана: %w", err)
+	}
+	return nil
+}
+
+// printPurgeReport выводит чаты, в которых удалены (или были бы удалены) сообщения
+func printPurgeReport(w io.Writer, r *retention.Report) {
+	verb := "удалено"
+	if r.DryRun {
+		fmt.Fprintln(w, "Пробный запуск: ничего не удалено")
+		verb = "будет удалено"
+	}
+	for _, chat := range r.Chats {
+		fmt.Fprintf(w, "Чат %d %q (срок %d дн., до %s): %s сообщений %d\n",
+			chat.ChatID, chat.Title, chat.RetentionDays, chat.Before.Format("2006-01-02 15:04"), verb, chat.Messages)
+	}
+	fmt.Fprintf(w, "Итого: чатов %d, %s сообщений %d\n", len(r.Chats), verb, r.Messages)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go-chat-app/internal/config"
+	"go-chat-app/internal/db/migrate"
+	"go-chat-app/internal/db/service"
+	"go-chat-app/internal/handler"
+	"go-chat-app/internal/importer"
+	"go-chat-app/internal/outbox"
+	"go-chat-app/internal/ratelimit"
+	"go-chat-app/internal/repository"
+	"go-chat-app/internal/retention"
+	"go-chat-app/internal/scheduler"
+	"go-chat-app/internal/server"
+	"go-chat-app/internal/tracing"
+	"go-chat-app/internal/unfurl"
+)
+
+// runServe запускает HTTP сервер и ждет SIGINT/SIGTERM для graceful shutdown
+func runServe(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	autoMigrate := flags.Bool("auto-migrate", cfg.DB.AutoMigrate, "применять миграции при запуске")
+	flags.Parse(args)
+
+	// Контекст отменяется по SIGINT/SIGTERM - с этого начинается graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Трассировка: OTLP экспорт или вывод в консоль для локальной отладки
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing.ServiceName, cfg.Tracing.Exporter)
+	if err != nil {
+		return fmt.Errorf("ошибка трассировки: %w", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Подключение к БД
+	db, err := openDB(cfg.DB)
+	if err != nil {
+		return fmt.Errorf("ошибка БД: %w", err)
+	}
+
+	// Миграции при запуске можно отключить, если их применяет
+	// отдельный шаг деплоя (main migrate up)
+	// goose сам проверяет, какие миграции уже применены
+	if *autoMigrate {
+		slog.Info("Проверка и применение миграций...")
+		if err := migrate.Up(ctx, db); err != nil {
+			return fmt.Errorf("ошибка миграций: %w", err)
+		}
+		slog.Info("Миграции проверены/применены.")
+	}
+
+	// Инициализация зависимостей
+	// Без публикации события outbox не пишутся: их некому доставить и удалить
+	var repoOpts []repository.Option
+	if cfg.Outbox.Publisher == "none" {
+		repoOpts = append(repoOpts, repository.WithoutOutbox())
+	}
+	chatRepo := repository.NewChatRepository(db, repoOpts...)
+	messageRepo := repository.NewMessageRepository(db, repoOpts...)
+	scheduledRepo := repository.NewScheduledRepository(db)
+	pinRepo := repository.NewPinRepository(db, repoOpts...)
+	mentionRepo := repository.NewMentionRepository(db)
+	previewRepo := repository.NewLinkPreviewRepository(db)
+	pollRepo := repository.NewPollRepository(db)
+	// Хранилище лимитов в памяти: лимиты считаются отдельно на каждом инстансе
+	limitStore := ratelimit.NewMemoryStore()
+	events := newPubSub(ctx, cfg, db)
+	serviceOpts := []service.Option{
+		service.WithRateLimitStore(limitStore),
+		service.WithPubSub(events),
+		service.WithScheduledStore(scheduledRepo),
+		service.WithPins(pinRepo, cfg.Pins.MaxPerChat),
+		service.WithMentions(mentionRepo),
+		service.WithPolls(pollRepo),
+	}
+	if cfg.Previews.Enabled {
+		serviceOpts = append(serviceOpts, service.WithLinkPreviews(previewRepo, cfg.Previews.CacheTTL))
+	}
+	chatService := service.NewChatService(chatRepo, messageRepo, serviceOpts...)
+	healthHandler := handler.NewHealthHandler(cfg.Server.HealthTimeout,
+		handler.HealthCheck{
+			Name: "database",
+			Check: func(ctx context.Context) error {
+				sqlDB, err := db.DB()
+				if err != nil {
+					return err
+				}
+				return sqlDB.PingContext(ctx)
+			},
+		},
+		handler.HealthCheck{
+			Name:  "migrations",
+			Check: func(ctx context.Context) error { return migrate.CheckVersion(ctx, db) },
+		},
+	)
+	outboxRepo := repository.NewOutboxRepository(db)
+	startOutboxRelay(ctx, cfg.Outbox, outboxRepo)
+	startRetentionPurge(ctx, cfg.Retention, chatRepo, messageRepo, outboxRepo)
+	go sweepExpiredMessages(ctx, chatService, cfg.Ephemeral)
+	startScheduler(ctx, cfg.Scheduler, scheduledRepo, chatService)
+	if cfg.Previews.Enabled {
+		startUnfurler(ctx, cfg.Previews, previewRepo, chatService)
+	}
+
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	go cleanupIdempotencyKeys(ctx, idempotencyRepo, cfg.Idempotency.CleanupInterval)
+
+	routerOpts := server.Options{
+		UserHeader:       cfg.Auth.UserHeader,
+		TrustProxy:       cfg.Server.TrustProxy,
+		IdempotencyStore: idempotencyRepo,
+		IdempotencyTTL:   cfg.Idempotency.TTL,
+		AdminToken:       cfg.Admin.Token,
+		Importer:         importer.New(chatRepo, messageRepo),
+		ImportMaxBytes:   int64(cfg.Admin.ImportMaxMB) << 20,
+	}
+	if cfg.RateLimit.Enabled {
+		routerOpts.RateLimits = cfg.RateLimit.Rules()
+		routerOpts.RateLimitStore = limitStore
+	}
+	router := server.NewRouter(chatService, healthHandler, routerOpts)
+
+	// Запуск сервера
+	addr := fmt.Sprintf(":%s", cfg.Server.Port)
+	srv := &http.Server{
+		Addr:         addr,
+		Handler:      router,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+	// Потоки событий сами не завершаются: закрываем подписки в начале Shutdown,
+	// иначе он ждал бы их до ShutdownTimeout
+	srv.RegisterOnShutdown(events.Close)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Сервер запущен", slog.String("addr", "http://localhost"+addr))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("ошибка сервера: %w", err)
+	case <-ctx.Done():
+	}
+
+	// Graceful shutdown:
+	// 1. /readyz начинает отвечать 503, балансировщик убирает инстанс
+	// 2. ждем ShutdownDelay, пока это изменение до него дойдет
+	// 3. перестаем принимать соединения и ждем завершения активных запросов
+	slog.Info("Остановка сервера...")
+	healthHandler.SetShuttingDown()
+	time.Sleep(cfg.Server.ShutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("ошибка остановки сервера: %w", err)
+	}
+	slog.Info("Сервер остановлен")
+	return nil
+}
+
+// startOutboxRelay запускает в фоне доставку событий outbox (до отмены ctx)
+// На нескольких инстансах события публикует только один - владелец аренды
+func startOutboxRelay(ctx context.Context, cfg config.OutboxConfig, store repository.OutboxStore) {
+	var publisher outbox.EventPublisher
+	switch cfg.Publisher {
+	case "none":
+		slog.Info("Outbox выключен: события не пишутся и не публикуются")
+		return
+	case "webhook":
+		publisher = outbox.NewWebhookPublisher(cfg.WebhookURL, cfg.WebhookTimeout)
+	default:
+		publisher = outbox.LogPublisher{}
+	}
+	relay := outbox.NewRelay(store, publisher, outbox.Config{
+		BatchSize:   cfg.BatchSize,
+		Interval:    cfg.Interval,
+		Retention:   cfg.Retention,
+		LeaseTTL:    cfg.LeaseTTL,
+		MaxAttempts: cfg.MaxAttempts,
+		RetryDelay:  cfg.RetryDelay,
+	})
+	go relay.Run(ctx)
+	slog.Info("Relay outbox запущен", slog.String("publisher", cfg.Publisher))
+}
+
+// startRetentionPurge запускает в фоне очистку сообщений по сроку хранения чатов (до отмены ctx)
+// Аренда хранится в той же таблице, что и аренда relay outbox: чистит только один инстанс
+func startRetentionPurge(ctx context.Context, cfg config.RetentionConfig, chats repository.ChatStore, messages repository.MessageStore, leases retention.Leaser) {
+	purger := retention.NewPurger(chats, messages, leases, retention.Config{
+		DefaultDays: cfg.DefaultDays,
+		BatchSize:   cfg.BatchSize,
+		Interval:    cfg.Interval,
+		DryRun:      cfg.DryRun,
+	})
+	go purger.Run(ctx)
+	slog.Info("Очистка старых сообщений запущена",
+		slog.Int("default_days", cfg.DefaultDays),
+		slog.Duration("interval", cfg.Interval),
+		slog.Bool("dry_run", cfg.DryRun),
+	)
+}
+
+// startScheduler запускает в фоне отправку запланированных сообщений (до отмены ctx)
+// Работает на каждом инстансе: сообщения делятся захватом строк, и каждое отправляется один раз
+func startScheduler(ctx context.Context, cfg config.SchedulerConfig, store repository.ScheduledStore, chatService *service.ChatService) {
+	worker := scheduler.NewWorker(store, chatService, scheduler.Config{
+		Interval:    cfg.Interval,
+		BatchSize:   cfg.BatchSize,
+		Lease:       cfg.Lease,
+		MaxAttempts: cfg.MaxAttempts,
+		RetryDelay:  cfg.RetryDelay,
+	})
+	go worker.Run(ctx)
+	slog.Info("Отправка запланированных сообщений запущена",
+		slog.Duration("interval", cfg.Interval),
+		slog.Duration("lease", cfg.Lease),
+	)
+}
+
+// startUnfurler запускает в фоне загрузку превью ссылок (до отмены ctx)
+// Как и отправка запланированных сообщений, работает на каждом инстансе и делит страницы захватом строк
+func startUnfurler(ctx context.Context, cfg config.PreviewsConfig, store repository.LinkPreviewStore, chatService *service.ChatService) {
+	fetcher := unfurl.NewFetcher(unfurl.FetcherConfig{
+		Timeout:      cfg.FetchTimeout,
+		MaxBytes:     int64(cfg.MaxKB) << 10,
+		AllowPrivate: cfg.AllowPrivate,
+	})
+	worker := unfurl.NewWorker(store, fetcher, chatService, unfurl.Config{
+		Interval:    cfg.Interval,
+		BatchSize:   cfg.BatchSize,
+		Lease:       cfg.Lease,
+		MaxAttempts: cfg.MaxAttempts,
+		RetryDelay:  cfg.RetryDelay,
+	})
+	go worker.Run(ctx)
+	if cfg.AllowPrivate {
+		slog.Warn("Превью ссылок загружаются и с внутренних адресов: не используйте allow_private в продакшене")
+	}
+	slog.Info("Загрузка превью ссылок запущена",
+		slog.Duration("interval", cfg.Interval),
+		slog.Duration("fetch_timeout", cfg.FetchTimeout),
+	)
+}
+
+// sweepExpiredMessages периодически удаляет истекшие исчезающие сообщения
+// Подписчики получают message.deleted от того инстанса, который удалил сообщение;
+// на нескольких инстансах каждое сообщение удаляется один раз, поэтому аренда не нужна
+func sweepExpiredMessages(ctx context.Context, chatService *service.ChatService, cfg config.EphemeralConfig) {
+	ticker := time.NewTicker(cfg.SweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// Полная пачка - истекших сообщений, скорее всего, больше: продолжаем без паузы
+		for ctx.Err() == nil {
+			deleted, err := chatService.DeleteExpiredMessages(ctx, cfg.BatchSize)
+			if err != nil {
+				slog.WarnContext(ctx, "не удалось удалить истекшие сообщения", slog.Any("error", err))
+				break
+			}
+			if deleted > 0 {
+				slog.DebugContext(ctx, "удалены истекшие сообщения", slog.Int("count", deleted))
+			}
+			if deleted < cfg.BatchSize {
+				break
+			}
+		}
+	}
+}
+
+// cleanupIdempotencyKeys периодически удаляет истекшие ключи идемпотентности
+// Истекший ключ и так не мешает повторному использованию, очистка только экономит место
+func cleanupIdempotencyKeys(ctx context.Context, store repository.IdempotencyStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			deleted, err := store.DeleteExpired(ctx, now)
+			if err != nil {
+				slog.WarnContext(ctx, "не удалось удалить истекшие ключи идемпотентности", slog.Any("error", err))
+				continue
+			}
+			if deleted > 0 {
+				slog.DebugContext(ctx, "удалены истекшие ключи идемпотентности", slog.Int64("count", deleted))
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Package auth - идентификация пользователя запроса
+// Приложение не проверяет пароли и токены само: пользователя определяет
+// доверенный шлюз (API gateway, oauth2-proxy) и передает его ID в заголовке
+package auth
+
+import (
+	"context"
+	"net/http"
+)
+
+// ctxKey - ключ контекста для ID пользователя
+type ctxKey struct{}
+
+// WithUser сохраняет ID пользователя в контексте
+func WithUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, userID)
+}
+
+// UserID возвращает ID пользователя из контекста
+// Второе значение false - запрос анонимный
+func UserID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(ctxKey{}).(string)
+	return id, ok && id != ""
+}
+
+// Middleware берет ID пользователя из заголовка header
+// Если header пустой, идентификация выключена и все запросы анонимные
+// Заголовок должен выставлять шлюз, иначе клиент сможет представиться кем угодно
+func Middleware(header string, next http.Handler) http.Handler {
+	if header == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := r.Header.Get(header); id != "" && len(id) <= 128 {
+			r = r.WithContext(WithUser(r.Context(), id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Validate проверяет конфигурацию и возвращает все найденные ошибки сразу
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	// Сервер
+	if !isPort(c.Server.Port) {
+		add("server.port: ожидается номер порта 1-65535, получено %q", c.Server.Port)
+	}
+	positive := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.health_timeout", c.Server.HealthTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+		{"db.conn_max_lifetime", c.DB.ConnMaxLifetime},
+		{"db.conn_max_idle_time", c.DB.ConnMaxIdleTime},
+		{"db.connect_timeout", c.DB.ConnectTimeout},
+		{"idempotency.ttl", c.Idempotency.TTL},
+		{"idempotency.cleanup_interval", c.Idempotency.CleanupInterval},
+		{"outbox.webhook_timeout", c.Outbox.WebhookTimeout},
+		{"outbox.interval", c.Outbox.Interval},
+		{"outbox.retention", c.Outbox.Retention},
+		{"retention.interval", c.Retention.Interval},
+		{"ephemeral.sweep_interval", c.Ephemeral.SweepInterval},
+		{"scheduler.interval", c.Scheduler.Interval},
+		{"scheduler.lease", c.Scheduler.Lease},
+		{"scheduler.retry_delay", c.Scheduler.RetryDelay},
+		{"link_previews.interval", c.Previews.Interval},
+		{"link_previews.lease", c.Previews.Lease},
+		{"link_previews.retry_delay", c.Previews.RetryDelay},
+		{"link_previews.cache_ttl", c.Previews.CacheTTL},
+		{"link_previews.fetch_timeout", c.Previews.FetchTimeout},
+	}
+	for _, p := range positive {
+		if p.d <= 0 {
+			add("%s: длительность должна быть больше нуля", p.name)
+		}
+	}
+	if c.Server.ShutdownDelay < 0 {
+		add("server.shutdown_delay: длительность не может быть отрицательной")
+	}
+
+	// База данных
+	switch c.DB.Driver {
+	case "postgres":
+		if c.DB.Host == "" {
+			add("db.host: не может быть пустым")
+		}
+		if !isPort(c.DB.Port) {
+			add("db.port: ожидается номер порта 1-65535, получено %q", c.DB.Port)
+		}
+		if c.DB.User == "" {
+			add("db.user: не может быть пустым")
+		}
+		if c.DB.Name == "" {
+			add("db.name: не может быть пустым")
+		}
+		switch c.DB.SSLMode {
+		case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		default:
+			add("db.ssl_mode: неизвестный режим %q", c.DB.SSLMode)
+		}
+	case "sqlite":
+		if c.DB.SQLitePath == "" {
+			add("db.sqlite_path: не может быть пустым")
+		}
+	default:
+		add("db.driver: ожидается postgres или sqlite, получено %q", c.DB.Driver)
+	}
+	if c.DB.MaxOpenConns <= 0 {
+		add("db.max_open_conns: должно быть больше нуля")
+	}
+	if c.DB.MaxIdleConns < 0 || c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		add("db.max_idle_conns: должно быть от 0 до max_open_conns (%d)", c.DB.MaxOpenConns)
+	}
+
+	// Логирование и трассировка
+	switch c.Log.Format {
+	case "json", "text":
+	default:
+		add("log.format: ожидается json или text, получено %q", c.Log.Format)
+	}
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		add("log.level: ожидается debug, info, warn или error, получено %q", c.Log.Level)
+	}
+	switch c.Tracing.Exporter {
+	case "auto", "otlp", "stdout", "none":
+	default:
+		add("tracing.exporter: ожидается auto, otlp, stdout или none, получено %q", c.Tracing.Exporter)
+	}
+
+	// Лимиты запросов
+	limits := []struct {
+		name  string
+		value int
+	}{
+		{"rate_limit.messages_per_minute", c.RateLimit.MessagesPerMinute},
+		{"rate_limit.messages_burst", c.RateLimit.MessagesBurst},
+		{"rate_limit.chats_per_minute", c.RateLimit.ChatsPerMinute},
+		{"rate_limit.chats_burst", c.RateLimit.ChatsBurst},
+		{"rate_limit.reads_per_minute", c.RateLimit.ReadsPerMinute},
+		{"rate_limit.reads_burst", c.RateLimit.ReadsBurst},
+	}
+	for _, l := range limits {
+		if l.value < 0 {
+			add("%s: не может быть отрицательным", l.name)
+		}
+	}
+
+	// События
+	switch c.Events.Backend {
+	case "auto", "local":
+	case "postgres":
+		if c.DB.Driver != "postgres" {
+			add("events.backend: postgres требует db.driver postgres")
+		}
+	default:
+		add("events.backend: ожидается auto, local или postgres, получено %q", c.Events.Backend)
+	}
+
+	// Outbox
+	switch c.Outbox.Publisher {
+	case "log", "none":
+	case "webhook":
+		if u, err := url.Parse(c.Outbox.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("outbox.webhook_url: ожидается http(s) URL, получено %q", c.Outbox.WebhookURL)
+		}
+	default:
+		add("outbox.publisher: ожидается log, webhook или none, получено %q", c.Outbox.Publisher)
+	}
+	if c.Outbox.BatchSize <= 0 {
+		add("outbox.batch_size: должно быть больше нуля")
+	}
+
+	// Срок хранения сообщений
+	if c.Retention.DefaultDays < 0 {
+		add("retention.default_days: не может быть отрицательным")
+	}
+	if c.Retention.BatchSize <= 0 {
+		add("retention.batch_size: должно быть больше нуля")
+	}
+
+	if c.Ephemeral.BatchSize <= 0 {
+		add("ephemeral.batch_size: должно быть больше нуля")
+	}
+
+	// Запланированные сообщения
+	if c.Scheduler.BatchSize <= 0 {
+		add("scheduler.batch_size: должно быть больше нуля")
+	}
+	if c.Scheduler.MaxAttempts <= 0 {
+		add("scheduler.max_attempts: должно быть больше нуля")
+	}
+
+	// Закрепленные сообщения: список возвращается целиком вместе с GET /chats/{id}
+	if c.Pins.MaxPerChat <= 0 || c.Pins.MaxPerChat > 1000 {
+		add("pins.max_per_chat: должно быть от 1 до 1000")
+	}
+
+	// Превью ссылок: загрузка страницы должна укладываться в аренду захвата
+	if c.Previews.BatchSize <= 0 {
+		add("link_previews.batch_size: должно быть больше нуля")
+	}
+	if c.Previews.MaxAttempts <= 0 {
+		add("link_previews.max_attempts: должно быть больше нуля")
+	}
+	if c.Previews.MaxKB <= 0 || c.Previews.MaxKB > 10240 {
+		add("link_previews.max_kb: должно быть от 1 до 10240")
+	}
+	if c.Previews.FetchTimeout >= c.Previews.Lease {
+		add("link_previews.fetch_timeout: должен быть меньше link_previews.lease")
+	}
+
+	// Служебные эндпоинты
+	if c.Admin.ImportMaxMB <= 0 {
+		add("admin.import_max_mb: должно быть больше нуля")
+	}
+	if c.Admin.Token != "" && len(c.Admin.Token) < 16 {
+		add("admin.token: не короче 16 символов")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("некорректная конфигурация:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// isPort проверяет, что строка - номер TCP порта
+func isPort(s string) bool {
+	n, err := strconv.Atoi(s)
+	return err == nil && n > 0 && n <= 65535
+}
@@ -0,0 +1,194 @@
+// Package migrate применяет встроенные миграции goose к PostgreSQL или SQLite
+// Диалект определяется по подключению GORM, файлы берутся из бинарника (migrations.FS)
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"path/filepath"
+
+	"go-chat-app/migrations"
+
+	"github.com/pressly/goose/v3"
+	"gorm.io/gorm"
+)
+
+// newProvider создает goose.Provider для диалекта подключения
+// Provider не использует глобальное состояние goose, поэтому безопасен
+// для одновременных вызовов (например, из readiness пробы)
+func newProvider(db *gorm.DB) (*goose.Provider, error) {
+	dialect := db.Dialector.Name()
+
+	fsys, err := migrations.FS(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	gooseDialect := goose.DialectPostgres
+	if dialect == "sqlite" {
+		gooseDialect = goose.DialectSQLite3
+	}
+
+	// Получаем низкоуровневое соединение *sql.DB из GORM
+	// goose работает со стандартным sql.DB, а не с GORM
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить sql.DB: %w", err)
+	}
+
+	provider, err := goose.NewProvider(gooseDialect, sqlDB, fsys)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось подготовить миграции: %w", err)
+	}
+	return provider, nil
+}
+
+// Up применяет все непримененные миграции (goose up)
+func Up(ctx context.Context, db *gorm.DB) error {
+	provider, err := newProvider(db)
+	if err != nil {
+		return err
+	}
+
+	// Применяем миграции
+	results, err := provider.Up(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось применить миграции: %w", err)
+	}
+	for _, r := range results {
+		log.Printf("Применена миграция %s (%s)", r.Source.Path, r.Duration)
+	}
+
+	log.Println("Миграции базы данных успешно применены!")
+	return nil
+}
+
+// Down откатывает последнюю примененную миграцию (goose down)
+func Down(ctx context.Context, db *gorm.DB) error {
+	provider, err := newProvider(db)
+	if err != nil {
+		return err
+	}
+	result, err := provider.Down(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось откатить миграцию: %w", err)
+	}
+	log.Printf("Откачена миграция %s", result.Source.Path)
+	return nil
+}
+
+// Redo откатывает и заново применяет последнюю миграцию (goose redo)
+func Redo(ctx context.Context, db *gorm.DB) error {
+	provider, err := newProvider(db)
+	if err != nil {
+		return err
+	}
+	if _, err := provider.Down(ctx); err != nil {
+		return fmt.Errorf("не удалось откатить миграцию: %w", err)
+	}
+	result, err := provider.UpByOne(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось повторно применить миграцию: %w", err)
+	}
+	log.Printf("Повторно применена миграция %s", result.Source.Path)
+	return nil
+}
+
+// Status печатает список миграций и признак их применения (goose status)
+func Status(ctx context.Context, db *gorm.DB, w io.Writer) error {
+	provider, err := newProvider(db)
+	if err != nil {
+		return err
+	}
+	statuses, err := provider.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось получить статус миграций: %w", err)
+	}
+
+	fmt.Fprintf(w, "%-30s %-10s %s\n", "МИГРАЦИЯ", "СТАТУС", "ПРИМЕНЕНА")
+	for _, s := range statuses {
+		appliedAt := "-"
+		if s.State == goose.StateApplied {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%-30s %-10s %s\n", s.Source.Path, s.State, appliedAt)
+	}
+	return nil
+}
+
+// Version возвращает текущую версию схемы БД (goose version)
+func Version(ctx context.Context, db *gorm.DB) (int64, error) {
+	provider, err := newProvider(db)
+	if err != nil {
+		return 0, err
+	}
+	version, err := provider.GetDBVersion(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось получить версию схемы: %w", err)
+	}
+	return version, nil
+}
+
+// ExpectedVersion возвращает номер последней миграции, встроенной в бинарник
+// Именно до этой версии должна быть обновлена схема БД
+func ExpectedVersion(dialect string) (int64, error) {
+	fsys, err := migrations.FS(dialect)
+	if err != nil {
+		return 0, err
+	}
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return 0, fmt.Errorf("не удалось прочитать встроенные миграции: %w", err)
+	}
+
+	// Версия миграции - числовой префикс имени файла (001_create_tables.sql → 1)
+	var last int64
+	for _, name := range files {
+		version, err := goose.NumericComponent(name)
+		if err != nil {
+			return 0, fmt.Errorf("некорректное имя миграции %s: %w", name, err)
+		}
+		if version > last {
+			last = version
+		}
+	}
+	if last == 0 {
+		return 0, fmt.Errorf("встроенные миграции не найдены")
+	}
+	return last, nil
+}
+
+// CheckVersion сверяет текущую версию схемы БД с ожидаемой
+// Возвращает ошибку, если миграции не применены или БД новее бинарника
+func CheckVersion(ctx context.Context, db *gorm.DB) error {
+	expected, err := ExpectedVersion(db.Dialector.Name())
+	if err != nil {
+		return err
+	}
+
+	current, err := Version(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if current != expected {
+		return fmt.Errorf("версия схемы %d, ожидается %d", current, expected)
+	}
+	return nil
+}
+
+// Create создает новые SQL файлы миграции для всех диалектов
+// dir - корневая папка migrations, внутри которой лежат postgres/ и sqlite/
+// Файлы нумеруются последовательно (002_name.sql), номер одинаковый во всех папках
+func Create(dir, name string) error {
+	goose.SetSequential(true)
+	for _, dialect := range []string{"postgres", "sqlite"} {
+		if err := goose.Create(nil, filepath.Join(dir, dialect), name, "sql"); err != nil {
+			return fmt.Errorf("не удалось создать миграцию %s: %w", dialect, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package migrate
+
+import (
+	"io/fs"
+	"testing"
+
+	"go-chat-app/migrations"
+)
+
+// TestDialectsInSync проверяет, что у каждого диалекта одинаковый набор миграций
+// Иначе readiness проба и migrate version будут по-разному вести себя на разных БД
+func TestDialectsInSync(t *testing.T) {
+	names := map[string][]string{}
+	for _, dialect := range []string{"postgres", "sqlite"} {
+		fsys, err := migrations.FS(dialect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, err := fs.Glob(fsys, "*.sql")
+		if err != nil {
+			t.Fatal(err)
+		}
+		names[dialect] = files
+	}
+
+	pg, lite := names["postgres"], names["sqlite"]
+	if len(pg) != len(lite) {
+		t.Fatalf("Разное количество миграций: postgres %v, sqlite %v", pg, lite)
+	}
+	for i := range pg {
+		if pg[i] != lite[i] {
+			t.Errorf("Миграции не совпадают: postgres %s, sqlite %s", pg[i], lite[i])
+		}
+	}
+
+	pgVersion, err := ExpectedVersion("postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	liteVersion, err := ExpectedVersion("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pgVersion != liteVersion {
+		t.Errorf("Разные ожидаемые версии: postgres %d, sqlite %d", pgVersion, liteVersion)
+	}
+}
@@ -1,12 +1,15 @@
 package postgres
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"os"
+	"strings"
 	"time"
 
-	"github.com/joho/godotenv"
+	"go-chat-app/internal/config"
+	"go-chat-app/internal/tracing"
+
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -17,28 +20,8 @@ var DB *gorm.DB
 
 // InitDB инициализирует подключение к базе данных PostgreSQL
 // Возвращает *gorm.DB для работы с БД и error в случае неудачи
-func InitDB() (*gorm.DB, error) {
-	// Загружаем переменные окружения из .env файла
-	// Если файла нет - игнорируем ошибку (переменные могут быть уже установлены)
-	_ = godotenv.Load()
-
-	// Формируем строку подключения (DSN - Data Source Name) к PostgreSQL
-	// Используем переменные окружения для конфигурации:
-	// DB_HOST - хост БД (например: localhost или postgres для Docker)
-	// DB_USER - имя пользователя БД
-	// DB_PASSWORD - пароль пользователя
-	// DB_NAME - имя базы данных
-	// DB_PORT - порт PostgreSQL (по умолчанию 5432)
-	// DB_SSL_MODE - режим SSL (disable для разработки, require для продакшена)
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("DB_SSL_MODE"),
-	)
+func InitDB(cfg config.DBConfig) (*gorm.DB, error) {
+	dsn := DSN(cfg)
 
 	// Подключаемся к базе данных
 	var err error
@@ -50,27 +33,40 @@ func InitDB() (*gorm.DB, error) {
 		return nil, fmt.Errorf("не удалось подключиться к базе данных: %w", err)
 	}
 
+	// Подключаем плагин трассировки: каждый SQL запрос получит свой спан
+	if err := DB.Use(tracing.GormPlugin{}); err != nil {
+		return nil, fmt.Errorf("не удалось подключить трассировку к GORM: %w", err)
+	}
+
 	// Получаем низкоуровневое соединение *sql.DB из GORM
 	// Это нужно для настройки пула соединений
-	sqlDB, _ := DB.DB()
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить sql.DB: %w", err)
+	}
 
-	// Настраиваем пул соединений для оптимальной производительности:
+	// Настраиваем пул соединений (значения из конфигурации):
 
 	// SetMaxIdleConns - максимальное количество неактивных (idle) соединений в пуле
-	// 20 соединений будет поддерживаться в готовности для быстрого использования
-	sqlDB.SetMaxIdleConns(20)
+	// Они поддерживаются в готовности для быстрого использования
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
 
 	// SetMaxOpenConns - максимальное количество открытых соединений одновременно
-	// Ограничивает нагрузку на БД, не позволяет открыть больше 100 соединений
-	sqlDB.SetMaxOpenConns(100)
+	// Ограничивает нагрузку на БД
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
 
 	// SetConnMaxLifetime - максимальное время жизни соединения
-	// Через 1 час соединение будет закрыто и создано новое
+	// По истечении соединение будет закрыто и создано новое
 	// Это помогает избежать проблем с устаревшими соединениями
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	// SetConnMaxIdleTime - сколько соединение может простаивать до закрытия
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
-	// Проверяем соединение с БД
-	err = sqlDB.Ping()
+	// Проверяем соединение с БД (не дольше ConnectTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
+	defer cancel()
+	err = sqlDB.PingContext(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось проверить связь с базой данных: %w", err)
 	}
@@ -79,6 +75,43 @@ func InitDB() (*gorm.DB, error) {
 	return DB, nil
 }
 
+// DSN формирует строку подключения (DSN - Data Source Name) к PostgreSQL
+// Все параметры берутся из единой конфигурации (см. config.Load):
+// Host - хост БД (например: localhost или postgres для Docker)
+// User - имя пользователя БД
+// Password - пароль пользователя
+// Name - имя базы данных
+// Port - порт PostgreSQL (по умолчанию 5432)
+// SSLMode - режим SSL (disable для разработки, require для продакшена)
+// ConnectTimeout - таймаут установки каждого соединения (connect_timeout, в целых секундах с
+// округлением вверх), а не только первой проверки связи: без него новое соединение пула к
+// недоступной БД ждет таймаута TCP
+// Значения берутся в кавычки (см. dsnValue): пароль с пробелом или кавычкой не ломает строку
+func DSN(cfg config.DBConfig) string {
+	dsn := fmt.Sprintf(
+		"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
+		dsnValue(cfg.Host),
+		dsnValue(cfg.User),
+		dsnValue(cfg.Password),
+		dsnValue(cfg.Name),
+		dsnValue(cfg.Port),
+		dsnValue(cfg.SSLMode),
+	)
+	if cfg.ConnectTimeout > 0 {
+		seconds := int64((cfg.ConnectTimeout + time.Second - 1) / time.Second)
+		dsn += fmt.Sprintf(" connect_timeout=%d", seconds)
+	}
+	return dsn
+}
+
+// dsnValue записывает значение DSN по правилам libpq: в одинарных кавычках,
+// а обратный слэш и одинарная кавычка внутри экранируются обратным слэшем
+func dsnValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
 // GetDB возвращает глобальное подключение к базе данных
 // Используется в других частях приложения для получения DB
 func GetDB() *gorm.DB {
@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"go-chat-app/internal/config"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// TestDSNEscaping проверяет, что спецсимволы в значениях не ломают DSN
+func TestDSNEscaping(t *testing.T) {
+	cfg := config.Default().DB
+	cfg.User = "chat user"
+	cfg.Password = `p@ss 'word'\ sslmode=disable`
+	cfg.Name = "chat"
+
+	parsed, err := pgconn.ParseConfig(DSN(cfg))
+	if err != nil {
+		t.Fatalf("DSN не разбирается: %v", err)
+	}
+	if parsed.User != cfg.User || parsed.Password != cfg.Password || parsed.Database != cfg.Name {
+		t.Errorf("Значения искажены: user=%q password=%q dbname=%q", parsed.User, parsed.Password, parsed.Database)
+	}
+}
+
+// TestDSNConnectTimeout проверяет, что таймаут подключения попадает в DSN в целых секундах
+func TestDSNConnectTimeout(t *testing.T) {
+	tests := []struct {
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{5 * time.Second, 5 * time.Second},
+		{1500 * time.Millisecond, 2 * time.Second},
+		{0, 0},
+	}
+	for _, tt := range tests {
+		cfg := config.Default().DB
+		cfg.ConnectTimeout = tt.timeout
+
+		parsed, err := pgconn.ParseConfig(DSN(cfg))
+		if err != nil {
+			t.Fatalf("DSN не разбирается: %v", err)
+		}
+		if parsed.ConnectTimeout != tt.want {
+			t.Errorf("ConnectTimeout=%v: в DSN %v, ожидалось %v", tt.timeout, parsed.ConnectTimeout, tt.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go-chat-app/internal/db/service"
+	"go-chat-app/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	// notifyChannel - канал LISTEN/NOTIFY для событий чатов
+	notifyChannel = "chat_events"
+	// maxNotifyPayload - предел размера уведомления с запасом (в PostgreSQL - 8000 байт)
+	// Сообщение, которое не помещается, передается только по ID и читается из БД получателем
+	maxNotifyPayload = 7000
+	// maxReconnectDelay - максимальная пауза между попытками переподключения LISTEN
+	maxReconnectDelay = 10 * time.Second
+)
+
+// PubSub доставляет события чатов через LISTEN/NOTIFY, чтобы подписчики
+// на любом инстансе узнавали о сообщениях, отправленных через другие инстансы
+//
+// Publish только отправляет NOTIFY; локальные подписчики (в том числе на этом же
+// инстансе) получают событие из общего LISTEN соединения, поэтому порядок событий
+// одинаков на всех инстансах
+type PubSub struct {
+	db    *gorm.DB
+	dsn   string
+	local *service.LocalPubSub
+}
+
+// notification - событие в канале NOTIFY
+// Если сообщение не помещается в уведомление, передается только его ID (FetchMessageID),
+// а получатель читает сообщение из БД. Имя поля отличается от Event.MessageID
+// (ID удаленного сообщения в message.deleted), иначе одно скрыло бы другое в JSON
+type notification struct {
+	service.Event
+	FetchMessageID uint `json:"fetch_message_id,omitempty"`
+}
+
+// NewPubSub создает PubSub поверх PostgreSQL
+// db используется для NOTIFY и чтения крупных сообщений, dsn - для отдельного LISTEN соединения
+// Уведомления принимаются только после запуска Listen
+func NewPubSub(db *gorm.DB, dsn string) *PubSub {
+	return &PubSub{db: db, dsn: dsn, local: service.NewLocalPubSub()}
+}
+
+// Publish отправляет событие всем инстансам через pg_notify
+func (p *PubSub) Publish(ctx context.Context, event service.Event) error {
+	payload, err := json.Marshal(notification{Event: event})
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxNotifyPayload && event.Message != nil {
+		messageID := event.Message.ID
+		event.Message = nil
+		payload, err = json.Marshal(notification{Event: event, FetchMessageID: messageID})
+		if err != nil {
+			return err
+		}
+	}
+	if len(payload) > maxNotifyPayload && event.Preview != nil {
+		// Превью с длинными адресами и описанием передается без описания:
+		// целиком клиент прочитает его вместе с сообщением через GET /chats/{id}
+		preview := *event.Preview
+		preview.Description = ""
+		event.Preview = &preview
+		payload, err = json.Marshal(notification{Event: event})
+		if err != nil {
+			return err
+		}
+	}
+	if len(payload) > maxNotifyPayload && event.Poll != nil {
+		// Итоги открытого опроса с множеством проголосовавших передаются без списков голосовавших
+		poll := *event.Poll
+		poll.Options = make([]models.PollOption, len(event.Poll.Options))
+		for i, option := range event.Poll.Options {
+			poll.Options[i] = models.PollOption{Text: option.Text, Votes: option.Votes}
+		}
+		event.Poll = &poll
+		payload, err = json.Marshal(notification{Event: event})
+		if err != nil {
+			return err
+		}
+	}
+	return p.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", notifyChannel, string(payload)).Error
+}
+
+// Subscribe подписывается на события чата (см. service.PubSub)
+func (p *PubSub) Subscribe(ctx context.Context, chatID uint) (<-chan service.Event, func()) {
+	return p.local.Subscribe(ctx, chatID)
+}
+
+// Close закрывает все локальные подписки
+func (p *PubSub) Close() {
+	p.local.Close()
+}
+
+// Listen держит LISTEN соединение и раздает уведомления локальным подписчикам
+// Работает до отмены ctx; при обрыве соединения переподключается
+// События, отправленные во время обрыва, теряются - клиенты дочитывают их через GET /chats/{id}
+func (p *PubSub) Listen(ctx context.Context) {
+	delay := 100 * time.Millisecond
+	for ctx.Err() == nil {
+		started := time.Now()
+		err := p.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		// Соединение проработало долго - начинаем паузы заново
+		if time.Since(started) > maxReconnectDelay {
+			delay = 100 * time.Millisecond
+		}
+		slog.WarnContext(ctx, "LISTEN соединение прервано, переподключение",
+			slog.Any("error", err),
+			slog.Duration("retry_in", delay),
+		)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// listen выполняет LISTEN на одном соединении до первой ошибки
+func (p *PubSub) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, p.dsn)
+	if err != nil {
+		return fmt.Errorf("не удалось подключиться для LISTEN: %w", err)
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		return fmt.Errorf("не удалось выполнить LISTEN: %w", err)
+	}
+	slog.DebugContext(ctx, "LISTEN запущен", slog.String("channel", notifyChannel))
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		p.dispatch(ctx, n.Payload)
+	}
+}
+
+// dispatch разбирает уведомление и передает событие локальным подписчикам
+func (p *PubSub) dispatch(ctx context.Context, payload string) {
+	var n notification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		slog.WarnContext(ctx, "некорректное уведомление о событии", slog.Any("error", err))
+		return
+	}
+	if p.local.Subscribers(n.ChatID) == 0 {
+		return // на этом инстансе никто не подписан на чат
+	}
+
+	event := n.Event
+	if n.FetchMessageID != 0 && event.Message == nil {
+		var message models.Message
+		if err := p.db.WithContext(ctx).First(&message, n.FetchMessageID).Error; err != nil {
+			slog.WarnContext(ctx, "не удалось прочитать сообщение из уведомления",
+				slog.Uint64("message_id", uint64(n.FetchMessageID)),
+				slog.Any("error", err),
+			)
+			return
+		}
+		event.Message = &message
+	}
+	p.local.Publish(ctx, event)
+}
+
+// Проверка на этапе компиляции, что PubSub реализует service.PubSub
+var _ service.PubSub = (*PubSub)(nil)
@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go-chat-app/internal/db/service"
+	"go-chat-app/internal/models"
+
+	gormpostgres "gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestPubSubAcrossInstances проверяет, что событие, опубликованное одним инстансом,
+// доходит до подписчика другого (два PubSub - два независимых LISTEN соединения)
+// Запуск: TEST_DATABASE_DSN="host=localhost user=chat_user password=chat_password dbname=chat_test sslmode=disable" go test ./internal/db/postgres/
+func TestPubSubAcrossInstances(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN не задан, тесты на PostgreSQL пропущены")
+	}
+	db, err := gorm.Open(gormpostgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Не удалось подключиться к тестовой БД: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	a, b := NewPubSub(db, dsn), NewPubSub(db, dsn)
+	go a.Listen(ctx)
+	go b.Listen(ctx)
+
+	events, unsubscribe := b.Subscribe(ctx, 42)
+	defer unsubscribe()
+
+	// LISTEN запускается асинхронно: публикуем, пока событие не дойдет
+	small := service.Event{Type: service.EventMessageCreated, ChatID: 42, Message: &models.Message{ID: 1, ChatID: 42, Text: "привет"}}
+	got := waitEvent(ctx, t, events, func() { a.Publish(ctx, small) })
+	if got.Message == nil || got.Message.Text != "привет" {
+		t.Errorf("Получено неверное событие: %+v", got)
+	}
+
+	// Крупное сообщение передается по ID и читается из БД
+	chat := &models.Chat{Title: "pubsub"}
+	db.Create(chat)
+	defer db.Unscoped().Delete(chat)
+	big := &models.Message{ChatID: chat.ID, Text: strings.Repeat("я", 5000)}
+	db.Create(big)
+
+	events2, unsubscribe2 := b.Subscribe(ctx, chat.ID)
+	defer unsubscribe2()
+	a.Publish(ctx, service.Event{Type: service.EventMessageCreated, ChatID: chat.ID, Message: big})
+	got = waitEvent(ctx, t, events2, nil)
+	if got.Message == nil || got.Message.ID != big.ID || got.Message.Text != big.Text {
+		t.Errorf("Крупное сообщение не восстановлено из БД: %+v", got.Message)
+	}
+}
+
+// waitEvent ждет событие, повторяя publish (если задан) раз в 100 мс
+func waitEvent(ctx context.Context, t *testing.T, events <-chan service.Event, publish func()) service.Event {
+	t.Helper()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	if publish != nil {
+		publish()
+	}
+	for {
+		select {
+		case event := <-events:
+			return event
+		case <-ticker.C:
+			if publish != nil {
+				publish()
+			}
+		case <-ctx.Done():
+			t.Fatal("Событие не получено")
+		}
+	}
+}
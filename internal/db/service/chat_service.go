@@ -1,29 +1,95 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log/slog"
 	"strings"
+	"time"
 
+	"go-chat-app/internal/auth"
+	"go-chat-app/internal/markdown"
 	"go-chat-app/internal/models"
+	"go-chat-app/internal/ratelimit"
 	"go-chat-app/internal/repository"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrChatNotFound - чат не существует или удален
+var ErrChatNotFound = errors.New("чат не найден")
+
+// ErrUnknownFormat - неизвестный формат текста сообщения (WithFormat)
+var ErrUnknownFormat = errors.New("format должен быть plain или markdown")
+
+// exportBatchSize - сколько сообщений читать из хранилища за раз при выгрузке истории
+const exportBatchSize = 500
+
+// maxSlowModeSeconds - максимальный интервал медленного режима (6 часов)
+const maxSlowModeSeconds = 6 * 60 * 60
+
+// maxMessageTTL - максимальное время жизни исчезающего сообщения (30 дней)
+const maxMessageTTL = 30 * 24 * time.Hour
+
+// maxRetentionDays - максимальный срок хранения сообщений чата (10 лет)
+// Хранить дольше - значит хранить всегда, для этого есть retention_days = 0
+const maxRetentionDays = 3650
+
+// RateLimitError - сообщение отклонено медленным режимом чата
+type RateLimitError struct {
+	RetryAfter time.Duration // через сколько можно отправить следующее сообщение
+}
+
+// Error реализует интерфейс error
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("в чате включен медленный режим, повторите через %s", e.RetryAfter.Round(time.Second))
+}
+
+// tracer создает спаны слоя бизнес-логики
+var tracer = otel.Tracer("go-chat-app/internal/db/service")
+
 // ChatService содержит бизнес-логику работы с чатами
 type ChatService struct {
-	chatRepo    *repository.ChatRepository
-	messageRepo *repository.MessageRepository
+	chatRepo    repository.ChatStore
+	messageRepo repository.MessageStore
+	limits      ratelimit.Store // лимиты медленного режима
+	events      PubSub          // события для потоковых подписчиков
+
+	scheduledRepo repository.ScheduledStore   // запланированные сообщения (nil - выключены)
+	pinRepo       repository.PinStore         // закрепленные сообщения (nil - выключены)
+	maxPins       int                         // предел закрепленных сообщений в чате
+	mentionRepo   repository.MentionStore     // уведомления об упоминаниях (nil - не создаются)
+	previewRepo   repository.LinkPreviewStore // превью ссылок (nil - выключены)
+	previewTTL    time.Duration               // сколько превью считается свежим
+	pollRepo      repository.PollStore        // голоса в опросах (nil - опросы выключены)
 }
 
 // NewChatService создает новый сервис для работы с чатами
-func NewChatService(chatRepo *repository.ChatRepository, messageRepo *repository.MessageRepository) *ChatService {
-	return &ChatService{
+// Хранилища передаются интерфейсами: в приложении это GORM репозитории,
+// в тестах - реализация в памяти (repository/memory)
+// Необязательные зависимости задаются опциями (см. options.go)
+func NewChatService(chatRepo repository.ChatStore, messageRepo repository.MessageStore, opts ...Option) *ChatService {
+	s := &ChatService{
 		chatRepo:    chatRepo,
 		messageRepo: messageRepo,
+		limits:      ratelimit.NewMemoryStore(),
+		events:      NewLocalPubSub(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // CreateChat создает новый чат
-func (s *ChatService) CreateChat(title string) (*models.Chat, error) {
+func (s *ChatService) CreateChat(ctx context.Context, title string) (*models.Chat, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.CreateChat")
+	defer span.End()
+
 	// -------------------------------------------------
 	// 1. Триммируем пробелы по краям (как рекомендуется в ТЗ)
 	trimmedTitle := strings.TrimSpace(title)
@@ -43,21 +109,33 @@ func (s *ChatService) CreateChat(title string) (*models.Chat, error) {
 	}
 
 	// 4. Сохраняем в базу
-	err := s.chatRepo.Create(chat)
+	err := s.chatRepo.Create(ctx, chat)
 	if err != nil {
-		return nil, err
+		return nil, recordError(span, err)
 	}
+	slog.InfoContext(ctx, "чат создан", slog.Uint64("chat_id", uint64(chat.ID)))
 
 	return chat, nil
 }
 
 // SendMessage отправляет сообщение в чат
-func (s *ChatService) SendMessage(chatID uint, text string) (*models.Message, error) {
+// Опции WithTTL и WithExpiresAt делают сообщение исчезающим
+// Опция WithFormat(models.MessageFormatMarkdown) включает разметку: HTML и элементы
+// разметки строятся один раз при отправке и хранятся вместе с исходным текстом
+// Упоминания @user и @all сохраняются в message.Mentions, а упомянутые пользователи
+// получают уведомления (GET /me/mentions), если настроено хранилище упоминаний
+// Ссылки из текста ставятся в очередь загрузки превью (WithLinkPreviews): в ответе они
+// уже есть в message.Previews, а о загрузке подписчики узнают событием message.preview
+// Опция WithPoll делает сообщение опросом (Kind = models.MessageKindPoll), текст - вопрос
+func (s *ChatService) SendMessage(ctx context.Context, chatID uint, text string, opts ...MessageOption) (*models.Message, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.SendMessage", trace.WithAttributes(attribute.Int("chat.id", int(chatID))))
+	defer span.End()
+
 	// 1. Проверяем что чат существует
-	_, err := s.chatRepo.GetByID(chatID)
+	chat, err := s.chatRepo.GetByID(ctx, chatID)
 	if err != nil {
 		// Если чат не найден - возвращаем ошибку
-		return nil, errors.New("чат не найден")
+		return nil, chatLookupError(span, err)
 	}
 	// ---------------------------------
 	// 2. Триммируем пробелы по краям
@@ -72,27 +150,215 @@ func (s *ChatService) SendMessage(chatID uint, text string) (*models.Message, er
 		return nil, errors.New("объем текста должен быть не более 5000 символов")
 	}
 
-	// 4. Создаем объект сообщения
+	// 4. Время исчезновения (не раньше текущего момента и не позже чем через 30 дней), формат текста и опрос
+	var o messageOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	now := time.Now()
+	expiresAt, err := messageExpiry(o, now)
+	if err != nil {
+		return nil, err
+	}
+	var poll *models.Poll
+	if o.poll != nil {
+		if s.pollRepo == nil {
+			return nil, ErrPollsDisabled
+		}
+		if poll, err = newPoll(*o.poll, now); err != nil {
+			return nil, err
+		}
+	}
+	switch o.format {
+	case "":
+		o.format = models.MessageFormatPlain
+	case models.MessageFormatPlain, models.MessageFormatMarkdown:
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	// 5. Медленный режим: не чаще одного сообщения в N секунд от одного клиента
+	if err := s.checkSlowMode(ctx, chat); err != nil {
+		return nil, err
+	}
+
+	// 6. Создаем объект сообщения
+	// Автор - пользователь запроса, если шлюз его передал
+	authorID, _ := auth.UserID(ctx)
 	message := &models.Message{
-		ChatID: chatID,
-		Text:   trimmedText,
+		ChatID:    chatID,
+		Text:      trimmedText,
+		AuthorID:  authorID,
+		ExpiresAt: expiresAt,
+		Scheduled: o.scheduled,
+		Format:    o.format,
+		Mentions:  parseMentions(trimmedText),
+		Poll:      poll,
+		// Пересланное сообщение (ForwardMessage) ссылается на оригинал
+		ForwardedFrom: o.forward,
+	}
+	if poll != nil {
+		message.Kind = models.MessageKindPoll
+	}
+	if message.Format == models.MessageFormatMarkdown {
+		message.HTML, message.Entities = markdown.Render(trimmedText)
+	}
+	// Пересланное сообщение не уведомляет упомянутых повторно: их уже уведомил оригинал
+	if s.mentionRepo != nil && o.forward == nil {
+		if message.Notify, err = s.mentionRecipients(ctx, message); err != nil {
+			return nil, recordError(span, err)
+		}
 	}
 
-	// 5. Сохраняем в базу
-	err = s.messageRepo.Create(message)
+	// 7. Сохраняем в базу (вместе с уведомлениями об упоминаниях)
+	err = s.messageRepo.Create(ctx, message)
 	if err != nil {
-		return nil, err
+		return nil, recordError(span, err)
 	}
+	slog.DebugContext(ctx, "сообщение отправлено",
+		slog.Uint64("chat_id", uint64(chatID)),
+		slog.Uint64("message_id", uint64(message.ID)),
+	)
+
+	// 8. Ставим ссылки в очередь превью
+	s.queuePreviews(ctx, message)
+
+	// 9. Сообщаем подписчикам чата (на всех инстансах)
+	s.publish(ctx, Event{Type: EventMessageCreated, ChatID: chatID, Message: message})
 
 	return message, nil
 }
 
+// messageExpiry вычисляет время исчезновения сообщения из опций (nil - обычное сообщение)
+func messageExpiry(o messageOptions, now time.Time) (*time.Time, error) {
+	var at time.Time
+	switch {
+	case o.ttl != nil && o.expiresAt != nil:
+		return nil, errors.New("укажите ttl_seconds или expires_at, не более одного из них")
+	case o.ttl != nil:
+		if *o.ttl < time.Second || *o.ttl > maxMessageTTL {
+			return nil, fmt.Errorf("ttl_seconds должен быть от 1 и не более %d", int(maxMessageTTL.Seconds()))
+		}
+		at = now.Add(*o.ttl)
+	case o.expiresAt != nil:
+		if !o.expiresAt.After(now) || o.expiresAt.Sub(now) > maxMessageTTL {
+			return nil, fmt.Errorf("expires_at должен быть в будущем и не более чем через %d дней", int(maxMessageTTL.Hours()/24))
+		}
+		at = *o.expiresAt
+	default:
+		return nil, nil
+	}
+	// В местном времени, как GORM записывает created_at: колонки сравниваются между собой
+	// и с текущим временем без часового пояса
+	at = at.Local()
+	return &at, nil
+}
+
+// DeleteExpiredMessages удаляет не больше limit истекших исчезающих сообщений
+// и сообщает о каждом подписчикам чата (message.deleted)
+// Возвращает количество удаленных; меньше limit - истекших сообщений больше нет
+func (s *ChatService) DeleteExpiredMessages(ctx context.Context, limit int) (int, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.DeleteExpiredMessages")
+	defer span.End()
+
+	deleted, err := s.messageRepo.DeleteExpired(ctx, time.Now(), limit)
+	if err != nil {
+		return 0, recordError(span, err)
+	}
+	for _, m := range deleted {
+		s.publish(ctx, Event{Type: EventMessageDeleted, ChatID: m.ChatID, MessageID: m.ID})
+	}
+	span.SetAttributes(attribute.Int("messages.deleted", len(deleted)))
+	return len(deleted), nil
+}
+
+// ChatSettings - изменяемые настройки чата (PATCH /chats/{id})
+// nil означает "не менять"
+type ChatSettings struct {
+	SlowModeSeconds *int // медленный режим, 0 - выключить
+	RetentionDays   *int // срок хранения сообщений в днях, 0 - хранить всегда
+	// DefaultRetention возвращает срок хранения по умолчанию сервера (RetentionDays при этом не задается)
+	DefaultRetention bool
+}
+
+// SetSlowMode включает медленный режим чата (seconds = 0 - выключает)
+func (s *ChatService) SetSlowMode(ctx context.Context, chatID uint, seconds int) (*models.Chat, error) {
+	return s.UpdateSettings(ctx, chatID, ChatSettings{SlowModeSeconds: &seconds})
+}
+
+// UpdateSettings изменяет настройки чата одним обновлением
+func (s *ChatService) UpdateSettings(ctx context.Context, chatID uint, settings ChatSettings) (*models.Chat, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.UpdateSettings", trace.WithAttributes(attribute.Int("chat.id", int(chatID))))
+	defer span.End()
+
+	// 1. Проверяем значения: медленный режим от 0 до 6 часов, срок хранения от 0 до 10 лет
+	if seconds := settings.SlowModeSeconds; seconds != nil && (*seconds < 0 || *seconds > maxSlowModeSeconds) {
+		return nil, fmt.Errorf("slow_mode_seconds должен быть от 0 и не более %d", maxSlowModeSeconds)
+	}
+	if days := settings.RetentionDays; days != nil && (*days < 0 || *days > maxRetentionDays) {
+		return nil, fmt.Errorf("retention_days должен быть от 0 и не более %d", maxRetentionDays)
+	}
+	if settings.DefaultRetention && settings.RetentionDays != nil {
+		return nil, errors.New("retention_days: задан и срок, и возврат к сроку по умолчанию")
+	}
+
+	// 2. Получаем чат
+	chat, err := s.chatRepo.GetByID(ctx, chatID)
+	if err != nil {
+		return nil, chatLookupError(span, err)
+	}
+
+	// 3. Сохраняем настройки
+	if settings.SlowModeSeconds != nil {
+		chat.SlowModeSeconds = *settings.SlowModeSeconds
+	}
+	if settings.RetentionDays != nil || settings.DefaultRetention {
+		chat.RetentionDays = settings.RetentionDays
+	}
+	if err := s.chatRepo.Update(ctx, chat); err != nil {
+		return nil, chatLookupError(span, err)
+	}
+
+	attrs := []any{slog.Uint64("chat_id", uint64(chatID)), slog.Int("slow_mode_seconds", chat.SlowModeSeconds)}
+	if chat.RetentionDays != nil {
+		attrs = append(attrs, slog.Int("retention_days", *chat.RetentionDays))
+	} else {
+		attrs = append(attrs, slog.String("retention_days", "по умолчанию"))
+	}
+	slog.InfoContext(ctx, "настройки чата изменены", attrs...)
+	return chat, nil
+}
+
+// checkSlowMode проверяет медленный режим чата для текущего клиента
+// Клиент (пользователь или IP) берется из контекста, его кладет middleware лимитов
+func (s *ChatService) checkSlowMode(ctx context.Context, chat *models.Chat) error {
+	client := ratelimit.Client(ctx)
+	if chat.SlowModeSeconds <= 0 || client == "" {
+		return nil
+	}
+
+	limit := ratelimit.Limit{Requests: 1, Period: time.Duration(chat.SlowModeSeconds) * time.Second, Burst: 1}
+	res, err := s.limits.Take(ctx, fmt.Sprintf("slowmode:%d:%s", chat.ID, client), limit)
+	if err != nil {
+		// Недоступное хранилище лимитов не должно блокировать переписку
+		slog.WarnContext(ctx, "не удалось проверить медленный режим", slog.Any("error", err))
+		return nil
+	}
+	if !res.Allowed {
+		return &RateLimitError{RetryAfter: res.RetryAfter}
+	}
+	return nil
+}
+
 // GetChatWithMessages возвращает чат и последние сообщения
-func (s *ChatService) GetChatWithMessages(chatID uint, limit int) (*models.Chat, []models.Message, error) {
+func (s *ChatService) GetChatWithMessages(ctx context.Context, chatID uint, limit int) (*models.Chat, []models.Message, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.GetChatWithMessages", trace.WithAttributes(attribute.Int("chat.id", int(chatID))))
+	defer span.End()
+
 	// 1. Получаем чат
-	chat, err := s.chatRepo.GetByID(chatID)
+	chat, err := s.chatRepo.GetByID(ctx, chatID)
 	if err != nil {
-		return nil, nil, errors.New("чат не найден")
+		return nil, nil, chatLookupError(span, err)
 	}
 
 	// 2. Ограничиваем limit максимум 100, как в ТЗ
@@ -103,24 +369,158 @@ func (s *ChatService) GetChatWithMessages(chatID uint, limit int) (*models.Chat,
 		limit = 20 // значение по умолчанию из ТЗ
 	}
 
-	// 3. Получаем последние сообщения
-	messages, err := s.messageRepo.GetLastMessagesByChatID(chatID, limit)
+	// 3. Получаем последние сообщения вместе с превью ссылок и итогами опросов
+	messages, err := s.messageRepo.GetLastMessagesByChatID(ctx, chatID, limit)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, recordError(span, err)
+	}
+	refs := make([]*models.Message, len(messages))
+	for i := range messages {
+		refs[i] = &messages[i]
+	}
+	if err := s.decorate(ctx, refs); err != nil {
+		return nil, nil, recordError(span, err)
 	}
 
 	return chat, messages, nil
 }
 
+// decorate добавляет к прочитанным сообщениям то, что хранится отдельно от них:
+// превью ссылок, итоги опросов (с выбором пользователя запроса) и удаленные оригиналы пересланных
+func (s *ChatService) decorate(ctx context.Context, messages []*models.Message) error {
+	if err := s.attachPreviews(ctx, messages); err != nil {
+		return err
+	}
+	if err := s.attachPolls(ctx, messages); err != nil {
+		return err
+	}
+	return s.attachForwards(ctx, messages)
+}
+
+// GetChat возвращает чат без сообщений
+func (s *ChatService) GetChat(ctx context.Context, chatID uint) (*models.Chat, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.GetChat", trace.WithAttributes(attribute.Int("chat.id", int(chatID))))
+	defer span.End()
+
+	chat, err := s.chatRepo.GetByID(ctx, chatID)
+	if err != nil {
+		return nil, chatLookupError(span, err)
+	}
+	return chat, nil
+}
+
+// ExportMessages передает fn всю историю чата в диапазоне [from, to) в хронологическом порядке
+// Сообщения читаются пачками по exportBatchSize, в памяти держится только текущая пачка
+// Нулевые from и to - без ограничения; ошибка fn прерывает выгрузку
+func (s *ChatService) ExportMessages(ctx context.Context, chatID uint, from, to time.Time, fn func([]models.Message) error) error {
+	ctx, span := tracer.Start(ctx, "ChatService.ExportMessages", trace.WithAttributes(attribute.Int("chat.id", int(chatID))))
+	defer span.End()
+
+	rng := repository.MessageRange{From: from, To: to}
+	total := 0
+	for {
+		batch, err := s.messageRepo.ListRange(ctx, chatID, rng, exportBatchSize)
+		if err != nil {
+			return recordError(span, err)
+		}
+		if len(batch) > 0 {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			total += len(batch)
+		}
+		if len(batch) < exportBatchSize {
+			break
+		}
+		rng = rng.After(batch[len(batch)-1])
+	}
+	span.SetAttributes(attribute.Int("export.messages", total))
+	return nil
+}
+
+// ListChats возвращает страницу чатов в порядке ID, начиная после afterID
+func (s *ChatService) ListChats(ctx context.Context, afterID uint, limit int) ([]models.Chat, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.ListChats")
+	defer span.End()
+
+	// Ограничения как у сообщений: по умолчанию 50, максимум 100
+	if limit > 100 {
+		limit = 100
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	chats, err := s.chatRepo.List(ctx, afterID, limit)
+	if err != nil {
+		return nil, recordError(span, err)
+	}
+	return chats, nil
+}
+
 // DeleteChat удаляет чат (сообщения удалятся каскадно через GORM)
-func (s *ChatService) DeleteChat(chatID uint) error {
+func (s *ChatService) DeleteChat(ctx context.Context, chatID uint) error {
+	ctx, span := tracer.Start(ctx, "ChatService.DeleteChat", trace.WithAttributes(attribute.Int("chat.id", int(chatID))))
+	defer span.End()
+
 	// 1. Проверяем что чат существует
-	_, err := s.chatRepo.GetByID(chatID)
+	_, err := s.chatRepo.GetByID(ctx, chatID)
 	if err != nil {
-		return errors.New("чат не найден")
+		return chatLookupError(span, err)
 	}
 
 	// 2. Удаляем чат
 	// Сообщения удалятся автоматически благодаря constraint:OnDelete:CASCADE в модели Chat
-	return s.chatRepo.Delete(chatID)
+	if err := s.chatRepo.Delete(ctx, chatID); err != nil {
+		return recordError(span, err)
+	}
+	slog.InfoContext(ctx, "чат удален", slog.Uint64("chat_id", uint64(chatID)))
+
+	// 3. Сообщаем подписчикам, их подписки на этот чат завершатся
+	s.publish(ctx, Event{Type: EventChatDeleted, ChatID: chatID})
+	return nil
+}
+
+// Subscribe подписывает на события чата (новые сообщения, удаление)
+// Возвращает ErrChatNotFound, если чата нет; cancel нужно вызвать по окончании чтения
+func (s *ChatService) Subscribe(ctx context.Context, chatID uint) (<-chan Event, func(), error) {
+	ctx, span := tracer.Start(ctx, "ChatService.Subscribe", trace.WithAttributes(attribute.Int("chat.id", int(chatID))))
+	defer span.End()
+
+	if _, err := s.chatRepo.GetByID(ctx, chatID); err != nil {
+		return nil, nil, chatLookupError(span, err)
+	}
+	events, cancel := s.events.Subscribe(ctx, chatID)
+	return events, cancel, nil
+}
+
+// publish отправляет событие подписчикам
+// Ошибка доставки не отменяет уже сохраненное действие - только пишется в лог
+func (s *ChatService) publish(ctx context.Context, event Event) {
+	event.OccurredAt = time.Now().UTC()
+	if err := s.events.Publish(ctx, event); err != nil {
+		slog.WarnContext(ctx, "не удалось опубликовать событие",
+			slog.String("type", string(event.Type)),
+			slog.Uint64("chat_id", uint64(event.ChatID)),
+			slog.Any("error", err),
+		)
+	}
+}
+
+// chatLookupError превращает "запись не найдена" в ErrChatNotFound,
+// а остальные ошибки хранилища возвращает как есть (это ошибка сервера, а не 404)
+func chatLookupError(span trace.Span, err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrChatNotFound
+	}
+	return recordError(span, err)
+}
+
+// recordError помечает спан как ошибочный и возвращает ту же ошибку
+func recordError(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
 }
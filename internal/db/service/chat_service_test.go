@@ -0,0 +1,751 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go-chat-app/internal/auth"
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/ratelimit"
+	"go-chat-app/internal/repository/memory"
+)
+
+// newTestService создает сервис поверх хранилища в памяти
+func newTestService() *ChatService {
+	db := memory.New()
+	return NewChatService(db.Chats(), db.Messages())
+}
+
+// TestCreateChatValidation проверяет обрезку пробелов и ограничения title
+func TestCreateChatValidation(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+
+	chat, err := s.CreateChat(ctx, "  Общий чат  ")
+	if err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	if chat.Title != "Общий чат" {
+		t.Errorf("Пробелы по краям не обрезаны: %q", chat.Title)
+	}
+
+	for _, title := range []string{"", "   ", strings.Repeat("a", 201)} {
+		if _, err := s.CreateChat(ctx, title); err == nil {
+			t.Errorf("Ожидалась ошибка валидации для title длиной %d", len(title))
+		}
+	}
+}
+
+// TestSendMessage проверяет отправку сообщений и ошибки для несуществующего чата
+func TestSendMessage(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+
+	if _, err := s.SendMessage(ctx, 999, "привет"); !errors.Is(err, ErrChatNotFound) {
+		t.Errorf("Ожидалась ErrChatNotFound, получено %v", err)
+	}
+
+	chat, _ := s.CreateChat(ctx, "чат")
+	msg, err := s.SendMessage(ctx, chat.ID, "  привет  ")
+	if err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	if msg.Text != "привет" || msg.ChatID != chat.ID {
+		t.Errorf("Сообщение сохранено неверно: %+v", msg)
+	}
+
+	if _, err := s.SendMessage(ctx, chat.ID, strings.Repeat("x", 5001)); err == nil {
+		t.Error("Ожидалась ошибка для слишком длинного текста")
+	}
+}
+
+// TestSendMessageFormat проверяет markdown: HTML и элементы разметки строятся при отправке
+func TestSendMessageFormat(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+	chat, _ := s.CreateChat(ctx, "чат")
+
+	plain, err := s.SendMessage(ctx, chat.ID, "**как есть** <b>")
+	if err != nil || plain.Format != models.MessageFormatPlain || plain.HTML != "" || plain.Entities != nil {
+		t.Errorf("Сообщение без формата должно быть plain без HTML: %+v, %v", plain, err)
+	}
+	rich, err := s.SendMessage(ctx, chat.ID, "**важно** <script>", WithFormat(models.MessageFormatMarkdown))
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if rich.Text != "**важно** <script>" || rich.HTML != "<strong>важно</strong> &lt;script&gt;" || len(rich.Entities) != 1 {
+		t.Errorf("Сообщение markdown: %+v", rich)
+	}
+	if _, err := s.SendMessage(ctx, chat.ID, "x", WithFormat("html")); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Ожидалась ErrUnknownFormat, получено %v", err)
+	}
+}
+
+// TestGetChatWithMessagesLimit проверяет лимит по умолчанию и максимальный лимит
+func TestGetChatWithMessagesLimit(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+
+	chat, _ := s.CreateChat(ctx, "чат")
+	for i := 0; i < 120; i++ {
+		if _, err := s.SendMessage(ctx, chat.ID, "сообщение"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{0, 20},    // значение по умолчанию
+		{5, 5},     // обычный лимит
+		{500, 100}, // не больше 100
+	}
+	for _, tt := range tests {
+		_, messages, err := s.GetChatWithMessages(ctx, chat.ID, tt.limit)
+		if err != nil {
+			t.Fatalf("limit=%d: %v", tt.limit, err)
+		}
+		if len(messages) != tt.want {
+			t.Errorf("limit=%d: ожидалось %d сообщений, получено %d", tt.limit, tt.want, len(messages))
+		}
+	}
+}
+
+// TestDeleteChat проверяет, что удаленный чат больше не доступен
+func TestDeleteChat(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+
+	chat, _ := s.CreateChat(ctx, "чат")
+	if err := s.DeleteChat(ctx, chat.ID); err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	if _, _, err := s.GetChatWithMessages(ctx, chat.ID, 20); !errors.Is(err, ErrChatNotFound) {
+		t.Errorf("Ожидалась ErrChatNotFound после удаления, получено %v", err)
+	}
+	if err := s.DeleteChat(ctx, chat.ID); !errors.Is(err, ErrChatNotFound) {
+		t.Errorf("Повторное удаление должно вернуть ErrChatNotFound, получено %v", err)
+	}
+}
+
+// TestSlowMode проверяет медленный режим: второе сообщение подряд от того же
+// клиента отклоняется, другой клиент пишет без ограничений
+func TestSlowMode(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+
+	chat, _ := s.CreateChat(ctx, "чат")
+	if _, err := s.SetSlowMode(ctx, chat.ID, -1); err == nil {
+		t.Error("Ожидалась ошибка для отрицательного интервала")
+	}
+	if _, err := s.SetSlowMode(ctx, chat.ID, 30); err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+
+	alice := ratelimit.WithClient(ctx, "user:alice")
+	bob := ratelimit.WithClient(ctx, "user:bob")
+
+	if _, err := s.SendMessage(alice, chat.ID, "первое"); err != nil {
+		t.Fatalf("Первое сообщение отклонено: %v", err)
+	}
+	_, err := s.SendMessage(alice, chat.ID, "второе")
+	var rateErr *RateLimitError
+	if !errors.As(err, &rateErr) {
+		t.Fatalf("Ожидалась RateLimitError, получено %v", err)
+	}
+	if rateErr.RetryAfter <= 0 || rateErr.RetryAfter > 30*time.Second {
+		t.Errorf("Некорректный RetryAfter: %s", rateErr.RetryAfter)
+	}
+	if _, err := s.SendMessage(bob, chat.ID, "от другого"); err != nil {
+		t.Errorf("Сообщение другого клиента отклонено: %v", err)
+	}
+
+	// Выключение медленного режима снимает ограничение
+	if _, err := s.SetSlowMode(ctx, chat.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SendMessage(alice, chat.ID, "снова"); err != nil {
+		t.Errorf("После выключения медленного режима: %v", err)
+	}
+}
+
+// TestUpdateSettings проверяет частичное изменение настроек и срок хранения
+func TestUpdateSettings(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+	chat, _ := s.CreateChat(ctx, "чат")
+
+	days, tooLong := 30, maxRetentionDays+1
+	if _, err := s.UpdateSettings(ctx, chat.ID, ChatSettings{RetentionDays: &tooLong}); err == nil || !strings.Contains(err.Error(), "не более") {
+		t.Errorf("Ожидалась ошибка для слишком долгого срока, получено %v", err)
+	}
+	got, err := s.UpdateSettings(ctx, chat.ID, ChatSettings{RetentionDays: &days})
+	if err != nil || got.RetentionDays == nil || *got.RetentionDays != 30 {
+		t.Fatalf("Срок хранения не сохранен: %+v, %v", got, err)
+	}
+
+	// Изменение медленного режима не трогает срок хранения
+	if got, _ := s.SetSlowMode(ctx, chat.ID, 10); got.RetentionDays == nil || got.SlowModeSeconds != 10 {
+		t.Errorf("Срок хранения потерян: %+v", got)
+	}
+	got, err = s.UpdateSettings(ctx, chat.ID, ChatSettings{DefaultRetention: true})
+	if err != nil || got.RetentionDays != nil || got.SlowModeSeconds != 10 {
+		t.Errorf("Срок хранения не сброшен: %+v, %v", got, err)
+	}
+	if _, err := s.UpdateSettings(ctx, 999, ChatSettings{RetentionDays: &days}); !errors.Is(err, ErrChatNotFound) {
+		t.Errorf("Ожидалась ErrChatNotFound, получено %v", err)
+	}
+}
+
+// TestScheduledMessages проверяет проверки, изменение и отмену запланированных сообщений
+func TestScheduledMessages(t *testing.T) {
+	db := memory.New()
+	ctx := auth.WithUser(context.Background(), "alice")
+	if _, err := NewChatService(db.Chats(), db.Messages()).ScheduleMessage(ctx, 1, "x", time.Now().Add(time.Hour)); !errors.Is(err, ErrSchedulingDisabled) {
+		t.Errorf("Без хранилища ожидалась ErrSchedulingDisabled, получено %v", err)
+	}
+
+	s := NewChatService(db.Chats(), db.Messages(), WithScheduledStore(db.Scheduled()))
+	chat, _ := s.CreateChat(ctx, "чат")
+	other, _ := s.CreateChat(ctx, "другой")
+	sendAt := time.Now().Add(time.Hour)
+
+	for name, at := range map[string]time.Time{"в прошлом": time.Now().Add(-time.Minute), "через два года": time.Now().AddDate(2, 0, 0)} {
+		if _, err := s.ScheduleMessage(ctx, chat.ID, "x", at); err == nil || !strings.Contains(err.Error(), "не более") {
+			t.Errorf("%s: ожидалась ошибка send_at, получено %v", name, err)
+		}
+	}
+	if _, err := s.ScheduleMessage(ctx, chat.ID, "  ", sendAt); err == nil {
+		t.Error("Пустой текст должен отклоняться")
+	}
+	if _, err := s.ScheduleMessage(ctx, 999, "x", sendAt); !errors.Is(err, ErrChatNotFound) {
+		t.Errorf("Ожидалась ErrChatNotFound, получено %v", err)
+	}
+
+	m, err := s.ScheduleMessage(ctx, chat.ID, "  стендап  ", sendAt)
+	if err != nil || m.Text != "стендап" || m.AuthorID != "alice" || m.Status != models.ScheduledPending {
+		t.Fatalf("ScheduleMessage: %+v, %v", m, err)
+	}
+
+	text := "стендап в 10:00"
+	updated, err := s.UpdateScheduled(ctx, chat.ID, m.ID, ScheduledUpdate{Text: &text})
+	if err != nil || updated.Text != text || !updated.SendAt.Equal(sendAt) {
+		t.Errorf("UpdateScheduled: %+v, %v", updated, err)
+	}
+	// Сообщение другого чата не видно
+	if _, err := s.UpdateScheduled(ctx, other.ID, m.ID, ScheduledUpdate{Text: &text}); !errors.Is(err, ErrScheduledNotFound) {
+		t.Errorf("Чужой чат: ожидалась ErrScheduledNotFound, получено %v", err)
+	}
+
+	if canceled, err := s.CancelScheduled(ctx, chat.ID, m.ID); err != nil || canceled.Status != models.ScheduledCanceled {
+		t.Fatalf("CancelScheduled: %+v, %v", canceled, err)
+	}
+	if _, err := s.UpdateScheduled(ctx, chat.ID, m.ID, ScheduledUpdate{Text: &text}); !errors.Is(err, ErrScheduledNotPending) {
+		t.Errorf("Изменение отмененного: ожидалась ErrScheduledNotPending, получено %v", err)
+	}
+
+	list, err := s.ListScheduled(ctx, chat.ID, "", 0)
+	if err != nil || len(list) != 1 {
+		t.Errorf("ListScheduled: %+v, %v", list, err)
+	}
+	if list, _ := s.ListScheduled(ctx, chat.ID, models.ScheduledPending, 0); len(list) != 0 {
+		t.Errorf("Отмененное сообщение не должно попадать в pending: %+v", list)
+	}
+}
+
+// TestExportMessages проверяет выгрузку пачками в хронологическом порядке
+// и сохранение автора сообщения
+func TestExportMessages(t *testing.T) {
+	s := newTestService()
+	ctx := auth.WithUser(context.Background(), "alice")
+
+	chat, _ := s.CreateChat(ctx, "чат")
+	total := exportBatchSize*2 + 1
+	for i := 0; i < total; i++ {
+		if _, err := s.SendMessage(ctx, chat.ID, "сообщение"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var sizes []int
+	var lastID uint
+	err := s.ExportMessages(ctx, chat.ID, time.Time{}, time.Time{}, func(batch []models.Message) error {
+		sizes = append(sizes, len(batch))
+		for _, m := range batch {
+			if m.ID <= lastID {
+				t.Fatalf("Нарушен порядок: %d после %d", m.ID, lastID)
+			}
+			if m.AuthorID != "alice" {
+				t.Fatalf("Автор не сохранен: %q", m.AuthorID)
+			}
+			lastID = m.ID
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	if len(sizes) != 3 || sizes[2] != 1 {
+		t.Errorf("Ожидались пачки %d, %d, 1, получено %v", exportBatchSize, exportBatchSize, sizes)
+	}
+
+	// Ошибка обработчика прерывает выгрузку
+	stop := errors.New("стоп")
+	calls := 0
+	err = s.ExportMessages(ctx, chat.ID, time.Time{}, time.Time{}, func([]models.Message) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Ожидалась остановка после первой пачки: err=%v, вызовов %d", err, calls)
+	}
+}
+
+// TestPins проверяет закрепление сообщений, предел и служебное сообщение о закреплении
+func TestPins(t *testing.T) {
+	db := memory.New()
+	ctx := auth.WithUser(context.Background(), "alice")
+	if _, err := NewChatService(db.Chats(), db.Messages()).PinMessage(ctx, 1, 1); !errors.Is(err, ErrPinsDisabled) {
+		t.Errorf("Без хранилища ожидалась ErrPinsDisabled, получено %v", err)
+	}
+
+	events := NewLocalPubSub()
+	s := NewChatService(db.Chats(), db.Messages(), WithPins(db.Pins(), 2), WithPubSub(events))
+	chat, _ := s.CreateChat(ctx, "чат")
+	first, _ := s.SendMessage(ctx, chat.ID, "релиз в пятницу")
+	second, _ := s.SendMessage(ctx, chat.ID, "ревью обязательно")
+	third, _ := s.SendMessage(ctx, chat.ID, "третье")
+	sub, cancel := events.Subscribe(ctx, chat.ID)
+	defer cancel()
+
+	pin, err := s.PinMessage(ctx, chat.ID, first.ID)
+	if err != nil || pin.PinnedBy != "alice" || pin.Message == nil || pin.Message.Text != first.Text {
+		t.Fatalf("PinMessage: %+v, %v", pin, err)
+	}
+	select {
+	case ev := <-sub:
+		if ev.Type != EventMessageCreated || ev.Message.Kind != models.MessageKindPin || ev.Message.AuthorID != "alice" {
+			t.Errorf("Неверное служебное сообщение: %+v", ev.Message)
+		}
+	case <-time.After(time.Second):
+		t.Error("Служебное сообщение о закреплении не опубликовано")
+	}
+
+	if _, err := s.PinMessage(ctx, chat.ID, first.ID); !errors.Is(err, ErrAlreadyPinned) {
+		t.Errorf("Повторное закрепление: ожидалась ErrAlreadyPinned, получено %v", err)
+	}
+	if _, err := s.PinMessage(ctx, chat.ID, 999); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("Нет сообщения: ожидалась ErrMessageNotFound, получено %v", err)
+	}
+	if _, err := s.PinMessage(ctx, 999, first.ID); !errors.Is(err, ErrChatNotFound) {
+		t.Errorf("Нет чата: ожидалась ErrChatNotFound, получено %v", err)
+	}
+	if _, err := s.PinMessage(ctx, chat.ID, second.ID); err != nil {
+		t.Fatalf("PinMessage: %v", err)
+	}
+	if _, err := s.PinMessage(ctx, chat.ID, third.ID); !errors.Is(err, ErrPinLimit) {
+		t.Errorf("Сверх предела: ожидалась ErrPinLimit, получено %v", err)
+	}
+
+	ids, err := s.PinnedMessageIDs(ctx, chat.ID)
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("PinnedMessageIDs: %v, %v", ids, err)
+	}
+	if err := s.UnpinMessage(ctx, chat.ID, first.ID); err != nil {
+		t.Fatalf("UnpinMessage: %v", err)
+	}
+	if err := s.UnpinMessage(ctx, chat.ID, first.ID); !errors.Is(err, ErrNotPinned) {
+		t.Errorf("Повторное открепление: ожидалась ErrNotPinned, получено %v", err)
+	}
+	if pins, err := s.ListPins(ctx, chat.ID); err != nil || len(pins) != 1 || pins[0].MessageID != second.ID {
+		t.Errorf("ListPins: %+v, %v", pins, err)
+	}
+}
+
+// TestParseMentions проверяет разбор упоминаний: границы, регистр @all и смещения в рунах
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		text string
+		want []models.Mention
+	}{
+		{"привет @alice", []models.Mention{{Offset: 7, Length: 6, Target: "alice"}}},
+		{"@bob, @ALL!", []models.Mention{{Offset: 0, Length: 4, Target: "bob"}, {Offset: 6, Length: 4, Target: models.MentionAll}}},
+		{"спросите @dev.ops.", []models.Mention{{Offset: 9, Length: 8, Target: "dev.ops"}}},
+		{"(@Мария)", []models.Mention{{Offset: 1, Length: 6, Target: "Мария"}}},
+		{"почта mail@example.com", nil},
+		{"@ @- @@carol", []models.Mention{{Offset: 6, Length: 6, Target: "carol"}}},
+		{"@" + strings.Repeat("x", 129), nil},
+	}
+	for _, tt := range tests {
+		got := parseMentions(tt.text)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: ожидалось %+v, получено %+v", tt.text, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: ожидалось %+v, получено %+v", tt.text, tt.want, got)
+			}
+		}
+	}
+
+	if got := parseMentions(strings.Repeat("@a ", maxMentions+10)); len(got) != maxMentions {
+		t.Errorf("Ожидалось не больше %d упоминаний, получено %d", maxMentions, len(got))
+	}
+}
+
+// TestMentions проверяет уведомления об упоминаниях: без автора и повторов, @all, прочтение
+func TestMentions(t *testing.T) {
+	db := memory.New()
+	alice := auth.WithUser(context.Background(), "alice")
+	bob := auth.WithUser(context.Background(), "bob")
+	carol := auth.WithUser(context.Background(), "carol")
+	if _, err := NewChatService(db.Chats(), db.Messages()).ListMentions(bob, false, 0, 0); !errors.Is(err, ErrMentionsDisabled) {
+		t.Errorf("Без хранилища ожидалась ErrMentionsDisabled, получено %v", err)
+	}
+
+	s := NewChatService(db.Chats(), db.Messages(), WithMentions(db.Mentions()))
+	if _, err := s.ListMentions(context.Background(), false, 0, 0); !errors.Is(err, ErrNoUser) {
+		t.Errorf("Без пользователя ожидалась ErrNoUser, получено %v", err)
+	}
+	chat, _ := s.CreateChat(alice, "чат")
+	if _, err := s.SendMessage(carol, chat.ID, "всем привет"); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	direct, err := s.SendMessage(alice, chat.ID, "@bob @bob @alice посмотри")
+	if err != nil || len(direct.Mentions) != 3 {
+		t.Fatalf("SendMessage: %+v, %v", direct, err)
+	}
+	if _, err := s.SendMessage(alice, chat.ID, "@all релиз"); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	page, err := s.ListMentions(bob, false, 0, 0)
+	if err != nil || len(page.Mentions) != 1 || page.UnreadCount != 1 || page.Mentions[0].MessageID != direct.ID {
+		t.Fatalf("ListMentions bob: %+v, %v", page, err)
+	}
+	if page.Mentions[0].Message == nil || page.Mentions[0].Message.Text != direct.Text {
+		t.Errorf("Уведомление без сообщения: %+v", page.Mentions[0])
+	}
+	if page, _ := s.ListMentions(alice, false, 0, 0); len(page.Mentions) != 0 {
+		t.Errorf("Автор не должен получать уведомления о своих сообщениях: %+v", page.Mentions)
+	}
+	if page, _ := s.ListMentions(carol, false, 0, 0); len(page.Mentions) != 1 || page.UnreadCount != 1 {
+		t.Errorf("@all: ожидалось уведомление участнику чата, получено %+v", page)
+	}
+
+	if marked, err := s.MarkMentionsRead(bob, nil); err != nil || marked != 1 {
+		t.Fatalf("MarkMentionsRead: %d, %v", marked, err)
+	}
+	if page, _ := s.ListMentions(bob, true, 0, 0); len(page.Mentions) != 0 || page.UnreadCount != 0 {
+		t.Errorf("После прочтения непрочитанных быть не должно: %+v", page)
+	}
+	if page, _ := s.ListMentions(bob, false, 0, 0); len(page.Mentions) != 1 || page.Mentions[0].ReadAt == nil {
+		t.Errorf("Прочитанное уведомление должно остаться в списке: %+v", page)
+	}
+	if _, err := s.ListMentions(bob, false, 0, maxMentionsLimit+1); err == nil {
+		t.Error("Ожидалась ошибка для limit больше максимального")
+	}
+}
+
+// TestFindLinks проверяет поиск ссылок: границы, знаки препинания, скобки, код и повторы
+func TestFindLinks(t *testing.T) {
+	code := []models.Entity{{Type: models.EntityCode, Offset: 0, Length: 21}}
+	tests := []struct {
+		text     string
+		entities []models.Entity
+		want     []string
+	}{
+		{"смотри https://example.com/a?b=1.", nil, []string{"https://example.com/a?b=1"}},
+		{"(см. https://en.wikipedia.org/wiki/Go_(язык)), HTTP://X.ORG!", nil,
+			[]string{"https://en.wikipedia.org/wiki/Go_(язык)", "HTTP://X.ORG"}},
+		{"[доки](https://go.dev/doc) и **https://go.dev/doc**", nil, []string{"https://go.dev/doc"}},
+		{"nothttps://example.com ftp://example.com javascript:alert(1) https://", nil, nil},
+		{"`https://example.com` https://example.org", code, []string{"https://example.org"}},
+	}
+	for _, tt := range tests {
+		got := findLinks(tt.text, tt.entities)
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%q: ожидалось %q, получено %q", tt.text, tt.want, got)
+		}
+	}
+
+	var many []string
+	for i := 0; i < maxLinks+3; i++ {
+		many = append(many, fmt.Sprintf("https://example.com/%d", i))
+	}
+	if got := findLinks(strings.Join(many, " "), nil); len(got) != maxLinks {
+		t.Errorf("Ожидалось не больше %d ссылок, получено %q", maxLinks, got)
+	}
+}
+
+// TestLinkPreviews проверяет очередь превью при отправке, превью при чтении и событие message.preview
+func TestLinkPreviews(t *testing.T) {
+	db := memory.New()
+	ctx := context.Background()
+	if m, err := newTestService().SendMessage(ctx, mustChat(t, db), "https://example.com"); err == nil && len(m.Previews) != 0 {
+		t.Errorf("Без хранилища превью не ставятся в очередь: %+v", m.Previews)
+	}
+
+	s := NewChatService(db.Chats(), db.Messages(), WithLinkPreviews(db.LinkPreviews(), time.Hour), WithPins(db.Pins(), 0))
+	chat, _ := s.CreateChat(ctx, "ссылки")
+	events, cancel, _ := s.Subscribe(ctx, chat.ID)
+	defer cancel()
+
+	m, err := s.SendMessage(ctx, chat.ID, "релиз: https://example.com/release и https://example.com/release")
+	if err != nil || len(m.Previews) != 1 || m.Previews[0].Status != models.LinkPreviewPending {
+		t.Fatalf("SendMessage: ожидалось одно ожидающее превью, получено %+v, %v", m, err)
+	}
+	if event := <-events; event.Type != EventMessageCreated || len(event.Message.Previews) != 1 {
+		t.Errorf("message.created без превью: %+v", event)
+	}
+
+	// Обработчик загрузил превью: подписчики узнают об этом, а чтение возвращает его с сообщением
+	claimed, _ := db.LinkPreviews().Claim(ctx, "w", time.Now(), time.Minute, 10)
+	preview := claimed[0]
+	preview.Title = "Релиз 2.0"
+	if err := db.LinkPreviews().Complete(ctx, &preview, "w", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	s.PublishLinkPreview(ctx, preview)
+	if event := <-events; event.Type != EventMessagePreview || event.MessageID != m.ID || event.Preview == nil || event.Preview.Title != "Релиз 2.0" {
+		t.Errorf("Ожидалось событие message.preview, получено %+v", event)
+	}
+	_, messages, _ := s.GetChatWithMessages(ctx, chat.ID, 10)
+	if len(messages) != 1 || len(messages[0].Previews) != 1 || messages[0].Previews[0].Status != models.LinkPreviewReady {
+		t.Errorf("GetChatWithMessages: ожидалось загруженное превью, получено %+v", messages)
+	}
+	if _, err := s.PinMessage(ctx, chat.ID, m.ID); err != nil {
+		t.Fatal(err)
+	}
+	if pins, _ := s.ListPins(ctx, chat.ID); len(pins) != 1 || len(pins[0].Message.Previews) != 1 {
+		t.Errorf("ListPins: ожидалось сообщение с превью, получено %+v", pins)
+	}
+
+	// Свежий кэш: превью другого сообщения с той же ссылкой сразу загружено
+	again, _ := s.SendMessage(ctx, chat.ID, "еще раз https://example.com/release")
+	if len(again.Previews) != 1 || again.Previews[0].Status != models.LinkPreviewReady || again.Previews[0].Title != "Релиз 2.0" {
+		t.Errorf("Ожидалось превью из кэша, получено %+v", again.Previews)
+	}
+}
+
+// TestPolls проверяет опросы: проверку при создании, голосование и отзыв голоса,
+// анонимность, закрытие и события poll.updated
+func TestPolls(t *testing.T) {
+	db := memory.New()
+	alice := auth.WithUser(context.Background(), "alice")
+	bob := auth.WithUser(context.Background(), "bob")
+	poll := models.Poll{Options: []models.PollOption{{Text: " Да "}, {Text: "Нет"}}}
+	if _, err := NewChatService(db.Chats(), db.Messages()).SendMessage(alice, mustChat(t, db), "Релиз?", WithPoll(poll)); !errors.Is(err, ErrPollsDisabled) {
+		t.Errorf("Без хранилища ожидалась ErrPollsDisabled, получено %v", err)
+	}
+
+	s := NewChatService(db.Chats(), db.Messages(), WithPolls(db.Polls()))
+	chat, _ := s.CreateChat(alice, "опросы")
+	events, cancel, _ := s.Subscribe(alice, chat.ID)
+	defer cancel()
+	past := time.Now().Add(-time.Minute)
+	for _, invalid := range []models.Poll{
+		{Options: []models.PollOption{{Text: "Да"}}},
+		{Options: []models.PollOption{{Text: "Да"}, {Text: " "}}},
+		{Options: []models.PollOption{{Text: "Да"}, {Text: "да"}}},
+		{Options: poll.Options, ClosesAt: &past},
+	} {
+		if _, err := s.SendMessage(alice, chat.ID, "Релиз?", WithPoll(invalid)); !errors.Is(err, ErrInvalidPoll) {
+			t.Errorf("%+v: ожидалась ErrInvalidPoll, получено %v", invalid, err)
+		}
+	}
+
+	m, err := s.SendMessage(alice, chat.ID, "Релиз?", WithPoll(poll))
+	if err != nil || m.Kind != models.MessageKindPoll || m.Poll.Options[0].Text != "Да" {
+		t.Fatalf("SendMessage: %+v, %v", m, err)
+	}
+	<-events // message.created
+	plain, _ := s.SendMessage(alice, chat.ID, "не опрос")
+	<-events
+
+	if _, err := s.Vote(context.Background(), chat.ID, m.ID, []int{0}); !errors.Is(err, ErrNoUser) {
+		t.Errorf("Без пользователя ожидалась ErrNoUser, получено %v", err)
+	}
+	if _, err := s.Vote(bob, chat.ID, plain.ID, []int{0}); !errors.Is(err, ErrNotPoll) {
+		t.Errorf("Ожидалась ErrNotPoll, получено %v", err)
+	}
+	for _, options := range [][]int{nil, {0, 1}, {2}} {
+		if _, err := s.Vote(bob, chat.ID, m.ID, options); !errors.Is(err, ErrInvalidVote) {
+			t.Errorf("%v: ожидалась ErrInvalidVote, получено %v", options, err)
+		}
+	}
+
+	// Повторный голос заменяет прежний
+	s.Vote(alice, chat.ID, m.ID, []int{0})
+	<-events
+	s.Vote(bob, chat.ID, m.ID, []int{0})
+	<-events
+	result, err := s.Vote(bob, chat.ID, m.ID, []int{1})
+	if err != nil || result.TotalVoters != 2 || result.Options[0].Votes != 1 || result.Options[1].Votes != 1 || !slices.Equal(result.MyVotes, []int{1}) {
+		t.Fatalf("Vote: %+v, %v", result, err)
+	}
+	event := <-events
+	if event.Type != EventPollUpdated || event.MessageID != m.ID || event.Poll.MyVotes != nil || !slices.Equal(event.Poll.Options[1].Voters, []string{"bob"}) {
+		t.Errorf("Ожидалось poll.updated без выбора пользователя, получено %+v", event)
+	}
+	_, messages, _ := s.GetChatWithMessages(alice, chat.ID, 10) // новые первыми
+	if got := messages[1].Poll; got.TotalVoters != 2 || !slices.Equal(got.MyVotes, []int{0}) {
+		t.Errorf("GetChatWithMessages: ожидались итоги с выбором alice, получено %+v", got)
+	}
+
+	if result, err := s.RetractVote(bob, chat.ID, m.ID); err != nil || result.TotalVoters != 1 || result.MyVotes != nil {
+		t.Errorf("RetractVote: %+v, %v", result, err)
+	}
+	<-events
+
+	// Анонимный опрос с несколькими вариантами и сроком
+	closesAt := time.Now().Add(time.Hour)
+	anon, err := s.SendMessage(alice, chat.ID, "Что чинить?", WithPoll(models.Poll{
+		Options: []models.PollOption{{Text: "Поиск"}, {Text: "Экспорт"}, {Text: "Вход"}}, Multiple: true, Anonymous: true, ClosesAt: &closesAt,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-events
+	result, err = s.Vote(bob, chat.ID, anon.ID, []int{2, 0})
+	if err != nil || result.TotalVoters != 1 || result.Options[0].Voters != nil || !slices.Equal(result.MyVotes, []int{0, 2}) {
+		t.Errorf("Vote в анонимном опросе: %+v, %v", result, err)
+	}
+	<-events
+
+	// Закрытый опрос: голосовать и отзывать голос нельзя, итоги остаются
+	soon := time.Now().Add(20 * time.Millisecond)
+	closing, _ := s.SendMessage(alice, chat.ID, "Быстрый опрос", WithPoll(models.Poll{Options: poll.Options, ClosesAt: &soon}))
+	<-events
+	s.Vote(bob, chat.ID, closing.ID, []int{1})
+	<-events
+	time.Sleep(30 * time.Millisecond)
+	if _, err := s.Vote(alice, chat.ID, closing.ID, []int{0}); !errors.Is(err, ErrPollClosed) {
+		t.Errorf("Vote: ожидалась ErrPollClosed, получено %v", err)
+	}
+	if _, err := s.RetractVote(bob, chat.ID, closing.ID); !errors.Is(err, ErrPollClosed) {
+		t.Errorf("RetractVote: ожидалась ErrPollClosed, получено %v", err)
+	}
+	_, messages, _ = s.GetChatWithMessages(bob, chat.ID, 10)
+	if got := messages[0].Poll; !got.Closed || got.Options[1].Votes != 1 {
+		t.Errorf("Закрытый опрос: ожидались итоги, получено %+v", got)
+	}
+}
+
+// TestForwardMessage проверяет пересылку: ссылку на оригинал, проверки обоих чатов,
+// пересылку пересланного и отметку удаленного оригинала при чтении
+func TestForwardMessage(t *testing.T) {
+	db := memory.New()
+	alice := auth.WithUser(context.Background(), "alice")
+	bob := auth.WithUser(context.Background(), "bob")
+	s := NewChatService(db.Chats(), db.Messages(), WithMentions(db.Mentions()), WithPins(db.Pins(), 0), WithPolls(db.Polls()))
+	source, _ := s.CreateChat(alice, "Команда")
+	target, _ := s.CreateChat(alice, "Общий")
+	third, _ := s.CreateChat(alice, "Архив")
+	events, cancel, _ := s.Subscribe(alice, target.ID)
+	defer cancel()
+
+	original, _ := s.SendMessage(alice, source.ID, "**релиз** в пятницу, @carol", WithFormat(models.MessageFormatMarkdown))
+	forwarded, err := s.ForwardMessage(bob, source.ID, original.ID, target.ID)
+	if err != nil {
+		t.Fatalf("ForwardMessage: %v", err)
+	}
+	want := models.Forward{ChatID: source.ID, ChatTitle: "Команда", MessageID: original.ID, AuthorID: "alice", SentAt: original.CreatedAt}
+	if forwarded.ChatID != target.ID || forwarded.AuthorID != "bob" || forwarded.Text != original.Text || forwarded.HTML != original.HTML || *forwarded.ForwardedFrom != want {
+		t.Errorf("Пересланное сообщение: %+v (%+v)", forwarded, forwarded.ForwardedFrom)
+	}
+	if event := <-events; event.Type != EventMessageCreated || event.Message.ForwardedFrom == nil {
+		t.Errorf("Ожидалось message.created с forwarded_from, получено %+v", event)
+	}
+	carol := auth.WithUser(context.Background(), "carol")
+	if page, _ := s.ListMentions(carol, false, 0, 0); len(page.Mentions) != 1 {
+		t.Errorf("Пересылка не должна повторно уведомлять упомянутых: %+v", page.Mentions)
+	}
+
+	// Пересланное дальше ссылается на первый оригинал
+	again, err := s.ForwardMessage(bob, target.ID, forwarded.ID, third.ID)
+	if err != nil || *again.ForwardedFrom != want {
+		t.Errorf("Пересылка пересланного: %+v, %v", again, err)
+	}
+
+	notice := mustPin(t, alice, s, source.ID, original.ID)
+	poll, _ := s.SendMessage(alice, source.ID, "Релиз?", WithPoll(models.Poll{Options: []models.PollOption{{Text: "Да"}, {Text: "Нет"}}}))
+	for _, tt := range []struct {
+		chatID, messageID, targetID uint
+		want                        error
+	}{
+		{source.ID, original.ID, source.ID, ErrForwardSameChat},
+		{source.ID, original.ID, 999, ErrForwardTargetNotFound},
+		{999, original.ID, target.ID, ErrChatNotFound},
+		{source.ID, 999, target.ID, ErrMessageNotFound},
+		{target.ID, original.ID, third.ID, ErrMessageNotFound}, // сообщение из другого чата
+		{source.ID, notice, target.ID, ErrNotForwardable},
+		{source.ID, poll.ID, target.ID, ErrNotForwardable},
+	} {
+		if _, err := s.ForwardMessage(bob, tt.chatID, tt.messageID, tt.targetID); !errors.Is(err, tt.want) {
+			t.Errorf("ForwardMessage(%d, %d, %d): ожидалась %v, получено %v", tt.chatID, tt.messageID, tt.targetID, tt.want, err)
+		}
+	}
+
+	// Без пользователя пересылка запрещена и в целевом чате ничего не появляется,
+	// хотя отправить свое сообщение аноним может
+	_, before, _ := s.GetChatWithMessages(alice, target.ID, 10)
+	if _, err := s.ForwardMessage(context.Background(), source.ID, original.ID, target.ID); !errors.Is(err, ErrNoUser) {
+		t.Errorf("Ожидалась ErrNoUser, получено %v", err)
+	}
+	if _, err := s.SendMessage(context.Background(), source.ID, "анонимно"); err != nil {
+		t.Errorf("Анонимная отправка должна работать: %v", err)
+	}
+	if _, after, _ := s.GetChatWithMessages(alice, target.ID, 10); len(after) != len(before) {
+		t.Errorf("Без пользователя сообщение не должно пересылаться: было %d, стало %d", len(before), len(after))
+	}
+
+	// Копия исчезающего сообщения исчезает вместе с оригиналом
+	expiresAt := time.Now().Add(time.Hour)
+	disappearing, _ := s.SendMessage(alice, source.ID, "секрет", WithExpiresAt(expiresAt))
+	if copied, err := s.ForwardMessage(bob, source.ID, disappearing.ID, target.ID); err != nil || copied.ExpiresAt == nil || !copied.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Пересылка исчезающего сообщения: %+v, %v", copied, err)
+	}
+	<-events
+
+	// Чат оригинала удален: копии остаются, но ссылка отмечена удаленной
+	if err := s.DeleteChat(alice, source.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, messages, _ := s.GetChatWithMessages(alice, target.ID, 10)
+	for _, m := range messages {
+		if m.ForwardedFrom == nil || !m.ForwardedFrom.Deleted || m.ForwardedFrom.ChatTitle != "Команда" {
+			t.Errorf("Ожидалась ссылка на удаленный оригинал: %+v", m.ForwardedFrom)
+		}
+	}
+	if _, err := s.ForwardMessage(bob, target.ID, forwarded.ID, third.ID); err != nil {
+		t.Errorf("Копию удаленного оригинала можно переслать дальше, получено %v", err)
+	}
+}
+
+// mustPin закрепляет сообщение и возвращает ID служебного сообщения о закреплении
+func mustPin(t *testing.T, ctx context.Context, s *ChatService, chatID, messageID uint) uint {
+	t.Helper()
+	if _, err := s.PinMessage(ctx, chatID, messageID); err != nil {
+		t.Fatal(err)
+	}
+	_, messages, _ := s.GetChatWithMessages(ctx, chatID, 1)
+	return messages[0].ID
+}
+
+// mustChat создает чат в хранилище и возвращает его ID
+func mustChat(t *testing.T, db *memory.DB) uint {
+	t.Helper()
+	chat := &models.Chat{Title: "чат"}
+	if err := db.Chats().Create(context.Background(), chat); err != nil {
+		t.Fatal(err)
+	}
+	return chat.ID
+}
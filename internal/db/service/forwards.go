@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"go-chat-app/internal/auth"
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Ошибки пересылки сообщений
+var (
+	ErrForwardTargetNotFound = errors.New("чат, в который пересылается сообщение, не найден")
+	ErrForwardSameChat       = errors.New("сообщение нельзя переслать в тот же чат")
+	ErrNotForwardable        = errors.New("служебные сообщения и опросы нельзя переслать")
+)
+
+// ForwardMessage пересылает сообщение messageID чата chatID в чат targetChatID от имени
+// пользователя запроса: в целевом чате появляется копия текста (с тем же форматом) со ссылкой
+// на оригинал в ForwardedFrom, подписчики получают ее событием message.created
+// Пересылать может только определенный пользователь (ErrNoUser), оба чата должны существовать
+// (см. forwardChats), а оригинал - не быть удален или истекшим (ErrMessageNotFound)
+// Копия исчезающего сообщения исчезает тогда же, когда оригинал; медленный режим целевого чата
+// действует как при отправке. Упомянутые в тексте пользователи повторно не уведомляются
+func (s *ChatService) ForwardMessage(ctx context.Context, chatID, messageID, targetChatID uint) (*models.Message, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.ForwardMessage", trace.WithAttributes(
+		attribute.Int("chat.id", int(chatID)),
+		attribute.Int("message.id", int(messageID)),
+		attribute.Int("forward.chat_id", int(targetChatID)),
+	))
+	defer span.End()
+
+	if targetChatID == chatID {
+		return nil, ErrForwardSameChat
+	}
+
+	// 1. Пользователь и оба чата, затем оригинал
+	source, err := s.forwardChats(ctx, chatID, targetChatID)
+	if err != nil {
+		return nil, recordError(span, err)
+	}
+	original, err := s.messageRepo.GetByID(ctx, chatID, messageID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, recordError(span, err)
+	}
+	if original.Kind != "" {
+		return nil, ErrNotForwardable
+	}
+
+	// 2. Ссылка на оригинал; пересланное дальше сообщение ссылается на первый оригинал
+	forward := models.Forward{ChatID: source.ID, ChatTitle: source.Title, MessageID: original.ID, AuthorID: original.AuthorID, SentAt: original.CreatedAt}
+	if original.ForwardedFrom != nil {
+		forward = *original.ForwardedFrom
+		forward.Deleted = false
+	}
+
+	// 3. Отправляем копию в целевой чат
+	opts := []MessageOption{WithFormat(original.Format), withForward(forward)}
+	if original.ExpiresAt != nil {
+		opts = append(opts, WithExpiresAt(*original.ExpiresAt))
+	}
+	message, err := s.SendMessage(ctx, targetChatID, original.Text, opts...)
+	if errors.Is(err, ErrChatNotFound) {
+		return nil, ErrForwardTargetNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "сообщение переслано",
+		slog.Uint64("chat_id", uint64(chatID)),
+		slog.Uint64("message_id", uint64(messageID)),
+		slog.Uint64("target_chat_id", uint64(targetChatID)),
+		slog.Uint64("forward_id", uint64(message.ID)),
+	)
+	return message, nil
+}
+
+// forwardChats проверяет пользователя запроса и существование обоих чатов и возвращает чат оригинала
+// Участников у чатов нет: читать и писать в чат может любой клиент, как в GET и POST
+// /chats/{id}/messages, поэтому прав на отдельные чаты здесь не проверяется
+// В отличие от SendMessage аноним переслать не может: копия чужого сообщения всегда
+// подписана тем, кто ее переслал (как голос в опросе)
+func (s *ChatService) forwardChats(ctx context.Context, chatID, targetChatID uint) (*models.Chat, error) {
+	userID, ok := auth.UserID(ctx)
+	if !ok || userID == "" {
+		return nil, ErrNoUser
+	}
+	source, err := s.chatRepo.GetByID(ctx, chatID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrChatNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.chatRepo.GetByID(ctx, targetChatID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrForwardTargetNotFound
+		}
+		return nil, err
+	}
+	return source, nil
+}
+
+// attachForwards отмечает у пересланных сообщений, оригинал которых уже не прочитать, Deleted
+func (s *ChatService) attachForwards(ctx context.Context, messages []*models.Message) error {
+	var ids []uint
+	for _, m := range messages {
+		if m.ForwardedFrom != nil {
+			ids = append(ids, m.ForwardedFrom.MessageID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	existing, err := s.messageRepo.ExistingIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	alive := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		alive[id] = true
+	}
+	for _, m := range messages {
+		if m.ForwardedFrom != nil {
+			// Копия: ссылка может быть общей с хранилищем
+			forward := *m.ForwardedFrom
+			forward.Deleted = !alive[forward.MessageID]
+			m.ForwardedFrom = &forward
+		}
+	}
+	return nil
+}
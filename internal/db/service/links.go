@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"go-chat-app/internal/markdown"
+	"go-chat-app/internal/models"
+)
+
+// maxLinks - для скольких ссылок сообщения загружаются превью, остальные остаются текстом
+const maxLinks = 5
+
+// maxPreviewNotifications - скольким последним сообщениям со ссылкой сообщается о загруженном превью
+const maxPreviewNotifications = 100
+
+// defaultPreviewCacheTTL - сколько превью считается свежим, если WithLinkPreviews не задает срок
+const defaultPreviewCacheTTL = 24 * time.Hour
+
+// queuePreviews ставит ссылки отправленного сообщения в очередь загрузки превью
+// и добавляет превью к сообщению: из кэша - сразу загруженные, новые - со статусом pending
+// Сообщение уже сохранено, поэтому ошибка только пишется в лог: сообщение останется без превью
+func (s *ChatService) queuePreviews(ctx context.Context, message *models.Message) {
+	urls := findLinks(message.Text, message.Entities)
+	if s.previewRepo == nil || len(urls) == 0 {
+		return
+	}
+	previews, err := s.previewRepo.Attach(ctx, message.ID, urls, time.Now().Add(-s.previewTTL))
+	if err != nil {
+		slog.WarnContext(ctx, "не удалось поставить ссылки в очередь превью",
+			slog.Uint64("message_id", uint64(message.ID)),
+			slog.Any("error", err),
+		)
+		return
+	}
+	message.Previews = previews
+}
+
+// attachPreviews добавляет к сообщениям их превью ссылок (если превью включены)
+func (s *ChatService) attachPreviews(ctx context.Context, messages []*models.Message) error {
+	if s.previewRepo == nil || len(messages) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(messages))
+	for i, m := range messages {
+		ids[i] = m.ID
+	}
+	previews, err := s.previewRepo.ListByMessages(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, m := range messages {
+		m.Previews = previews[m.ID]
+	}
+	return nil
+}
+
+// PublishLinkPreview сообщает подписчикам чатов о загруженном (или окончательно
+// не загруженном) превью: событие message.preview для каждого из последних
+// maxPreviewNotifications сообщений, получивших ссылку с момента постановки в очередь
+// Вызывается обработчиком превью (internal/unfurl); ошибки только пишутся в лог
+func (s *ChatService) PublishLinkPreview(ctx context.Context, preview models.LinkPreview) {
+	if s.previewRepo == nil {
+		return
+	}
+	messages, err := s.previewRepo.Messages(ctx, preview.ID, preview.QueuedAt, maxPreviewNotifications)
+	if err != nil {
+		slog.WarnContext(ctx, "не удалось найти сообщения со ссылкой", slog.Any("error", err))
+		return
+	}
+	for _, m := range messages {
+		s.publish(ctx, Event{Type: EventMessagePreview, ChatID: m.ChatID, MessageID: m.ID, Preview: &preview})
+	}
+}
+
+// findLinks находит в тексте http(s) ссылки без повторов, не больше maxLinks
+// Ссылка начинается с "http://" или "https://" не внутри слова и продолжается до пробела;
+// знаки препинания в конце и непарные закрывающие скобки к ней не относятся
+// ("(см. https://example.com/a_(b))." - это https://example.com/a_(b)), поэтому находятся
+// и адреса markdown ссылок [текст](адрес). Текст элементов skip (код) пропускается
+func findLinks(text string, skip []models.Entity) []string {
+	runes := []rune(text)
+	var links []string
+	for i := 0; i < len(runes) && len(links) < maxLinks; i++ {
+		if (i > 0 && (unicode.IsLetter(runes[i-1]) || unicode.IsDigit(runes[i-1]))) || !hasScheme(runes[i:]) {
+			continue
+		}
+		if end, ok := insideCode(i, skip); ok {
+			i = end - 1
+			continue
+		}
+		end := i
+		for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(`<>"`, runes[end]) {
+			end++
+		}
+		end = trimLink(runes, i, end)
+		link := string(runes[i:end])
+		if markdown.SafeURL(link) && !slices.Contains(links, link) {
+			links = append(links, link)
+		}
+		i = end - 1
+	}
+	return links
+}
+
+// hasScheme сообщает, начинаются ли руны с "http://" или "https://" (без учета регистра)
+func hasScheme(runes []rune) bool {
+	prefix := strings.ToLower(string(runes[:min(len(runes), len("https://"))]))
+	return strings.HasPrefix(prefix, "http://") || strings.HasPrefix(prefix, "https://")
+}
+
+// insideCode сообщает, попадает ли позиция i в код или блок кода, и возвращает его конец
+func insideCode(i int, entities []models.Entity) (int, bool) {
+	for _, e := range entities {
+		if (e.Type == models.EntityCode || e.Type == models.EntityPre) && i >= e.Offset && i < e.Offset+e.Length {
+			return e.Offset + e.Length, true
+		}
+	}
+	return 0, false
+}
+
+// trimLink убирает с конца ссылки runes[start:end] знаки препинания и закрывающие скобки без пары
+func trimLink(runes []rune, start, end int) int {
+	for end > start {
+		r := runes[end-1]
+		switch {
+		case strings.ContainsRune(".,;:!?'*_", r):
+			end--
+		case r == ')' || r == ']':
+			open := '('
+			if r == ']' {
+				open = '['
+			}
+			link := runes[start:end]
+			if count(link, open) >= count(link, r) {
+				return end
+			}
+			end--
+		default:
+			return end
+		}
+	}
+	return end
+}
+
+// count возвращает количество рун r в runes
+func count(runes []rune, r rune) int {
+	n := 0
+	for _, c := range runes {
+		if c == r {
+			n++
+		}
+	}
+	return n
+}
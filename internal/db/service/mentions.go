@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"go-chat-app/internal/auth"
+	"go-chat-app/internal/models"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxMentions - сколько упоминаний разбирается в одном сообщении, остальные остаются текстом
+const maxMentions = 50
+
+// maxMentionLength - максимальная длина ID пользователя в упоминании (как author_id)
+const maxMentionLength = 128
+
+// defaultMentionsLimit и maxMentionsLimit - размер страницы GET /me/mentions
+const (
+	defaultMentionsLimit = 50
+	maxMentionsLimit     = 100
+)
+
+// Ошибки упоминаний
+var (
+	ErrMentionsDisabled = errors.New("упоминания не настроены")
+	ErrNoUser           = errors.New("пользователь не определен")
+)
+
+// MentionPage - страница уведомлений об упоминаниях пользователя
+type MentionPage struct {
+	Mentions    []models.MessageMention
+	UnreadCount int64 // всего непрочитанных, а не только на странице
+}
+
+// ListMentions возвращает уведомления об упоминаниях пользователя запроса (сообщения - с превью ссылок и итогами опросов), новые первыми
+// unreadOnly - только непрочитанные, beforeID > 0 - следующая страница (id меньше beforeID),
+// limit <= 0 - defaultMentionsLimit, больше maxMentionsLimit - ошибка
+func (s *ChatService) ListMentions(ctx context.Context, unreadOnly bool, beforeID uint, limit int) (*MentionPage, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.ListMentions", trace.WithAttributes(attribute.Bool("mentions.unread_only", unreadOnly)))
+	defer span.End()
+
+	if s.mentionRepo == nil {
+		return nil, ErrMentionsDisabled
+	}
+	userID, ok := auth.UserID(ctx)
+	if !ok || userID == "" {
+		return nil, ErrNoUser
+	}
+	if limit <= 0 {
+		limit = defaultMentionsLimit
+	}
+	if limit > maxMentionsLimit {
+		return nil, errors.New("limit должен быть не более 100")
+	}
+
+	mentions, err := s.mentionRepo.ListByUser(ctx, userID, unreadOnly, beforeID, limit)
+	if err != nil {
+		return nil, recordError(span, err)
+	}
+	unread, err := s.mentionRepo.CountUnread(ctx, userID)
+	if err != nil {
+		return nil, recordError(span, err)
+	}
+	messages := make([]*models.Message, 0, len(mentions))
+	for _, mention := range mentions {
+		if mention.Message != nil {
+			messages = append(messages, mention.Message)
+		}
+	}
+	if err := s.decorate(ctx, messages); err != nil {
+		return nil, recordError(span, err)
+	}
+	return &MentionPage{Mentions: mentions, UnreadCount: unread}, nil
+}
+
+// MarkMentionsRead отмечает прочитанными уведомления пользователя запроса с ID из ids
+// (пустой ids - все непрочитанные) и возвращает количество отмеченных
+func (s *ChatService) MarkMentionsRead(ctx context.Context, ids []uint) (int64, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.MarkMentionsRead", trace.WithAttributes(attribute.Int("mentions.ids", len(ids))))
+	defer span.End()
+
+	if s.mentionRepo == nil {
+		return 0, ErrMentionsDisabled
+	}
+	userID, ok := auth.UserID(ctx)
+	if !ok || userID == "" {
+		return 0, ErrNoUser
+	}
+	marked, err := s.mentionRepo.MarkRead(ctx, userID, ids, time.Now())
+	if err != nil {
+		return 0, recordError(span, err)
+	}
+	return marked, nil
+}
+
+// mentionRecipients возвращает, кому создать уведомления об упоминаниях в сообщении:
+// упомянутым пользователям без повторов и без автора, а для @all - всем участникам чата
+func (s *ChatService) mentionRecipients(ctx context.Context, message *models.Message) ([]string, error) {
+	var recipients []string
+	add := func(userID string) {
+		if userID != message.AuthorID && !slices.Contains(recipients, userID) {
+			recipients = append(recipients, userID)
+		}
+	}
+	for _, mention := range message.Mentions {
+		if mention.Target != models.MentionAll {
+			add(mention.Target)
+			continue
+		}
+		participants, err := s.messageRepo.Participants(ctx, message.ChatID)
+		if err != nil {
+			return nil, err
+		}
+		for _, userID := range participants {
+			add(userID)
+		}
+	}
+	return recipients, nil
+}
+
+// parseMentions находит упоминания @user и @all в тексте
+// Упоминание начинается с "@" в начале текста или после символа, который не может быть
+// частью имени или адреса (так "mail@example.com" не упоминание), и продолжается буквами,
+// цифрами и "_.-"; точка и дефис в конце считаются знаками препинания ("@bob." - это bob)
+// @all не зависит от регистра. Offset и Length - в рунах
+func parseMentions(text string) []models.Mention {
+	runes := []rune(text)
+	var mentions []models.Mention
+	for i := 0; i < len(runes) && len(mentions) < maxMentions; i++ {
+		if runes[i] != '@' || (i > 0 && isMentionRune(runes[i-1])) {
+			continue
+		}
+		end := i + 1
+		for end < len(runes) && isMentionRune(runes[end]) {
+			end++
+		}
+		for end > i+1 && (runes[end-1] == '.' || runes[end-1] == '-') {
+			end--
+		}
+		name := string(runes[i+1 : end])
+		if name == "" || len(name) > maxMentionLength {
+			i = end - 1
+			continue
+		}
+		if strings.EqualFold(name, models.MentionAll) {
+			name = models.MentionAll
+		}
+		mentions = append(mentions, models.Mention{Offset: i, Length: end - i, Target: name})
+		i = end - 1
+	}
+	return mentions
+}
+
+// isMentionRune сообщает, может ли символ быть частью имени в упоминании
+func isMentionRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-'
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"time"
+
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/ratelimit"
+	"go-chat-app/internal/repository"
+)
+
+// Option настраивает необязательные зависимости ChatService
+type Option func(*ChatService)
+
+// WithRateLimitStore задает хранилище лимитов для медленного режима чатов
+// По умолчанию используется хранилище в памяти процесса; при нескольких
+// инстансах нужно общее хранилище, иначе лимит считается на каждом отдельно
+func WithRateLimitStore(store ratelimit.Store) Option {
+	return func(s *ChatService) {
+		s.limits = store
+	}
+}
+
+// WithPubSub задает доставку событий чатов подписчикам
+// По умолчанию - LocalPubSub: события видны только подписчикам этого же инстанса
+func WithPubSub(events PubSub) Option {
+	return func(s *ChatService) {
+		s.events = events
+	}
+}
+
+// WithScheduledStore включает запланированные сообщения (POST /chats/{id}/scheduled-messages)
+// Без хранилища методы запланированных сообщений возвращают ErrSchedulingDisabled
+func WithScheduledStore(store repository.ScheduledStore) Option {
+	return func(s *ChatService) {
+		s.scheduledRepo = store
+	}
+}
+
+// WithPins включает закрепленные сообщения (POST /chats/{id}/pins)
+// maxPerChat - сколько сообщений можно закрепить в одном чате (<= 0 - defaultMaxPins)
+// Без хранилища закрепление возвращает ErrPinsDisabled, а список закрепленных пуст
+func WithPins(store repository.PinStore, maxPerChat int) Option {
+	return func(s *ChatService) {
+		s.pinRepo = store
+		s.maxPins = maxPerChat
+		if s.maxPins <= 0 {
+			s.maxPins = defaultMaxPins
+		}
+	}
+}
+
+// WithMentions включает уведомления об упоминаниях (GET /me/mentions)
+// Упоминания в тексте разбираются и без хранилища, но уведомления не создаются,
+// а методы уведомлений возвращают ErrMentionsDisabled
+func WithMentions(store repository.MentionStore) Option {
+	return func(s *ChatService) {
+		s.mentionRepo = store
+	}
+}
+
+// WithLinkPreviews включает превью ссылок: SendMessage ставит ссылки в очередь, а при чтении
+// сообщений к ним добавляются превью. Загружает превью фоновый обработчик (internal/unfurl)
+// cacheTTL - сколько загруженное превью считается свежим, потом адрес загружается заново
+// (<= 0 - defaultPreviewCacheTTL)
+func WithLinkPreviews(store repository.LinkPreviewStore, cacheTTL time.Duration) Option {
+	return func(s *ChatService) {
+		s.previewRepo = store
+		s.previewTTL = cacheTTL
+		if s.previewTTL <= 0 {
+			s.previewTTL = defaultPreviewCacheTTL
+		}
+	}
+}
+
+// WithPolls включает опросы: сообщения с WithPoll и голосование (POST /chats/{id}/messages/{message_id}/votes)
+// Без хранилища голосов отправка опроса и голосование возвращают ErrPollsDisabled
+func WithPolls(store repository.PollStore) Option {
+	return func(s *ChatService) {
+		s.pollRepo = store
+	}
+}
+
+// MessageOption задает необязательные параметры отправляемого сообщения
+type MessageOption func(*messageOptions)
+
+// messageOptions - параметры SendMessage
+// Указатели отличают "не задано" от нулевого значения, которое считается ошибкой
+type messageOptions struct {
+	ttl       *time.Duration
+	expiresAt *time.Time
+	scheduled *models.ScheduledClaim
+	format    string
+	poll      *models.Poll
+	forward   *models.Forward
+}
+
+// WithTTL делает сообщение исчезающим: оно пропадет через ttl после отправки
+func WithTTL(ttl time.Duration) MessageOption {
+	return func(o *messageOptions) {
+		o.ttl = &ttl
+	}
+}
+
+// WithExpiresAt делает сообщение исчезающим: оно пропадет в момент at
+// Одновременно с WithTTL не используется
+func WithExpiresAt(at time.Time) MessageOption {
+	return func(o *messageOptions) {
+		o.expiresAt = &at
+	}
+}
+
+// WithFormat задает формат текста: models.MessageFormatPlain (по умолчанию)
+// или models.MessageFormatMarkdown - тогда сервер строит HTML и элементы разметки
+func WithFormat(format string) MessageOption {
+	return func(o *messageOptions) {
+		o.format = format
+	}
+}
+
+// WithPoll делает сообщение опросом, текст сообщения - вопрос
+// Используются варианты (Options[].Text), Multiple, Anonymous и ClosesAt; итоги игнорируются
+func WithPoll(poll models.Poll) MessageOption {
+	return func(o *messageOptions) {
+		o.poll = &poll
+	}
+}
+
+// withForward отправляет сообщение как пересланное из forward (см. ForwardMessage)
+func withForward(forward models.Forward) MessageOption {
+	return func(o *messageOptions) {
+		o.forward = &forward
+	}
+}
+
+// WithScheduledClaim отправляет сообщение как доставку запланированного сообщения:
+// хранилище в той же транзакции отметит его отправленным, а если захват уже не
+// принадлежит обработчику - не сохранит сообщение (repository.ErrClaimLost)
+func WithScheduledClaim(claim models.ScheduledClaim) MessageOption {
+	return func(o *messageOptions) {
+		o.scheduled = &claim
+	}
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"go-chat-app/internal/auth"
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultMaxPins - предел закрепленных сообщений в чате по умолчанию
+const defaultMaxPins = 50
+
+// Ошибки закрепленных сообщений
+var (
+	ErrMessageNotFound = errors.New("сообщение не найдено")
+	ErrAlreadyPinned   = errors.New("сообщение уже закреплено")
+	ErrPinLimit        = errors.New("в чате уже максимум закрепленных сообщений: открепите одно из них")
+	ErrNotPinned       = errors.New("сообщение не закреплено")
+	ErrPinsDisabled    = errors.New("закрепленные сообщения не настроены")
+)
+
+// PinMessage закрепляет сообщение чата
+// В чат от имени пользователя запроса отправляется служебное сообщение о закреплении
+// (Kind = models.MessageKindPin): оно сохраняется вместе с закреплением и приходит
+// подписчикам событием message.created
+func (s *ChatService) PinMessage(ctx context.Context, chatID, messageID uint) (*models.Pin, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.PinMessage", trace.WithAttributes(
+		attribute.Int("chat.id", int(chatID)),
+		attribute.Int("message.id", int(messageID)),
+	))
+	defer span.End()
+
+	if s.pinRepo == nil {
+		return nil, ErrPinsDisabled
+	}
+
+	// 1. Проверяем что чат существует: иначе ErrNotFound хранилища был бы неоднозначен
+	if _, err := s.chatRepo.GetByID(ctx, chatID); err != nil {
+		return nil, chatLookupError(span, err)
+	}
+
+	// 2. Закрепляем вместе со служебным сообщением
+	userID, _ := auth.UserID(ctx)
+	pin := &models.Pin{ChatID: chatID, MessageID: messageID, PinnedBy: userID}
+	notice := &models.Message{
+		ChatID:   chatID,
+		AuthorID: userID,
+		Kind:     models.MessageKindPin,
+		Text:     fmt.Sprintf("Сообщение #%d закреплено", messageID),
+	}
+	if err := s.pinRepo.Pin(ctx, pin, s.maxPins, notice); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			return nil, ErrMessageNotFound
+		case errors.Is(err, repository.ErrAlreadyPinned):
+			return nil, ErrAlreadyPinned
+		case errors.Is(err, repository.ErrPinLimit):
+			return nil, ErrPinLimit
+		default:
+			return nil, recordError(span, err)
+		}
+	}
+	slog.InfoContext(ctx, "сообщение закреплено",
+		slog.Uint64("chat_id", uint64(chatID)),
+		slog.Uint64("message_id", uint64(messageID)),
+	)
+
+	// 3. Сообщаем подписчикам чата
+	s.publish(ctx, Event{Type: EventMessageCreated, ChatID: chatID, Message: notice})
+	return pin, nil
+}
+
+// UnpinMessage открепляет сообщение чата
+func (s *ChatService) UnpinMessage(ctx context.Context, chatID, messageID uint) error {
+	ctx, span := tracer.Start(ctx, "ChatService.UnpinMessage", trace.WithAttributes(
+		attribute.Int("chat.id", int(chatID)),
+		attribute.Int("message.id", int(messageID)),
+	))
+	defer span.End()
+
+	if s.pinRepo == nil {
+		return ErrPinsDisabled
+	}
+	if _, err := s.chatRepo.GetByID(ctx, chatID); err != nil {
+		return chatLookupError(span, err)
+	}
+	if err := s.pinRepo.Unpin(ctx, chatID, messageID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrNotPinned
+		}
+		return recordError(span, err)
+	}
+	slog.InfoContext(ctx, "сообщение откреплено",
+		slog.Uint64("chat_id", uint64(chatID)),
+		slog.Uint64("message_id", uint64(messageID)),
+	)
+	return nil
+}
+
+// ListPins возвращает закрепленные сообщения чата (с превью ссылок и итогами опросов), последние закрепленные первыми
+// Без хранилища закрепленных сообщений - пустой список
+func (s *ChatService) ListPins(ctx context.Context, chatID uint) ([]models.Pin, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.ListPins", trace.WithAttributes(attribute.Int("chat.id", int(chatID))))
+	defer span.End()
+
+	if _, err := s.chatRepo.GetByID(ctx, chatID); err != nil {
+		return nil, chatLookupError(span, err)
+	}
+	pins, err := s.pins(ctx, span, chatID)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]*models.Message, 0, len(pins))
+	for _, pin := range pins {
+		if pin.Message != nil {
+			messages = append(messages, pin.Message)
+		}
+	}
+	if err := s.decorate(ctx, messages); err != nil {
+		return nil, recordError(span, err)
+	}
+	return pins, nil
+}
+
+// PinnedMessageIDs возвращает ID закрепленных сообщений чата в порядке ListPins
+// Существование чата не проверяется: вызывается после GetChatWithMessages
+func (s *ChatService) PinnedMessageIDs(ctx context.Context, chatID uint) ([]uint, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.PinnedMessageIDs", trace.WithAttributes(attribute.Int("chat.id", int(chatID))))
+	defer span.End()
+
+	pins, err := s.pins(ctx, span, chatID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(pins))
+	for i, pin := range pins {
+		ids[i] = pin.MessageID
+	}
+	return ids, nil
+}
+
+// pins читает закрепленные сообщения из хранилища (пустой список, если оно не настроено)
+func (s *ChatService) pins(ctx context.Context, span trace.Span, chatID uint) ([]models.Pin, error) {
+	if s.pinRepo == nil {
+		return []models.Pin{}, nil
+	}
+	pins, err := s.pinRepo.List(ctx, chatID)
+	if err != nil {
+		return nil, recordError(span, err)
+	}
+	return pins, nil
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go-chat-app/internal/auth"
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Ограничения опроса
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 10
+	maxPollOptionLength = 100 // символов в варианте ответа
+)
+
+// Ошибки опросов
+var (
+	ErrPollsDisabled = errors.New("опросы не настроены")
+	ErrInvalidPoll   = errors.New("некорректный опрос")
+	ErrInvalidVote   = errors.New("некорректный голос")
+	ErrNotPoll       = errors.New("сообщение не является опросом")
+	ErrPollClosed    = errors.New("опрос закрыт")
+)
+
+// newPoll проверяет опрос из WithPoll и возвращает его для сохранения: варианты без
+// итогов и лишних пробелов. Ошибки оборачивают ErrInvalidPoll
+func newPoll(poll models.Poll, now time.Time) (*models.Poll, error) {
+	if len(poll.Options) < minPollOptions || len(poll.Options) > maxPollOptions {
+		return nil, fmt.Errorf("%w: вариантов ответа должно быть от %d до %d", ErrInvalidPoll, minPollOptions, maxPollOptions)
+	}
+	options := make([]models.PollOption, len(poll.Options))
+	for i, option := range poll.Options {
+		text := strings.TrimSpace(option.Text)
+		switch {
+		case text == "":
+			return nil, fmt.Errorf("%w: вариант ответа не может быть пустым", ErrInvalidPoll)
+		case utf8.RuneCountInString(text) > maxPollOptionLength:
+			return nil, fmt.Errorf("%w: вариант ответа должен быть не более %d символов", ErrInvalidPoll, maxPollOptionLength)
+		}
+		for _, prev := range options[:i] {
+			if strings.EqualFold(prev.Text, text) {
+				return nil, fmt.Errorf("%w: варианты ответа повторяются (%q)", ErrInvalidPoll, text)
+			}
+		}
+		options[i] = models.PollOption{Text: text}
+	}
+	stored := &models.Poll{Options: options, Multiple: poll.Multiple, Anonymous: poll.Anonymous}
+	if poll.ClosesAt != nil {
+		if !poll.ClosesAt.After(now) {
+			return nil, fmt.Errorf("%w: closes_at должен быть в будущем", ErrInvalidPoll)
+		}
+		closesAt := poll.ClosesAt.UTC()
+		stored.ClosesAt = &closesAt
+	}
+	return stored, nil
+}
+
+// Vote голосует пользователем запроса в опросе messageID чата chatID за варианты options
+// (номера в poll.options с 0): повторное голосование заменяет прежний выбор
+// Подписчики чата получают новые итоги событием poll.updated
+// Возвращает опрос с итогами и выбором пользователя (MyVotes)
+func (s *ChatService) Vote(ctx context.Context, chatID, messageID uint, options []int) (*models.Poll, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.Vote", trace.WithAttributes(
+		attribute.Int("chat.id", int(chatID)),
+		attribute.Int("message.id", int(messageID)),
+	))
+	defer span.End()
+
+	message, userID, err := s.pollMessage(ctx, chatID, messageID)
+	if err != nil {
+		return nil, recordError(span, err)
+	}
+	poll := message.Poll
+	if poll.ClosesAt != nil && !poll.ClosesAt.After(time.Now()) {
+		return nil, ErrPollClosed
+	}
+	if len(options) == 0 {
+		return nil, fmt.Errorf("%w: выберите хотя бы один вариант", ErrInvalidVote)
+	}
+	if !poll.Multiple && len(options) > 1 {
+		return nil, fmt.Errorf("%w: в опросе можно выбрать только один вариант", ErrInvalidVote)
+	}
+	for i, option := range options {
+		if option < 0 || option >= len(poll.Options) {
+			return nil, fmt.Errorf("%w: варианта %d нет в опросе", ErrInvalidVote, option)
+		}
+		if slices.Contains(options[:i], option) {
+			return nil, fmt.Errorf("%w: вариант %d указан дважды", ErrInvalidVote, option)
+		}
+	}
+
+	if err := s.pollRepo.Vote(ctx, messageID, userID, options, time.Now()); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, recordError(span, err)
+	}
+	return s.publishPoll(ctx, message, userID)
+}
+
+// RetractVote отзывает голоса пользователя запроса в опросе; если он не голосовал - не ошибка
+// Подписчики чата получают новые итоги событием poll.updated
+func (s *ChatService) RetractVote(ctx context.Context, chatID, messageID uint) (*models.Poll, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.RetractVote", trace.WithAttributes(
+		attribute.Int("chat.id", int(chatID)),
+		attribute.Int("message.id", int(messageID)),
+	))
+	defer span.End()
+
+	message, userID, err := s.pollMessage(ctx, chatID, messageID)
+	if err != nil {
+		return nil, recordError(span, err)
+	}
+	if message.Poll.ClosesAt != nil && !message.Poll.ClosesAt.After(time.Now()) {
+		return nil, ErrPollClosed
+	}
+	if err := s.pollRepo.Retract(ctx, messageID, userID); err != nil {
+		return nil, recordError(span, err)
+	}
+	return s.publishPoll(ctx, message, userID)
+}
+
+// pollMessage находит опрос для голосования и пользователя запроса
+func (s *ChatService) pollMessage(ctx context.Context, chatID, messageID uint) (*models.Message, string, error) {
+	if s.pollRepo == nil {
+		return nil, "", ErrPollsDisabled
+	}
+	// Голос принадлежит пользователю: без идентификации голосовать нельзя
+	userID, ok := auth.UserID(ctx)
+	if !ok || userID == "" {
+		return nil, "", ErrNoUser
+	}
+	if _, err := s.chatRepo.GetByID(ctx, chatID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, "", ErrChatNotFound
+		}
+		return nil, "", err
+	}
+	message, err := s.messageRepo.GetByID(ctx, chatID, messageID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, "", ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if message.Poll == nil {
+		return nil, "", ErrNotPoll
+	}
+	return message, userID, nil
+}
+
+// publishPoll считает итоги опроса после голосования, сообщает их подписчикам чата
+// (без выбора конкретного пользователя) и возвращает итоги для пользователя userID
+func (s *ChatService) publishPoll(ctx context.Context, message *models.Message, userID string) (*models.Poll, error) {
+	votes, err := s.pollRepo.Votes(ctx, []uint{message.ID})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	s.publish(ctx, Event{Type: EventPollUpdated, ChatID: message.ChatID, MessageID: message.ID, Poll: tally(message.Poll, votes, "", now)})
+	return tally(message.Poll, votes, userID, now), nil
+}
+
+// attachPolls добавляет к опросам среди сообщений итоги и выбор пользователя запроса
+func (s *ChatService) attachPolls(ctx context.Context, messages []*models.Message) error {
+	var ids []uint
+	for _, m := range messages {
+		if m.Poll != nil {
+			ids = append(ids, m.ID)
+		}
+	}
+	if s.pollRepo == nil || len(ids) == 0 {
+		return nil
+	}
+	votes, err := s.pollRepo.Votes(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byMessage := make(map[uint][]models.PollVote)
+	for _, vote := range votes {
+		byMessage[vote.MessageID] = append(byMessage[vote.MessageID], vote)
+	}
+	userID, _ := auth.UserID(ctx)
+	now := time.Now()
+	for _, m := range messages {
+		if m.Poll != nil {
+			m.Poll = tally(m.Poll, byMessage[m.ID], userID, now)
+		}
+	}
+	return nil
+}
+
+// tally возвращает копию опроса с итогами по его голосам votes
+// userID - чей выбор вернуть в MyVotes (пусто - ничей, как в событии для всех подписчиков)
+// Исходный опрос не меняется: он может быть общим с хранилищем
+func tally(poll *models.Poll, votes []models.PollVote, userID string, now time.Time) *models.Poll {
+	result := *poll
+	result.Options = make([]models.PollOption, len(poll.Options))
+	for i, option := range poll.Options {
+		result.Options[i] = models.PollOption{Text: option.Text}
+	}
+	result.Closed = poll.ClosesAt != nil && !poll.ClosesAt.After(now)
+	result.MyVotes = nil
+
+	voters := make(map[string]bool)
+	for _, vote := range votes {
+		if vote.Option < 0 || vote.Option >= len(result.Options) {
+			continue
+		}
+		option := &result.Options[vote.Option]
+		option.Votes++
+		if !poll.Anonymous {
+			option.Voters = append(option.Voters, vote.UserID)
+		}
+		voters[vote.UserID] = true
+		if userID != "" && vote.UserID == userID {
+			result.MyVotes = append(result.MyVotes, vote.Option)
+		}
+	}
+	result.TotalVoters = len(voters)
+	slices.Sort(result.MyVotes)
+	return &result
+}
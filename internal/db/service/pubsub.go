@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-chat-app/internal/models"
+)
+
+// EventType - тип события чата
+type EventType string
+
+const (
+	// EventMessageCreated - в чат отправлено сообщение
+	EventMessageCreated EventType = "message.created"
+	// EventMessageDeleted - исчезающее сообщение истекло и удалено, клиент убирает его с экрана
+	EventMessageDeleted EventType = "message.deleted"
+	// EventMessagePreview - превью ссылки сообщения загружено (или не загрузится: status = failed)
+	EventMessagePreview EventType = "message.preview"
+	// EventPollUpdated - изменились итоги опроса (кто-то проголосовал или отозвал голос)
+	EventPollUpdated EventType = "poll.updated"
+	// EventChatDeleted - чат удален, после этого события подписка на чат завершается
+	EventChatDeleted EventType = "chat.deleted"
+)
+
+// subscriberBuffer - сколько событий может ждать медленный подписчик
+// Если буфер переполнен, подписка закрывается: клиент переподключится
+// и дочитает пропущенное через GET /chats/{id}
+const subscriberBuffer = 64
+
+// Event - событие чата для потоковых подписчиков
+type Event struct {
+	Type       EventType           `json:"type"`
+	ChatID     uint                `json:"chat_id"`
+	Message    *models.Message     `json:"message,omitempty"`    // для message.created
+	MessageID  uint                `json:"message_id,omitempty"` // для message.deleted, message.preview и poll.updated (текст сообщения не передается)
+	Preview    *models.LinkPreview `json:"preview,omitempty"`    // для message.preview
+	Poll       *models.Poll        `json:"poll,omitempty"`       // для poll.updated: итоги без выбора конкретного пользователя
+	OccurredAt time.Time           `json:"occurred_at"`
+}
+
+// PubSub доставляет события чатов подписчикам
+// Реализации: LocalPubSub (в пределах процесса) и postgres.PubSub (LISTEN/NOTIFY,
+// события доходят до подписчиков на всех инстансах)
+type PubSub interface {
+	// Publish отправляет событие всем подписчикам чата
+	Publish(ctx context.Context, event Event) error
+	// Subscribe подписывается на события чата
+	// Канал закрывается после cancel, отмены ctx или переполнения буфера подписчика
+	Subscribe(ctx context.Context, chatID uint) (events <-chan Event, cancel func())
+	// Close закрывает все подписки (при остановке сервера потоки событий завершаются)
+	Close()
+}
+
+// LocalPubSub - PubSub в памяти процесса
+// Подходит для одного инстанса; также используется postgres.PubSub
+// для раздачи полученных уведомлений локальным подписчикам
+type LocalPubSub struct {
+	mu     sync.Mutex
+	subs   map[uint]map[*subscription]struct{}
+	closed bool
+}
+
+// subscription - один подписчик
+type subscription struct {
+	ch   chan Event
+	once sync.Once
+}
+
+// close закрывает канал подписчика ровно один раз
+func (s *subscription) close() {
+	s.once.Do(func() { close(s.ch) })
+}
+
+// NewLocalPubSub создает PubSub в памяти процесса
+func NewLocalPubSub() *LocalPubSub {
+	return &LocalPubSub{subs: make(map[uint]map[*subscription]struct{})}
+}
+
+// Publish раздает событие подписчикам чата, не блокируясь на медленных
+func (p *LocalPubSub) Publish(ctx context.Context, event Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for sub := range p.subs[event.ChatID] {
+		select {
+		case sub.ch <- event:
+		default:
+			// Подписчик не успевает читать - отключаем его, а не тормозим остальных
+			p.removeLocked(event.ChatID, sub)
+		}
+	}
+	return nil
+}
+
+// Subscribe подписывается на события чата
+func (p *LocalPubSub) Subscribe(ctx context.Context, chatID uint) (<-chan Event, func()) {
+	sub := &subscription{ch: make(chan Event, subscriberBuffer)}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		sub.close()
+		return sub.ch, func() {}
+	}
+	if p.subs[chatID] == nil {
+		p.subs[chatID] = make(map[*subscription]struct{})
+	}
+	p.subs[chatID][sub] = struct{}{}
+	p.mu.Unlock()
+
+	stop := context.AfterFunc(ctx, func() { p.unsubscribe(chatID, sub) })
+	cancel := func() {
+		stop()
+		p.unsubscribe(chatID, sub)
+	}
+	return sub.ch, cancel
+}
+
+// Close закрывает все подписки, новые подписки сразу получают закрытый канал
+func (p *LocalPubSub) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for chatID, subs := range p.subs {
+		for sub := range subs {
+			p.removeLocked(chatID, sub)
+		}
+	}
+	p.closed = true
+}
+
+// unsubscribe удаляет подписчика и закрывает его канал
+func (p *LocalPubSub) unsubscribe(chatID uint, sub *subscription) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removeLocked(chatID, sub)
+}
+
+// removeLocked удаляет подписчика, вызывается под p.mu
+func (p *LocalPubSub) removeLocked(chatID uint, sub *subscription) {
+	delete(p.subs[chatID], sub)
+	if len(p.subs[chatID]) == 0 {
+		delete(p.subs, chatID)
+	}
+	sub.close()
+}
+
+// Subscribers возвращает число подписчиков чата (для тестов и метрик)
+func (p *LocalPubSub) Subscribers(chatID uint) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.subs[chatID])
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// receive ждет событие из канала не дольше секунды
+func receive(t *testing.T, events <-chan Event) (Event, bool) {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		return event, ok
+	case <-time.After(time.Second):
+		t.Fatal("Событие не пришло за секунду")
+		return Event{}, false
+	}
+}
+
+// TestLocalPubSub проверяет доставку по чатам, отписку и закрытие
+func TestLocalPubSub(t *testing.T) {
+	p := NewLocalPubSub()
+	ctx := context.Background()
+
+	chat1, cancel1 := p.Subscribe(ctx, 1)
+	chat2, cancel2 := p.Subscribe(ctx, 2)
+	defer cancel2()
+
+	p.Publish(ctx, Event{Type: EventMessageCreated, ChatID: 1})
+	if event, ok := receive(t, chat1); !ok || event.ChatID != 1 {
+		t.Errorf("Подписчик чата 1 получил %+v", event)
+	}
+	select {
+	case event := <-chat2:
+		t.Errorf("Подписчик чата 2 не должен получать события чата 1: %+v", event)
+	default:
+	}
+
+	cancel1()
+	if _, ok := <-chat1; ok {
+		t.Error("После cancel канал должен быть закрыт")
+	}
+	if n := p.Subscribers(1); n != 0 {
+		t.Errorf("После cancel осталось подписчиков: %d", n)
+	}
+
+	// Отмена контекста тоже завершает подписку
+	subCtx, cancelCtx := context.WithCancel(ctx)
+	chat3, _ := p.Subscribe(subCtx, 3)
+	cancelCtx()
+	if _, ok := receive(t, chat3); ok {
+		t.Error("После отмены контекста канал должен быть закрыт")
+	}
+
+	p.Close()
+	if _, ok := receive(t, chat2); ok {
+		t.Error("После Close канал должен быть закрыт")
+	}
+	if _, ok := receive(t, mustSubscribe(p, 4)); ok {
+		t.Error("Подписка после Close должна быть сразу закрыта")
+	}
+}
+
+// mustSubscribe подписывается на чат без отмены (для проверки закрытого PubSub)
+func mustSubscribe(p *LocalPubSub, chatID uint) <-chan Event {
+	events, _ := p.Subscribe(context.Background(), chatID)
+	return events
+}
+
+// TestLocalPubSubSlowSubscriber проверяет, что переполненный подписчик отключается
+func TestLocalPubSubSlowSubscriber(t *testing.T) {
+	p := NewLocalPubSub()
+	ctx := context.Background()
+	events, cancel := p.Subscribe(ctx, 1)
+	defer cancel()
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		p.Publish(ctx, Event{Type: EventMessageCreated, ChatID: 1})
+	}
+
+	received := 0
+	for range events {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("Ожидалось %d событий до отключения, получено %d", subscriberBuffer, received)
+	}
+}
+
+// TestChatServiceEvents проверяет события отправки сообщения и удаления чата
+func TestChatServiceEvents(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+
+	if _, _, err := s.Subscribe(ctx, 999); err != ErrChatNotFound {
+		t.Errorf("Ожидалась ErrChatNotFound, получено %v", err)
+	}
+
+	chat, _ := s.CreateChat(ctx, "чат")
+	events, cancel, err := s.Subscribe(ctx, chat.ID)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer cancel()
+
+	msg, _ := s.SendMessage(ctx, chat.ID, "привет")
+	event, _ := receive(t, events)
+	if event.Type != EventMessageCreated || event.Message == nil || event.Message.ID != msg.ID {
+		t.Errorf("Ожидалось message.created с сообщением %d, получено %+v", msg.ID, event)
+	}
+	if event.OccurredAt.IsZero() {
+		t.Error("Не заполнено время события")
+	}
+
+	s.DeleteChat(ctx, chat.ID)
+	if event, _ := receive(t, events); event.Type != EventChatDeleted || event.ChatID != chat.ID {
+		t.Errorf("Ожидалось chat.deleted, получено %+v", event)
+	}
+}
+
+// TestEphemeralMessages проверяет исчезающие сообщения: ограничения времени жизни,
+// скрытие после истечения и событие message.deleted после очистки
+func TestEphemeralMessages(t *testing.T) {
+	s := newTestService()
+	ctx := context.Background()
+	chat, _ := s.CreateChat(ctx, "чат")
+
+	for name, opts := range map[string][]MessageOption{
+		"срок в прошлом": {WithExpiresAt(time.Now().Add(-time.Second))},
+		"больше 30 дней": {WithTTL(maxMessageTTL + time.Second)},
+		"меньше секунды": {WithTTL(time.Millisecond)},
+		"оба параметра":  {WithTTL(time.Minute), WithExpiresAt(time.Now().Add(time.Minute))},
+	} {
+		if _, err := s.SendMessage(ctx, chat.ID, "x", opts...); err == nil || !strings.Contains(err.Error(), "не более") {
+			t.Errorf("%s: ожидалась ошибка проверки, получено %v", name, err)
+		}
+	}
+
+	long, err := s.SendMessage(ctx, chat.ID, "на час", WithTTL(time.Hour))
+	if err != nil || long.ExpiresAt == nil || time.Until(*long.ExpiresAt) <= 59*time.Minute {
+		t.Fatalf("Неверное время исчезновения: %+v, %v", long, err)
+	}
+	secret, err := s.SendMessage(ctx, chat.ID, "пароль", WithExpiresAt(time.Now().Add(50*time.Millisecond)))
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	events, cancel, _ := s.Subscribe(ctx, chat.ID)
+	defer cancel()
+
+	time.Sleep(60 * time.Millisecond)
+	_, messages, _ := s.GetChatWithMessages(ctx, chat.ID, 10)
+	if len(messages) != 1 || messages[0].ID != long.ID {
+		t.Errorf("Истекшее сообщение должно скрываться сразу: %+v", messages)
+	}
+
+	if n, err := s.DeleteExpiredMessages(ctx, 10); err != nil || n != 1 {
+		t.Fatalf("DeleteExpiredMessages: удалено %d, err=%v", n, err)
+	}
+	event, _ := receive(t, events)
+	if event.Type != EventMessageDeleted || event.MessageID != secret.ID || event.Message != nil {
+		t.Errorf("Ожидалось message.deleted для сообщения %d, получено %+v", secret.ID, event)
+	}
+}
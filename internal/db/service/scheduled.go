@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go-chat-app/internal/auth"
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxScheduleAhead - насколько вперед можно запланировать сообщение (1 год)
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+// Ошибки запланированных сообщений
+var (
+	ErrScheduledNotFound   = errors.New("запланированное сообщение не найдено")
+	ErrScheduledNotPending = errors.New("запланированное сообщение уже отправляется, отправлено или отменено")
+	ErrSchedulingDisabled  = errors.New("запланированные сообщения не настроены")
+)
+
+// ScheduledUpdate - изменения запланированного сообщения (PATCH), nil означает "не менять"
+type ScheduledUpdate struct {
+	Text   *string
+	SendAt *time.Time
+}
+
+// ScheduleMessage планирует отправку сообщения в чат в момент sendAt
+// Автор - пользователь запроса: от его имени сообщение и будет отправлено
+func (s *ChatService) ScheduleMessage(ctx context.Context, chatID uint, text string, sendAt time.Time) (*models.ScheduledMessage, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.ScheduleMessage", trace.WithAttributes(attribute.Int("chat.id", int(chatID))))
+	defer span.End()
+
+	if s.scheduledRepo == nil {
+		return nil, ErrSchedulingDisabled
+	}
+
+	// 1. Проверяем что чат существует
+	if _, err := s.chatRepo.GetByID(ctx, chatID); err != nil {
+		return nil, chatLookupError(span, err)
+	}
+
+	// 2. Текст и время - по тем же правилам, что и при изменении
+	trimmedText, err := scheduledText(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSendAt(sendAt, time.Now()); err != nil {
+		return nil, err
+	}
+
+	// 3. Сохраняем
+	authorID, _ := auth.UserID(ctx)
+	message := &models.ScheduledMessage{ChatID: chatID, AuthorID: authorID, Text: trimmedText, SendAt: sendAt}
+	if err := s.scheduledRepo.Create(ctx, message); err != nil {
+		return nil, recordError(span, err)
+	}
+	slog.InfoContext(ctx, "сообщение запланировано",
+		slog.Uint64("chat_id", uint64(chatID)),
+		slog.Uint64("scheduled_id", uint64(message.ID)),
+		slog.Time("send_at", message.SendAt),
+	)
+	return message, nil
+}
+
+// ListScheduled возвращает запланированные сообщения чата в порядке отправки
+// status - фильтр по статусу ("" - все), limit - по умолчанию 50, максимум 100
+func (s *ChatService) ListScheduled(ctx context.Context, chatID uint, status string, limit int) ([]models.ScheduledMessage, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.ListScheduled", trace.WithAttributes(attribute.Int("chat.id", int(chatID))))
+	defer span.End()
+
+	if s.scheduledRepo == nil {
+		return nil, ErrSchedulingDisabled
+	}
+	if _, err := s.chatRepo.GetByID(ctx, chatID); err != nil {
+		return nil, chatLookupError(span, err)
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	messages, err := s.scheduledRepo.ListByChat(ctx, chatID, status, limit)
+	if err != nil {
+		return nil, recordError(span, err)
+	}
+	return messages, nil
+}
+
+// UpdateScheduled изменяет текст и/или время отправки сообщения, которое еще не отправлялось
+func (s *ChatService) UpdateScheduled(ctx context.Context, chatID, id uint, update ScheduledUpdate) (*models.ScheduledMessage, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.UpdateScheduled", trace.WithAttributes(attribute.Int("chat.id", int(chatID))))
+	defer span.End()
+
+	// 1. Проверяем новые значения
+	if update.Text != nil {
+		trimmedText, err := scheduledText(*update.Text)
+		if err != nil {
+			return nil, err
+		}
+		update.Text = &trimmedText
+	}
+	if update.SendAt != nil {
+		if err := checkSendAt(*update.SendAt, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. Получаем сообщение этого чата
+	message, err := s.getScheduled(ctx, span, chatID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Сохраняем: хранилище изменит сообщение, только если его еще не захватил обработчик
+	if update.Text != nil {
+		message.Text = *update.Text
+	}
+	if update.SendAt != nil {
+		message.SendAt = *update.SendAt
+	}
+	if err := s.scheduledRepo.Update(ctx, message); err != nil {
+		return nil, scheduledError(span, err)
+	}
+	return message, nil
+}
+
+// CancelScheduled отменяет сообщение, которое еще не отправлялось
+// Запись остается со статусом canceled
+func (s *ChatService) CancelScheduled(ctx context.Context, chatID, id uint) (*models.ScheduledMessage, error) {
+	ctx, span := tracer.Start(ctx, "ChatService.CancelScheduled", trace.WithAttributes(attribute.Int("chat.id", int(chatID))))
+	defer span.End()
+
+	if _, err := s.getScheduled(ctx, span, chatID, id); err != nil {
+		return nil, err
+	}
+	message, err := s.scheduledRepo.Cancel(ctx, id)
+	if err != nil {
+		return nil, scheduledError(span, err)
+	}
+	slog.InfoContext(ctx, "запланированное сообщение отменено",
+		slog.Uint64("chat_id", uint64(chatID)),
+		slog.Uint64("scheduled_id", uint64(id)),
+	)
+	return message, nil
+}
+
+// getScheduled возвращает запланированное сообщение чата
+// Сообщение другого чата не отличается от несуществующего
+func (s *ChatService) getScheduled(ctx context.Context, span trace.Span, chatID, id uint) (*models.ScheduledMessage, error) {
+	if s.scheduledRepo == nil {
+		return nil, ErrSchedulingDisabled
+	}
+	if _, err := s.chatRepo.GetByID(ctx, chatID); err != nil {
+		return nil, chatLookupError(span, err)
+	}
+	message, err := s.scheduledRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, scheduledError(span, err)
+	}
+	if message.ChatID != chatID {
+		return nil, ErrScheduledNotFound
+	}
+	return message, nil
+}
+
+// scheduledText проверяет текст так же, как SendMessage: при отправке он уже не должен упасть
+func scheduledText(text string) (string, error) {
+	trimmedText := strings.TrimSpace(text)
+	if len(trimmedText) == 0 {
+		return "", errors.New("текст не может быть пустым")
+	}
+	if len(trimmedText) > 5000 {
+		return "", errors.New("объем текста должен быть не более 5000 символов")
+	}
+	return trimmedText, nil
+}
+
+// checkSendAt проверяет время отправки: в будущем и не дальше maxScheduleAhead
+func checkSendAt(sendAt, now time.Time) error {
+	if !sendAt.After(now) || sendAt.Sub(now) > maxScheduleAhead {
+		return fmt.Errorf("send_at должен быть в будущем и не более чем через %d дней", int(maxScheduleAhead.Hours()/24))
+	}
+	return nil
+}
+
+// scheduledError превращает ошибки хранилища в ошибки сервиса
+func scheduledError(span trace.Span, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrScheduledNotFound
+	case errors.Is(err, repository.ErrNotPending):
+		return ErrScheduledNotPending
+	default:
+		return recordError(span, err)
+	}
+}
@@ -0,0 +1,60 @@
+// Package sqlite - подключение к SQLite для небольших инсталляций в одном бинарнике
+// Используется чистый Go драйвер (без cgo), поэтому бинарник остается статическим
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"go-chat-app/internal/config"
+	"go-chat-app/internal/tracing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// InitDB открывает (или создает) файл базы SQLite
+func InitDB(cfg config.DBConfig) (*gorm.DB, error) {
+	// Параметры подключения:
+	// foreign_keys(1) - SQLite по умолчанию не проверяет внешние ключи и не выполняет
+	//   ON DELETE CASCADE, включаем для поведения как в PostgreSQL
+	// journal_mode(WAL) - чтение не блокируется записью
+	// busy_timeout(5000) - ждать блокировку до 5 секунд вместо немедленной ошибки
+	// _txlock=immediate - транзакция сразу берет блокировку на запись,
+	//   это исключает взаимные блокировки при одновременных транзакциях
+	dsn := fmt.Sprintf(
+		"file:%s?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_txlock=immediate",
+		cfg.SQLitePath,
+	)
+
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть базу SQLite: %w", err)
+	}
+
+	// Подключаем плагин трассировки: каждый SQL запрос получит свой спан
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		return nil, fmt.Errorf("не удалось подключить трассировку к GORM: %w", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить sql.DB: %w", err)
+	}
+
+	// Пул соединений: SQLite - это файл, большой пул не нужен, но настройки общие
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
+	defer cancel()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("не удалось проверить связь с базой SQLite: %w", err)
+	}
+
+	log.Printf("База данных SQLite подключена: %s", cfg.SQLitePath)
+	return db, nil
+}
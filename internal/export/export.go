@@ -0,0 +1,111 @@
+// Package export - выгрузка истории чата в JSON, CSV, HTML и простой текст
+//
+// Writer получает сообщения пачками и сразу пишет их в поток: размер выгрузки
+// не ограничен памятью сервера. Время везде выводится в UTC
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go-chat-app/internal/models"
+)
+
+// ErrUnknownFormat - запрошен неподдерживаемый формат
+var ErrUnknownFormat = errors.New("неизвестный формат выгрузки (json, csv, html, txt)")
+
+// Formats - поддерживаемые форматы
+var Formats = []string{"json", "csv", "html", "txt"}
+
+// Meta - сведения о выгрузке, которые пишутся вместе с сообщениями
+type Meta struct {
+	Chat       models.Chat
+	ExportedAt time.Time
+	From       time.Time // нулевое - с начала истории
+	To         time.Time // нулевое - до конца истории
+}
+
+// Writer пишет выгрузку: Begin, затем Write для каждой пачки сообщений, затем End
+type Writer interface {
+	Begin(meta Meta) error
+	Write(messages []models.Message) error
+	End() error
+}
+
+// New создает Writer формата format
+func New(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case "json":
+		return &jsonWriter{w: w}, nil
+	case "csv":
+		return newCSVWriter(w), nil
+	case "html":
+		return &htmlWriter{w: w}, nil
+	case "txt":
+		return &textWriter{w: w}, nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// ContentType возвращает Content-Type формата
+func ContentType(format string) string {
+	switch format {
+	case "json":
+		return "application/json"
+	case "csv":
+		return "text/csv; charset=utf-8"
+	case "html":
+		return "text/html; charset=utf-8"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// FileName - имя файла для Content-Disposition: chat-12-20260119.json
+// Название чата в имя не попадает: в нем могут быть символы, недопустимые в именах файлов
+func FileName(meta Meta, format string) string {
+	return fmt.Sprintf("chat-%d-%s.%s", meta.Chat.ID, meta.ExportedAt.UTC().Format("20060102"), format)
+}
+
+// timestamp - время сообщения для JSON и CSV
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// humanTime - время для текста и HTML
+func humanTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05 UTC")
+}
+
+// period - описание диапазона выгрузки для текста и HTML
+func period(meta Meta) string {
+	from, to := "начала истории", "конца истории"
+	if !meta.From.IsZero() {
+		from = humanTime(meta.From)
+	}
+	if !meta.To.IsZero() {
+		to = humanTime(meta.To)
+	}
+	return fmt.Sprintf("с %s до %s", from, to)
+}
+
+// author - автор сообщения для текста и HTML
+func author(m models.Message) string {
+	if m.AuthorID == "" {
+		return "аноним"
+	}
+	return m.AuthorID
+}
+
+// forwarded - откуда переслано сообщение, для текста и HTML; "" - сообщение не пересланное
+func forwarded(m models.Message) string {
+	f := m.ForwardedFrom
+	if f == nil {
+		return ""
+	}
+	return fmt.Sprintf("переслано из %q (#%d), автор %s, %s",
+		f.ChatTitle, f.ChatID, author(models.Message{AuthorID: f.AuthorID}), humanTime(f.SentAt))
+}
@@ -0,0 +1,160 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"go-chat-app/internal/models"
+)
+
+// write выгружает две пачки сообщений в формате format
+func write(t *testing.T, format string) string {
+	t.Helper()
+	at := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	meta := Meta{
+		Chat:       models.Chat{ID: 7, Title: `Общий <script>alert(1)</script>`, CreatedAt: at},
+		ExportedAt: at.Add(time.Hour),
+		From:       at,
+	}
+	var buf bytes.Buffer
+	w, err := New(format, &buf)
+	if err != nil {
+		t.Fatalf("New(%q): %v", format, err)
+	}
+	if err := w.Begin(meta); err != nil {
+		t.Fatal(err)
+	}
+	batches := [][]models.Message{
+		{{ID: 1, ChatID: 7, Text: "привет", AuthorID: "alice", CreatedAt: at.Add(time.Minute)}},
+		{
+			{ID: 2, ChatID: 7, Text: "=HYPERLINK(\"http://evil\")", CreatedAt: at.Add(2 * time.Minute)},
+			{ID: 3, ChatID: 7, Text: "строка 1\nстрока 2 <b>", AuthorID: "bob", CreatedAt: at.Add(3 * time.Minute),
+				ForwardedFrom: &models.Forward{ChatID: 2, ChatTitle: "Команда", MessageID: 5, AuthorID: "alice", SentAt: at}},
+		},
+	}
+	for _, batch := range batches {
+		if err := w.Write(batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.End(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// TestJSON проверяет, что потоковая выгрузка собирается в корректный JSON
+func TestJSON(t *testing.T) {
+	var doc struct {
+		Chat         models.Chat `json:"chat"`
+		ExportedAt   time.Time   `json:"exported_at"`
+		From         time.Time   `json:"from"`
+		To           *time.Time  `json:"to"`
+		MessageCount int         `json:"message_count"`
+		Messages     []struct {
+			ID            uint            `json:"id"`
+			AuthorID      string          `json:"author_id"`
+			Text          string          `json:"text"`
+			CreatedAt     time.Time       `json:"created_at"`
+			ForwardedFrom *models.Forward `json:"forwarded_from"`
+		} `json:"messages"`
+	}
+	out := write(t, "json")
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("Выгрузка - некорректный JSON: %v\n%s", err, out)
+	}
+	if doc.Chat.ID != 7 || doc.From.IsZero() || doc.To != nil || doc.MessageCount != 3 || len(doc.Messages) != 3 {
+		t.Errorf("Неверная выгрузка: %+v", doc)
+	}
+	if doc.Messages[0].AuthorID != "alice" || doc.Messages[2].Text != "строка 1\nстрока 2 <b>" {
+		t.Errorf("Неверные сообщения: %+v", doc.Messages)
+	}
+	if doc.Messages[0].ForwardedFrom != nil || doc.Messages[2].ForwardedFrom == nil || doc.Messages[2].ForwardedFrom.MessageID != 5 {
+		t.Errorf("Неверная ссылка на оригинал: %+v", doc.Messages)
+	}
+}
+
+// TestCSV проверяет строки таблицы и защиту от формул
+func TestCSV(t *testing.T) {
+	rows, err := csv.NewReader(strings.NewReader(write(t, "csv"))).ReadAll()
+	if err != nil {
+		t.Fatalf("Выгрузка - некорректный CSV: %v", err)
+	}
+	if len(rows) != 4 || rows[0][2] != "message_id" {
+		t.Fatalf("Ожидались заголовок и 3 строки: %v", rows)
+	}
+	if rows[1][4] != "alice" || rows[1][3] != "2026-01-02T10:01:00Z" {
+		t.Errorf("Неверная строка: %v", rows[1])
+	}
+	if !strings.HasPrefix(rows[2][5], "'=") {
+		t.Errorf("Формула должна экранироваться: %q", rows[2][5])
+	}
+	if rows[3][5] != "строка 1\nстрока 2 <b>" {
+		t.Errorf("Многострочный текст искажен: %q", rows[3][5])
+	}
+	if rows[1][6] != "" || rows[3][6] != "2" || rows[3][7] != "5" {
+		t.Errorf("Неверные колонки оригинала: %v, %v", rows[1], rows[3])
+	}
+}
+
+// TestHTMLEscapes проверяет экранирование данных пользователя в HTML
+func TestHTMLEscapes(t *testing.T) {
+	out := write(t, "html")
+	if strings.Contains(out, "<script>") || strings.Contains(out, "2 <b>") {
+		t.Errorf("Данные пользователя должны экранироваться:\n%s", out)
+	}
+	for _, want := range []string{"&lt;script&gt;", "alice", "аноним", "Сообщений: 3", "</html>",
+		`<div class="forward">переслано из &#34;Команда&#34; (#2), автор alice`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("В HTML нет %q", want)
+		}
+	}
+}
+
+// TestText проверяет текстовую выгрузку и отступы многострочных сообщений
+func TestText(t *testing.T) {
+	out := write(t, "txt")
+	for _, want := range []string{
+		"Чат: Общий",
+		"Период: с 2026-01-02 10:00:00 UTC до конца истории",
+		"[2026-01-02 10:01:00 UTC] alice: привет",
+		"bob: [переслано из \"Команда\" (#2), автор alice, 2026-01-02 10:00:00 UTC] строка 1\n    строка 2",
+		"Сообщений: 3",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("В тексте нет %q:\n%s", want, out)
+		}
+	}
+}
+
+// TestUnknownFormat проверяет ошибку для неподдерживаемого формата
+func TestUnknownFormat(t *testing.T) {
+	if _, err := New("xml", &bytes.Buffer{}); err != ErrUnknownFormat {
+		t.Errorf("Ожидалась ErrUnknownFormat, получено %v", err)
+	}
+}
+
+// TestHTMLMarkdown проверяет, что markdown сообщения выгружаются готовым HTML, а plain - экранированным текстом
+func TestHTMLMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := New("html", &buf)
+	at := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	if err := w.Begin(Meta{Chat: models.Chat{ID: 7, Title: "чат", CreatedAt: at}, ExportedAt: at}); err != nil {
+		t.Fatal(err)
+	}
+	err := w.Write([]models.Message{
+		{ID: 1, Text: "**важно**", Format: models.MessageFormatMarkdown, HTML: "<strong>важно</strong>", CreatedAt: at},
+		{ID: 2, Text: "<strong>не разметка</strong>", Format: models.MessageFormatPlain, CreatedAt: at},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "<strong>важно</strong>") || !strings.Contains(out, "&lt;strong&gt;не разметка") {
+		t.Errorf("Неверная выгрузка markdown:\n%s", out)
+	}
+}
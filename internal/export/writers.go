@@ -0,0 +1,233 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+
+	"go-chat-app/internal/models"
+)
+
+// jsonWriter - один JSON объект: {"chat": ..., "exported_at": ..., "messages": [...], "message_count": N}
+// Массив сообщений пишется по мере поступления, объект целиком в памяти не собирается
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+// jsonMessage - сообщение в выгрузке JSON (те же поля, что в API)
+type jsonMessage struct {
+	ID            uint            `json:"id"`
+	AuthorID      string          `json:"author_id,omitempty"`
+	Text          string          `json:"text"`
+	Format        string          `json:"format,omitempty"`
+	HTML          string          `json:"html,omitempty"`
+	ForwardedFrom *models.Forward `json:"forwarded_from,omitempty"`
+	CreatedAt     string          `json:"created_at"`
+}
+
+func (j *jsonWriter) Begin(meta Meta) error {
+	header := struct {
+		Chat       models.Chat `json:"chat"`
+		ExportedAt string      `json:"exported_at"`
+		From       string      `json:"from,omitempty"`
+		To         string      `json:"to,omitempty"`
+	}{Chat: meta.Chat, ExportedAt: timestamp(meta.ExportedAt)}
+	if !meta.From.IsZero() {
+		header.From = timestamp(meta.From)
+	}
+	if !meta.To.IsZero() {
+		header.To = timestamp(meta.To)
+	}
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	// Открываем объект заголовка заново, чтобы дописать в него массив сообщений
+	_, err = fmt.Fprintf(j.w, "%s,\"messages\":[", data[:len(data)-1])
+	return err
+}
+
+func (j *jsonWriter) Write(messages []models.Message) error {
+	var b strings.Builder
+	for _, m := range messages {
+		data, err := json.Marshal(jsonMessage{
+			ID:            m.ID,
+			AuthorID:      m.AuthorID,
+			Text:          m.Text,
+			Format:        m.Format,
+			HTML:          m.HTML,
+			ForwardedFrom: m.ForwardedFrom,
+			CreatedAt:     timestamp(m.CreatedAt),
+		})
+		if err != nil {
+			return err
+		}
+		if j.count > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString("\n")
+		b.Write(data)
+		j.count++
+	}
+	_, err := io.WriteString(j.w, b.String())
+	return err
+}
+
+func (j *jsonWriter) End() error {
+	_, err := fmt.Fprintf(j.w, "\n],\"message_count\":%d}\n", j.count)
+	return err
+}
+
+// csvWriter - таблица: одна строка на сообщение, метаданные чата в каждой строке
+// (CSV не умеет заголовок документа, а так строки остаются самодостаточными при фильтрации)
+type csvWriter struct {
+	w    *csv.Writer
+	chat models.Chat
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Begin(meta Meta) error {
+	c.chat = meta.Chat
+	return c.w.Write([]string{"chat_id", "chat_title", "message_id", "created_at", "author_id", "text",
+		"forwarded_from_chat_id", "forwarded_from_message_id"})
+}
+
+func (c *csvWriter) Write(messages []models.Message) error {
+	chatID := strconv.FormatUint(uint64(c.chat.ID), 10)
+	for _, m := range messages {
+		// У непересланных сообщений колонки оригинала пустые
+		var fromChatID, fromMessageID string
+		if f := m.ForwardedFrom; f != nil {
+			fromChatID = strconv.FormatUint(uint64(f.ChatID), 10)
+			fromMessageID = strconv.FormatUint(uint64(f.MessageID), 10)
+		}
+		err := c.w.Write([]string{
+			chatID,
+			csvSafe(c.chat.Title),
+			strconv.FormatUint(uint64(m.ID), 10),
+			timestamp(m.CreatedAt),
+			csvSafe(m.AuthorID),
+			csvSafe(m.Text),
+			fromChatID,
+			fromMessageID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) End() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// csvSafe защищает от формул в табличных редакторах (CSV injection):
+// значение, начинающееся с = + - @ или управляющего символа, Excel выполнит как формулу
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// htmlWriter - самостоятельная HTML страница без скриптов и внешних ресурсов
+// Все данные пользователя экранируются
+type htmlWriter struct {
+	w     io.Writer
+	count int
+}
+
+// htmlHead - начало страницы; стили встроены, чтобы файл открывался без сервера
+const htmlHead = `<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<meta http-equiv="Content-Security-Policy" content="default-src 'none'; style-src 'unsafe-inline'">
+<title>%s</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+.meta { color: #555; }
+.message { border-bottom: 1px solid #eee; padding: .5em 0; }
+.message .time { color: #888; font-size: .9em; }
+.message .text { white-space: pre-wrap; margin-top: .25em; }
+.message .forward { color: #555; font-style: italic; margin-top: .25em; }
+</style>
+</head>
+<body>
+<h1>%s</h1>
+<p class="meta">Чат #%d, создан %s<br>Выгрузка от %s, период: %s</p>
+`
+
+func (h *htmlWriter) Begin(meta Meta) error {
+	title := html.EscapeString(meta.Chat.Title)
+	_, err := fmt.Fprintf(h.w, htmlHead, title, title, meta.Chat.ID,
+		humanTime(meta.Chat.CreatedAt), humanTime(meta.ExportedAt), html.EscapeString(period(meta)))
+	return err
+}
+
+func (h *htmlWriter) Write(messages []models.Message) error {
+	var b strings.Builder
+	for _, m := range messages {
+		// HTML markdown сообщения уже безопасен: его собрал internal/markdown из экранированного текста
+		text := html.EscapeString(m.Text)
+		if m.Format == models.MessageFormatMarkdown && m.HTML != "" {
+			text = m.HTML
+		}
+		if note := forwarded(m); note != "" {
+			text = "<div class=\"forward\">" + html.EscapeString(note) + "</div>" + text
+		}
+		fmt.Fprintf(&b, "<div class=\"message\" id=\"m%d\"><span class=\"time\">%s</span> <b>%s</b><div class=\"text\">%s</div></div>\n",
+			m.ID, humanTime(m.CreatedAt), html.EscapeString(author(m)), text)
+		h.count++
+	}
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *htmlWriter) End() error {
+	_, err := fmt.Fprintf(h.w, "<p class=\"meta\">Сообщений: %d</p>\n</body>\n</html>\n", h.count)
+	return err
+}
+
+// textWriter - простой текст для чтения человеком
+// Многострочные сообщения выводятся с отступом, чтобы не путать их со следующим сообщением
+type textWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (t *textWriter) Begin(meta Meta) error {
+	_, err := fmt.Fprintf(t.w, "Чат: %s (#%d)\nСоздан: %s\nВыгрузка: %s\nПериод: %s\n\n",
+		meta.Chat.Title, meta.Chat.ID, humanTime(meta.Chat.CreatedAt), humanTime(meta.ExportedAt), period(meta))
+	return err
+}
+
+func (t *textWriter) Write(messages []models.Message) error {
+	var b strings.Builder
+	for _, m := range messages {
+		text := strings.ReplaceAll(m.Text, "\n", "\n    ")
+		if note := forwarded(m); note != "" {
+			text = "[" + note + "] " + text
+		}
+		fmt.Fprintf(&b, "[%s] %s: %s\n", humanTime(m.CreatedAt), author(m), text)
+		t.count++
+	}
+	_, err := io.WriteString(t.w, b.String())
+	return err
+}
+
+func (t *textWriter) End() error {
+	_, err := fmt.Fprintf(t.w, "\nСообщений: %d\n", t.count)
+	return err
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-chat-app/internal/importer"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// importTimeout - сколько даем на загрузку и разбор выгрузки
+// Общие таймауты сервера рассчитаны на обычные запросы и для импорта снимаются
+const importTimeout = 15 * time.Minute
+
+// AdminHandler обрабатывает служебные запросы /admin/*
+// Доступ - только с токеном из настроек (Authorization: Bearer <token>)
+type AdminHandler struct {
+	importer       *importer.Importer
+	token          string
+	maxImportBytes int64
+}
+
+// NewAdminHandler создает обработчик служебных запросов
+// maxImportBytes - максимальный размер файла выгрузки
+func NewAdminHandler(im *importer.Importer, token string, maxImportBytes int64) *AdminHandler {
+	return &AdminHandler{importer: im, token: token, maxImportBytes: maxImportBytes}
+}
+
+// ServeHTTP проверяет токен и выбирает обработчик по пути
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		http.Error(w, "Требуется токен администратора", http.StatusUnauthorized) // 401
+		return
+	}
+
+	switch {
+	// Импорт истории: POST /admin/import?source=slack|telegram
+	case r.URL.Path == "/admin/import" && r.Method == http.MethodPost:
+		h.Import(w, r)
+	// Счетчики фоновых задач: GET /admin/metrics
+	case r.URL.Path == "/admin/metrics" && r.Method == http.MethodGet:
+		h.Metrics(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// authorized сравнивает токен за постоянное время, чтобы его нельзя было подобрать по задержке ответа
+func (h *AdminHandler) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && h.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+// POST /admin/import - импорт истории из выгрузки другого мессенджера
+// Query параметры: source=slack|telegram, dry_run=true - только отчет, без записи
+// Тело: ZIP выгрузки Slack или result.json Telegram Desktop
+// Ответ: отчет об импорте (importer.Report)
+func (h *AdminHandler) Import(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "AdminHandler.Import")
+	defer span.End()
+
+	source := r.URL.Query().Get("source")
+	if !slices.Contains(importer.Sources, source) {
+		http.Error(w, importer.ErrUnknownSource.Error(), http.StatusBadRequest) // 400
+		return
+	}
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, "Неверный параметр dry_run", http.StatusBadRequest) // 400
+			return
+		}
+	}
+	span.SetAttributes(attribute.String("import.source", source), attribute.Bool("import.dry_run", dryRun))
+
+	rc := http.NewResponseController(w)
+	for _, extend := range []func(time.Time) error{rc.SetReadDeadline, rc.SetWriteDeadline} {
+		if err := extend(time.Now().Add(importTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			slog.WarnContext(ctx, "не удалось продлить таймаут импорта", slog.Any("error", err))
+		}
+	}
+
+	// ZIP читается с произвольного места, поэтому тело сначала сохраняется во временный файл
+	file, err := os.CreateTemp("", "chat-import-*")
+	if err != nil {
+		slog.ErrorContext(ctx, "не удалось создать временный файл", slog.Any("error", err))
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError) // 500
+		return
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	size, err := io.Copy(file, http.MaxBytesReader(w, r.Body, h.maxImportBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Файл выгрузки слишком большой", http.StatusRequestEntityTooLarge) // 413
+		} else {
+			http.Error(w, "Не удалось прочитать тело запроса", http.StatusBadRequest) // 400
+		}
+		return
+	}
+
+	src, err := importer.Open(source, file, size)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest) // 400
+		return
+	}
+
+	// Обрыв соединения не прерывает импорт: иначе в базе остался бы неполный чат
+	report, err := h.importer.Import(context.WithoutCancel(ctx), src, dryRun)
+	if err != nil {
+		slog.ErrorContext(ctx, "импорт прерван",
+			slog.String("source", source),
+			slog.Int("chats", len(report.Chats)),
+			slog.Int("messages", report.Messages),
+			slog.Any("error", err),
+		)
+		http.Error(w, "Импорт прерван: часть чатов уже создана, подробности в логе сервера", http.StatusInternalServerError) // 500
+		return
+	}
+	slog.InfoContext(ctx, "импорт завершен",
+		slog.String("source", source),
+		slog.Bool("dry_run", dryRun),
+		slog.Int("chats", len(report.Chats)),
+		slog.Int("messages", report.Messages),
+		slog.Int("skipped", report.SkippedTotal),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GET /admin/metrics - счетчики приложения в формате expvar (JSON)
+// retention - очистка старых сообщений (см. internal/retention), scheduler - отправка
+// запланированных сообщений (см. internal/scheduler), memstats - память Go
+// Закрыт токеном: cmdline содержит флаги запуска, среди которых могут быть секреты
+func (h *AdminHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	expvar.Handler().ServeHTTP(w, r)
+}
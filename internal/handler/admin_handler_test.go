@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-chat-app/internal/importer"
+	"go-chat-app/internal/repository/memory"
+)
+
+// telegramExport - выгрузка Telegram с одним сообщением и одним служебным
+const telegramExport = `{"name":"Команда","type":"private_group","id":7,"messages":[
+	{"id":1,"type":"service","action":"create_group","date_unixtime":"1704103200"},
+	{"id":2,"type":"message","date_unixtime":"1704103260","from_id":"user1","text":"привет"}
+]}`
+
+// TestAdminImport проверяет доступ по токену, ошибки запроса и импорт с отчетом
+func TestAdminImport(t *testing.T) {
+	db := memory.New()
+	h := NewAdminHandler(importer.New(db.Chats(), db.Messages()), "test-admin-token", 1024)
+
+	do := func(query, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/admin/import"+query, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		token string
+		body  string
+		want  int
+	}{
+		{"без токена", "?source=telegram", "", telegramExport, http.StatusUnauthorized},
+		{"чужой токен", "?source=telegram", "wrong-admin-token", telegramExport, http.StatusUnauthorized},
+		{"неизвестный источник", "?source=icq", "test-admin-token", telegramExport, http.StatusBadRequest},
+		{"неверный dry_run", "?source=telegram&dry_run=да", "test-admin-token", telegramExport, http.StatusBadRequest},
+		{"не выгрузка", "?source=slack", "test-admin-token", telegramExport, http.StatusBadRequest},
+		{"слишком большой файл", "?source=telegram", "test-admin-token", strings.Repeat(" ", 2048), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		if rr := do(tt.query, tt.token, tt.body); rr.Code != tt.want {
+			t.Errorf("%s: ожидался статус %d, получен %d (%s)", tt.name, tt.want, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Пробный запуск ничего не создает, обычный - создает чат
+	for _, dryRun := range []bool{true, false} {
+		query := "?source=telegram"
+		if dryRun {
+			query += "&dry_run=true"
+		}
+		rr := do(query, "test-admin-token", telegramExport)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("dry_run=%v: ожидался статус 200, получен %d (%s)", dryRun, rr.Code, rr.Body.String())
+		}
+		var report importer.Report
+		if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+			t.Fatalf("Отчет не JSON: %v", err)
+		}
+		if report.DryRun != dryRun || len(report.Chats) != 1 || report.Messages != 1 || report.SkippedTotal != 1 {
+			t.Errorf("dry_run=%v: неверный отчет %+v", dryRun, report)
+		}
+		chats, _ := db.Chats().List(context.Background(), 0, 10)
+		if wantChats := map[bool]int{true: 0, false: 1}[dryRun]; len(chats) != wantChats {
+			t.Errorf("dry_run=%v: ожидалось чатов %d, получено %d", dryRun, wantChats, len(chats))
+		}
+	}
+}
+
+// TestAdminMetrics проверяет, что счетчики отдаются только с токеном
+func TestAdminMetrics(t *testing.T) {
+	h := NewAdminHandler(nil, "test-admin-token", 1024)
+
+	req := httptest.NewRequest("GET", "/admin/metrics", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Без токена ожидался статус 401, получен %d", rr.Code)
+	}
+
+	req.Header.Set("Authorization", "Bearer test-admin-token")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	var vars map[string]json.RawMessage
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &vars) != nil || vars["memstats"] == nil {
+		t.Errorf("Ожидался JSON expvar, получен %d: %.200s", rr.Code, rr.Body.String())
+	}
+}
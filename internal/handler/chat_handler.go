@@ -2,14 +2,24 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"go-chat-app/internal/db/service"
+	"log/slog"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"go-chat-app/internal/models"
+
+	"go.opentelemetry.io/otel"
 )
 
+// tracer создает спаны HTTP слоя
+// Родительский спан запроса (с учетом заголовка traceparent) создает otelhttp в server.Router
+var tracer = otel.Tracer("go-chat-app/internal/handler")
+
 // ChatHandler обрабатывает HTTP запросы для работы с чатами и сообщениями
 type ChatHandler struct {
 	service *service.ChatService // Сервис с бизнес-логикой
@@ -28,12 +38,60 @@ func (h *ChatHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case r.URL.Path == "/chats" && r.Method == "POST":
 		h.CreateChat(w, r)
 
+	// СЛУЧАЙ 1а: Список чатов
+	// Путь: GET /chats
+	// Пример: GET http://localhost:8080/chats?limit=50&after=120
+	case r.URL.Path == "/chats" && r.Method == "GET":
+		h.ListChats(w, r)
+
+	// СЛУЧАЙ 1б: Запланированные сообщения (создание, список, изменение, отмена)
+	// Путь: /chats/{id}/scheduled-messages[/{sid}]
+	// Пример: POST http://localhost:8080/chats/123/scheduled-messages
+	case strings.HasPrefix(r.URL.Path, "/chats/") && strings.Contains(r.URL.Path, "/scheduled-messages"):
+		h.Scheduled(w, r)
+
+	// СЛУЧАЙ 1в: Закрепленные сообщения (закрепить, список, открепить)
+	// Путь: /chats/{id}/pins[/{message_id}]
+	// Пример: POST http://localhost:8080/chats/123/pins
+	case strings.HasPrefix(r.URL.Path, "/chats/") && strings.Contains(r.URL.Path, "/pins"):
+		h.Pins(w, r)
+
+	// СЛУЧАЙ 1г: Упоминания пользователя запроса (список, отметка прочитанными)
+	// Путь: /me/mentions[/read]
+	// Пример: GET http://localhost:8080/me/mentions?unread=true
+	case strings.HasPrefix(r.URL.Path, "/me/mentions"):
+		h.Mentions(w, r)
+
+	// СЛУЧАЙ 1д: Голосование в опросе (проголосовать, отозвать голос)
+	// Путь: /chats/{id}/messages/{message_id}/votes
+	// Пример: POST http://localhost:8080/chats/123/messages/45/votes
+	case strings.HasPrefix(r.URL.Path, "/chats/") && strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/votes"):
+		h.Votes(w, r)
+
+	// СЛУЧАЙ 1е: Пересылка сообщения в другой чат
+	// Путь: POST /chats/{id}/messages/{message_id}/forward
+	// Пример: POST http://localhost:8080/chats/123/messages/45/forward
+	case strings.HasPrefix(r.URL.Path, "/chats/") && strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/forward"):
+		h.ForwardMessage(w, r)
+
 	// СЛУЧАЙ 2: Отправка сообщения в чат
 	// Путь: POST /chats/{id}/messages
 	// Пример: POST http://localhost:8080/chats/123/messages
 	case strings.HasPrefix(r.URL.Path, "/chats/") && strings.HasSuffix(r.URL.Path, "/messages") && r.Method == "POST":
 		h.SendMessage(w, r)
 
+	// СЛУЧАЙ 3а: Поток событий чата (Server-Sent Events)
+	// Путь: GET /chats/{id}/events
+	// Пример: GET http://localhost:8080/chats/123/events
+	case strings.HasPrefix(r.URL.Path, "/chats/") && strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/events") && r.Method == "GET":
+		h.StreamEvents(w, r)
+
+	// СЛУЧАЙ 3б: Выгрузка истории чата
+	// Путь: GET /chats/{id}/export
+	// Пример: GET http://localhost:8080/chats/123/export?format=csv&from=2026-01-01
+	case strings.HasPrefix(r.URL.Path, "/chats/") && strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/export") && r.Method == "GET":
+		h.ExportChat(w, r)
+
 	// СЛУЧАЙ 3: Получение информации о чате с сообщениями
 	// Путь: GET /chats/{id}
 	// Пример: GET http://localhost:8080/chats/123?limit=20
@@ -47,14 +105,12 @@ func (h *ChatHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case strings.HasPrefix(r.URL.Path, "/chats/") && r.Method == "DELETE":
 		h.DeleteChat(w, r)
 
-	// ВАРИАНТ 5: HEALTH CHECK (для мониторинга)
-	// Условие: путь "/health" И метод GET
-	// Используется Docker, Kubernetes и т.д. для проверки что сервер жив
-	case r.URL.Path == "/health" && r.Method == "GET":
-		// Устанавливаем заголовок Content-Type
-		w.Header().Set("Content-Type", "application/json")
-		// Пишем простой JSON ответ
-		w.Write([]byte(`{"status":"ok"}`))
+	// СЛУЧАЙ 5: Изменение настроек чата
+	// Путь: PATCH /chats/{id}
+	// Пример: PATCH http://localhost:8080/chats/123
+	case strings.HasPrefix(r.URL.Path, "/chats/") && r.Method == "PATCH":
+		h.UpdateChat(w, r)
+
 	default:
 
 		// ВАРИАНТ 6: НЕИЗВЕСТНЫЙ ПУТЬ
@@ -73,6 +129,9 @@ func NewChatHandler(service *service.ChatService) *ChatHandler {
 // Тело запроса: {"title": "Название чата"}
 // Ответ: созданный чат в формате JSON
 func (h *ChatHandler) CreateChat(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.CreateChat")
+	defer span.End()
+
 	// Проверяем, что используется правильный HTTP метод
 	if r.Method != "POST" {
 		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed) // 405
@@ -91,13 +150,14 @@ func (h *ChatHandler) CreateChat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Вызываем сервис для создания чата
-	chat, err := h.service.CreateChat(data.Title)
+	chat, err := h.service.CreateChat(ctx, data.Title)
 	if err != nil {
 		// Обрабатываем ошибки валидации (400) и остальные (500)
 		if strings.Contains(err.Error(), "не может быть пустым") ||
 			strings.Contains(err.Error(), "не более") {
 			http.Error(w, err.Error(), http.StatusBadRequest) // 400
 		} else {
+			slog.ErrorContext(ctx, "ошибка обработки запроса", slog.Any("error", err))
 			http.Error(w, "Ошибка сервера", http.StatusInternalServerError) // 500
 		}
 		return
@@ -117,10 +177,16 @@ func (h *ChatHandler) CreateChat(w http.ResponseWriter, r *http.Request) {
 	// GORM автоматически заполнил chat.ID, chat.CreatedAt и т.д.
 }
 
-// 2. POST /chats/{id}/messages/ - отправить сообщение в чат
+// 2. POST /chats/{id}/messages - отправить сообщение в чат
 // Тело запроса: {"text": "Текст сообщения"}
+// Исчезающее сообщение: {"text": "...", "ttl_seconds": 60} или {"text": "...", "expires_at": "2026-01-01T10:00:00Z"}
+// Разметка: {"text": "**важно**", "format": "markdown"} - в ответе появятся html и entities
+// Опрос: {"text": "Куда идем?", "poll": {"options": ["Кино", "Театр"], "multiple": false, "anonymous": true}}
 // Ответ: созданное сообщение в формате JSON
 func (h *ChatHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.SendMessage")
+	defer span.End()
+
 	// Проверяем HTTP метод
 	if r.Method != "POST" {
 		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed) // 405
@@ -128,7 +194,7 @@ func (h *ChatHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Разбираем URL путь для получения ID чата
-	// Пример: /chats/123/messages/ → parts = ["chats", "123", "messages"]
+	// Пример: /chats/123/messages → parts = ["chats", "123", "messages"]
 	path := strings.Trim(r.URL.Path, "/") // Убираем слэши в начале и конце
 	parts := strings.Split(path, "/")     // Разбиваем по слэшам
 
@@ -148,6 +214,21 @@ func (h *ChatHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 	// Структура для парсинга JSON тела запроса
 	var data struct {
 		Text string `json:"text"` // Текст сообщения
+		// ClientMsgID - ключ идемпотентности от клиента, его обрабатывает
+		// idempotency.Middleware: повтор с тем же ключом не создаст второе сообщение
+		ClientMsgID string `json:"client_msg_id"`
+		// Исчезающее сообщение: время жизни в секундах или момент исчезновения (одно из двух)
+		TTLSeconds *int       `json:"ttl_seconds"`
+		ExpiresAt  *time.Time `json:"expires_at"`
+		// Формат текста: plain (по умолчанию) или markdown
+		Format string `json:"format"`
+		// Опрос: текст сообщения - вопрос
+		Poll *struct {
+			Options   []string   `json:"options"`
+			Multiple  bool       `json:"multiple"`
+			Anonymous bool       `json:"anonymous"`
+			ClosesAt  *time.Time `json:"closes_at"`
+		} `json:"poll"`
 	}
 
 	// Декодируем JSON тело запроса
@@ -156,16 +237,45 @@ func (h *ChatHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var opts []service.MessageOption
+	if data.TTLSeconds != nil {
+		// Ограничение сверху защищает от переполнения Duration: сервис все равно отклонит такой срок
+		seconds := min(*data.TTLSeconds, math.MaxInt32)
+		opts = append(opts, service.WithTTL(time.Duration(seconds)*time.Second))
+	}
+	if data.ExpiresAt != nil {
+		opts = append(opts, service.WithExpiresAt(*data.ExpiresAt))
+	}
+	if data.Format != "" {
+		opts = append(opts, service.WithFormat(data.Format))
+	}
+	if data.Poll != nil {
+		poll := models.Poll{Multiple: data.Poll.Multiple, Anonymous: data.Poll.Anonymous, ClosesAt: data.Poll.ClosesAt}
+		for _, option := range data.Poll.Options {
+			poll.Options = append(poll.Options, models.PollOption{Text: option})
+		}
+		opts = append(opts, service.WithPoll(poll))
+	}
+
 	// Вызываем сервис для отправки сообщения
-	message, err := h.service.SendMessage(uint(chatID), data.Text)
+	message, err := h.service.SendMessage(ctx, uint(chatID), data.Text, opts...)
 	if err != nil {
 		// Разные типы ошибок = разные HTTP статусы
-		if strings.Contains(err.Error(), "не найден") {
+		var rateErr *service.RateLimitError
+		if errors.As(err, &rateErr) {
+			// Медленный режим: клиент узнает, через сколько секунд можно повторить
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rateErr.RetryAfter.Seconds()))))
+			http.Error(w, err.Error(), http.StatusTooManyRequests) // 429
+		} else if strings.Contains(err.Error(), "не найден") {
 			http.Error(w, "Чат не найден", http.StatusNotFound) // 404
-		} else if strings.Contains(err.Error(), "не может быть пустым") ||
+		} else if errors.Is(err, service.ErrPollsDisabled) {
+			http.Error(w, "Опросы не настроены", http.StatusNotImplemented) // 501
+		} else if errors.Is(err, service.ErrUnknownFormat) || errors.Is(err, service.ErrInvalidPoll) ||
+			strings.Contains(err.Error(), "не может быть пустым") ||
 			strings.Contains(err.Error(), "не более") {
 			http.Error(w, err.Error(), http.StatusBadRequest) // 400
 		} else {
+			slog.ErrorContext(ctx, "ошибка обработки запроса", slog.Any("error", err))
 			http.Error(w, "Ошибка сервера", http.StatusInternalServerError) // 500
 		}
 		return
@@ -179,8 +289,11 @@ func (h *ChatHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 
 // 3. GET /chats/{id} - получить информацию о чате и его сообщениях
 // Query параметр: limit (по умолчанию 20, максимум 100)
-// Ответ: {"chat": {...}, "messages": [...]}
+// Ответ: {"chat": {...}, "messages": [...], "pinned_message_ids": [...]}
 func (h *ChatHandler) GetChat(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.GetChat")
+	defer span.End()
+
 	// Проверяем HTTP метод
 	if r.Method != "GET" {
 		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed) // 405
@@ -220,32 +333,93 @@ func (h *ChatHandler) GetChat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Вызываем сервис для получения чата и сообщений
-	chat, messages, err := h.service.GetChatWithMessages(uint(chatID), limit)
+	chat, messages, err := h.service.GetChatWithMessages(ctx, uint(chatID), limit)
 	if err != nil {
 		// Обрабатываем ошибки
 		if strings.Contains(err.Error(), "не найден") {
 			http.Error(w, "Чат не найден", http.StatusNotFound) // 404
 		} else {
+			slog.ErrorContext(ctx, "ошибка обработки запроса", slog.Any("error", err))
 			http.Error(w, "Ошибка сервера", http.StatusInternalServerError) // 500
 		}
 		return
 	}
+	pinned, err := h.service.PinnedMessageIDs(ctx, uint(chatID))
+	if err != nil {
+		slog.ErrorContext(ctx, "ошибка обработки запроса", slog.Any("error", err))
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError) // 500
+		return
+	}
 
 	// Формируем и возвращаем ответ
 	w.Header().Set("Content-Type", "application/json")
 	// Анонимная структура для ответа
 	json.NewEncoder(w).Encode(struct {
-		Chat     models.Chat      `json:"chat"`     // Информация о чате
-		Messages []models.Message `json:"messages"` // Список сообщений
+		Chat             models.Chat      `json:"chat"`               // Информация о чате
+		Messages         []models.Message `json:"messages"`           // Список сообщений
+		PinnedMessageIDs []uint           `json:"pinned_message_ids"` // Закрепленные, последние первыми
 	}{
-		Chat:     *chat,
-		Messages: messages,
+		Chat:             *chat,
+		Messages:         messages,
+		PinnedMessageIDs: pinned,
+	})
+}
+
+// 3а. GET /chats - список чатов в порядке ID
+// Query параметры: limit (по умолчанию 50, максимум 100), after - ID последнего чата предыдущей страницы
+// Ответ: {"chats": [...], "next_after": 170} - next_after есть, только если страница заполнена целиком
+func (h *ChatHandler) ListChats(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.ListChats")
+	defer span.End()
+
+	query := r.URL.Query()
+	limit := 50
+	if limitStr := query.Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			http.Error(w, "Неверный limit", http.StatusBadRequest) // 400
+			return
+		}
+		limit = min(l, 100)
+	}
+	var afterID uint64
+	if afterStr := query.Get("after"); afterStr != "" {
+		var err error
+		if afterID, err = strconv.ParseUint(afterStr, 10, 32); err != nil {
+			http.Error(w, "Неверный параметр after", http.StatusBadRequest) // 400
+			return
+		}
+	}
+
+	chats, err := h.service.ListChats(ctx, uint(afterID), limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "ошибка обработки запроса", slog.Any("error", err))
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError) // 500
+		return
+	}
+
+	// Полная страница - возможно, есть следующая: клиент продолжит с after=next_after
+	var nextAfter *uint
+	if len(chats) == limit {
+		nextAfter = &chats[len(chats)-1].ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Chats     []models.Chat `json:"chats"`
+		NextAfter *uint         `json:"next_after,omitempty"`
+	}{
+		Chats:     chats,
+		NextAfter: nextAfter,
 	})
 }
 
 // 4. DELETE /chats/{id} - удалить чат и все его сообщения
 // Ответ: 204 No Content
 func (h *ChatHandler) DeleteChat(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.DeleteChat")
+	defer span.End()
+
 	// Проверяем HTTP метод
 	if r.Method != "DELETE" {
 		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed) // 405
@@ -271,12 +445,13 @@ func (h *ChatHandler) DeleteChat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Вызываем сервис для удаления чата
-	err = h.service.DeleteChat(uint(chatID))
+	err = h.service.DeleteChat(ctx, uint(chatID))
 	if err != nil {
 		// Обрабатываем ошибки
 		if strings.Contains(err.Error(), "не найден") {
 			http.Error(w, "Чат не найден", http.StatusNotFound) // 404
 		} else {
+			slog.ErrorContext(ctx, "ошибка обработки запроса", slog.Any("error", err))
 			http.Error(w, "Ошибка сервера", http.StatusInternalServerError) // 500
 		}
 		return
@@ -285,3 +460,81 @@ func (h *ChatHandler) DeleteChat(w http.ResponseWriter, r *http.Request) {
 	// Успешный ответ: 204 No Content (как указано в ТЗ)
 	w.WriteHeader(http.StatusNoContent) // 204
 }
+
+// 5. PATCH /chats/{id} - изменить настройки чата
+// Тело запроса: {"slow_mode_seconds": 30, "retention_days": 90} (любое из полей)
+// Ответ: обновленный чат в формате JSON
+func (h *ChatHandler) UpdateChat(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.UpdateChat")
+	defer span.End()
+
+	// Проверяем HTTP метод
+	if r.Method != "PATCH" {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed) // 405
+		return
+	}
+
+	// Разбираем URL путь для получения ID чата
+	// Пример: /chats/123 → parts = ["chats", "123"]
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+
+	// Проверяем структуру пути: должно быть 2 части
+	if len(parts) != 2 || parts[0] != "chats" {
+		http.Error(w, "Неверный URL", http.StatusBadRequest) // 400
+		return
+	}
+
+	// Преобразуем ID чата из строки в число
+	chatID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		http.Error(w, "Неверный ID чата", http.StatusBadRequest) // 400
+		return
+	}
+
+	// Структура для парсинга JSON тела запроса
+	// Указатель отличает "поле не передано" от "передан 0",
+	// а json.RawMessage для retention_days - еще и "передан null" (срок по умолчанию сервера)
+	var data struct {
+		SlowModeSeconds *int            `json:"slow_mode_seconds"` // Медленный режим, 0 - выключить
+		RetentionDays   json.RawMessage `json:"retention_days"`    // Срок хранения в днях, 0 - всегда, null - по умолчанию
+	}
+
+	// Декодируем JSON тело запроса
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, "Неверный JSON", http.StatusBadRequest) // 400
+		return
+	}
+	settings := service.ChatSettings{SlowModeSeconds: data.SlowModeSeconds}
+	if len(data.RetentionDays) > 0 {
+		if string(data.RetentionDays) == "null" {
+			settings.DefaultRetention = true
+		} else if err := json.Unmarshal(data.RetentionDays, &settings.RetentionDays); err != nil {
+			http.Error(w, "retention_days: ожидается целое число или null", http.StatusBadRequest) // 400
+			return
+		}
+	}
+	if settings.SlowModeSeconds == nil && settings.RetentionDays == nil && !settings.DefaultRetention {
+		http.Error(w, "Нет изменяемых полей", http.StatusBadRequest) // 400
+		return
+	}
+
+	// Вызываем сервис для изменения настроек
+	chat, err := h.service.UpdateSettings(ctx, uint(chatID), settings)
+	if err != nil {
+		// Обрабатываем ошибки
+		if strings.Contains(err.Error(), "не найден") {
+			http.Error(w, "Чат не найден", http.StatusNotFound) // 404
+		} else if strings.Contains(err.Error(), "не более") {
+			http.Error(w, err.Error(), http.StatusBadRequest) // 400
+		} else {
+			slog.ErrorContext(ctx, "ошибка обработки запроса", slog.Any("error", err))
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError) // 500
+		}
+		return
+	}
+
+	// Успешный ответ: возвращаем обновленный чат
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chat)
+}
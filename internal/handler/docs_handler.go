@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"go-chat-app/api"
+
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// swaggerInitializer настраивает Swagger UI на нашу спецификацию
+// (заменяет swagger-initializer.js из дистрибутива, который открывает petstore)
+const swaggerInitializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: "/openapi.json",
+    dom_id: "#swagger-ui",
+    deepLinking: true,
+    presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+    plugins: [SwaggerUIBundle.plugins.DownloadUrl],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+// DocsHandler отдает спецификацию OpenAPI и встроенный в бинарник Swagger UI
+// Внешние CDN не нужны: документация работает и без доступа в интернет
+type DocsHandler struct {
+	ui http.Handler
+}
+
+// NewDocsHandler создает обработчик документации
+func NewDocsHandler() *DocsHandler {
+	files := http.FileServer(http.FS(swaggerFiles.FS))
+	return &DocsHandler{
+		ui: http.StripPrefix("/docs/", files),
+	}
+}
+
+// Spec - GET /openapi.json
+func (h *DocsHandler) Spec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(api.Spec)
+}
+
+// UI - GET /docs/ и файлы Swagger UI
+func (h *DocsHandler) UI(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/docs":
+		http.Redirect(w, r, "/docs/", http.StatusMovedPermanently) // 301
+	case r.URL.Path == "/docs/swagger-initializer.js":
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		w.Write([]byte(swaggerInitializer))
+	case strings.HasSuffix(r.URL.Path, ".map"):
+		// Карты исходников весят несколько мегабайт и нужны только отладчику
+		http.NotFound(w, r)
+	default:
+		h.ui.ServeHTTP(w, r)
+	}
+}
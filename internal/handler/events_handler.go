@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-chat-app/internal/db/service"
+)
+
+// heartbeatInterval - как часто отправлять комментарий-пинг в поток событий,
+// чтобы прокси и балансировщики не закрывали простаивающее соединение
+const heartbeatInterval = 15 * time.Second
+
+// 6. GET /chats/{id}/events - поток событий чата (Server-Sent Events)
+// События: message.created (в data - сообщение), message.deleted (исчезающее сообщение удалено,
+// в data - message_id), chat.deleted (после него поток закрывается)
+// Формат: "event: <тип>\ndata: <JSON события>\n\n"
+func (h *ChatHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.StreamEvents")
+	defer span.End()
+
+	// Разбираем URL путь для получения ID чата
+	// Пример: /chats/123/events → parts = ["chats", "123", "events"]
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "chats" || parts[2] != "events" {
+		http.Error(w, "Неверный URL", http.StatusBadRequest) // 400
+		return
+	}
+	chatID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		http.Error(w, "Неверный ID чата", http.StatusBadRequest) // 400
+		return
+	}
+
+	// Поток живет долго: снимаем таймаут записи сервера для этого запроса
+	// и проверяем, что ответ можно отправлять частями
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		slog.WarnContext(ctx, "не удалось снять таймаут записи", slog.Any("error", err))
+	}
+
+	events, cancel, err := h.service.Subscribe(ctx, uint(chatID))
+	if err != nil {
+		if strings.Contains(err.Error(), "не найден") {
+			http.Error(w, "Чат не найден", http.StatusNotFound) // 404
+		} else {
+			slog.ErrorContext(ctx, "ошибка обработки запроса", slog.Any("error", err))
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError) // 500
+		}
+		return
+	}
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx не должен буферизовать поток
+	w.WriteHeader(http.StatusOK)
+	// Первый комментарий сразу сообщает клиенту, что подписка оформлена
+	fmt.Fprint(w, ": subscribed\n\n")
+	if err := rc.Flush(); err != nil {
+		slog.WarnContext(ctx, "поток событий не поддерживается", slog.Any("error", err))
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return // клиент отключился или сервер останавливается
+
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+
+		case event, ok := <-events:
+			if !ok {
+				// Подписка закрыта (клиент не успевал читать) - клиент переподключится
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				slog.ErrorContext(ctx, "не удалось закодировать событие", slog.Any("error", err))
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			if event.Type == service.EventChatDeleted {
+				rc.Flush()
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
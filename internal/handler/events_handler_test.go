@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-chat-app/internal/db/service"
+	"go-chat-app/internal/repository/memory"
+)
+
+// TestStreamEvents проверяет поток событий: новое сообщение и удаление чата
+func TestStreamEvents(t *testing.T) {
+	db := memory.New()
+	svc := service.NewChatService(db.Chats(), db.Messages())
+	srv := httptest.NewServer(NewChatHandler(svc))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := http.Get(srv.URL + "/chats/999/events")
+	if err != nil {
+		t.Fatalf("Запрос не выполнен: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Для несуществующего чата ожидался 404, получен %d", resp.StatusCode)
+	}
+
+	chat, _ := svc.CreateChat(ctx, "чат")
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/chats/1/events", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Не удалось подписаться: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Ожидался text/event-stream, получен %q", ct)
+	}
+
+	// Читаем поток построчно: первая строка - комментарий о подписке
+	lines := bufio.NewScanner(resp.Body)
+	if !lines.Scan() || lines.Text() != ": subscribed" {
+		t.Fatalf("Ожидался комментарий о подписке, получено %q", lines.Text())
+	}
+
+	svc.SendMessage(ctx, chat.ID, "привет")
+	svc.DeleteChat(ctx, chat.ID)
+
+	var got []string
+	for lines.Scan() {
+		if line := lines.Text(); line != "" {
+			got = append(got, line)
+		}
+	}
+	if len(got) != 4 {
+		t.Fatalf("Ожидалось 2 события (4 строки), получено: %q", got)
+	}
+	if got[0] != "event: message.created" || !strings.Contains(got[1], `"text":"привет"`) {
+		t.Errorf("Неверное событие сообщения: %q", got[:2])
+	}
+	if got[2] != "event: chat.deleted" {
+		t.Errorf("Неверное событие удаления: %q", got[2:])
+	}
+}
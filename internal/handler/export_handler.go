@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-chat-app/internal/export"
+	"go-chat-app/internal/models"
+)
+
+// exportWriteTimeout - сколько даем на запись одной пачки выгрузки
+// Общий таймаут записи сервера снимается: вся история может выгружаться дольше
+const exportWriteTimeout = time.Minute
+
+// 7. GET /chats/{id}/export - выгрузка истории чата
+// Query параметры: format=json|csv|html|txt (по умолчанию json),
+// from, to - границы периода [from, to): RFC 3339 или дата 2006-01-02
+// Ответ отдается потоком с Content-Disposition: attachment
+func (h *ChatHandler) ExportChat(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.ExportChat")
+	defer span.End()
+
+	// Пример: /chats/123/export → parts = ["chats", "123", "export"]
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "chats" || parts[2] != "export" {
+		http.Error(w, "Неверный URL", http.StatusBadRequest) // 400
+		return
+	}
+	chatID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		http.Error(w, "Неверный ID чата", http.StatusBadRequest) // 400
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	from, err := parseExportTime(query.Get("from"))
+	if err != nil {
+		http.Error(w, "Неверный параметр from: ожидается RFC 3339 или дата ГГГГ-ММ-ДД", http.StatusBadRequest) // 400
+		return
+	}
+	to, err := parseExportTime(query.Get("to"))
+	if err != nil {
+		http.Error(w, "Неверный параметр to: ожидается RFC 3339 или дата ГГГГ-ММ-ДД", http.StatusBadRequest) // 400
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		http.Error(w, "from должен быть раньше to", http.StatusBadRequest) // 400
+		return
+	}
+
+	chat, err := h.service.GetChat(ctx, uint(chatID))
+	if err != nil {
+		if strings.Contains(err.Error(), "не найден") {
+			http.Error(w, "Чат не найден", http.StatusNotFound) // 404
+		} else {
+			slog.ErrorContext(ctx, "ошибка обработки запроса", slog.Any("error", err))
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError) // 500
+		}
+		return
+	}
+
+	meta := export.Meta{Chat: *chat, ExportedAt: time.Now(), From: from, To: to}
+	out, err := export.New(format, w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest) // 400
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": export.FileName(meta, format),
+	}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	// После заголовков статус уже не изменить: при ошибке обрываем ответ,
+	// клиент увидит незавершенный файл (нет закрывающей части формата)
+	rc := http.NewResponseController(w)
+	extendDeadline := func() {
+		if err := rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			slog.WarnContext(ctx, "не удалось продлить таймаут записи", slog.Any("error", err))
+		}
+	}
+	extendDeadline()
+
+	err = out.Begin(meta)
+	if err == nil {
+		err = h.service.ExportMessages(ctx, uint(chatID), from, to, func(batch []models.Message) error {
+			extendDeadline()
+			if err := out.Write(batch); err != nil {
+				return err
+			}
+			// Отдаем пачку клиенту сразу, не копим в буфере сервера
+			if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+			return nil
+		})
+	}
+	if err == nil {
+		err = out.End()
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "выгрузка чата прервана",
+			slog.Uint64("chat_id", uint64(chatID)),
+			slog.Any("error", err),
+		)
+		panic(http.ErrAbortHandler) // обрываем соединение, чтобы файл не выглядел полным
+	}
+}
+
+// parseExportTime разбирает границу периода: RFC 3339 или дата (полночь UTC)
+func parseExportTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-chat-app/internal/db/service"
+)
+
+// 19. POST /chats/{id}/messages/{message_id}/forward - переслать сообщение в другой чат
+// Тело запроса: {"chat_id": 7} - куда переслать
+// Ответ: пересланное сообщение (201) с forwarded_from - откуда оно переслано
+func (h *ChatHandler) ForwardMessage(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.ForwardMessage")
+	defer span.End()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed) // 405
+		return
+	}
+
+	// Пример: /chats/123/messages/45/forward → parts = ["chats", "123", "messages", "45", "forward"]
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 5 || parts[0] != "chats" || parts[2] != "messages" || parts[4] != "forward" {
+		http.NotFound(w, r)
+		return
+	}
+	chatID, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		http.Error(w, "Неверный ID чата", http.StatusBadRequest) // 400
+		return
+	}
+	messageID, err := strconv.ParseUint(parts[3], 10, 32)
+	if err != nil {
+		http.Error(w, "Неверный ID сообщения", http.StatusBadRequest) // 400
+		return
+	}
+
+	var data struct {
+		ChatID uint `json:"chat_id"` // чат, в который пересылается сообщение
+		// ClientMsgID - ключ идемпотентности, как при отправке сообщения
+		ClientMsgID string `json:"client_msg_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, "Неверный JSON", http.StatusBadRequest) // 400
+		return
+	}
+	if data.ChatID == 0 {
+		http.Error(w, "Укажите chat_id - куда переслать сообщение", http.StatusBadRequest) // 400
+		return
+	}
+
+	message, err := h.service.ForwardMessage(ctx, uint(chatID), uint(messageID), data.ChatID)
+	if err != nil {
+		var rateErr *service.RateLimitError
+		switch {
+		case errors.As(err, &rateErr):
+			// Медленный режим целевого чата, как при отправке
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rateErr.RetryAfter.Seconds()))))
+			http.Error(w, err.Error(), http.StatusTooManyRequests) // 429
+		case errors.Is(err, service.ErrNoUser):
+			http.Error(w, "Пользователь не определен", http.StatusUnauthorized) // 401
+		case errors.Is(err, service.ErrChatNotFound):
+			http.Error(w, "Чат не найден", http.StatusNotFound) // 404
+		case errors.Is(err, service.ErrMessageNotFound):
+			http.Error(w, "Сообщение не найдено или удалено", http.StatusNotFound) // 404
+		case errors.Is(err, service.ErrForwardTargetNotFound):
+			http.Error(w, "Чат, в который пересылается сообщение, не найден", http.StatusNotFound) // 404
+		case errors.Is(err, service.ErrForwardSameChat) || errors.Is(err, service.ErrNotForwardable):
+			http.Error(w, err.Error(), http.StatusBadRequest) // 400
+		default:
+			slog.ErrorContext(ctx, "ошибка обработки запроса", slog.Any("error", err))
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError) // 500
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated) // 201 Created
+	json.NewEncoder(w).Encode(message)
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HealthCheck - одна проверка зависимости для readiness пробы
+type HealthCheck struct {
+	Name  string                          // имя проверки в ответе (например "database")
+	Check func(ctx context.Context) error // nil - зависимость в порядке
+}
+
+// checkResult - результат одной проверки в JSON ответе /readyz
+type checkResult struct {
+	Status    string  `json:"status"` // "ok" или "fail"
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthHandler обслуживает пробы Kubernetes/Docker:
+//
+//	/livez  - процесс жив (не зависит от внешних сервисов)
+//	/readyz - приложение готово принимать трафик (БД доступна, схема актуальна)
+type HealthHandler struct {
+	checks   []HealthCheck
+	timeout  time.Duration // общий таймаут на все проверки
+	shutdown atomic.Bool   // true - сервер останавливается, новый трафик не нужен
+}
+
+// NewHealthHandler создает обработчик проб с набором проверок
+func NewHealthHandler(timeout time.Duration, checks ...HealthCheck) *HealthHandler {
+	return &HealthHandler{checks: checks, timeout: timeout}
+}
+
+// SetShuttingDown переводит readiness в "не готов"
+// Вызывается в начале graceful shutdown, чтобы балансировщик перестал слать запросы
+func (h *HealthHandler) SetShuttingDown() {
+	h.shutdown.Store(true)
+}
+
+// Live - GET /livez
+// Отвечает 200, пока процесс способен обрабатывать HTTP запросы
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// Ready - GET /readyz
+// Выполняет все проверки параллельно и отвечает 200 или 503 с деталями по каждой
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	results := make(map[string]checkResult, len(h.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, c := range h.checks {
+		wg.Add(1)
+		go func(c HealthCheck) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := c.Check(ctx)
+			res := checkResult{
+				Status:    "ok",
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				res.Status = "fail"
+				res.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[c.Name] = res
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	// Итоговый статус: готов только если все проверки прошли и нет остановки
+	status := "ok"
+	code := http.StatusOK
+	for _, res := range results {
+		if res.Status != "ok" {
+			status = "fail"
+			code = http.StatusServiceUnavailable // 503
+		}
+	}
+	if h.shutdown.Load() {
+		status = "shutting_down"
+		code = http.StatusServiceUnavailable // 503
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Status string                 `json:"status"`
+		Checks map[string]checkResult `json:"checks"`
+	}{
+		Status: status,
+		Checks: results,
+	})
+}
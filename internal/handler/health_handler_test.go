@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestReadiness проверяет итоговый статус /readyz в зависимости от проверок
+func TestReadiness(t *testing.T) {
+	ok := HealthCheck{Name: "database", Check: func(ctx context.Context) error { return nil }}
+	failing := HealthCheck{Name: "migrations", Check: func(ctx context.Context) error {
+		return errors.New("версия схемы 0, ожидается 1")
+	}}
+	slow := HealthCheck{Name: "database", Check: func(ctx context.Context) error {
+		<-ctx.Done() // зависшая БД: проверка завершится только по таймауту
+		return ctx.Err()
+	}}
+
+	tests := []struct {
+		name       string
+		checks     []HealthCheck
+		shutdown   bool
+		wantCode   int
+		wantStatus string
+	}{
+		{"все проверки прошли", []HealthCheck{ok}, false, http.StatusOK, "ok"},
+		{"одна проверка упала", []HealthCheck{ok, failing}, false, http.StatusServiceUnavailable, "fail"},
+		{"таймаут проверки", []HealthCheck{slow}, false, http.StatusServiceUnavailable, "fail"},
+		{"идет остановка", []HealthCheck{ok}, true, http.StatusServiceUnavailable, "shutting_down"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHealthHandler(50*time.Millisecond, tt.checks...)
+			if tt.shutdown {
+				h.SetShuttingDown()
+			}
+
+			rr := httptest.NewRecorder()
+			h.Ready(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+			if rr.Code != tt.wantCode {
+				t.Errorf("Ожидался статус %d, получен %d", tt.wantCode, rr.Code)
+			}
+
+			var body struct {
+				Status string                 `json:"status"`
+				Checks map[string]checkResult `json:"checks"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+				t.Fatalf("Некорректный JSON: %v", err)
+			}
+			if body.Status != tt.wantStatus {
+				t.Errorf("Ожидался status %q, получен %q", tt.wantStatus, body.Status)
+			}
+			if len(body.Checks) != len(tt.checks) {
+				t.Errorf("Ожидалось %d проверок в ответе, получено %d", len(tt.checks), len(body.Checks))
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-chat-app/internal/db/service"
+	"go-chat-app/internal/models"
+)
+
+// Mentions разбирает путь /me/mentions[/read] и вызывает обработчик по методу
+func (h *ChatHandler) Mentions(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "/me/mentions":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed) // 405
+			return
+		}
+		h.ListMentions(w, r)
+	case "/me/mentions/read":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed) // 405
+			return
+		}
+		h.MarkMentionsRead(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// 15. GET /me/mentions - упоминания пользователя запроса, новые первыми
+// Параметры: unread=true - только непрочитанные, limit (по умолчанию 50, максимум 100),
+// before - ID уведомления, с которого начинается следующая страница (next_before)
+// Ответ: {"mentions": [...], "unread_count": 3, "next_before": 17}
+func (h *ChatHandler) ListMentions(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.ListMentions")
+	defer span.End()
+
+	query := r.URL.Query()
+	var unreadOnly bool
+	if unreadStr := query.Get("unread"); unreadStr != "" {
+		var err error
+		if unreadOnly, err = strconv.ParseBool(unreadStr); err != nil {
+			http.Error(w, "Неверный параметр unread", http.StatusBadRequest) // 400
+			return
+		}
+	}
+	limit := 50
+	if limitStr := query.Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			http.Error(w, "Неверный limit", http.StatusBadRequest) // 400
+			return
+		}
+		limit = min(l, 100)
+	}
+	var beforeID uint64
+	if beforeStr := query.Get("before"); beforeStr != "" {
+		var err error
+		if beforeID, err = strconv.ParseUint(beforeStr, 10, 32); err != nil {
+			http.Error(w, "Неверный параметр before", http.StatusBadRequest) // 400
+			return
+		}
+	}
+
+	page, err := h.service.ListMentions(ctx, unreadOnly, uint(beforeID), limit)
+	if err != nil {
+		writeMentionError(ctx, w, err)
+		return
+	}
+
+	// Полная страница - возможно, есть следующая: клиент продолжит с before=next_before
+	var nextBefore *uint
+	if len(page.Mentions) == limit {
+		nextBefore = &page.Mentions[len(page.Mentions)-1].ID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Mentions    []models.MessageMention `json:"mentions"`
+		UnreadCount int64                   `json:"unread_count"`
+		NextBefore  *uint                   `json:"next_before,omitempty"`
+	}{
+		Mentions:    page.Mentions,
+		UnreadCount: page.UnreadCount,
+		NextBefore:  nextBefore,
+	})
+}
+
+// 16. POST /me/mentions/read - отметить упоминания прочитанными
+// Тело запроса: {"ids": [17, 18]}; без ids (или пустой список) - все непрочитанные
+// Ответ: {"marked": 2} - сколько уведомлений отмечено сейчас
+func (h *ChatHandler) MarkMentionsRead(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.MarkMentionsRead")
+	defer span.End()
+
+	var data struct {
+		IDs []uint `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, "Неверный JSON", http.StatusBadRequest) // 400
+		return
+	}
+
+	marked, err := h.service.MarkMentionsRead(ctx, data.IDs)
+	if err != nil {
+		writeMentionError(ctx, w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Marked int64 `json:"marked"`
+	}{
+		Marked: marked,
+	})
+}
+
+// writeMentionError отвечает на ошибку сервиса упоминаний
+func writeMentionError(ctx context.Context, w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrMentionsDisabled):
+		http.Error(w, "Упоминания не настроены", http.StatusNotImplemented) // 501
+	case errors.Is(err, service.ErrNoUser):
+		http.Error(w, "Пользователь не определен", http.StatusUnauthorized) // 401
+	default:
+		slog.ErrorContext(ctx, "ошибка обработки запроса", slog.Any("error", err))
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError) // 500
+	}
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-chat-app/internal/db/service"
+	"go-chat-app/internal/models"
+)
+
+// Pins разбирает путь /chats/{id}/pins[/{message_id}] и вызывает обработчик по методу
+func (h *ChatHandler) Pins(w http.ResponseWriter, r *http.Request) {
+	// Пример: /chats/123/pins/45 → parts = ["chats", "123", "pins", "45"]
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 3 || len(parts) > 4 || parts[0] != "chats" || parts[2] != "pins" {
+		http.NotFound(w, r)
+		return
+	}
+	chatID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		http.Error(w, "Неверный ID чата", http.StatusBadRequest) // 400
+		return
+	}
+
+	// Коллекция: /chats/{id}/pins
+	if len(parts) == 3 {
+		switch r.Method {
+		case http.MethodPost:
+			h.PinMessage(w, r, uint(chatID))
+		case http.MethodGet:
+			h.ListPins(w, r, uint(chatID))
+		default:
+			http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed) // 405
+		}
+		return
+	}
+
+	// Одно закрепление: /chats/{id}/pins/{message_id}
+	messageID, err := strconv.ParseUint(parts[3], 10, 32)
+	if err != nil {
+		http.Error(w, "Неверный ID сообщения", http.StatusBadRequest) // 400
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed) // 405
+		return
+	}
+	h.UnpinMessage(w, r, uint(chatID), uint(messageID))
+}
+
+// 12. POST /chats/{id}/pins - закрепить сообщение
+// Тело запроса: {"message_id": 45}
+// Ответ: закрепление с сообщением в формате JSON; в чат отправляется служебное сообщение
+func (h *ChatHandler) PinMessage(w http.ResponseWriter, r *http.Request, chatID uint) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.PinMessage")
+	defer span.End()
+
+	var data struct {
+		MessageID uint `json:"message_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, "Неверный JSON", http.StatusBadRequest) // 400
+		return
+	}
+	if data.MessageID == 0 {
+		http.Error(w, "message_id не может быть пустым", http.StatusBadRequest) // 400
+		return
+	}
+
+	pin, err := h.service.PinMessage(ctx, chatID, data.MessageID)
+	if err != nil {
+		writePinError(ctx, w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated) // 201 Created
+	json.NewEncoder(w).Encode(pin)
+}
+
+// 13. GET /chats/{id}/pins - закрепленные сообщения чата, последние закрепленные первыми
+// Ответ: {"pins": [{"message_id": 45, "pinned_by": "alice", "pinned_at": "...", "message": {...}}]}
+func (h *ChatHandler) ListPins(w http.ResponseWriter, r *http.Request, chatID uint) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.ListPins")
+	defer span.End()
+
+	pins, err := h.service.ListPins(ctx, chatID)
+	if err != nil {
+		writePinError(ctx, w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Pins []models.Pin `json:"pins"`
+	}{
+		Pins: pins,
+	})
+}
+
+// 14. DELETE /chats/{id}/pins/{message_id} - открепить сообщение
+// Ответ: 204 No Content
+func (h *ChatHandler) UnpinMessage(w http.ResponseWriter, r *http.Request, chatID, messageID uint) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.UnpinMessage")
+	defer span.End()
+
+	if err := h.service.UnpinMessage(ctx, chatID, messageID); err != nil {
+		writePinError(ctx, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent) // 204
+}
+
+// writePinError отвечает на ошибку сервиса закрепленных сообщений
+func writePinError(ctx context.Context, w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrPinsDisabled):
+		http.Error(w, "Закрепленные сообщения не настроены", http.StatusNotImplemented) // 501
+	case errors.Is(err, service.ErrAlreadyPinned) || errors.Is(err, service.ErrPinLimit):
+		http.Error(w, err.Error(), http.StatusConflict) // 409
+	case errors.Is(err, service.ErrMessageNotFound):
+		http.Error(w, "Сообщение не найдено", http.StatusNotFound) // 404
+	case errors.Is(err, service.ErrNotPinned):
+		http.Error(w, "Сообщение не закреплено", http.StatusNotFound) // 404
+	case strings.Contains(err.Error(), "не найден"):
+		http.Error(w, "Чат не найден", http.StatusNotFound) // 404
+	default:
+		slog.ErrorContext(ctx, "ошибка обработки запроса", slog.Any("error", err))
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError) // 500
+	}
+}
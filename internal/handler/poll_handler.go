@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-chat-app/internal/db/service"
+)
+
+// Votes разбирает путь /chats/{id}/messages/{message_id}/votes и вызывает обработчик по методу
+func (h *ChatHandler) Votes(w http.ResponseWriter, r *http.Request) {
+	// Пример: /chats/123/messages/45/votes → parts = ["chats", "123", "messages", "45", "votes"]
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 5 || parts[0] != "chats" || parts[2] != "messages" || parts[4] != "votes" {
+		http.NotFound(w, r)
+		return
+	}
+	chatID, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		http.Error(w, "Неверный ID чата", http.StatusBadRequest) // 400
+		return
+	}
+	messageID, err := strconv.ParseUint(parts[3], 10, 32)
+	if err != nil {
+		http.Error(w, "Неверный ID сообщения", http.StatusBadRequest) // 400
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		h.Vote(w, r, uint(chatID), uint(messageID))
+	case http.MethodDelete:
+		h.RetractVote(w, r, uint(chatID), uint(messageID))
+	default:
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed) // 405
+	}
+}
+
+// 17. POST /chats/{id}/messages/{message_id}/votes - проголосовать в опросе
+// Тело запроса: {"options": [0, 2]} - номера вариантов с 0; повторный голос заменяет прежний
+// Ответ: опрос с итогами и выбором пользователя (my_votes); подписчики получают poll.updated
+func (h *ChatHandler) Vote(w http.ResponseWriter, r *http.Request, chatID, messageID uint) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.Vote")
+	defer span.End()
+
+	var data struct {
+		Options []int `json:"options"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, "Неверный JSON", http.StatusBadRequest) // 400
+		return
+	}
+
+	poll, err := h.service.Vote(ctx, chatID, messageID, data.Options)
+	if err != nil {
+		writePollError(ctx, w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(poll)
+}
+
+// 18. DELETE /chats/{id}/messages/{message_id}/votes - отозвать свой голос
+// Ответ: опрос с итогами без голосов пользователя; подписчики получают poll.updated
+func (h *ChatHandler) RetractVote(w http.ResponseWriter, r *http.Request, chatID, messageID uint) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.RetractVote")
+	defer span.End()
+
+	poll, err := h.service.RetractVote(ctx, chatID, messageID)
+	if err != nil {
+		writePollError(ctx, w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(poll)
+}
+
+// writePollError отвечает на ошибку голосования
+func writePollError(ctx context.Context, w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrPollsDisabled):
+		http.Error(w, "Опросы не настроены", http.StatusNotImplemented) // 501
+	case errors.Is(err, service.ErrNoUser):
+		http.Error(w, "Пользователь не определен", http.StatusUnauthorized) // 401
+	case errors.Is(err, service.ErrChatNotFound):
+		http.Error(w, "Чат не найден", http.StatusNotFound) // 404
+	case errors.Is(err, service.ErrMessageNotFound):
+		http.Error(w, "Сообщение не найдено", http.StatusNotFound) // 404
+	case errors.Is(err, service.ErrNotPoll) || errors.Is(err, service.ErrInvalidVote):
+		http.Error(w, err.Error(), http.StatusBadRequest) // 400
+	case errors.Is(err, service.ErrPollClosed):
+		http.Error(w, "Опрос закрыт", http.StatusConflict) // 409
+	default:
+		slog.ErrorContext(ctx, "ошибка обработки запроса", slog.Any("error", err))
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError) // 500
+	}
+}
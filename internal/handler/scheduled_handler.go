@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-chat-app/internal/db/service"
+	"go-chat-app/internal/models"
+)
+
+// Scheduled разбирает путь /chats/{id}/scheduled-messages[/{sid}] и вызывает обработчик по методу
+func (h *ChatHandler) Scheduled(w http.ResponseWriter, r *http.Request) {
+	// Пример: /chats/123/scheduled-messages/7 → parts = ["chats", "123", "scheduled-messages", "7"]
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 3 || len(parts) > 4 || parts[0] != "chats" || parts[2] != "scheduled-messages" {
+		http.NotFound(w, r)
+		return
+	}
+	chatID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		http.Error(w, "Неверный ID чата", http.StatusBadRequest) // 400
+		return
+	}
+
+	// Коллекция: /chats/{id}/scheduled-messages
+	if len(parts) == 3 {
+		switch r.Method {
+		case http.MethodPost:
+			h.ScheduleMessage(w, r, uint(chatID))
+		case http.MethodGet:
+			h.ListScheduled(w, r, uint(chatID))
+		default:
+			http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed) // 405
+		}
+		return
+	}
+
+	// Одно сообщение: /chats/{id}/scheduled-messages/{sid}
+	id, err := strconv.ParseUint(parts[3], 10, 32)
+	if err != nil {
+		http.Error(w, "Неверный ID запланированного сообщения", http.StatusBadRequest) // 400
+		return
+	}
+	switch r.Method {
+	case http.MethodPatch:
+		h.UpdateScheduled(w, r, uint(chatID), uint(id))
+	case http.MethodDelete:
+		h.CancelScheduled(w, r, uint(chatID), uint(id))
+	default:
+		http.Error(w, "Метод не разрешен", http.StatusMethodNotAllowed) // 405
+	}
+}
+
+// 8. POST /chats/{id}/scheduled-messages - запланировать сообщение
+// Тело запроса: {"text": "Стендап через 5 минут", "send_at": "2026-01-23T09:55:00Z"}
+// Ответ: запланированное сообщение в формате JSON (status = pending)
+func (h *ChatHandler) ScheduleMessage(w http.ResponseWriter, r *http.Request, chatID uint) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.ScheduleMessage")
+	defer span.End()
+
+	var data struct {
+		Text   string     `json:"text"`
+		SendAt *time.Time `json:"send_at"`
+		// ClientMsgID - ключ идемпотентности, как у обычных сообщений (idempotency.Middleware)
+		ClientMsgID string `json:"client_msg_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, "Неверный JSON", http.StatusBadRequest) // 400
+		return
+	}
+	if data.SendAt == nil {
+		http.Error(w, "send_at не может быть пустым", http.StatusBadRequest) // 400
+		return
+	}
+
+	message, err := h.service.ScheduleMessage(ctx, chatID, data.Text, *data.SendAt)
+	if err != nil {
+		writeScheduledError(ctx, w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated) // 201 Created
+	json.NewEncoder(w).Encode(message)
+}
+
+// 9. GET /chats/{id}/scheduled-messages - запланированные сообщения чата в порядке отправки
+// Query параметры: status=pending|sending|sent|failed|canceled (по умолчанию все),
+// limit (по умолчанию 50, максимум 100)
+// Ответ: {"scheduled_messages": [...]}
+func (h *ChatHandler) ListScheduled(w http.ResponseWriter, r *http.Request, chatID uint) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.ListScheduled")
+	defer span.End()
+
+	query := r.URL.Query()
+	status := query.Get("status")
+	if status != "" && !slices.Contains(models.ScheduledStatuses, status) {
+		http.Error(w, "Неверный status: ожидается "+strings.Join(models.ScheduledStatuses, ", "), http.StatusBadRequest) // 400
+		return
+	}
+	limit := 50
+	if limitStr := query.Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			http.Error(w, "Неверный limit", http.StatusBadRequest) // 400
+			return
+		}
+		limit = min(l, 100)
+	}
+
+	messages, err := h.service.ListScheduled(ctx, chatID, status, limit)
+	if err != nil {
+		writeScheduledError(ctx, w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		ScheduledMessages []models.ScheduledMessage `json:"scheduled_messages"`
+	}{
+		ScheduledMessages: messages,
+	})
+}
+
+// 10. PATCH /chats/{id}/scheduled-messages/{sid} - изменить текст и/или время отправки
+// Тело запроса: {"text": "...", "send_at": "..."} (любое из полей)
+// Только для status = pending, иначе 409
+// Ответ: обновленное запланированное сообщение
+func (h *ChatHandler) UpdateScheduled(w http.ResponseWriter, r *http.Request, chatID, id uint) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.UpdateScheduled")
+	defer span.End()
+
+	var data struct {
+		Text   *string    `json:"text"`
+		SendAt *time.Time `json:"send_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		http.Error(w, "Неверный JSON", http.StatusBadRequest) // 400
+		return
+	}
+	if data.Text == nil && data.SendAt == nil {
+		http.Error(w, "Нет изменяемых полей", http.StatusBadRequest) // 400
+		return
+	}
+
+	message, err := h.service.UpdateScheduled(ctx, chatID, id, service.ScheduledUpdate{Text: data.Text, SendAt: data.SendAt})
+	if err != nil {
+		writeScheduledError(ctx, w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(message)
+}
+
+// 11. DELETE /chats/{id}/scheduled-messages/{sid} - отменить запланированное сообщение
+// Запись остается в списке со статусом canceled; только для status = pending, иначе 409
+// Ответ: 204 No Content
+func (h *ChatHandler) CancelScheduled(w http.ResponseWriter, r *http.Request, chatID, id uint) {
+	ctx, span := tracer.Start(r.Context(), "ChatHandler.CancelScheduled")
+	defer span.End()
+
+	if _, err := h.service.CancelScheduled(ctx, chatID, id); err != nil {
+		writeScheduledError(ctx, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent) // 204
+}
+
+// writeScheduledError отвечает на ошибку сервиса запланированных сообщений
+func writeScheduledError(ctx context.Context, w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrSchedulingDisabled):
+		http.Error(w, "Запланированные сообщения не настроены", http.StatusNotImplemented) // 501
+	case errors.Is(err, service.ErrScheduledNotPending):
+		http.Error(w, err.Error(), http.StatusConflict) // 409
+	case errors.Is(err, service.ErrScheduledNotFound):
+		http.Error(w, "Запланированное сообщение не найдено", http.StatusNotFound) // 404
+	case strings.Contains(err.Error(), "не найден"):
+		http.Error(w, "Чат не найден", http.StatusNotFound) // 404
+	case strings.Contains(err.Error(), "не может быть пустым") ||
+		strings.Contains(err.Error(), "не более"):
+		http.Error(w, err.Error(), http.StatusBadRequest) // 400
+	default:
+		slog.ErrorContext(ctx, "ошибка обработки запроса", slog.Any("error", err))
+		http.Error(w, "Ошибка сервера", http.StatusInternalServerError) // 500
+	}
+}
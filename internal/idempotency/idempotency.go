@@ -0,0 +1,239 @@
+// Package idempotency - повтор запросов с ключом идемпотентности без повторного выполнения
+//
+// Клиент передает ключ в заголовке Idempotency-Key (или, для сообщений, в поле
+// client_msg_id тела запроса). Первый запрос с ключом выполняется, его ответ сохраняется;
+// повторы с тем же ключом и тем же телом получают сохраненный ответ, а повтор с тем же
+// ключом, но другим телом - 422 (у анонимных клиентов - новый запрос, см. requestScope)
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-chat-app/internal/auth"
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/ratelimit"
+	"go-chat-app/internal/repository"
+)
+
+const (
+	// Header - заголовок с ключом идемпотентности
+	Header = "Idempotency-Key"
+	// ReplayedHeader выставляется в ответах, взятых из хранилища
+	ReplayedHeader = "Idempotent-Replayed"
+
+	// maxKeyLength - максимальная длина ключа
+	maxKeyLength = 255
+	// maxBodySize - максимальный размер тела запроса, который читается для хеширования
+	maxBodySize = 1 << 20
+	// DefaultTTL - время хранения ключа по умолчанию
+	DefaultTTL = 24 * time.Hour
+)
+
+// Middleware оборачивает next: запросы создания чатов и сообщений с ключом
+// выполняются не больше одного раза за ttl
+// store == nil - идемпотентность выключена
+func Middleware(store repository.IdempotencyStore, ttl time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	if store == nil {
+		return next
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !applies(req) {
+			next(w, req)
+			return
+		}
+
+		// Тело читаем целиком: оно нужно и для хеша, и самому обработчику
+		body, err := io.ReadAll(io.LimitReader(req.Body, maxBodySize+1))
+		if err != nil {
+			http.Error(w, "Не удалось прочитать тело запроса", http.StatusBadRequest) // 400
+			return
+		}
+		if len(body) > maxBodySize {
+			http.Error(w, "Слишком большое тело запроса", http.StatusRequestEntityTooLarge) // 413
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		key := requestKey(req, body)
+		if key == "" {
+			next(w, req)
+			return
+		}
+		if len(key) > maxKeyLength {
+			http.Error(w, "Ключ идемпотентности не более 255 символов", http.StatusBadRequest) // 400
+			return
+		}
+
+		ctx := req.Context()
+		hash := requestHash(req, body)
+		scope := requestScope(req, hash)
+
+		existing, claimed, err := store.Claim(ctx, &models.IdempotencyKey{
+			Scope:       scope,
+			Key:         key,
+			RequestHash: hash,
+			ExpiresAt:   time.Now().Add(ttl),
+		})
+		if err != nil {
+			// Недоступное хранилище ключей не должно останавливать API,
+			// но без него повтор может создать дубликат - предупреждаем в логах
+			slog.WarnContext(ctx, "не удалось проверить ключ идемпотентности", slog.Any("error", err))
+			next(w, req)
+			return
+		}
+
+		if !claimed {
+			switch {
+			case existing.RequestHash != hash:
+				http.Error(w, "Ключ идемпотентности уже использован с другим запросом", http.StatusUnprocessableEntity) // 422
+			case !existing.Completed():
+				// Первый запрос еще выполняется - клиенту стоит повторить позже
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Запрос с этим ключом идемпотентности еще выполняется", http.StatusConflict) // 409
+			default:
+				replay(w, existing)
+			}
+			return
+		}
+
+		rec := &recorder{header: http.Header{}, status: http.StatusOK}
+		completed := false
+		defer func() {
+			// Паника или ошибка сервера: снимаем резерв, чтобы повтор мог выполниться заново
+			if !completed {
+				if err := store.Release(ctx, scope, key); err != nil {
+					slog.WarnContext(ctx, "не удалось снять резерв ключа идемпотентности", slog.Any("error", err))
+				}
+			}
+		}()
+
+		next(rec, req)
+
+		// Сохраняем только окончательные ответы: 5xx и 429 клиент вправе повторить
+		if rec.status < 500 && rec.status != http.StatusTooManyRequests {
+			err := store.Complete(ctx, scope, key, rec.status, rec.header.Get("Content-Type"), rec.body.Bytes())
+			if err != nil {
+				slog.WarnContext(ctx, "не удалось сохранить ответ для ключа идемпотентности", slog.Any("error", err))
+			} else {
+				completed = true
+			}
+		}
+		rec.flush(w)
+	}
+}
+
+// requestScope возвращает область ключа: ключи разных клиентов и разных endpoint'ов не пересекаются
+// Анонимный клиент известен только по IP, а за одним NAT их может быть много: чтобы чужой
+// ключ с тем же значением не вернул ему чужой ответ или 422, в область входит и хеш запроса
+// Поэтому для анонимов ключ повторяет только точно такой же запрос, а тот же ключ с другим
+// телом выполняется как новый запрос, без 422
+func requestScope(req *http.Request, hash string) string {
+	scope := ratelimit.Client(req.Context()) + " " + req.Method + " " + strings.TrimSuffix(req.URL.Path, "/")
+	if _, ok := auth.UserID(req.Context()); !ok {
+		scope += " " + hash
+	}
+	return scope
+}
+
+// applies сообщает, поддерживает ли запрос ключи идемпотентности:
+// POST /chats, POST /chats/{id}/messages, POST /chats/{id}/scheduled-messages
+// и POST /chats/{id}/messages/{message_id}/forward
+func applies(req *http.Request) bool {
+	if req.Method != http.MethodPost {
+		return false
+	}
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	return path == "/chats" || (strings.HasPrefix(path, "/chats/") && isMessagePath(path))
+}
+
+// isMessagePath сообщает, создает ли POST по пути сообщение (обычное, запланированное или пересланное)
+func isMessagePath(path string) bool {
+	return strings.HasSuffix(path, "/messages") || strings.HasSuffix(path, "/scheduled-messages") ||
+		strings.HasSuffix(path, "/forward")
+}
+
+// requestKey возвращает ключ из заголовка Idempotency-Key,
+// а для сообщений без заголовка - из поля client_msg_id тела запроса
+func requestKey(req *http.Request, body []byte) string {
+	if key := strings.TrimSpace(req.Header.Get(Header)); key != "" {
+		return key
+	}
+	if !isMessagePath(strings.TrimSuffix(req.URL.Path, "/")) {
+		return ""
+	}
+	var data struct {
+		ClientMsgID string `json:"client_msg_id"`
+	}
+	// Некорректный JSON здесь не ошибка - его отклонит обработчик
+	_ = json.Unmarshal(body, &data)
+	return strings.TrimSpace(data.ClientMsgID)
+}
+
+// requestHash - хеш метода, пути и тела запроса
+func requestHash(req *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, req.Method+" "+strings.TrimSuffix(req.URL.Path, "/")+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replay отправляет сохраненный ответ
+func replay(w http.ResponseWriter, key *models.IdempotencyKey) {
+	if key.ContentType != "" {
+		w.Header().Set("Content-Type", key.ContentType)
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.Header().Set("Content-Length", strconv.Itoa(len(key.ResponseBody)))
+	w.WriteHeader(key.StatusCode)
+	w.Write(key.ResponseBody)
+}
+
+// recorder буферизует ответ обработчика, чтобы его можно было сохранить
+type recorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+// Header возвращает заголовки ответа
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+// WriteHeader запоминает статус (только первый вызов, как в net/http)
+func (r *recorder) WriteHeader(code int) {
+	if r.wroteHeader {
+		return
+	}
+	r.status = code
+	r.wroteHeader = true
+}
+
+// Write пишет тело ответа в буфер
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.body.Write(b)
+}
+
+// flush отправляет буферизованный ответ клиенту
+func (r *recorder) flush(w http.ResponseWriter) {
+	for name, values := range r.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(r.status)
+	w.Write(r.body.Bytes())
+}
@@ -0,0 +1,187 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-chat-app/internal/auth"
+	"go-chat-app/internal/ratelimit"
+	"go-chat-app/internal/repository/memory"
+)
+
+// countingHandler создает "сообщение" на каждый вызов и возвращает его номер
+func countingHandler(calls *int, status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"id":%d}`, *calls)
+	}
+}
+
+// send выполняет запрос от имени клиента client ("user:<id>" - пользователь, "ip:<адрес>" - аноним)
+func send(h http.HandlerFunc, client, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+	ctx := ratelimit.WithClient(req.Context(), client)
+	if userID, ok := strings.CutPrefix(client, "user:"); ok {
+		ctx = auth.WithUser(ctx, userID)
+	}
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+	h(rr, req)
+	return rr
+}
+
+// TestMiddlewareReplay проверяет повтор ответа, 422 при другом теле
+// и независимость ключей разных клиентов
+func TestMiddlewareReplay(t *testing.T) {
+	calls := 0
+	h := Middleware(memory.New().Idempotency(), time.Hour, countingHandler(&calls, http.StatusCreated))
+
+	first := send(h, "user:1", "/chats", "key-1", `{"title":"a"}`)
+	if first.Code != http.StatusCreated || first.Body.String() != `{"id":1}` {
+		t.Fatalf("Первый запрос: %d %s", first.Code, first.Body.String())
+	}
+
+	replayed := send(h, "user:1", "/chats", "key-1", `{"title":"a"}`)
+	if calls != 1 {
+		t.Errorf("Повтор не должен вызывать обработчик, вызовов: %d", calls)
+	}
+	if replayed.Code != http.StatusCreated || replayed.Body.String() != `{"id":1}` {
+		t.Errorf("Повтор вернул другой ответ: %d %s", replayed.Code, replayed.Body.String())
+	}
+	if replayed.Header().Get(ReplayedHeader) != "true" || replayed.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Неверные заголовки повтора: %v", replayed.Header())
+	}
+
+	if rr := send(h, "user:1", "/chats", "key-1", `{"title":"b"}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Тот же ключ с другим телом: ожидался 422, получен %d", rr.Code)
+	}
+
+	if rr := send(h, "user:2", "/chats", "key-1", `{"title":"a"}`); rr.Code != http.StatusCreated || calls != 2 {
+		t.Errorf("Ключ другого клиента должен выполниться: %d, вызовов %d", rr.Code, calls)
+	}
+
+	if send(h, "user:1", "/chats", "", `{"title":"a"}`); calls != 3 {
+		t.Errorf("Запрос без ключа должен выполняться всегда, вызовов %d", calls)
+	}
+}
+
+// TestMiddlewareAnonymousScope проверяет, что анонимы за одним IP не получают чужих ответов по ключу
+func TestMiddlewareAnonymousScope(t *testing.T) {
+	calls := 0
+	h := Middleware(memory.New().Idempotency(), time.Hour, countingHandler(&calls, http.StatusCreated))
+
+	send(h, "ip:10.0.0.1", "/chats", "key-1", `{"title":"a"}`)
+	if rr := send(h, "ip:10.0.0.1", "/chats", "key-1", `{"title":"b"}`); rr.Code != http.StatusCreated || calls != 2 {
+		t.Errorf("Тот же ключ с другим телом от соседа по NAT должен выполниться: %d, вызовов %d", rr.Code, calls)
+	}
+	if rr := send(h, "ip:10.0.0.1", "/chats", "key-1", `{"title":"a"}`); rr.Header().Get(ReplayedHeader) != "true" || calls != 2 {
+		t.Errorf("Точный повтор анонимного запроса должен вернуть сохраненный ответ: %s, вызовов %d", rr.Body.String(), calls)
+	}
+}
+
+// TestMiddlewareClientMsgID проверяет ключ из поля client_msg_id тела сообщения
+func TestMiddlewareClientMsgID(t *testing.T) {
+	calls := 0
+	h := Middleware(memory.New().Idempotency(), time.Hour, countingHandler(&calls, http.StatusCreated))
+	body := `{"text":"привет","client_msg_id":"m-1"}`
+
+	send(h, "ip:1.2.3.4", "/chats/1/messages", "", body)
+	rr := send(h, "ip:1.2.3.4", "/chats/1/messages", "", body)
+	if calls != 1 || rr.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("client_msg_id должен работать как ключ: вызовов %d, заголовки %v", calls, rr.Header())
+	}
+
+	// В другом чате тот же client_msg_id - другой запрос
+	send(h, "ip:1.2.3.4", "/chats/2/messages", "", body)
+	if calls != 2 {
+		t.Errorf("Ключи разных чатов не должны пересекаться, вызовов %d", calls)
+	}
+
+	// Запланированные сообщения поддерживают тот же ключ
+	scheduled := `{"text":"привет","send_at":"2030-01-01T00:00:00Z","client_msg_id":"m-1"}`
+	send(h, "ip:1.2.3.4", "/chats/1/scheduled-messages", "", scheduled)
+	if rr := send(h, "ip:1.2.3.4", "/chats/1/scheduled-messages", "", scheduled); calls != 3 || rr.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("client_msg_id должен работать для запланированных сообщений: вызовов %d", calls)
+	}
+
+	// И пересылка: повтор не перешлет сообщение второй раз
+	forward := `{"chat_id":2,"client_msg_id":"m-1"}`
+	send(h, "ip:1.2.3.4", "/chats/1/messages/5/forward", "", forward)
+	if rr := send(h, "ip:1.2.3.4", "/chats/1/messages/5/forward", "", forward); calls != 4 || rr.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("client_msg_id должен работать для пересылки: вызовов %d", calls)
+	}
+}
+
+// TestMiddlewareServerErrorReleasesKey проверяет, что ответ 5xx не сохраняется
+func TestMiddlewareServerErrorReleasesKey(t *testing.T) {
+	calls := 0
+	status := http.StatusInternalServerError
+	h := Middleware(memory.New().Idempotency(), time.Hour, func(w http.ResponseWriter, req *http.Request) {
+		countingHandler(&calls, status)(w, req)
+	})
+
+	if rr := send(h, "user:1", "/chats", "k", `{}`); rr.Code != http.StatusInternalServerError {
+		t.Fatalf("Ожидался 500, получен %d", rr.Code)
+	}
+	status = http.StatusCreated
+	if rr := send(h, "user:1", "/chats", "k", `{}`); rr.Code != http.StatusCreated || calls != 2 {
+		t.Errorf("После 500 повтор должен выполниться заново: %d, вызовов %d", rr.Code, calls)
+	}
+}
+
+// TestMiddlewareInProgress проверяет ответ 409, пока первый запрос не завершен
+func TestMiddlewareInProgress(t *testing.T) {
+	store := memory.New().Idempotency()
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	h := Middleware(store, time.Hour, func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-finish
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		send(h, "user:1", "/chats", "k", `{}`)
+	}()
+	<-started
+
+	if rr := send(h, "user:1", "/chats", "k", `{}`); rr.Code != http.StatusConflict {
+		t.Errorf("Во время выполнения ожидался 409, получен %d", rr.Code)
+	}
+	close(finish)
+	<-done
+
+	if rr := send(h, "user:1", "/chats", "k", `{}`); rr.Code != http.StatusCreated {
+		t.Errorf("После завершения ожидался повтор 201, получен %d", rr.Code)
+	}
+}
+
+// TestMiddlewareExpiredKey проверяет, что истекший ключ можно использовать снова
+func TestMiddlewareExpiredKey(t *testing.T) {
+	calls := 0
+	store := memory.New().Idempotency()
+	h := Middleware(store, time.Millisecond, countingHandler(&calls, http.StatusCreated))
+
+	send(h, "user:1", "/chats", "k", `{"title":"a"}`)
+	time.Sleep(5 * time.Millisecond)
+	if rr := send(h, "user:1", "/chats", "k", `{"title":"b"}`); rr.Code != http.StatusCreated || calls != 2 {
+		t.Errorf("Истекший ключ должен выполниться заново: %d, вызовов %d", rr.Code, calls)
+	}
+
+	deleted, _ := store.DeleteExpired(context.Background(), time.Now().Add(time.Hour))
+	if deleted != 1 {
+		t.Errorf("Ожидалось удаление 1 ключа, удалено %d", deleted)
+	}
+}
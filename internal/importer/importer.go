@@ -0,0 +1,257 @@
+// Package importer - перенос истории чатов из выгрузок других мессенджеров
+//
+// Поддерживаются выгрузка рабочего пространства Slack (ZIP) и выгрузка Telegram Desktop
+// (result.json). Источник (Source) разбирает файл в чаты и сообщения, Importer создает
+// их через репозитории, сохраняя исходное время, и собирает отчет о пропущенном
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+)
+
+// Ограничения те же, что при создании через API (см. service.ChatService)
+const (
+	maxTitleLen  = 200
+	maxTextLen   = 5000
+	maxAuthorLen = 128
+)
+
+// batchSize - сколько сообщений сохраняется одной вставкой
+const batchSize = 500
+
+// maxExamples - сколько пропущенных элементов перечислять в отчете поименно
+// Остальные учитываются только в счетчиках по причинам
+const maxExamples = 100
+
+var (
+	// ErrUnknownSource - неизвестный тип выгрузки
+	ErrUnknownSource = errors.New("неизвестный источник импорта (slack, telegram)")
+	// ErrInvalidExport - файл не похож на выгрузку указанного источника
+	ErrInvalidExport = errors.New("некорректный файл выгрузки")
+)
+
+// Sources - поддерживаемые источники
+var Sources = []string{"slack", "telegram"}
+
+// Chat - чат из выгрузки
+type Chat struct {
+	SourceID  string    // ID в исходной системе (для отчета)
+	Title     string    // пустое - будет "<источник> <SourceID>"
+	CreatedAt time.Time // нулевое - время первого сообщения
+	Messages  []Message
+}
+
+// Message - сообщение из выгрузки
+type Message struct {
+	SourceID  string // ID в исходной системе (для отчета)
+	AuthorID  string // уже с префиксом источника: slack:alice, telegram:user123
+	Text      string
+	CreatedAt time.Time
+}
+
+// Source - разобранная выгрузка
+type Source interface {
+	// Name - имя источника (slack, telegram)
+	Name() string
+	// Chats вызывает fn для каждого чата выгрузки по очереди
+	// То, что перенести нельзя, источник отмечает в отчете через Report.Skip
+	Chats(rep *Report, fn func(*Chat) error) error
+}
+
+// Open открывает выгрузку источника source (slack - ZIP архив, telegram - result.json)
+func Open(source string, r io.ReaderAt, size int64) (Source, error) {
+	switch source {
+	case "slack":
+		return OpenSlack(r, size)
+	case "telegram":
+		return OpenTelegram(io.NewSectionReader(r, 0, size))
+	default:
+		return nil, ErrUnknownSource
+	}
+}
+
+// Report - отчет об импорте
+type Report struct {
+	Source   string         `json:"source"`
+	DryRun   bool           `json:"dry_run"`         // ничего не записано, только разбор
+	Chats    []ChatReport   `json:"chats"`           // перенесенные чаты
+	Messages int            `json:"messages"`        // перенесено сообщений всего
+	Split    int            `json:"split,omitempty"` // длинных сообщений, разбитых на части
+	Skipped  map[string]int `json:"skipped"`         // пропущено по причинам
+	// SkippedTotal - пропущено всего; примеры - только первые maxExamples
+	SkippedTotal int           `json:"skipped_total"`
+	Examples     []SkippedItem `json:"skipped_examples,omitempty"`
+}
+
+// ChatReport - результат по одному чату
+type ChatReport struct {
+	ID       uint   `json:"id,omitempty"` // ID созданного чата (нет при dry run)
+	SourceID string `json:"source_id"`
+	Title    string `json:"title"`
+	Messages int    `json:"messages"`
+}
+
+// SkippedItem - пропущенный элемент выгрузки
+type SkippedItem struct {
+	Chat   string `json:"chat,omitempty"`
+	Item   string `json:"item"`
+	Reason string `json:"reason"`
+}
+
+// Skip отмечает пропущенный элемент: chat - чат выгрузки, item - что именно
+func (r *Report) Skip(chat, item, reason string) {
+	if r.Skipped == nil {
+		r.Skipped = make(map[string]int)
+	}
+	r.Skipped[reason]++
+	r.SkippedTotal++
+	if len(r.Examples) < maxExamples {
+		r.Examples = append(r.Examples, SkippedItem{Chat: chat, Item: item, Reason: reason})
+	}
+}
+
+// Importer создает чаты и сообщения выгрузки в хранилище
+type Importer struct {
+	chats    repository.ChatStore
+	messages repository.MessageStore
+}
+
+// New создает Importer поверх хранилищ приложения
+func New(chats repository.ChatStore, messages repository.MessageStore) *Importer {
+	return &Importer{chats: chats, messages: messages}
+}
+
+// Import переносит все чаты источника src
+// dryRun - только разобрать выгрузку и составить отчет, ничего не записывая
+// Каждый запуск создает новые чаты: повторный импорт той же выгрузки продублирует историю
+// При ошибке хранилища возвращается отчет о том, что уже перенесено, и ошибка
+func (im *Importer) Import(ctx context.Context, src Source, dryRun bool) (*Report, error) {
+	rep := &Report{Source: src.Name(), DryRun: dryRun, Chats: []ChatReport{}, Skipped: map[string]int{}}
+	err := src.Chats(rep, func(chat *Chat) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return im.importChat(ctx, rep, src.Name(), chat, dryRun)
+	})
+	return rep, err
+}
+
+// importChat создает один чат и его сообщения пачками по batchSize
+func (im *Importer) importChat(ctx context.Context, rep *Report, source string, chat *Chat, dryRun bool) error {
+	title := truncate(strings.TrimSpace(chat.Title), maxTitleLen)
+	if title == "" {
+		title = truncate(source+" "+chat.SourceID, maxTitleLen)
+	}
+
+	messages := im.prepare(rep, title, chat.Messages)
+	createdAt := chat.CreatedAt
+	if len(messages) > 0 && (createdAt.IsZero() || messages[0].CreatedAt.Before(createdAt)) {
+		createdAt = messages[0].CreatedAt
+	}
+
+	result := ChatReport{SourceID: chat.SourceID, Title: title, Messages: len(messages)}
+	if dryRun {
+		rep.Chats = append(rep.Chats, result)
+		rep.Messages += len(messages)
+		return nil
+	}
+
+	// Время приводится к локальному, как у сообщений, созданных сервером (см. MessageRepository.ListRange)
+	stored := &models.Chat{Title: title}
+	if !createdAt.IsZero() {
+		stored.CreatedAt = createdAt.Local()
+	}
+	if err := im.chats.Create(ctx, stored); err != nil {
+		return fmt.Errorf("создание чата %q: %w", title, err)
+	}
+	result.ID = stored.ID
+	result.Messages = 0
+	rep.Chats = append(rep.Chats, result)
+	current := &rep.Chats[len(rep.Chats)-1]
+
+	for start := 0; start < len(messages); start += batchSize {
+		end := min(start+batchSize, len(messages))
+		batch := make([]models.Message, 0, end-start)
+		for _, m := range messages[start:end] {
+			batch = append(batch, models.Message{
+				ChatID:    stored.ID,
+				AuthorID:  m.AuthorID,
+				Text:      m.Text,
+				CreatedAt: m.CreatedAt.Local(),
+			})
+		}
+		if err := im.messages.CreateBatch(ctx, batch); err != nil {
+			return fmt.Errorf("сообщения чата %q: %w", title, err)
+		}
+		current.Messages += len(batch)
+		rep.Messages += len(batch)
+	}
+	return nil
+}
+
+// prepare проверяет сообщения чата по правилам API и сортирует их по времени
+// Пустые пропускаются, слишком длинные разбиваются на части
+func (im *Importer) prepare(rep *Report, chat string, messages []Message) []Message {
+	result := make([]Message, 0, len(messages))
+	for _, m := range messages {
+		m.Text = strings.TrimSpace(m.Text)
+		if m.Text == "" {
+			rep.Skip(chat, m.SourceID, "пустое сообщение")
+			continue
+		}
+		if m.CreatedAt.IsZero() {
+			rep.Skip(chat, m.SourceID, "нет времени сообщения")
+			continue
+		}
+		m.AuthorID = truncate(m.AuthorID, maxAuthorLen)
+		parts := splitText(m.Text, maxTextLen)
+		if len(parts) > 1 {
+			rep.Split++
+		}
+		for _, part := range parts {
+			m.Text = part
+			result = append(result, m)
+		}
+	}
+	// Стабильная сортировка сохраняет порядок частей и сообщений с одинаковым временем
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// truncate обрезает строку до max байт, не разрывая символ UTF-8
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// splitText делит текст на части не длиннее max байт
+// Граница по возможности - последний перевод строки или пробел в части
+func splitText(s string, max int) []string {
+	var parts []string
+	for len(s) > max {
+		cut := truncate(s, max)
+		if i := strings.LastIndexAny(cut, "\n "); i > max/2 {
+			cut = cut[:i]
+		}
+		parts = append(parts, strings.TrimSpace(cut))
+		s = strings.TrimSpace(s[len(cut):])
+	}
+	return append(parts, s)
+}
@@ -0,0 +1,223 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+	"go-chat-app/internal/repository/memory"
+)
+
+// slackZip собирает ZIP выгрузку Slack из файлов
+func slackZip(t *testing.T, files map[string]string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+// history возвращает сообщения чата в хронологическом порядке
+func history(t *testing.T, db *memory.DB, chatID uint) []models.Message {
+	t.Helper()
+	messages, err := db.Messages().ListRange(context.Background(), chatID, repository.MessageRange{}, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messages
+}
+
+// TestSlack проверяет перенос каналов Slack: время, авторы, разметку и пропуски
+func TestSlack(t *testing.T) {
+	archive := slackZip(t, map[string]string{
+		"channels.json": `[{"id":"C1","name":"general","created":1700000000},{"id":"C2","name":"random","created":1700000100}]`,
+		"users.json":    `[{"id":"U1","name":"alice"},{"id":"U2","name":"bob"}]`,
+		"groups.json":   `[{"id":"G1","name":"secret"}]`,
+		"general/2024-01-02.json": `[
+			{"type":"message","user":"U2","text":"второй день","ts":"1704153600.000200"}
+		]`,
+		"general/2024-01-01.json": `[
+			{"type":"message","user":"U1","text":"привет <@U2>, см. <https://example.com|доку> &amp; <#C2|random>","ts":"1704067200.123456"},
+			{"type":"message","subtype":"channel_join","user":"U2","text":"<@U2> has joined","ts":"1704067201.000000"},
+			{"type":"message","user":"U2","text":"","files":[{"id":"F1"}],"ts":"1704067202.000000"},
+			{"type":"message","subtype":"bot_message","username":"deploy","text":"выкатили <!here>","ts":"1704067203.000000"}
+		]`,
+		"secret/2024-01-01.json": `[{"type":"message","user":"U1","text":"тайна","ts":"1704067200.000000"}]`,
+		"stray/2024-01-01.json":  `[]`,
+	})
+
+	src, err := Open("slack", archive, archive.Size())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	db := memory.New()
+	rep, err := New(db.Chats(), db.Messages()).Import(context.Background(), src, false)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	if len(rep.Chats) != 2 || rep.Chats[0].Title != "general" || rep.Chats[0].Messages != 3 || rep.Chats[1].Messages != 0 {
+		t.Fatalf("Неверные чаты в отчете: %+v", rep.Chats)
+	}
+	if rep.Messages != 3 || rep.SkippedTotal != 4 {
+		t.Errorf("Ожидалось 3 сообщения и 4 пропуска: %+v", rep)
+	}
+	for _, reason := range []string{
+		"служебное сообщение (channel_join)",
+		"вложения не переносятся",
+		"приватные каналы и личные сообщения не переносятся",
+		"папка без описания в channels.json",
+	} {
+		if rep.Skipped[reason] != 1 {
+			t.Errorf("Пропуск %q: ожидался 1, получено %d", reason, rep.Skipped[reason])
+		}
+	}
+
+	chat, err := db.Chats().GetByID(context.Background(), rep.Chats[0].ID)
+	if err != nil || !chat.CreatedAt.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Время создания канала не сохранено: %+v, %v", chat, err)
+	}
+	got := history(t, db, chat.ID)
+	if len(got) != 3 {
+		t.Fatalf("Ожидалось 3 сообщения, получено %d", len(got))
+	}
+	if got[0].Text != "привет @bob, см. доку (https://example.com) & #random" || got[0].AuthorID != "slack:alice" {
+		t.Errorf("Неверное первое сообщение: %+v", got[0])
+	}
+	if !got[0].CreatedAt.Equal(time.Unix(1704067200, 123456000)) {
+		t.Errorf("Время не сохранено: %s", got[0].CreatedAt)
+	}
+	if got[1].Text != "выкатили @here" || got[1].AuthorID != "slack:deploy" {
+		t.Errorf("Неверное сообщение бота: %+v", got[1])
+	}
+	if got[2].Text != "второй день" {
+		t.Errorf("Дни должны идти по порядку: %+v", got[2])
+	}
+}
+
+// TestTelegram проверяет выгрузку одного чата Telegram и полную выгрузку
+func TestTelegram(t *testing.T) {
+	single := `{"name":"Команда","type":"private_supergroup","id":42,"messages":[
+		{"id":1,"type":"service","action":"create_group","date":"2024-01-01T10:00:00","date_unixtime":"1704103200","actor":"Alice"},
+		{"id":2,"type":"message","date":"2024-01-01T10:01:00","date_unixtime":"1704103260","from":"Alice","from_id":"user1",
+		 "text":["смотри ",{"type":"text_link","text":"тут","href":"https://example.com"},{"type":"bold","text":"!"}]},
+		{"id":3,"type":"message","date_unixtime":"1704103270","from":"Bob","from_id":"user2","photo":"photos/1.jpg","text":""},
+		{"id":4,"type":"message","date_unixtime":"1704103280","from":"Bob","from_id":"user2","file":"files/a.pdf","text":"отчет"}
+	]}`
+	src, err := Open("telegram", strings.NewReader(single), int64(len(single)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	db := memory.New()
+	rep, err := New(db.Chats(), db.Messages()).Import(context.Background(), src, false)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if len(rep.Chats) != 1 || rep.Chats[0].Title != "Команда" || rep.Chats[0].SourceID != "42" || rep.Messages != 2 {
+		t.Fatalf("Неверный отчет: %+v", rep)
+	}
+	if rep.Skipped["служебное сообщение (create_group)"] != 1 || rep.Skipped["вложения не переносятся"] != 2 {
+		t.Errorf("Неверные пропуски: %v", rep.Skipped)
+	}
+	got := history(t, db, rep.Chats[0].ID)
+	if len(got) != 2 || got[0].Text != "смотри тут (https://example.com)!" || got[0].AuthorID != "telegram:user1" ||
+		!got[0].CreatedAt.Equal(time.Unix(1704103260, 0)) || got[1].Text != "отчет" {
+		t.Errorf("Неверные сообщения: %+v", got)
+	}
+
+	full := `{"about":"...","chats":{"list":[
+		{"name":"Мама","type":"personal_chat","id":1,"messages":[{"id":1,"type":"message","date_unixtime":"1704103200","text":"привет"}]},
+		{"name":"Новости","type":"public_channel","id":2,"messages":[{"id":1,"type":"message","date_unixtime":"1704103200","from":"Новости","from_id":"channel2","text":"выпуск"}]}
+	]},"left_chats":{"list":[{"name":"","type":"private_group","id":3,"messages":[]}]}}`
+	src, err = Open("telegram", strings.NewReader(full), int64(len(full)))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	rep, err = New(db.Chats(), db.Messages()).Import(context.Background(), src, true)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if len(rep.Chats) != 2 || rep.Chats[0].Title != "Новости" || rep.Chats[1].Title != "telegram 3" || rep.Chats[0].ID != 0 {
+		t.Errorf("Неверные чаты полной выгрузки: %+v", rep.Chats)
+	}
+	if rep.Skipped["приватные каналы и личные сообщения не переносятся"] != 1 {
+		t.Errorf("Личный чат должен пропускаться: %v", rep.Skipped)
+	}
+	if chats, _ := db.Chats().List(context.Background(), 0, 10); len(chats) != 1 {
+		t.Errorf("Dry run не должен создавать чаты, чатов: %d", len(chats))
+	}
+}
+
+// TestLongMessagesSplit проверяет разбиение длинных сообщений и пачки вставки
+func TestLongMessagesSplit(t *testing.T) {
+	long := strings.Repeat("слово ", 1500) // 16500 байт
+	messages := []Message{{SourceID: "long", AuthorID: "slack:" + strings.Repeat("я", 100), Text: long, CreatedAt: time.Unix(1000, 0)}}
+	for i := 0; i < batchSize+10; i++ {
+		messages = append(messages, Message{SourceID: "m", Text: "x", CreatedAt: time.Unix(2000+int64(i), 0)})
+	}
+	messages = append(messages, Message{SourceID: "empty", Text: "  ", CreatedAt: time.Unix(1, 0)})
+
+	db := memory.New()
+	rep := &Report{}
+	err := New(db.Chats(), db.Messages()).importChat(context.Background(), rep, "slack", &Chat{SourceID: "C1", Messages: messages}, false)
+	if err != nil {
+		t.Fatalf("importChat: %v", err)
+	}
+	if rep.Split != 1 || rep.Skipped["пустое сообщение"] != 1 || rep.Chats[0].Title != "slack C1" {
+		t.Errorf("Неверный отчет: %+v", rep)
+	}
+
+	got := history(t, db, rep.Chats[0].ID)
+	parts := 0
+	for _, m := range got {
+		if len(m.Text) > maxTextLen || len(m.AuthorID) > maxAuthorLen {
+			t.Fatalf("Превышены ограничения API: текст %d байт, автор %d байт", len(m.Text), len(m.AuthorID))
+		}
+		if m.CreatedAt.Equal(time.Unix(1000, 0)) {
+			parts++
+		}
+	}
+	if parts != 4 || len(got) != parts+batchSize+10 || rep.Messages != len(got) {
+		t.Errorf("Ожидалось 4 части и %d коротких сообщений, получено %d частей из %d", batchSize+10, parts, len(got))
+	}
+	chat, _ := db.Chats().GetByID(context.Background(), rep.Chats[0].ID)
+	if !chat.CreatedAt.Equal(time.Unix(1000, 0)) {
+		t.Errorf("Время чата без created - время первого сообщения, получено %s", chat.CreatedAt)
+	}
+}
+
+// TestInvalidExport проверяет ошибки для файлов, не похожих на выгрузку
+func TestInvalidExport(t *testing.T) {
+	notZip := strings.NewReader("не архив")
+	if _, err := Open("slack", notZip, notZip.Size()); !errors.Is(err, ErrInvalidExport) {
+		t.Errorf("slack: ожидалась ErrInvalidExport, получено %v", err)
+	}
+	noChannels := slackZip(t, map[string]string{"users.json": `[]`})
+	if _, err := Open("slack", noChannels, noChannels.Size()); !errors.Is(err, ErrInvalidExport) {
+		t.Errorf("slack без channels.json: ожидалась ErrInvalidExport, получено %v", err)
+	}
+	for _, doc := range []string{"[1,2]", `{"about":"пусто"}`} {
+		r := strings.NewReader(doc)
+		if _, err := Open("telegram", r, r.Size()); !errors.Is(err, ErrInvalidExport) {
+			t.Errorf("telegram %s: ожидалась ErrInvalidExport, получено %v", doc, err)
+		}
+	}
+	if _, err := Open("discord", notZip, notZip.Size()); err != ErrUnknownSource {
+		t.Errorf("Ожидалась ErrUnknownSource, получено %v", err)
+	}
+}
@@ -0,0 +1,290 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// slackSource - выгрузка рабочего пространства Slack
+//
+// Структура архива: channels.json (публичные каналы), users.json, папка на каждый канал
+// с файлами по дням (general/2024-01-31.json - массив сообщений). Приватные каналы
+// (groups.json) и личные переписки (dms.json, mpims.json) не переносятся:
+// в приложении все чаты общие
+type slackSource struct {
+	files    map[string]*zip.File
+	users    map[string]string // ID пользователя → имя (handle)
+	channels []slackChannel
+}
+
+type slackChannel struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Created int64  `json:"created"`
+}
+
+type slackUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type slackMessage struct {
+	Type     string            `json:"type"`
+	Subtype  string            `json:"subtype"`
+	User     string            `json:"user"`
+	BotID    string            `json:"bot_id"`
+	Username string            `json:"username"`
+	Text     string            `json:"text"`
+	TS       string            `json:"ts"`
+	Files    []json.RawMessage `json:"files"`
+}
+
+// slackSubtypes - подтипы сообщений, которые переносятся как обычные
+// Остальные (channel_join, channel_topic, pinned_item...) - служебные
+var slackSubtypes = map[string]bool{
+	"":                 true,
+	"bot_message":      true,
+	"me_message":       true,
+	"thread_broadcast": true,
+	"file_share":       true,
+}
+
+// slackPrivate - списки приватных разговоров в выгрузке
+var slackPrivate = []string{"groups.json", "dms.json", "mpims.json"}
+
+// OpenSlack открывает ZIP выгрузку Slack
+func OpenSlack(r io.ReaderAt, size int64) (Source, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: не ZIP архив: %v", ErrInvalidExport, err)
+	}
+	s := &slackSource{files: make(map[string]*zip.File), users: make(map[string]string)}
+	for _, f := range archive.File {
+		s.files[strings.TrimPrefix(path.Clean(f.Name), "/")] = f
+	}
+
+	if _, ok := s.files["channels.json"]; !ok {
+		return nil, fmt.Errorf("%w: в архиве нет channels.json", ErrInvalidExport)
+	}
+	if err := s.readJSON("channels.json", &s.channels); err != nil {
+		return nil, err
+	}
+	var users []slackUser
+	if _, ok := s.files["users.json"]; ok {
+		if err := s.readJSON("users.json", &users); err != nil {
+			return nil, err
+		}
+	}
+	for _, u := range users {
+		s.users[u.ID] = u.Name
+	}
+	return s, nil
+}
+
+// Name - имя источника
+func (s *slackSource) Name() string { return "slack" }
+
+// Chats перебирает публичные каналы; приватные разговоры и лишние папки отмечаются в отчете
+func (s *slackSource) Chats(rep *Report, fn func(*Chat) error) error {
+	known := make(map[string]bool)
+	for _, ch := range s.channels {
+		known[ch.Name] = true
+	}
+
+	// Приватные разговоры считаются целиком, их папки в разборе ниже не участвуют
+	for _, name := range slackPrivate {
+		if _, ok := s.files[name]; !ok {
+			continue
+		}
+		var private []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}
+		if err := s.readJSON(name, &private); err != nil {
+			return err
+		}
+		for _, p := range private {
+			rep.Skip(p.Name, p.ID, "приватные каналы и личные сообщения не переносятся")
+			known[p.Name] = true
+			known[p.ID] = true
+		}
+	}
+	for _, dir := range s.dirs() {
+		if !known[dir] {
+			rep.Skip(dir, dir, "папка без описания в channels.json")
+		}
+	}
+
+	for _, ch := range s.channels {
+		chat, err := s.channel(rep, ch)
+		if err != nil {
+			return err
+		}
+		if err := fn(chat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// channel читает все дни канала
+func (s *slackSource) channel(rep *Report, ch slackChannel) (*Chat, error) {
+	chat := &Chat{SourceID: ch.ID, Title: ch.Name}
+	if ch.Created > 0 {
+		chat.CreatedAt = time.Unix(ch.Created, 0)
+	}
+
+	var days []string
+	for name := range s.files {
+		if dir, file := path.Split(name); strings.TrimSuffix(dir, "/") == ch.Name && strings.HasSuffix(file, ".json") {
+			days = append(days, name)
+		}
+	}
+	sort.Strings(days)
+
+	for _, day := range days {
+		var messages []slackMessage
+		if err := s.readJSON(day, &messages); err != nil {
+			return nil, err
+		}
+		for _, m := range messages {
+			if msg, ok := s.message(rep, ch.Name, m); ok {
+				chat.Messages = append(chat.Messages, msg)
+			}
+		}
+	}
+	return chat, nil
+}
+
+// message переводит сообщение Slack; false - сообщение пропущено
+func (s *slackSource) message(rep *Report, chat string, m slackMessage) (Message, bool) {
+	item := "сообщение " + m.TS
+	if m.Type != "message" || !slackSubtypes[m.Subtype] {
+		kind := m.Subtype
+		if kind == "" {
+			kind = m.Type
+		}
+		rep.Skip(chat, item, "служебное сообщение ("+kind+")")
+		return Message{}, false
+	}
+	at, err := slackTime(m.TS)
+	if err != nil {
+		rep.Skip(chat, item, "неверное время сообщения")
+		return Message{}, false
+	}
+
+	text := s.text(m.Text)
+	if len(m.Files) > 0 {
+		if strings.TrimSpace(text) == "" {
+			rep.Skip(chat, item, "вложения не переносятся")
+			return Message{}, false
+		}
+		rep.Skip(chat, item+": вложения", "вложения не переносятся")
+	}
+
+	author := m.User
+	if name, ok := s.users[author]; ok && name != "" {
+		author = name
+	}
+	if author == "" {
+		author = m.Username
+	}
+	if author == "" {
+		author = m.BotID
+	}
+	return Message{SourceID: m.TS, AuthorID: "slack:" + author, Text: text, CreatedAt: at}, true
+}
+
+// slackLink - разметка ссылок и упоминаний: <@U123>, <#C123|general>, <https://x|подпись>, <!here>
+var slackLink = regexp.MustCompile(`<([^<>|]*)(?:\|([^<>]*))?>`)
+
+// text переводит разметку Slack в простой текст
+func (s *slackSource) text(text string) string {
+	text = slackLink.ReplaceAllStringFunc(text, func(token string) string {
+		parts := slackLink.FindStringSubmatch(token)
+		target, label := parts[1], parts[2]
+		switch {
+		case strings.HasPrefix(target, "@"):
+			if label != "" {
+				return "@" + label
+			}
+			if name, ok := s.users[target[1:]]; ok {
+				return "@" + name
+			}
+			return target
+		case strings.HasPrefix(target, "#"):
+			if label != "" {
+				return "#" + label
+			}
+			return target
+		case strings.HasPrefix(target, "!"):
+			// <!here>, <!channel>, <!subteam^ID|@team>
+			if label != "" {
+				return label
+			}
+			name, _, _ := strings.Cut(target[1:], "^")
+			return "@" + name
+		case label != "" && label != target:
+			return label + " (" + target + ")"
+		default:
+			return target
+		}
+	})
+	// Slack экранирует только &, < и >
+	return html.UnescapeString(text)
+}
+
+// dirs возвращает папки верхнего уровня архива
+func (s *slackSource) dirs() []string {
+	seen := make(map[string]bool)
+	for name := range s.files {
+		if dir, _, ok := strings.Cut(name, "/"); ok {
+			seen[dir] = true
+		}
+	}
+	dirs := make([]string, 0, len(seen))
+	for dir := range seen {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// readJSON разбирает JSON файл архива
+func (s *slackSource) readJSON(name string, v any) error {
+	f, err := s.files[name].Open()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidExport, name, err)
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidExport, name, err)
+	}
+	return nil
+}
+
+// slackTime разбирает время сообщения: "1706700000.123456" (секунды и микросекунды)
+func slackTime(ts string) (time.Time, error) {
+	sec, frac, _ := strings.Cut(ts, ".")
+	seconds, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var micros int64
+	if frac != "" {
+		frac = (frac + "000000")[:6]
+		if micros, err = strconv.ParseInt(frac, 10, 64); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(seconds, micros*1000), nil
+}
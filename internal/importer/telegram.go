@@ -0,0 +1,203 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// telegramSource - выгрузка Telegram Desktop в формате JSON (result.json)
+//
+// Поддерживаются выгрузка одного чата ({"name", "type", "id", "messages"}) и выгрузка
+// всех данных ({"chats": {"list": [...]}, "left_chats": {"list": [...]}}). Переносятся
+// группы и каналы; личные переписки, боты и "Избранное" пропускаются: в приложении все чаты общие
+type telegramSource struct {
+	chats []telegramChat
+}
+
+type telegramChat struct {
+	ID       int64             `json:"id"`
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Messages []telegramMessage `json:"messages"`
+}
+
+type telegramMessage struct {
+	ID           int64           `json:"id"`
+	Type         string          `json:"type"`
+	Action       string          `json:"action"`
+	Date         string          `json:"date"`
+	DateUnixtime string          `json:"date_unixtime"`
+	From         string          `json:"from"`
+	FromID       string          `json:"from_id"`
+	Text         json.RawMessage `json:"text"`
+
+	// Вложения: достаточно знать, что они есть
+	Photo        string          `json:"photo"`
+	File         string          `json:"file"`
+	MediaType    string          `json:"media_type"`
+	Poll         json.RawMessage `json:"poll"`
+	Location     json.RawMessage `json:"location_information"`
+	Contact      json.RawMessage `json:"contact_information"`
+	StickerEmoji string          `json:"sticker_emoji"`
+	GameTitle    string          `json:"game_title"`
+	Invoice      json.RawMessage `json:"invoice_information"`
+}
+
+// telegramPublic - типы чатов, которые переносятся
+var telegramPublic = map[string]bool{
+	"private_group":      true,
+	"private_supergroup": true,
+	"public_supergroup":  true,
+	"private_channel":    true,
+	"public_channel":     true,
+}
+
+// OpenTelegram разбирает result.json выгрузки Telegram Desktop
+func OpenTelegram(r io.Reader) (Source, error) {
+	var doc struct {
+		telegramChat
+		Chats *struct {
+			List []telegramChat `json:"list"`
+		} `json:"chats"`
+		LeftChats *struct {
+			List []telegramChat `json:"list"`
+		} `json:"left_chats"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: ожидается result.json Telegram Desktop: %v", ErrInvalidExport, err)
+	}
+
+	s := &telegramSource{}
+	switch {
+	case doc.Chats != nil:
+		s.chats = doc.Chats.List
+		if doc.LeftChats != nil {
+			s.chats = append(s.chats, doc.LeftChats.List...)
+		}
+	case doc.Type != "":
+		s.chats = []telegramChat{doc.telegramChat}
+	default:
+		return nil, fmt.Errorf("%w: в result.json нет ни чата, ни списка чатов", ErrInvalidExport)
+	}
+	return s, nil
+}
+
+// Name - имя источника
+func (s *telegramSource) Name() string { return "telegram" }
+
+// Chats перебирает группы и каналы выгрузки
+func (s *telegramSource) Chats(rep *Report, fn func(*Chat) error) error {
+	for _, tc := range s.chats {
+		id := strconv.FormatInt(tc.ID, 10)
+		if !telegramPublic[tc.Type] {
+			rep.Skip(tc.Name, "чат "+id+" ("+tc.Type+")", "приватные каналы и личные сообщения не переносятся")
+			continue
+		}
+		chat := &Chat{SourceID: id, Title: tc.Name}
+		for _, m := range tc.Messages {
+			if msg, ok := s.message(rep, tc.Name, m); ok {
+				chat.Messages = append(chat.Messages, msg)
+			}
+		}
+		if err := fn(chat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// message переводит сообщение Telegram; false - сообщение пропущено
+func (s *telegramSource) message(rep *Report, chat string, m telegramMessage) (Message, bool) {
+	item := "сообщение " + strconv.FormatInt(m.ID, 10)
+	if m.Type != "message" {
+		kind := m.Action
+		if kind == "" {
+			kind = m.Type
+		}
+		rep.Skip(chat, item, "служебное сообщение ("+kind+")")
+		return Message{}, false
+	}
+	at, err := telegramTime(m)
+	if err != nil {
+		rep.Skip(chat, item, "неверное время сообщения")
+		return Message{}, false
+	}
+
+	text, err := telegramText(m.Text)
+	if err != nil {
+		rep.Skip(chat, item, "неизвестный формат текста")
+		return Message{}, false
+	}
+	if m.hasMedia() {
+		if strings.TrimSpace(text) == "" {
+			rep.Skip(chat, item, "вложения не переносятся")
+			return Message{}, false
+		}
+		rep.Skip(chat, item+": вложения", "вложения не переносятся")
+	}
+
+	// from_id (user123, channel456) не меняется, в отличие от отображаемого имени
+	author := m.FromID
+	if author == "" {
+		author = m.From
+	}
+	return Message{SourceID: strconv.FormatInt(m.ID, 10), AuthorID: "telegram:" + author, Text: text, CreatedAt: at}, true
+}
+
+// hasMedia сообщает, есть ли в сообщении вложение
+func (m telegramMessage) hasMedia() bool {
+	return m.Photo != "" || m.File != "" || m.MediaType != "" || m.StickerEmoji != "" || m.GameTitle != "" ||
+		len(m.Poll) > 0 || len(m.Location) > 0 || len(m.Contact) > 0 || len(m.Invoice) > 0
+}
+
+// telegramText собирает текст: строка или массив из строк и фрагментов {"type", "text", "href"}
+func telegramText(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+	var plain string
+	if err := json.Unmarshal(raw, &plain); err == nil {
+		return plain, nil
+	}
+	var parts []json.RawMessage
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, part := range parts {
+		if err := json.Unmarshal(part, &plain); err == nil {
+			b.WriteString(plain)
+			continue
+		}
+		var entity struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+			Href string `json:"href"`
+		}
+		if err := json.Unmarshal(part, &entity); err != nil {
+			return "", err
+		}
+		b.WriteString(entity.Text)
+		// Ссылка под текстом иначе потеряется
+		if entity.Type == "text_link" && entity.Href != "" && entity.Href != entity.Text {
+			b.WriteString(" (" + entity.Href + ")")
+		}
+	}
+	return b.String(), nil
+}
+
+// telegramTime - время сообщения: date_unixtime (новые версии) или date в местном времени выгрузки
+func telegramTime(m telegramMessage) (time.Time, error) {
+	if m.DateUnixtime != "" {
+		seconds, err := strconv.ParseInt(m.DateUnixtime, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(seconds, 0), nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04:05", m.Date, time.Local)
+}
@@ -0,0 +1,32 @@
+// Package lease - общие помощники фоновых обработчиков, которые делят работу
+// между инстансами через аренды и захват строк (outbox, очистка, планировщик, превью)
+package lease
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+)
+
+// MaxRetryDelay - предел паузы между повторами
+const MaxRetryDelay = time.Hour
+
+// RetryDelay - пауза перед повтором после attempt неудачных попыток: base, 2×, 4×... до MaxRetryDelay
+func RetryDelay(base time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, MaxRetryDelay)
+}
+
+// HolderID - уникальный идентификатор инстанса: хост, PID и случайный суффикс
+// Случайный суффикс различает обработчики одного процесса и перезапуски с тем же PID
+func HolderID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
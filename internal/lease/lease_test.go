@@ -0,0 +1,26 @@
+package lease
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHolderID проверяет, что идентификаторы одного процесса различаются
+func TestHolderID(t *testing.T) {
+	host, _ := os.Hostname()
+	a, b := HolderID(), HolderID()
+	if a == b || !strings.HasPrefix(a, host+"-") {
+		t.Errorf("Неверные идентификаторы: %q, %q", a, b)
+	}
+}
+
+// TestRetryDelay проверяет удвоение паузы и ее предел
+func TestRetryDelay(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 3: 2 * time.Minute, 20: time.Hour} {
+		if got := RetryDelay(30*time.Second, attempt); got != want {
+			t.Errorf("RetryDelay(%d) = %s, ожидалось %s", attempt, got, want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// ctxKey - тип ключей контекста пакета, чтобы не пересекаться с другими пакетами
+type ctxKey struct{}
+
+// New создает slog.Logger с JSON или текстовым выводом
+// format - "json" или "text", level - "debug", "info", "warn" или "error"
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("неизвестный уровень логирования: %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("неизвестный формат логов: %q", format)
+	}
+
+	return slog.New(contextHandler{Handler: h}), nil
+}
+
+// WithRequestID сохраняет ID запроса в контексте
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, requestID)
+}
+
+// RequestID возвращает ID запроса из контекста или пустую строку
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// contextHandler добавляет request_id из контекста к каждой записи лога
+// Благодаря этому достаточно вызывать slog.InfoContext(ctx, ...) в любом слое,
+// и строка лога будет связана с HTTP запросом
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle дописывает атрибуты из контекста и передает запись дальше
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs сохраняет обертку при создании дочернего логгера
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup сохраняет обертку при создании группы атрибутов
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
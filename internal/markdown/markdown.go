@@ -0,0 +1,339 @@
+// Package markdown превращает текст сообщения с markdown разметкой в безопасный HTML
+// и список элементов разметки (models.Entity)
+//
+// Поддерживается подмножество, которое нужно в чате:
+//
+//	**жирный**, *курсив*, _курсив_, `код`, [ссылка](https://example.com),
+//	блоки кода между строками ``` (после открывающей можно указать язык)
+//
+// и экранирование символов разметки обратной косой чертой (\*). Остальное - обычный текст.
+//
+// Безопасность: HTML собирается только из экранированного текста и фиксированного набора
+// тегов (strong, em, code, pre, a, br); HTML в тексте сообщения не пропускается, а ссылки
+// допускаются только http, https и mailto - javascript: и прочие остаются текстом
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"go-chat-app/internal/models"
+)
+
+// maxURLLength - ссылки длиннее остаются текстом
+const maxURLLength = 2048
+
+// languagePattern - допустимое имя языка блока кода (попадает в атрибут class)
+var languagePattern = regexp.MustCompile(`^[A-Za-z0-9_+#.-]{1,32}$`)
+
+// Render разбирает markdown текст и возвращает HTML и элементы разметки в порядке Offset
+// Переводы строк вне блоков кода становятся <br>. Без разметки элементов нет (nil)
+func Render(text string) (string, []models.Entity) {
+	p := &parser{src: []rune(text)}
+	start := 0
+	for i := 0; i < len(p.src); i++ {
+		// Блок кода начинается только с начала строки
+		if i > 0 && p.src[i-1] != '\n' {
+			continue
+		}
+		b, ok := p.fence(i)
+		if !ok {
+			continue
+		}
+		// Перевод строки перед блоком не нужен: pre - блочный элемент
+		before := i
+		if before > start && p.src[before-1] == '\n' {
+			before--
+		}
+		p.span(start, before, false)
+		p.block(b)
+		start = b.next
+		i = b.next - 1
+	}
+	p.span(start, len(p.src), false)
+	return p.out.String(), p.entities
+}
+
+// parser - состояние разбора одного текста
+type parser struct {
+	src      []rune
+	out      strings.Builder
+	entities []models.Entity
+}
+
+// codeBlock - блок кода между строками ``` (позиции в рунах)
+type codeBlock struct {
+	start, end int    // блок вместе с ``` (для Entity)
+	content    [2]int // код между строками ```
+	next       int    // продолжение текста: после перевода строки за закрывающей ```
+	language   string // язык после открывающей ``` (пусто - не указан или недопустим)
+}
+
+// fence ищет блок кода с позиции i (начало строки): ``` [язык], строки кода,
+// закрывающая ``` отдельной строкой. Без закрывающей строки блока нет
+func (p *parser) fence(i int) (codeBlock, bool) {
+	if !p.hasPrefix(i, "```") {
+		return codeBlock{}, false
+	}
+	lineEnd := p.indexRune(i+3, len(p.src), '\n')
+	if lineEnd < 0 {
+		return codeBlock{}, false
+	}
+	language := strings.TrimSpace(string(p.src[i+3 : lineEnd]))
+	if !languagePattern.MatchString(language) {
+		language = ""
+	}
+
+	for j := lineEnd; j >= 0; j = p.indexRune(j+1, len(p.src), '\n') {
+		end := j + 4
+		if !p.hasPrefix(j+1, "```") || (end < len(p.src) && p.src[end] != '\n') {
+			continue
+		}
+		b := codeBlock{start: i, end: end, next: end, language: language}
+		if j > lineEnd {
+			b.content = [2]int{lineEnd + 1, j}
+		}
+		if end < len(p.src) {
+			b.next++
+		}
+		return b, true
+	}
+	return codeBlock{}, false
+}
+
+// block пишет блок кода: текст внутри не разбирается
+func (p *parser) block(b codeBlock) {
+	p.entities = append(p.entities, models.Entity{Type: models.EntityPre, Offset: b.start, Length: b.end - b.start, Language: b.language})
+	p.out.WriteString("<pre><code")
+	if b.language != "" {
+		p.out.WriteString(` class="language-` + html.EscapeString(b.language) + `"`)
+	}
+	p.out.WriteString(">")
+	p.text(p.src[b.content[0]:b.content[1]])
+	p.out.WriteString("</code></pre>")
+}
+
+// span разбирает строчную разметку в src[start:end]
+// inLink - внутри текста ссылки, вложенные ссылки остаются текстом
+func (p *parser) span(start, end int, inLink bool) {
+	for i := start; i < end; {
+		r := p.src[i]
+		switch {
+		case r == '\\' && i+1 < end && isMarkup(p.src[i+1]):
+			p.text(p.src[i+1 : i+2])
+			i += 2
+
+		case r == '`':
+			n := p.run(i, end, '`')
+			closing := p.closingTicks(i+n, end, n)
+			if closing < 0 {
+				p.text(p.src[i : i+n])
+				i += n
+				continue
+			}
+			p.entities = append(p.entities, models.Entity{Type: models.EntityCode, Offset: i, Length: closing + n - i})
+			p.out.WriteString("<code>")
+			p.text(p.src[i+n : closing])
+			p.out.WriteString("</code>")
+			i = closing + n
+
+		case r == '*' && i+1 < end && p.src[i+1] == '*':
+			closing := p.scan(i+2, end, func(j int) bool {
+				return j+1 < end && p.src[j] == '*' && p.src[j+1] == '*'
+			})
+			if closing <= i+2 {
+				p.text(p.src[i : i+2])
+				i += 2
+				continue
+			}
+			p.entities = append(p.entities, models.Entity{Type: models.EntityBold, Offset: i, Length: closing + 2 - i})
+			p.out.WriteString("<strong>")
+			p.span(i+2, closing, inLink)
+			p.out.WriteString("</strong>")
+			i = closing + 2
+
+		case (r == '*' || r == '_') && p.opensEmphasis(i, end):
+			closing := p.scan(i+1, end, func(j int) bool {
+				return p.closesEmphasis(j, end, r)
+			})
+			if closing < 0 {
+				p.text(p.src[i : i+1])
+				i++
+				continue
+			}
+			p.entities = append(p.entities, models.Entity{Type: models.EntityItalic, Offset: i, Length: closing + 1 - i})
+			p.out.WriteString("<em>")
+			p.span(i+1, closing, inLink)
+			p.out.WriteString("</em>")
+			i = closing + 1
+
+		case r == '[' && !inLink:
+			next, ok := p.link(i, end)
+			if !ok {
+				p.text(p.src[i : i+1])
+				i++
+				continue
+			}
+			i = next
+
+		case r == '\n':
+			p.out.WriteString("<br>")
+			i++
+
+		default:
+			p.text(p.src[i : i+1])
+			i++
+		}
+	}
+}
+
+// link разбирает ссылку [текст](адрес) с позиции i и возвращает позицию после нее
+// Небезопасный или некорректный адрес - не ссылка (false)
+func (p *parser) link(i, end int) (int, bool) {
+	closeText := p.scan(i+1, end, func(j int) bool { return p.src[j] == ']' })
+	if closeText <= i+1 || closeText+1 >= end || p.src[closeText+1] != '(' {
+		return 0, false
+	}
+	closeURL := p.indexRune(closeText+2, end, ')')
+	if closeURL < 0 {
+		return 0, false
+	}
+	address := string(p.src[closeText+2 : closeURL])
+	if !SafeURL(address) {
+		return 0, false
+	}
+
+	p.entities = append(p.entities, models.Entity{Type: models.EntityLink, Offset: i, Length: closeURL + 1 - i, URL: address})
+	p.out.WriteString(`<a href="` + html.EscapeString(address) + `" rel="nofollow noopener noreferrer" target="_blank">`)
+	p.span(i+1, closeText, true)
+	p.out.WriteString("</a>")
+	return closeURL + 1, true
+}
+
+// SafeURL сообщает, можно ли сделать адрес ссылкой: абсолютный http(s) адрес с хостом
+// или mailto, без пробелов и управляющих символов
+func SafeURL(address string) bool {
+	if address == "" || len(address) > maxURLLength || strings.IndexFunc(address, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	}) >= 0 {
+		return false
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.Host != ""
+	case "mailto":
+		return u.Opaque != ""
+	default:
+		return false
+	}
+}
+
+// opensEmphasis сообщает, открывает ли * или _ на позиции i курсив:
+// за ним не пробел и не тот же символ, а "_" еще и не внутри слова (snake_case - не курсив)
+func (p *parser) opensEmphasis(i, end int) bool {
+	r := p.src[i]
+	if i+1 >= end || unicode.IsSpace(p.src[i+1]) || p.src[i+1] == r {
+		return false
+	}
+	return r == '*' || i == 0 || !isWordRune(p.src[i-1])
+}
+
+// closesEmphasis сообщает, закрывает ли символ на позиции j курсив, открытый символом r
+func (p *parser) closesEmphasis(j, end int, r rune) bool {
+	if p.src[j] != r || unicode.IsSpace(p.src[j-1]) {
+		return false
+	}
+	if j+1 < end && p.src[j+1] == r {
+		return false // "**" - жирный, а не конец курсива
+	}
+	return r == '*' || j+1 >= end || !isWordRune(p.src[j+1])
+}
+
+// scan ищет первую позицию в [from, end), для которой match истинно,
+// пропуская экранированные символы и код (`...`); -1 - не найдено
+func (p *parser) scan(from, end int, match func(j int) bool) int {
+	for j := from; j < end; j++ {
+		switch {
+		case p.src[j] == '\\' && j+1 < end && isMarkup(p.src[j+1]):
+			j++
+		case match(j):
+			return j
+		case p.src[j] == '`':
+			n := p.run(j, end, '`')
+			if closing := p.closingTicks(j+n, end, n); closing >= 0 {
+				j = closing + n - 1
+			} else {
+				j += n - 1
+			}
+		}
+	}
+	return -1
+}
+
+// closingTicks ищет в [from, end) серию ровно из n обратных кавычек; -1 - не найдена
+func (p *parser) closingTicks(from, end, n int) int {
+	for j := from; j < end; {
+		if p.src[j] != '`' {
+			j++
+			continue
+		}
+		m := p.run(j, end, '`')
+		if m == n {
+			return j
+		}
+		j += m
+	}
+	return -1
+}
+
+// run возвращает длину серии символов r с позиции i
+func (p *parser) run(i, end int, r rune) int {
+	n := 0
+	for i+n < end && p.src[i+n] == r {
+		n++
+	}
+	return n
+}
+
+// indexRune ищет r в [from, end); -1 - не найден
+func (p *parser) indexRune(from, end int, r rune) int {
+	for j := from; j < end; j++ {
+		if p.src[j] == r {
+			return j
+		}
+	}
+	return -1
+}
+
+// hasPrefix сообщает, начинается ли текст с позиции i со строки prefix
+func (p *parser) hasPrefix(i int, prefix string) bool {
+	for _, r := range prefix {
+		if i >= len(p.src) || p.src[i] != r {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// text пишет текст в HTML с экранированием
+func (p *parser) text(runes []rune) {
+	p.out.WriteString(html.EscapeString(string(runes)))
+}
+
+// isMarkup сообщает, можно ли экранировать символ обратной косой чертой
+func isMarkup(r rune) bool {
+	return strings.ContainsRune("\\`*_[]()#+-.!~>", r)
+}
+
+// isWordRune сообщает, может ли символ быть частью слова
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
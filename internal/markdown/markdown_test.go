@@ -0,0 +1,157 @@
+package markdown
+
+import (
+	"html"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go-chat-app/internal/models"
+)
+
+// TestRender проверяет HTML и элементы разметки поддерживаемого подмножества markdown
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		html     string
+		entities []models.Entity
+	}{
+		{
+			name: "без разметки",
+			text: "просто текст\nвторая строка",
+			html: "просто текст<br>вторая строка",
+		},
+		{
+			name:     "жирный с курсивом внутри",
+			text:     "**важно _очень_**!",
+			html:     "<strong>важно <em>очень</em></strong>!",
+			entities: []models.Entity{{Type: models.EntityBold, Offset: 0, Length: 17}, {Type: models.EntityItalic, Offset: 8, Length: 7}},
+		},
+		{
+			name:     "код не разбирается",
+			text:     "запусти `go test **./...`",
+			html:     "запусти <code>go test **./...</code>",
+			entities: []models.Entity{{Type: models.EntityCode, Offset: 8, Length: 17}},
+		},
+		{
+			name:     "ссылка",
+			text:     "[документация](https://example.com/a?b=1&c=2)",
+			html:     `<a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener noreferrer" target="_blank">документация</a>`,
+			entities: []models.Entity{{Type: models.EntityLink, Offset: 0, Length: 45, URL: "https://example.com/a?b=1&c=2"}},
+		},
+		{
+			name:     "блок кода",
+			text:     "смотри:\n```go\nif a < b {\n\t**x**\n}\n```\nготово",
+			html:     "смотри:<pre><code class=\"language-go\">if a &lt; b {\n\t**x**\n}</code></pre>готово",
+			entities: []models.Entity{{Type: models.EntityPre, Offset: 8, Length: 29, Language: "go"}},
+		},
+		{
+			name: "незакрытый блок кода - текст",
+			text: "```\ncode",
+			html: "```<br>code",
+		},
+		{
+			name: "snake_case и незакрытая разметка",
+			text: "snake_case_name и **не закрыто и *тоже",
+			html: "snake_case_name и **не закрыто и *тоже",
+		},
+		{
+			name: "экранирование разметки",
+			text: `\*не курсив\* и \[не ссылка\]`,
+			html: "*не курсив* и [не ссылка]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, entities := Render(tt.text)
+			if out != tt.html {
+				t.Errorf("HTML:\nожидалось %q\nполучено  %q", tt.html, out)
+			}
+			if !reflect.DeepEqual(entities, tt.entities) {
+				t.Errorf("Элементы:\nожидалось %+v\nполучено  %+v", tt.entities, entities)
+			}
+			for _, e := range entities {
+				if e.Offset < 0 || e.Offset+e.Length > len([]rune(tt.text)) {
+					t.Errorf("Элемент за пределами текста: %+v", e)
+				}
+			}
+		})
+	}
+}
+
+// TestRenderXSS проверяет, что из текста сообщения в HTML не попадают теги, атрибуты и опасные ссылки
+func TestRenderXSS(t *testing.T) {
+	attacks := []string{
+		`<script>alert(1)</script>`,
+		`<img src=x onerror=alert(1)>`,
+		`[клик](javascript:alert(1))`,
+		`[клик](JaVaScRiPt:alert(1))`,
+		`[клик](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)`,
+		`[клик](vbscript:msgbox)`,
+		`[клик](//evil.example/x)`,
+		`[клик](https://example.com/"onmouseover="alert(1))`,
+		`[клик](java&#115;cript:alert(1))`,
+		"[клик](java\tscript:alert(1))",
+		"```\"><script>alert(1)</script>\nx\n```",
+		"**<b onclick=alert(1)>**",
+		"`<iframe>`",
+	}
+	allowed := map[string]bool{"strong": true, "em": true, "code": true, "pre": true, "a": true, "br": true}
+	for _, text := range attacks {
+		out, entities := Render(text)
+		for _, tag := range tags(out) {
+			if !allowed[tag] {
+				t.Errorf("%q: недопустимый тег %q в %q", text, tag, out)
+			}
+		}
+		for _, href := range strings.Split(out, `href="`)[1:] {
+			if address := html.UnescapeString(href[:strings.IndexByte(href, '"')]); !SafeURL(address) {
+				t.Errorf("%q: опасная ссылка %q в %q", text, address, out)
+			}
+		}
+		if strings.Contains(out, `"onmouseover="`) {
+			t.Errorf("%q: кавычка в атрибуте не экранирована: %q", text, out)
+		}
+		for _, e := range entities {
+			if e.Type == models.EntityLink && !SafeURL(e.URL) {
+				t.Errorf("%q: небезопасная ссылка в элементах: %+v", text, e)
+			}
+		}
+	}
+}
+
+// tags возвращает имена всех тегов (открывающих и закрывающих) в HTML
+func tags(out string) []string {
+	var names []string
+	for i := strings.IndexByte(out, '<'); i >= 0; i = strings.IndexByte(out, '<') {
+		out = strings.TrimPrefix(out[i+1:], "/")
+		end := strings.IndexAny(out, " >")
+		if end < 0 {
+			end = len(out)
+		}
+		names = append(names, out[:end])
+	}
+	return names
+}
+
+// TestSafeURL проверяет список разрешенных адресов ссылок
+func TestSafeURL(t *testing.T) {
+	for address, want := range map[string]bool{
+		"https://example.com":         true,
+		"http://example.com/path?q=1": true,
+		"mailto:team@example.com":     true,
+		"HTTPS://EXAMPLE.COM":         true,
+		"javascript:alert(1)":         false,
+		"ftp://example.com":           false,
+		"/relative/path":              false,
+		"https://":                    false,
+		"mailto:":                     false,
+		"https://example.com/a b":     false,
+		"":                            false,
+	} {
+		if got := SafeURL(address); got != want {
+			t.Errorf("SafeURL(%q) = %v, ожидалось %v", address, got, want)
+		}
+	}
+}
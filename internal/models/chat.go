@@ -21,6 +21,16 @@ type Chat struct {
 	// json:"title" - в JSON будет как "title"
 	Title string `gorm:"size:200;not null" json:"title"`
 
+	// SlowModeSeconds - медленный режим: один пользователь может отправлять
+	// сообщение в этот чат не чаще раза в N секунд (0 - выключен)
+	SlowModeSeconds int `gorm:"not null;default:0" json:"slow_mode_seconds"`
+
+	// RetentionDays - срок хранения сообщений в днях: более старые сообщения
+	// удаляет фоновая очистка (internal/retention)
+	// nil (NULL) - срок по умолчанию сервера, 0 - хранить всегда
+	// json:"retention_days" - null в JSON означает срок по умолчанию
+	RetentionDays *int `json:"retention_days"`
+
 	// Временные метки
 
 	// CreatedAt - время создания записи
@@ -0,0 +1,28 @@
+package models
+
+// Форматы текста сообщения (Message.Format)
+const (
+	MessageFormatPlain    = "plain"    // текст показывается как есть
+	MessageFormatMarkdown = "markdown" // текст с разметкой, HTML строит сервер
+)
+
+// Виды элементов разметки (Entity.Type)
+const (
+	EntityBold   = "bold"   // **жирный**
+	EntityItalic = "italic" // *курсив* или _курсив_
+	EntityCode   = "code"   // `код`
+	EntityPre    = "pre"    // блок кода между строками ```
+	EntityLink   = "link"   // [текст](https://example.com)
+)
+
+// Entity - элемент markdown разметки в тексте сообщения для клиентов, которые
+// не показывают HTML. Offset и Length считаются в символах Unicode (рунах) исходного
+// текста и охватывают элемент вместе с разметкой ("**" по краям, "[...](...)")
+// Вложенные элементы (курсив внутри жирного) перечисляются отдельно
+type Entity struct {
+	Type     string `json:"type"`
+	Offset   int    `json:"offset"`
+	Length   int    `json:"length"`
+	URL      string `json:"url,omitempty"`      // адрес ссылки (EntityLink)
+	Language string `json:"language,omitempty"` // язык блока кода (EntityPre), если указан
+}
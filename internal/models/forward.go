@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Forward - откуда переслано сообщение (Message.ForwardedFrom), клиенты показывают его как "переслано из"
+// Хранится вместе с пересланным сообщением (колонка messages.forwarded_from) снимком на момент
+// пересылки: название чата и автор не меняются, если потом меняется оригинал
+// Пересылка пересланного сообщения ссылается на самый первый оригинал
+type Forward struct {
+	ChatID    uint      `json:"chat_id"`             // чат оригинала
+	ChatTitle string    `json:"chat_title"`          // название чата оригинала при пересылке
+	MessageID uint      `json:"message_id"`          // ID оригинала
+	AuthorID  string    `json:"author_id,omitempty"` // автор оригинала
+	SentAt    time.Time `json:"sent_at"`             // когда отправлен оригинал
+
+	// Deleted - оригинал удален (или удален его чат), ссылка на него больше не открывается
+	// Не хранится: ChatService проверяет оригиналы при каждом чтении
+	Deleted bool `json:"deleted,omitempty"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyKey - сохраненный результат запроса с ключом идемпотентности
+// Пока StatusCode == 0, запрос с этим ключом еще выполняется
+type IdempotencyKey struct {
+	// Scope - клиент и endpoint ("user:42 POST /chats"), вместе с Key - первичный ключ
+	Scope string `gorm:"primaryKey"`
+	Key   string `gorm:"primaryKey;column:idempotency_key"`
+
+	// RequestHash - хеш метода, пути и тела запроса
+	RequestHash string `gorm:"not null"`
+
+	// Сохраненный ответ
+	StatusCode   int `gorm:"not null;default:0"`
+	ResponseBody []byte
+	ContentType  string `gorm:"not null;default:''"`
+
+	CreatedAt time.Time
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+// Completed сообщает, сохранен ли уже ответ
+func (k *IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
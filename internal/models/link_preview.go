@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+)
+
+// Статусы превью ссылки
+//
+//	pending  → fetching → ready
+//	    ↑          ↓
+//	    └─ ошибка, есть попытки ─┘   ошибка без попыток → failed
+//	ready, failed → pending: кэш устарел, а адрес снова прислали в сообщении
+const (
+	LinkPreviewPending  = "pending"  // ждет загрузки (или повтора после ошибки)
+	LinkPreviewFetching = "fetching" // захвачено обработчиком, страница загружается
+	LinkPreviewReady    = "ready"    // метаданные загружены
+	LinkPreviewFailed   = "failed"   // загрузить не удалось, причина - в LastError
+)
+
+// LinkPreview - превью ссылки: метаданные страницы (OpenGraph/oEmbed), которые фоновый
+// обработчик (internal/unfurl) загружает по адресу из сообщения
+// Одно превью на адрес: это кэш, его используют все сообщения с той же ссылкой
+type LinkPreview struct {
+	ID  uint   `gorm:"primaryKey" json:"-"`
+	URL string `gorm:"size:2048;not null;uniqueIndex" json:"url"`
+
+	Status string `gorm:"size:20;not null;default:pending" json:"status"`
+
+	// Метаданные страницы; пустые, пока превью не загружено
+	Title       string `gorm:"size:300;not null;default:''" json:"title,omitempty"`
+	Description string `gorm:"type:text;not null;default:''" json:"description,omitempty"`
+	ImageURL    string `gorm:"size:2048;not null;default:''" json:"image_url,omitempty"`
+	SiteName    string `gorm:"size:200;not null;default:''" json:"site_name,omitempty"`
+
+	// Attempts и LastError - неудачные попытки загрузки
+	Attempts  int    `gorm:"not null;default:0" json:"-"`
+	LastError string `gorm:"not null;default:''" json:"-"`
+
+	// LockedBy и LockedUntil - захват обработчиком (status = fetching)
+	// У pending после ошибки LockedUntil - время следующей попытки
+	LockedBy    string     `gorm:"not null;default:''" json:"-"`
+	LockedUntil *time.Time `json:"-"`
+
+	// QueuedAt - когда адрес (снова) поставлен в очередь: о загрузке сообщается
+	// сообщениям, получившим ссылку с этого момента
+	QueuedAt time.Time `gorm:"not null" json:"-"`
+	// FetchedAt - последняя завершенная загрузка (ready или failed), по ней устаревает кэш
+	FetchedAt *time.Time `json:"fetched_at,omitempty"`
+
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+// MessageLink - ссылка сообщения на превью; Position - порядок ссылок в тексте
+type MessageLink struct {
+	MessageID uint      `gorm:"primaryKey"`
+	PreviewID uint      `gorm:"primaryKey"`
+	Position  int       `gorm:"not null"`
+	CreatedAt time.Time `gorm:"not null"`
+}
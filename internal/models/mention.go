@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// MentionAll - цель упоминания @all: все участники чата
+// Участниками считаются пользователи, писавшие в чат (отдельного списка участников нет)
+const MentionAll = "all"
+
+// Mention - упоминание в тексте сообщения (@alice, @all) для отображения клиентом
+// Offset и Length считаются в символах Unicode (рунах), а не в байтах UTF-8
+type Mention struct {
+	Offset int    `json:"offset"` // позиция "@" от начала текста
+	Length int    `json:"length"` // длина упоминания вместе с "@"
+	Target string `json:"target"` // ID упомянутого пользователя или MentionAll
+}
+
+// MessageMention - уведомление пользователя об упоминании (таблица message_mentions)
+// Создается вместе с сообщением для каждого упомянутого пользователя, кроме автора;
+// @all создает уведомления всем участникам чата
+type MessageMention struct {
+	ID uint `gorm:"primaryKey" json:"id"`
+
+	// UserID - упомянутый пользователь, владелец уведомления (GET /me/mentions)
+	UserID    string `gorm:"size:128;not null" json:"-"`
+	ChatID    uint   `gorm:"not null" json:"chat_id"`
+	MessageID uint   `gorm:"not null" json:"message_id"`
+	AuthorID  string `gorm:"size:128;not null;default:''" json:"author_id,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	// ReadAt - когда пользователь отметил уведомление прочитанным (nil - не прочитано)
+	ReadAt *time.Time `json:"read_at"`
+
+	// Message - сообщение с упоминанием (заполняет MentionStore.ListByUser)
+	Message *Message `gorm:"foreignKey:MessageID" json:"message,omitempty"`
+}
@@ -19,6 +19,62 @@ type Message struct {
 	// not null - сообщение не может быть пустым
 	Text string `gorm:"type:text;not null" json:"text"`
 
+	// AuthorID - ID пользователя, отправившего сообщение (из заголовка шлюза, см. internal/auth)
+	// Пустой, если идентификация выключена или запрос был анонимным
+	AuthorID string `gorm:"size:128;not null;default:''" json:"author_id,omitempty"`
+
+	// Kind - вид служебного сообщения, которое создал сервер (MessageKindPin - закрепление)
+	// Пустой у обычных сообщений пользователей; json:"kind,omitempty" - у них поля нет
+	Kind string `gorm:"size:20;not null;default:''" json:"kind,omitempty"`
+
+	// Format - формат текста: MessageFormatPlain или MessageFormatMarkdown
+	// default:plain - у сообщений, созданных без формата (импорт, служебные), и старых сообщений
+	Format string `gorm:"size:20;not null;default:plain" json:"format"`
+
+	// HTML - безопасный HTML markdown сообщения, его строит ChatService.SendMessage (internal/markdown)
+	// Пустой у сообщений plain; json:"html,omitempty" - у них поля нет
+	HTML string `gorm:"column:html;type:text;not null;default:''" json:"html,omitempty"`
+
+	// Entities - элементы markdown разметки (жирный, код, ссылки...) для клиентов без HTML
+	// serializer:json - хранятся JSON строкой в колонке entities (NULL - разметки нет)
+	Entities []Entity `gorm:"serializer:json" json:"entities,omitempty"`
+
+	// Mentions - упоминания @username и @all в тексте, их находит ChatService.SendMessage
+	// serializer:json - хранятся JSON строкой в колонке mentions (NULL - упоминаний нет)
+	Mentions []Mention `gorm:"serializer:json" json:"mentions,omitempty"`
+
+	// Poll - опрос (у сообщений Kind = MessageKindPoll), вопрос - Text
+	// serializer:json - варианты и настройки хранятся JSON строкой в колонке poll (NULL - не опрос);
+	// итоги заполняет ChatService при чтении по голосам из poll_votes
+	Poll *Poll `gorm:"serializer:json" json:"poll,omitempty"`
+
+	// ForwardedFrom - откуда переслано сообщение (POST /chats/{id}/messages/{message_id}/forward)
+	// serializer:json - хранится JSON строкой в колонке forwarded_from (NULL - не пересланное)
+	ForwardedFrom *Forward `gorm:"serializer:json" json:"forwarded_from,omitempty"`
+
+	// Previews - превью ссылок из текста в порядке ссылок (см. LinkPreview)
+	// gorm:"-" - хранятся отдельно (link_previews, message_links), их добавляет ChatService при чтении
+	Previews []LinkPreview `gorm:"-" json:"previews,omitempty"`
+
 	// Временные метки, ОПИСАННИЕ МОЖНО ПОСМОТРЕТЬ models/chat.go
 	CreatedAt time.Time `json:"created_at"`
+
+	// ExpiresAt - время исчезновения сообщения (ttl_seconds или expires_at при отправке)
+	// После него сообщение не возвращается при чтении, а фоновая очистка удаляет его
+	// и отправляет подписчикам событие message.deleted
+	// nil - обычное сообщение; json:"expires_at,omitempty" - у обычных сообщений поля нет
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// Scheduled - запланированное сообщение, которое доставляет это сообщение (см. ScheduledClaim)
+	// gorm:"-" - не хранится: MessageStore.Create только отмечает запланированное сообщение отправленным
+	Scheduled *ScheduledClaim `gorm:"-" json:"-"`
+
+	// Notify - кому MessageStore.Create создаст уведомления об упоминании (MessageMention)
+	// gorm:"-" - не хранится в messages: уведомления пишутся в message_mentions той же транзакцией
+	Notify []string `gorm:"-" json:"-"`
+}
+
+// Expired сообщает, истекло ли исчезающее сообщение к моменту now
+func (m *Message) Expired(now time.Time) bool {
+	return m.ExpiresAt != nil && !m.ExpiresAt.After(now)
 }
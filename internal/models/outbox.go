@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+)
+
+// Типы событий в outbox
+const (
+	OutboxMessageCreated = "message.created"
+	OutboxMessageDeleted = "message.deleted"
+	OutboxChatDeleted    = "chat.deleted"
+)
+
+// OutboxEvent - исходящее событие, записанное в одной транзакции с изменением
+// Relay (internal/outbox) публикует недоставленные события и заполняет DeliveredAt
+// (или FailedAt, если доставить так и не удалось)
+type OutboxEvent struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	ChatID uint   `gorm:"not null;index" json:"chat_id"`
+	Type   string `gorm:"column:event_type;not null" json:"type"`
+
+	// MessageID - сообщение события message.created и message.deleted (nil - chat.deleted)
+	// По нему MessageStore.DeleteExpired удаляет message.created с текстом исчезнувшего сообщения
+	MessageID *uint `json:"message_id,omitempty"`
+
+	// Payload - JSON события (в PostgreSQL - JSONB)
+	Payload string `gorm:"not null" json:"payload"`
+
+	// Attempts и LastError - неудачные попытки доставки
+	Attempts  int    `gorm:"not null;default:0" json:"attempts"`
+	LastError string `gorm:"not null;default:''" json:"last_error"`
+	// NextAttemptAt - когда повторить доставку после неудачи (nil - событие еще не падало)
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	// FailedAt - когда relay бросил событие после MaxAttempts попыток (nil - не брошено)
+	FailedAt *time.Time `json:"failed_at,omitempty"`
+
+	CreatedAt   time.Time  `json:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at"`
+}
+
+// TableName - таблица outbox (по умолчанию GORM назвал бы ее outbox_events)
+func (OutboxEvent) TableName() string {
+	return "outbox"
+}
+
+// OutboxLease - аренда relay: публикует события только ее владелец
+type OutboxLease struct {
+	Name      string `gorm:"primaryKey"`
+	Holder    string `gorm:"not null"`
+	ExpiresAt time.Time
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// MessageKindPin - служебное сообщение о закреплении (см. Message.Kind)
+const MessageKindPin = "pin"
+
+// Pin - закрепленное сообщение чата
+// Первичный ключ (chat_id, message_id): сообщение закрепляется в чате не больше одного раза
+type Pin struct {
+	ChatID    uint `gorm:"primaryKey;autoIncrement:false" json:"chat_id"`
+	MessageID uint `gorm:"primaryKey;autoIncrement:false" json:"message_id"`
+
+	// PinnedBy - пользователь, закрепивший сообщение (пусто, если идентификация выключена)
+	PinnedBy string    `gorm:"size:128;not null;default:''" json:"pinned_by,omitempty"`
+	PinnedAt time.Time `gorm:"not null" json:"pinned_at"`
+
+	// Message - само закрепленное сообщение (заполняет PinStore.List)
+	Message *Message `gorm:"foreignKey:MessageID" json:"message,omitempty"`
+}
+
+// TableName - таблица закрепленных сообщений
+func (Pin) TableName() string {
+	return "pinned_messages"
+}
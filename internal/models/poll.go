@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// MessageKindPoll - сообщение-опрос (см. Message.Kind и Message.Poll), вопрос - текст сообщения
+const MessageKindPoll = "poll"
+
+// Poll - опрос в сообщении
+// Варианты и настройки задаются при отправке и хранятся вместе с сообщением (колонка messages.poll),
+// а голоса - в таблице poll_votes. Итоги (Votes, Voters, TotalVoters, MyVotes, Closed)
+// ChatService считает по голосам при каждом чтении
+type Poll struct {
+	Options   []PollOption `json:"options"`
+	Multiple  bool         `json:"multiple"`  // можно выбрать несколько вариантов
+	Anonymous bool         `json:"anonymous"` // кто за что голосовал, не показывается
+	// ClosesAt - после этого момента голосовать нельзя; nil - опрос не закрывается
+	ClosesAt *time.Time `json:"closes_at,omitempty"`
+
+	Closed      bool  `json:"closed"`             // ClosesAt наступило
+	TotalVoters int   `json:"total_voters"`       // проголосовавших пользователей (не голосов)
+	MyVotes     []int `json:"my_votes,omitempty"` // варианты, выбранные пользователем запроса
+}
+
+// PollOption - вариант ответа и его итоги
+type PollOption struct {
+	Text  string `json:"text"`
+	Votes int    `json:"votes"`
+	// Voters - проголосовавшие за вариант в порядке голосования; в анонимном опросе пусто
+	Voters []string `json:"voters,omitempty"`
+}
+
+// PollVote - голос пользователя за вариант опроса (таблица poll_votes)
+// Первичный ключ (message_id, user_id, option_index): за вариант голосуют не больше одного раза
+type PollVote struct {
+	MessageID uint      `gorm:"primaryKey;autoIncrement:false"`
+	UserID    string    `gorm:"primaryKey;size:128"`
+	Option    int       `gorm:"column:option_index;primaryKey;autoIncrement:false"` // номер в Poll.Options, с 0
+	CreatedAt time.Time `gorm:"not null"`
+}
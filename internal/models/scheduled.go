@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+)
+
+// Статусы запланированного сообщения
+//
+//	pending  → sending → sent
+//	    ↑         ↓
+//	    └─ ошибка, есть попытки ─┘   ошибка без попыток → failed
+//	pending → canceled (DELETE /chats/{id}/scheduled-messages/{sid})
+const (
+	ScheduledPending  = "pending"  // ждет send_at (или повтора после ошибки)
+	ScheduledSending  = "sending"  // захвачено обработчиком, отправляется
+	ScheduledSent     = "sent"     // отправлено, MessageID - созданное сообщение
+	ScheduledFailed   = "failed"   // попытки исчерпаны, причина - в LastError
+	ScheduledCanceled = "canceled" // отменено до отправки
+)
+
+// ScheduledStatuses - все статусы (фильтр списка GET /chats/{id}/scheduled-messages?status=)
+var ScheduledStatuses = []string{ScheduledPending, ScheduledSending, ScheduledSent, ScheduledFailed, ScheduledCanceled}
+
+// ScheduledMessage - сообщение, которое фоновый обработчик (internal/scheduler)
+// отправит в чат в момент SendAt
+type ScheduledMessage struct {
+	ID     uint `gorm:"primaryKey" json:"id"`
+	ChatID uint `gorm:"not null;index" json:"chat_id"`
+
+	// AuthorID - пользователь, запланировавший сообщение: он же станет автором отправленного
+	AuthorID string    `gorm:"size:128;not null;default:''" json:"author_id,omitempty"`
+	Text     string    `gorm:"type:text;not null" json:"text"`
+	SendAt   time.Time `gorm:"not null" json:"send_at"`
+
+	Status string `gorm:"size:20;not null;default:pending" json:"status"`
+
+	// Attempts и LastError - неудачные попытки отправки
+	Attempts  int    `gorm:"not null;default:0" json:"attempts"`
+	LastError string `gorm:"not null;default:''" json:"last_error,omitempty"`
+
+	// MessageID - отправленное сообщение (status = sent)
+	// NULL, если еще не отправлено или сообщение уже удалено очисткой
+	MessageID *uint `json:"message_id,omitempty"`
+
+	// LockedBy и LockedUntil - захват обработчиком (status = sending)
+	// Если обработчик пропал, после LockedUntil сообщение захватит другой
+	// У pending после ошибки LockedUntil - время следующей попытки
+	LockedBy    string     `gorm:"not null;default:''" json:"-"`
+	LockedUntil *time.Time `json:"-"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ScheduledClaim - захват запланированного сообщения, которое доставляется сообщением
+// MessageStore.Create в той же транзакции отмечает его отправленным, если захват
+// все еще принадлежит Holder: так сообщение не отправится дважды, даже если
+// обработчик упадет между отправкой и отметкой или захват перейдет к другому инстансу
+type ScheduledClaim struct {
+	ID     uint
+	Holder string
+}
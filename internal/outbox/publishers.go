@@ -0,0 +1,68 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// LogPublisher пишет события в лог (уровень debug)
+// Подходит, пока внешних получателей нет: outbox не растет, события видны в логах
+type LogPublisher struct{}
+
+// Publish пишет событие в лог
+func (LogPublisher) Publish(ctx context.Context, event Event) error {
+	slog.DebugContext(ctx, "событие outbox",
+		slog.Uint64("event_id", uint64(event.ID)),
+		slog.String("type", event.Type),
+		slog.Uint64("chat_id", uint64(event.ChatID)),
+	)
+	return nil
+}
+
+// WebhookPublisher отправляет каждое событие POST запросом с JSON телом
+// Заголовок X-Event-ID позволяет получателю отбрасывать повторы
+// Любой ответ кроме 2xx считается ошибкой - событие будет отправлено снова
+type WebhookPublisher struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookPublisher создает publisher с таймаутом запроса timeout
+func NewWebhookPublisher(url string, timeout time.Duration) *WebhookPublisher {
+	return &WebhookPublisher{URL: url, Client: &http.Client{Timeout: timeout}}
+}
+
+// Publish отправляет событие на URL
+func (p *WebhookPublisher) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.FormatUint(uint64(event.ID), 10))
+	req.Header.Set("X-Event-Type", event.Type)
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Дочитываем тело, чтобы соединение вернулось в пул
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook ответил %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+// Package outbox - доставка событий из таблицы outbox во внешние системы
+//
+// Репозитории пишут событие в outbox в одной транзакции с изменением, а Relay
+// периодически читает недоставленные события и передает их EventPublisher.
+// Гарантия - "хотя бы один раз": если процесс упадет между публикацией и отметкой
+// о доставке, событие будет опубликовано повторно, поэтому получатели должны
+// отбрасывать дубликаты по Event.ID. События одного чата публикуются по порядку:
+// если событие не доставлено, следующие события этого чата ждут его повтора
+// (события других чатов из того же пакета при этом доставляются)
+//
+// Неудачное событие повторяется с растущей паузой (RetryDelay, 2×, 4×... до часа); пока
+// оно ждет, события его чата не читаются и не занимают пакет. После MaxAttempts попыток
+// событие бросается (FailedAt, ошибка в логе), и следующие события чата идут дальше
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go-chat-app/internal/lease"
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+)
+
+// leaseName - имя аренды relay в таблице outbox_leases
+const leaseName = "relay"
+
+// Event - событие для внешней системы
+type Event struct {
+	ID        uint            `json:"id"` // для отбрасывания дубликатов на стороне получателя
+	Type      string          `json:"type"`
+	ChatID    uint            `json:"chat_id"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// EventPublisher публикует событие во внешнюю систему
+// Ошибка означает, что событие не доставлено и будет отправлено повторно
+type EventPublisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// PublisherFunc позволяет использовать функцию как EventPublisher
+type PublisherFunc func(ctx context.Context, event Event) error
+
+// Publish вызывает f
+func (f PublisherFunc) Publish(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// Config - настройки Relay
+type Config struct {
+	BatchSize       int           // событий за один проход
+	Interval        time.Duration // пауза между проходами, если новых событий нет
+	LeaseTTL        time.Duration // аренда relay: если инстанс пропал, через LeaseTTL события подхватит другой
+	MaxAttempts     int           // попыток доставки события, после которых оно бросается
+	RetryDelay      time.Duration // пауза перед первым повтором, дальше удваивается (не больше часа)
+	Retention       time.Duration // сколько хранить доставленные и брошенные события
+	CleanupInterval time.Duration // как часто удалять доставленные и брошенные события
+}
+
+// withDefaults подставляет значения по умолчанию для незаданных полей
+func (c Config) withDefaults() Config {
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.Interval <= 0 {
+		c.Interval = time.Second
+	}
+	if c.LeaseTTL <= 0 {
+		c.LeaseTTL = 30 * time.Second
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 20
+	}
+	if c.RetryDelay <= 0 {
+		c.RetryDelay = 5 * time.Second
+	}
+	if c.Retention <= 0 {
+		c.Retention = 24 * time.Hour
+	}
+	if c.CleanupInterval <= 0 {
+		c.CleanupInterval = 10 * time.Minute
+	}
+	return c
+}
+
+// Relay переносит события из outbox в EventPublisher
+// На нескольких инстансах события публикует только владелец аренды
+type Relay struct {
+	store     repository.OutboxStore
+	publisher EventPublisher
+	cfg       Config
+	holder    string // идентификатор этого инстанса в аренде
+}
+
+// NewRelay создает relay
+func NewRelay(store repository.OutboxStore, publisher EventPublisher, cfg Config) *Relay {
+	return &Relay{
+		store:     store,
+		publisher: publisher,
+		cfg:       cfg.withDefaults(),
+		holder:    lease.HolderID(),
+	}
+}
+
+// Run публикует события до отмены ctx
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	lastCleanup := time.Now()
+
+	for {
+		published, err := r.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "ошибка доставки событий outbox", slog.Any("error", err))
+		}
+
+		if time.Since(lastCleanup) >= r.cfg.CleanupInterval {
+			r.cleanup(ctx)
+			lastCleanup = time.Now()
+		}
+
+		// Полный пакет - скорее всего, есть еще события: продолжаем без паузы
+		if err == nil && published == r.cfg.BatchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce выполняет один проход: публикует до BatchSize событий
+// Возвращает количество опубликованных событий; 0 без ошибки - если аренда у другого инстанса
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	ok, err := r.store.AcquireLease(ctx, leaseName, r.holder, r.cfg.LeaseTTL)
+	if err != nil {
+		return 0, fmt.Errorf("аренда relay: %w", err)
+	}
+	if !ok {
+		return 0, nil
+	}
+
+	events, err := r.store.Pending(ctx, r.cfg.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("чтение outbox: %w", err)
+	}
+
+	var delivered []uint
+	var leaseErr error
+	leaseUntil := time.Now().Add(r.cfg.LeaseTTL)
+	blocked := make(map[uint]bool) // чаты, в которых событие не доставлено
+	for _, e := range events {
+		if blocked[e.ChatID] {
+			continue // сохраняем порядок: сначала должно уйти предыдущее событие чата
+		}
+		// Пакет может публиковаться дольше аренды: с половины срока продлеваем ее перед каждым
+		// событием. Если аренду забрал другой инстанс, останавливаемся - иначе события одного
+		// чата публиковали бы двое и не по порядку
+		if time.Until(leaseUntil) < r.cfg.LeaseTTL/2 {
+			renewed := time.Now().Add(r.cfg.LeaseTTL)
+			ok, err := r.store.AcquireLease(ctx, leaseName, r.holder, r.cfg.LeaseTTL)
+			if err != nil {
+				leaseErr = fmt.Errorf("продление аренды relay: %w", err)
+				break
+			}
+			if !ok {
+				slog.WarnContext(ctx, "аренда relay перешла к другому инстансу, пакет остановлен")
+				break
+			}
+			leaseUntil = renewed
+		}
+		// Публикация не переживает аренду, даже если получатель отвечает дольше ее срока
+		publishCtx, cancel := context.WithDeadline(ctx, leaseUntil)
+		err := r.publisher.Publish(publishCtx, Event{
+			ID:        e.ID,
+			Type:      e.Type,
+			ChatID:    e.ChatID,
+			Payload:   json.RawMessage(e.Payload),
+			CreatedAt: e.CreatedAt,
+		})
+		cancel()
+		if err != nil {
+			blocked[e.ChatID] = true
+			r.markFailed(ctx, e, err)
+			continue
+		}
+		delivered = append(delivered, e.ID)
+	}
+
+	// Если процесс упадет до отметки, события будут опубликованы еще раз - это допустимо
+	// Отметку не отменяем вместе с ctx: при остановке уже опубликованное не нужно повторять
+	if err := r.store.MarkDelivered(context.WithoutCancel(ctx), delivered, time.Now()); err != nil {
+		return 0, fmt.Errorf("отметка доставки: %w", err)
+	}
+	return len(delivered), leaseErr
+}
+
+// markFailed записывает неудачную попытку: повтор через растущую паузу или, после
+// MaxAttempts попыток, отказ от события
+func (r *Relay) markFailed(ctx context.Context, e models.OutboxEvent, cause error) {
+	attempt := e.Attempts + 1
+	var retryAt time.Time
+	if attempt < r.cfg.MaxAttempts {
+		retryAt = time.Now().Add(lease.RetryDelay(r.cfg.RetryDelay, attempt))
+		slog.WarnContext(ctx, "событие outbox не доставлено",
+			slog.Uint64("event_id", uint64(e.ID)),
+			slog.Uint64("chat_id", uint64(e.ChatID)),
+			slog.Int("attempts", attempt),
+			slog.Time("retry_at", retryAt),
+			slog.Any("error", cause),
+		)
+	} else {
+		slog.ErrorContext(ctx, "событие outbox брошено после всех попыток",
+			slog.Uint64("event_id", uint64(e.ID)),
+			slog.Uint64("chat_id", uint64(e.ChatID)),
+			slog.String("type", e.Type),
+			slog.Int("attempts", attempt),
+			slog.Any("error", cause),
+		)
+	}
+	if err := r.store.MarkFailed(ctx, e.ID, cause.Error(), retryAt); err != nil {
+		slog.WarnContext(ctx, "не удалось записать ошибку доставки", slog.Any("error", err))
+	}
+}
+
+// cleanup удаляет доставленные и брошенные события старше Retention
+func (r *Relay) cleanup(ctx context.Context) {
+	deleted, err := r.store.DeleteDelivered(ctx, time.Now().Add(-r.cfg.Retention))
+	if err != nil {
+		slog.WarnContext(ctx, "не удалось удалить старые события outbox", slog.Any("error", err))
+		return
+	}
+	if deleted > 0 {
+		slog.DebugContext(ctx, "удалены старые события outbox", slog.Int64("count", deleted))
+	}
+}
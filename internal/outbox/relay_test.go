@@ -0,0 +1,237 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository/memory"
+)
+
+// seed создает два чата и по два сообщения в каждом (4 события в outbox)
+func seed(t *testing.T, db *memory.DB) (chat1, chat2 uint) {
+	t.Helper()
+	ctx := context.Background()
+	a, b := &models.Chat{Title: "a"}, &models.Chat{Title: "b"}
+	db.Chats().Create(ctx, a)
+	db.Chats().Create(ctx, b)
+	for _, chatID := range []uint{a.ID, b.ID, a.ID, b.ID} {
+		if err := db.Messages().Create(ctx, &models.Message{ChatID: chatID, Text: "x"}); err != nil {
+			t.Fatalf("Create message: %v", err)
+		}
+	}
+	return a.ID, b.ID
+}
+
+// TestRelayOrderingPerChat проверяет, что сбой доставки в одном чате задерживает
+// только его события, а после восстановления они уходят по порядку
+func TestRelayOrderingPerChat(t *testing.T) {
+	db := memory.New()
+	chat1, chat2 := seed(t, db)
+	ctx := context.Background()
+
+	var published []Event
+	failChat1 := true
+	relay := NewRelay(db.Outbox(), PublisherFunc(func(ctx context.Context, e Event) error {
+		if failChat1 && e.ChatID == chat1 {
+			return errors.New("получатель недоступен")
+		}
+		published = append(published, e)
+		return nil
+	}), Config{RetryDelay: 10 * time.Millisecond})
+
+	n, err := relay.RunOnce(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("Первый проход: опубликовано %d, err=%v", n, err)
+	}
+	for _, e := range published {
+		if e.ChatID != chat2 {
+			t.Errorf("Событие чата %d не должно было уйти", e.ChatID)
+		}
+	}
+	// До повтора события чата не читаются, после паузы - снова в очереди
+	if pending, _ := db.Outbox().Pending(ctx, 10); len(pending) != 0 {
+		t.Errorf("События чата не должны читаться до повтора: %+v", pending)
+	}
+	time.Sleep(20 * time.Millisecond)
+	pending, _ := db.Outbox().Pending(ctx, 10)
+	if len(pending) != 2 || pending[0].Attempts != 1 || pending[1].Attempts != 0 {
+		t.Errorf("Ожидалась 1 попытка для первого события чата и 0 для второго: %+v", pending)
+	}
+
+	failChat1 = false
+	published = nil
+	if n, _ := relay.RunOnce(ctx); n != 2 {
+		t.Fatalf("Второй проход: опубликовано %d", n)
+	}
+	if len(published) != 2 || published[0].ID >= published[1].ID {
+		t.Errorf("События чата должны уйти по порядку: %+v", published)
+	}
+	if string(published[0].Payload) == "" || published[0].Type != models.OutboxMessageCreated {
+		t.Errorf("Неверное событие: %+v", published[0])
+	}
+}
+
+// TestRelayGivesUp проверяет, что после MaxAttempts событие бросается и чат идет дальше
+func TestRelayGivesUp(t *testing.T) {
+	db := memory.New()
+	seed(t, db)
+	ctx := context.Background()
+
+	var poisoned uint
+	relay := NewRelay(db.Outbox(), PublisherFunc(func(ctx context.Context, e Event) error {
+		if poisoned == 0 || e.ID == poisoned {
+			poisoned = e.ID
+			return errors.New("получатель отвергает событие")
+		}
+		return nil
+	}), Config{MaxAttempts: 2, RetryDelay: time.Millisecond})
+
+	total := 0
+	for i := 0; i < 3; i++ {
+		n, err := relay.RunOnce(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += n
+		time.Sleep(5 * time.Millisecond)
+	}
+	if total != 3 {
+		t.Errorf("Ожидалась доставка 3 событий кроме брошенного, доставлено %d", total)
+	}
+	if pending, _ := db.Outbox().Pending(ctx, 10); len(pending) != 0 {
+		t.Errorf("Брошенное событие не должно оставаться в очереди: %+v", pending)
+	}
+	if deleted, _ := db.Outbox().DeleteDelivered(ctx, time.Now().Add(time.Minute)); deleted != 4 {
+		t.Errorf("Ожидалось 4 события к очистке (3 доставленных и брошенное), получено %d", deleted)
+	}
+}
+
+// TestRelayLease проверяет, что при двух relay события публикует только владелец аренды
+func TestRelayLease(t *testing.T) {
+	db := memory.New()
+	seed(t, db)
+	ctx := context.Background()
+
+	count := 0
+	publisher := PublisherFunc(func(ctx context.Context, e Event) error { count++; return nil })
+	first := NewRelay(db.Outbox(), publisher, Config{BatchSize: 1})
+	second := NewRelay(db.Outbox(), publisher, Config{BatchSize: 1})
+
+	if n, _ := first.RunOnce(ctx); n != 1 {
+		t.Fatalf("Первый relay должен получить аренду и опубликовать событие, опубликовано %d", n)
+	}
+	if n, _ := second.RunOnce(ctx); n != 0 {
+		t.Errorf("Второй relay не должен публиковать при чужой аренде, опубликовано %d", n)
+	}
+	if count != 1 {
+		t.Errorf("Ожидалась 1 публикация, получено %d", count)
+	}
+}
+
+// TestRelayRenewsLease проверяет, что пакет дольше аренды продлевает ее по ходу публикации
+func TestRelayRenewsLease(t *testing.T) {
+	db := memory.New()
+	seed(t, db)
+	ctx := context.Background()
+
+	relay := NewRelay(db.Outbox(), PublisherFunc(func(ctx context.Context, e Event) error {
+		time.Sleep(15 * time.Millisecond)
+		return nil
+	}), Config{LeaseTTL: 40 * time.Millisecond})
+	if n, err := relay.RunOnce(ctx); err != nil || n != 4 {
+		t.Fatalf("Ожидалась публикация 4 событий, опубликовано %d, err=%v", n, err)
+	}
+	if ok, _ := db.Outbox().AcquireLease(ctx, leaseName, "другой", time.Hour); ok {
+		t.Error("Аренда должна была продлеваться и еще не истечь")
+	}
+}
+
+// TestRelayStopsWhenLeaseLost проверяет, что relay останавливает пакет, если аренду забрал другой инстанс
+func TestRelayStopsWhenLeaseLost(t *testing.T) {
+	db := memory.New()
+	seed(t, db)
+	ctx := context.Background()
+
+	published := 0
+	relay := NewRelay(db.Outbox(), PublisherFunc(func(ctx context.Context, e Event) error {
+		published++
+		if published == 1 {
+			// Публикация дольше аренды: ее забирает другой инстанс
+			time.Sleep(25 * time.Millisecond)
+			if ok, _ := db.Outbox().AcquireLease(context.Background(), leaseName, "другой", time.Hour); !ok {
+				t.Error("Истекшая аренда должна достаться другому инстансу")
+			}
+		}
+		return nil
+	}), Config{LeaseTTL: 20 * time.Millisecond})
+	if n, err := relay.RunOnce(ctx); err != nil || n != 1 || published != 1 {
+		t.Errorf("Ожидалась остановка после первого события: опубликовано %d, отмечено %d, err=%v", published, n, err)
+	}
+}
+
+// TestRelayRunAndCleanup проверяет фоновую доставку и удаление доставленных событий
+func TestRelayRunAndCleanup(t *testing.T) {
+	db := memory.New()
+	seed(t, db)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	delivered := make(chan Event, 10)
+	relay := NewRelay(db.Outbox(), PublisherFunc(func(ctx context.Context, e Event) error {
+		delivered <- e
+		return nil
+	}), Config{Interval: 10 * time.Millisecond, Retention: time.Nanosecond})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		relay.Run(ctx)
+	}()
+
+	for i := 0; i < 4; i++ {
+		select {
+		case <-delivered:
+		case <-time.After(time.Second):
+			t.Fatalf("Доставлено только %d событий из 4", i)
+		}
+	}
+	cancel()
+	<-done
+
+	if pending, _ := db.Outbox().Pending(ctx, 10); len(pending) != 0 {
+		t.Fatalf("События не отмечены доставленными: %+v", pending)
+	}
+	time.Sleep(time.Millisecond)
+	relay.cleanup(context.Background())
+	if deleted, _ := db.Outbox().DeleteDelivered(context.Background(), time.Now()); deleted != 0 {
+		t.Errorf("cleanup должен был удалить все доставленные события, осталось %d", deleted)
+	}
+}
+
+// TestWebhookPublisher проверяет заголовки и обработку ответов webhook
+func TestWebhookPublisher(t *testing.T) {
+	status := http.StatusOK
+	var gotID string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = r.Header.Get("X-Event-ID")
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	p := NewWebhookPublisher(srv.URL, time.Second)
+	event := Event{ID: 7, Type: models.OutboxChatDeleted, ChatID: 1, Payload: []byte(`{}`)}
+	if err := p.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if gotID != "7" {
+		t.Errorf("Ожидался X-Event-ID 7, получен %q", gotID)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := p.Publish(context.Background(), event); err == nil {
+		t.Error("Ответ 503 должен считаться ошибкой доставки")
+	}
+}
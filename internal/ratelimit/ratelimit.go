@@ -0,0 +1,162 @@
+// Package ratelimit - ограничение частоты запросов по алгоритму token bucket
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit описывает ведро токенов: Requests запросов за Period,
+// при этом подряд (всплеском) можно сделать до Burst запросов
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// Enabled сообщает, задан ли лимит (нулевой Limit - без ограничений)
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// rate - скорость пополнения ведра в токенах в секунду
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// capacity - емкость ведра (если Burst не задан - Requests)
+func (l Limit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.Requests)
+}
+
+// String возвращает лимит в виде политики для заголовка RateLimit-Policy: "60;w=60"
+func (l Limit) String() string {
+	return fmt.Sprintf("%d;w=%d", l.Requests, int(math.Ceil(l.Period.Seconds())))
+}
+
+// Result - результат попытки взять токен
+type Result struct {
+	Allowed    bool
+	Limit      int           // размер лимита (для заголовка RateLimit-Limit)
+	Remaining  int           // сколько запросов осталось прямо сейчас
+	RetryAfter time.Duration // через сколько появится токен (если Allowed == false)
+	Reset      time.Duration // через сколько ведро наполнится полностью
+}
+
+// Store хранит состояние ведер
+// Реализация в памяти подходит для одного инстанса; для нескольких инстансов
+// нужна общая реализация (например, поверх Redis или PostgreSQL) с той же семантикой
+type Store interface {
+	// Take пытается взять один токен из ведра key с параметрами limit
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// MemoryStore - потокобезопасное хранилище ведер в памяти процесса
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// bucket - состояние одного ведра
+type bucket struct {
+	tokens float64
+	last   time.Time // когда tokens был пересчитан
+	full   time.Time // когда ведро наполнится полностью (после этого его можно забыть)
+}
+
+// sweepInterval - как часто удалять ведра, которые уже наполнились
+const sweepInterval = time.Minute
+
+// NewMemoryStore создает хранилище ведер в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Take пытается взять один токен из ведра key
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	if !limit.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	rate, capacity := limit.rate(), limit.capacity()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		s.buckets[key] = b
+	}
+
+	// Пополняем ведро за прошедшее время, но не больше емкости
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	res := Result{Limit: limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - b.tokens) / rate)
+	}
+	res.Remaining = int(math.Floor(b.tokens))
+	res.Reset = secondsToDuration((capacity - b.tokens) / rate)
+	b.full = now.Add(res.Reset)
+	return res, nil
+}
+
+// sweep удаляет ведра, которые уже наполнились: они неотличимы от новых
+// Вызывается под мьютексом не чаще раза в sweepInterval
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// secondsToDuration переводит дробные секунды в time.Duration
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// ctxKey - ключ контекста для идентификатора клиента
+type ctxKey struct{}
+
+// WithClient сохраняет в контексте ключ клиента (пользователь или IP),
+// по которому считаются лимиты
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, client)
+}
+
+// Client возвращает ключ клиента из контекста или пустую строку
+func Client(ctx context.Context) string {
+	client, _ := ctx.Value(ctxKey{}).(string)
+	return client
+}
+
+// Rules - лимиты для разных классов запросов API
+type Rules struct {
+	Messages Limit // отправка сообщений и все остальные изменения
+	Chats    Limit // создание чатов
+	Reads    Limit // чтение чатов и сообщений
+}
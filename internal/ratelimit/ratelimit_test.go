@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestMemoryStoreTokenBucket проверяет всплеск, отказ и пополнение ведра
+func TestMemoryStoreTokenBucket(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// 60 запросов в минуту = 1 токен в секунду, подряд не больше 3
+	limit := Limit{Requests: 60, Period: time.Minute, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		res, _ := s.Take(ctx, "client", limit)
+		if !res.Allowed {
+			t.Fatalf("Запрос %d из всплеска отклонен", i+1)
+		}
+		if res.Remaining != 2-i {
+			t.Errorf("Запрос %d: ожидалось Remaining=%d, получено %d", i+1, 2-i, res.Remaining)
+		}
+	}
+
+	res, _ := s.Take(ctx, "client", limit)
+	if res.Allowed {
+		t.Fatal("Четвертый запрос подряд должен быть отклонен")
+	}
+	if res.RetryAfter != time.Second {
+		t.Errorf("Ожидался RetryAfter=1s, получено %s", res.RetryAfter)
+	}
+	if res.Reset != 3*time.Second {
+		t.Errorf("Ожидался Reset=3s, получено %s", res.Reset)
+	}
+
+	// Другой клиент не зависит от первого
+	if res, _ := s.Take(ctx, "other", limit); !res.Allowed {
+		t.Error("Лимит другого клиента не должен быть исчерпан")
+	}
+
+	// Через секунду появляется один токен
+	now = now.Add(time.Second)
+	if res, _ := s.Take(ctx, "client", limit); !res.Allowed {
+		t.Error("После пополнения запрос должен пройти")
+	}
+	if res, _ := s.Take(ctx, "client", limit); res.Allowed {
+		t.Error("Пополнился только один токен")
+	}
+}
+
+// TestMemoryStoreSweep проверяет, что наполнившиеся ведра удаляются
+func TestMemoryStoreSweep(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	limit := Limit{Requests: 10, Period: time.Second}
+
+	for _, key := range []string{"a", "b", "c"} {
+		s.Take(context.Background(), key, limit)
+	}
+
+	now = now.Add(2 * sweepInterval)
+	s.Take(context.Background(), "d", limit)
+
+	if len(s.buckets) != 1 {
+		t.Errorf("Ожидалось 1 ведро после очистки, осталось %d", len(s.buckets))
+	}
+}
+
+// TestDisabledLimit проверяет, что нулевой лимит ничего не ограничивает
+func TestDisabledLimit(t *testing.T) {
+	s := NewMemoryStore()
+	for i := 0; i < 100; i++ {
+		if res, _ := s.Take(context.Background(), "client", Limit{}); !res.Allowed {
+			t.Fatal("Нулевой лимит не должен ограничивать запросы")
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"go-chat-app/internal/models"
 
 	"gorm.io/gorm"
@@ -8,32 +12,98 @@ import (
 
 // ChatRepository отвечает за работу с чатами в базе данных
 type ChatRepository struct {
-	db *gorm.DB
+	db     *gorm.DB
+	outbox outboxWriter
 }
 
 // NewChatRepository создает новый репозиторий для чатов
-func NewChatRepository(db *gorm.DB) *ChatRepository {
-	return &ChatRepository{db: db}
+func NewChatRepository(db *gorm.DB, opts ...Option) *ChatRepository {
+	return &ChatRepository{db: db, outbox: newOutboxWriter(opts)}
 }
 
 // Create сохраняет новый чат в базу данных
-func (r *ChatRepository) Create(chat *models.Chat) error {
-	return r.db.Create(chat).Error
+func (r *ChatRepository) Create(ctx context.Context, chat *models.Chat) error {
+	ctx, span := tracer.Start(ctx, "ChatRepository.Create")
+	defer span.End()
+
+	// WithContext передает контекст в GORM: запрос отменится вместе с контекстом,
+	// а спан запроса станет дочерним для спана репозитория
+	return recordError(ctx, span, r.db.WithContext(ctx).Create(chat).Error)
 }
 
 // GetByID находит чат по ID
-func (r *ChatRepository) GetByID(id uint) (*models.Chat, error) {
+func (r *ChatRepository) GetByID(ctx context.Context, id uint) (*models.Chat, error) {
+	ctx, span := tracer.Start(ctx, "ChatRepository.GetByID")
+	defer span.End()
+
 	var chat models.Chat
 	// First ищет первую запись по условию
-	err := r.db.First(&chat, id).Error
+	err := r.db.WithContext(ctx).First(&chat, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
-		return nil, err
+		return nil, recordError(ctx, span, err)
 	}
 	return &chat, nil
 }
 
-// Delete удаляет чат по ID
-func (r *ChatRepository) Delete(id uint) error {
-	// Delete удаляет запись по ID
-	return r.db.Delete(&models.Chat{}, id).Error
+// List возвращает страницу чатов в порядке ID
+func (r *ChatRepository) List(ctx context.Context, afterID uint, limit int) ([]models.Chat, error) {
+	ctx, span := tracer.Start(ctx, "ChatRepository.List")
+	defer span.End()
+
+	// Постраничный обход по ключу (id > afterID) вместо OFFSET:
+	// скорость не падает на дальних страницах, и удаление чатов не сдвигает страницы
+	chats := make([]models.Chat, 0, limit)
+	err := r.db.WithContext(ctx).
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&chats).Error
+	if err != nil {
+		return nil, recordError(ctx, span, err)
+	}
+	return chats, nil
+}
+
+// Update сохраняет изменяемые поля чата
+func (r *ChatRepository) Update(ctx context.Context, chat *models.Chat) error {
+	ctx, span := tracer.Start(ctx, "ChatRepository.Update")
+	defer span.End()
+
+	// Select перечисляет колонки явно: Updates без него пропустил бы нулевые значения
+	// (например, выключение медленного режима slow_mode_seconds = 0
+	// или возврат к сроку хранения по умолчанию retention_days = NULL)
+	res := r.db.WithContext(ctx).Model(chat).
+		Select("title", "slow_mode_seconds", "retention_days").
+		Updates(chat)
+	if res.Error != nil {
+		return recordError(ctx, span, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete мягко удаляет чат по ID (заполняет deleted_at)
+// Событие chat.deleted пишется в outbox в той же транзакции
+func (r *ChatRepository) Delete(ctx context.Context, id uint) error {
+	ctx, span := tracer.Start(ctx, "ChatRepository.Delete")
+	defer span.End()
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Delete для модели с gorm.DeletedAt выполняет UPDATE deleted_at, а не DELETE
+		res := tx.Delete(&models.Chat{}, id)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error // чата нет или он уже удален - событие не нужно
+		}
+		event, err := NewOutboxEvent(models.OutboxChatDeleted, id, nil, time.Now())
+		if err != nil {
+			return err
+		}
+		return r.outbox.write(tx, event)
+	})
+	return recordError(ctx, span, err)
 }
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-chat-app/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyRepository отвечает за хранение ключей идемпотентности в базе данных
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+// NewIdempotencyRepository создает новый репозиторий ключей идемпотентности
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// Claim резервирует ключ или возвращает уже сохраненную запись
+func (r *IdempotencyRepository) Claim(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+	ctx, span := tracer.Start(ctx, "IdempotencyRepository.Claim")
+	defer span.End()
+
+	db := r.db.WithContext(ctx)
+
+	// Две попытки: если найденный ключ истек, удаляем его и резервируем заново
+	for attempt := 0; attempt < 2; attempt++ {
+		// INSERT ... ON CONFLICT DO NOTHING: из двух одновременных запросов
+		// с одинаковым ключом вставку выполнит только один
+		res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+		if res.Error != nil {
+			return nil, false, recordError(ctx, span, res.Error)
+		}
+		if res.RowsAffected == 1 {
+			return nil, true, nil
+		}
+
+		var existing models.IdempotencyKey
+		err := db.Where("scope = ? AND idempotency_key = ?", key.Scope, key.Key).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue // ключ успели удалить между INSERT и SELECT - пробуем снова
+		}
+		if err != nil {
+			return nil, false, recordError(ctx, span, err)
+		}
+		if existing.ExpiresAt.After(time.Now()) {
+			return &existing, false, nil
+		}
+
+		// Ключ истек: удаляем только если его не обновили параллельно
+		err = db.Where("scope = ? AND idempotency_key = ? AND expires_at = ?", existing.Scope, existing.Key, existing.ExpiresAt).
+			Delete(&models.IdempotencyKey{}).Error
+		if err != nil {
+			return nil, false, recordError(ctx, span, err)
+		}
+	}
+	return nil, false, errors.New("не удалось зарезервировать ключ идемпотентности")
+}
+
+// Complete сохраняет ответ для зарезервированного ключа
+func (r *IdempotencyRepository) Complete(ctx context.Context, scope, key string, statusCode int, contentType string, body []byte) error {
+	ctx, span := tracer.Start(ctx, "IdempotencyRepository.Complete")
+	defer span.End()
+
+	err := r.db.WithContext(ctx).Model(&models.IdempotencyKey{}).
+		Where("scope = ? AND idempotency_key = ?", scope, key).
+		Updates(map[string]any{
+			"status_code":   statusCode,
+			"content_type":  contentType,
+			"response_body": body,
+		}).Error
+	return recordError(ctx, span, err)
+}
+
+// Release удаляет резерв ключа
+func (r *IdempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	ctx, span := tracer.Start(ctx, "IdempotencyRepository.Release")
+	defer span.End()
+
+	err := r.db.WithContext(ctx).
+		Where("scope = ? AND idempotency_key = ?", scope, key).
+		Delete(&models.IdempotencyKey{}).Error
+	return recordError(ctx, span, err)
+}
+
+// DeleteExpired удаляет истекшие ключи
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	ctx, span := tracer.Start(ctx, "IdempotencyRepository.DeleteExpired")
+	defer span.End()
+
+	res := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{})
+	return res.RowsAffected, recordError(ctx, span, res.Error)
+}
@@ -0,0 +1,243 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go-chat-app/internal/models"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LinkPreviewRepository отвечает за превью ссылок и ссылки сообщений на них
+// Превью загружает фоновый обработчик (internal/unfurl) через Claim, Complete и MarkFailed
+type LinkPreviewRepository struct {
+	db *gorm.DB
+}
+
+// NewLinkPreviewRepository создает новый репозиторий превью ссылок
+func NewLinkPreviewRepository(db *gorm.DB) *LinkPreviewRepository {
+	return &LinkPreviewRepository{db: db}
+}
+
+// Attach связывает сообщение с превью адресов urls, ставя новые и устаревшие адреса в очередь
+func (r *LinkPreviewRepository) Attach(ctx context.Context, messageID uint, urls []string, staleBefore time.Time) ([]models.LinkPreview, error) {
+	ctx, span := tracer.Start(ctx, "LinkPreviewRepository.Attach")
+	defer span.End()
+	span.SetAttributes(attribute.Int("link_previews.urls", len(urls)))
+
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	// Время в местном, как GORM записывает created_at: колонки сравниваются без часового пояса
+	now := time.Now().Local()
+	var previews []models.LinkPreview
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Новые адреса: превью уже может быть создано другим сообщением (в том числе параллельно)
+		queued := make([]models.LinkPreview, len(urls))
+		for i, u := range urls {
+			queued[i] = models.LinkPreview{URL: u, Status: models.LinkPreviewPending, QueuedAt: now}
+		}
+		err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "url"}}, DoNothing: true}).
+			Create(&queued).Error
+		if err != nil {
+			return err
+		}
+
+		// Устаревший кэш загружается заново; до загрузки клиенты видят прежние данные
+		err = tx.Model(&models.LinkPreview{}).
+			Where("url IN ? AND status IN ? AND fetched_at < ?", urls,
+				[]string{models.LinkPreviewReady, models.LinkPreviewFailed}, staleBefore.Local()).
+			Updates(map[string]any{
+				"status":       models.LinkPreviewPending,
+				"attempts":     0,
+				"last_error":   "",
+				"locked_until": nil,
+				"queued_at":    now,
+				"updated_at":   now,
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		var found []models.LinkPreview
+		if err := tx.Where("url IN ?", urls).Find(&found).Error; err != nil {
+			return err
+		}
+		byURL := make(map[string]models.LinkPreview, len(found))
+		for _, p := range found {
+			byURL[p.URL] = p
+		}
+		links := make([]models.MessageLink, 0, len(urls))
+		for i, u := range urls {
+			p := byURL[u]
+			previews = append(previews, p)
+			links = append(links, models.MessageLink{MessageID: messageID, PreviewID: p.ID, Position: i, CreatedAt: now})
+		}
+		return tx.Create(&links).Error
+	})
+	if err != nil {
+		return nil, recordError(ctx, span, err)
+	}
+	return previews, nil
+}
+
+// messagePreview - строка ListByMessages: превью вместе с сообщением, к которому оно относится
+type messagePreview struct {
+	models.LinkPreview
+	MessageID uint
+}
+
+// ListByMessages возвращает превью сообщений в порядке ссылок в тексте
+func (r *LinkPreviewRepository) ListByMessages(ctx context.Context, ids []uint) (map[uint][]models.LinkPreview, error) {
+	ctx, span := tracer.Start(ctx, "LinkPreviewRepository.ListByMessages")
+	defer span.End()
+
+	previews := make(map[uint][]models.LinkPreview)
+	if len(ids) == 0 {
+		return previews, nil
+	}
+	var rows []messagePreview
+	err := r.db.WithContext(ctx).Model(&models.LinkPreview{}).
+		Select("link_previews.*, message_links.message_id").
+		Joins("JOIN message_links ON message_links.preview_id = link_previews.id").
+		Where("message_links.message_id IN ?", ids).
+		Order("message_links.message_id, message_links.position").
+		Find(&rows).Error
+	if err != nil {
+		return nil, recordError(ctx, span, err)
+	}
+	for _, row := range rows {
+		previews[row.MessageID] = append(previews[row.MessageID], row.LinkPreview)
+	}
+	return previews, nil
+}
+
+// Claim захватывает пачку превью, которые пора загрузить
+func (r *LinkPreviewRepository) Claim(ctx context.Context, holder string, now time.Time, lease time.Duration, limit int) ([]models.LinkPreview, error) {
+	ctx, span := tracer.Start(ctx, "LinkPreviewRepository.Claim")
+	defer span.End()
+
+	now = now.Local()
+	db := r.db.WithContext(ctx)
+	due := db.Model(&models.LinkPreview{}).
+		Select("id").
+		Where("(status = ? AND (locked_until IS NULL OR locked_until <= ?)) OR (status = ? AND locked_until <= ?)",
+			models.LinkPreviewPending, now, models.LinkPreviewFetching, now).
+		Order("queued_at, id").
+		Limit(limit)
+	// Как в ScheduledRepository.Claim: в PostgreSQL захваченные другим инстансом строки пропускаются
+	if db.Dialector.Name() == "postgres" {
+		due = due.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+	}
+
+	var claimed []models.LinkPreview
+	err := db.Model(&claimed).
+		Clauses(clause.Returning{}).
+		Where("id IN (?)", due).
+		Updates(map[string]any{
+			"status":       models.LinkPreviewFetching,
+			"locked_by":    holder,
+			"locked_until": now.Add(lease),
+			"updated_at":   now,
+		}).Error
+	if err != nil {
+		return nil, recordError(ctx, span, err)
+	}
+	// RETURNING не гарантирует порядок строк
+	sort.Slice(claimed, func(i, j int) bool {
+		if !claimed[i].QueuedAt.Equal(claimed[j].QueuedAt) {
+			return claimed[i].QueuedAt.Before(claimed[j].QueuedAt)
+		}
+		return claimed[i].ID < claimed[j].ID
+	})
+	span.SetAttributes(attribute.Int("link_previews.claimed", len(claimed)))
+	return claimed, nil
+}
+
+// Complete сохраняет загруженные метаданные превью
+func (r *LinkPreviewRepository) Complete(ctx context.Context, preview *models.LinkPreview, holder string, at time.Time) error {
+	ctx, span := tracer.Start(ctx, "LinkPreviewRepository.Complete")
+	defer span.End()
+
+	at = at.Local()
+	res := r.db.WithContext(ctx).Model(&models.LinkPreview{}).
+		Where("id = ? AND status = ? AND locked_by = ?", preview.ID, models.LinkPreviewFetching, holder).
+		Updates(map[string]any{
+			"status":       models.LinkPreviewReady,
+			"title":        preview.Title,
+			"description":  preview.Description,
+			"image_url":    preview.ImageURL,
+			"site_name":    preview.SiteName,
+			"last_error":   "",
+			"locked_by":    "",
+			"locked_until": nil,
+			"fetched_at":   at,
+			"updated_at":   at,
+		})
+	if res.Error != nil {
+		return recordError(ctx, span, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrPreviewClaimLost
+	}
+	preview.Status = models.LinkPreviewReady
+	preview.LockedBy = ""
+	preview.LockedUntil = nil
+	preview.FetchedAt = &at
+	return nil
+}
+
+// MarkFailed записывает неудачную попытку загрузки
+func (r *LinkPreviewRepository) MarkFailed(ctx context.Context, id uint, holder, reason string, retryAt time.Time) error {
+	ctx, span := tracer.Start(ctx, "LinkPreviewRepository.MarkFailed")
+	defer span.End()
+
+	now := time.Now().Local()
+	values := map[string]any{
+		"attempts":     gorm.Expr("attempts + 1"),
+		"last_error":   reason,
+		"locked_by":    "",
+		"locked_until": nil,
+		"updated_at":   now,
+	}
+	if retryAt.IsZero() {
+		values["status"] = models.LinkPreviewFailed
+		values["fetched_at"] = now
+	} else {
+		values["status"] = models.LinkPreviewPending
+		values["locked_until"] = retryAt.Local()
+	}
+	res := r.db.WithContext(ctx).Model(&models.LinkPreview{}).
+		Where("id = ? AND status = ? AND locked_by = ?", id, models.LinkPreviewFetching, holder).
+		Updates(values)
+	if res.Error != nil {
+		return recordError(ctx, span, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrPreviewClaimLost
+	}
+	return nil
+}
+
+// Messages возвращает сообщения, получившие ссылку на превью не раньше since
+func (r *LinkPreviewRepository) Messages(ctx context.Context, previewID uint, since time.Time, limit int) ([]models.Message, error) {
+	ctx, span := tracer.Start(ctx, "LinkPreviewRepository.Messages")
+	defer span.End()
+
+	messages := make([]models.Message, 0)
+	err := r.db.WithContext(ctx).Model(&models.Message{}).
+		Select("messages.id, messages.chat_id").
+		Joins("JOIN message_links ON message_links.message_id = messages.id").
+		Where("message_links.preview_id = ? AND message_links.created_at >= ?", previewID, since.Local()).
+		Order("messages.id DESC").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		return nil, recordError(ctx, span, err)
+	}
+	return messages, nil
+}
@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"go-chat-app/internal/models"
+)
+
+// idempotencyID - составной ключ записи (как первичный ключ таблицы)
+type idempotencyID struct {
+	scope string
+	key   string
+}
+
+// IdempotencyStore - хранилище ключей идемпотентности в памяти
+type IdempotencyStore struct {
+	db *DB
+}
+
+// Claim резервирует ключ или возвращает уже сохраненную запись
+func (s *IdempotencyStore) Claim(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	id := idempotencyID{scope: key.Scope, key: key.Key}
+	if existing, ok := s.db.idempotency[id]; ok && existing.ExpiresAt.After(s.db.now()) {
+		return &existing, false, nil
+	}
+
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = s.db.now()
+	}
+	s.db.idempotency[id] = *key
+	return nil, true, nil
+}
+
+// Complete сохраняет ответ для зарезервированного ключа
+func (s *IdempotencyStore) Complete(ctx context.Context, scope, key string, statusCode int, contentType string, body []byte) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	id := idempotencyID{scope: scope, key: key}
+	stored, ok := s.db.idempotency[id]
+	if !ok {
+		return nil // как UPDATE без подходящих строк
+	}
+	stored.StatusCode = statusCode
+	stored.ContentType = contentType
+	stored.ResponseBody = append([]byte(nil), body...)
+	s.db.idempotency[id] = stored
+	return nil
+}
+
+// Release удаляет резерв ключа
+func (s *IdempotencyStore) Release(ctx context.Context, scope, key string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	delete(s.db.idempotency, idempotencyID{scope: scope, key: key})
+	return nil
+}
+
+// DeleteExpired удаляет истекшие ключи
+func (s *IdempotencyStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var deleted int64
+	for id, stored := range s.db.idempotency {
+		if !stored.ExpiresAt.After(now) {
+			delete(s.db.idempotency, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,212 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+)
+
+// linkID - первичный ключ ссылки сообщения (message_id, preview_id)
+type linkID struct {
+	message uint
+	preview uint
+}
+
+// LinkPreviewStore - превью ссылок в памяти
+type LinkPreviewStore struct {
+	db *DB
+}
+
+// Attach связывает сообщение с превью адресов urls, ставя новые и устаревшие адреса в очередь
+func (s *LinkPreviewStore) Attach(ctx context.Context, messageID uint, urls []string, staleBefore time.Time) ([]models.LinkPreview, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	// Аналог внешнего ключа message_links.message_id → messages.id
+	if _, ok := s.db.messages[messageID]; !ok {
+		return nil, fmt.Errorf("сообщение %d не существует: нарушение внешнего ключа", messageID)
+	}
+	now := s.db.now()
+	previews := make([]models.LinkPreview, 0, len(urls))
+	for i, u := range urls {
+		p, ok := s.db.previewByURL(u)
+		switch {
+		case !ok:
+			s.db.lastPreviewID++
+			p = models.LinkPreview{ID: s.db.lastPreviewID, URL: u, Status: models.LinkPreviewPending, QueuedAt: now, CreatedAt: now, UpdatedAt: now}
+		case (p.Status == models.LinkPreviewReady || p.Status == models.LinkPreviewFailed) && p.FetchedAt.Before(staleBefore):
+			p.Status = models.LinkPreviewPending
+			p.Attempts = 0
+			p.LastError = ""
+			p.LockedUntil = nil
+			p.QueuedAt = now
+			p.UpdatedAt = now
+		}
+		s.db.previews[p.ID] = p
+		s.db.links[linkID{messageID, p.ID}] = models.MessageLink{MessageID: messageID, PreviewID: p.ID, Position: i, CreatedAt: now}
+		previews = append(previews, p)
+	}
+	return previews, nil
+}
+
+// previewByURL находит превью по адресу, вызывается под db.mu
+func (db *DB) previewByURL(u string) (models.LinkPreview, bool) {
+	for _, p := range db.previews {
+		if p.URL == u {
+			return p, true
+		}
+	}
+	return models.LinkPreview{}, false
+}
+
+// ListByMessages возвращает превью сообщений в порядке ссылок в тексте
+func (s *LinkPreviewStore) ListByMessages(ctx context.Context, ids []uint) (map[uint][]models.LinkPreview, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	wanted := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var links []models.MessageLink
+	for key, link := range s.db.links {
+		if wanted[key.message] {
+			links = append(links, link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].Position < links[j].Position
+	})
+	previews := make(map[uint][]models.LinkPreview)
+	for _, link := range links {
+		previews[link.MessageID] = append(previews[link.MessageID], s.db.previews[link.PreviewID])
+	}
+	return previews, nil
+}
+
+// Claim захватывает пачку превью, которые пора загрузить
+// Захват выполняется под одной блокировкой - аналог UPDATE ... FOR UPDATE SKIP LOCKED
+func (s *LinkPreviewStore) Claim(ctx context.Context, holder string, now time.Time, lease time.Duration, limit int) ([]models.LinkPreview, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	due := []models.LinkPreview{}
+	for _, p := range s.db.previews {
+		lockExpired := p.LockedUntil == nil || !p.LockedUntil.After(now)
+		if (p.Status == models.LinkPreviewPending || p.Status == models.LinkPreviewFetching) && lockExpired {
+			due = append(due, p)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].QueuedAt.Equal(due[j].QueuedAt) {
+			return due[i].QueuedAt.Before(due[j].QueuedAt)
+		}
+		return due[i].ID < due[j].ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	until := now.Add(lease)
+	for i := range due {
+		due[i].Status = models.LinkPreviewFetching
+		due[i].LockedBy = holder
+		due[i].LockedUntil = &until
+		due[i].UpdatedAt = now
+		s.db.previews[due[i].ID] = due[i]
+	}
+	return due, nil
+}
+
+// Complete сохраняет загруженные метаданные превью
+func (s *LinkPreviewStore) Complete(ctx context.Context, preview *models.LinkPreview, holder string, at time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	p, err := s.db.claimedPreview(preview.ID, holder)
+	if err != nil {
+		return err
+	}
+	p.Status = models.LinkPreviewReady
+	p.Title = preview.Title
+	p.Description = preview.Description
+	p.ImageURL = preview.ImageURL
+	p.SiteName = preview.SiteName
+	p.LastError = ""
+	p.LockedBy = ""
+	p.LockedUntil = nil
+	p.FetchedAt = &at
+	p.UpdatedAt = at
+	s.db.previews[p.ID] = p
+
+	preview.Status = p.Status
+	preview.LockedBy = ""
+	preview.LockedUntil = nil
+	preview.FetchedAt = &at
+	return nil
+}
+
+// MarkFailed записывает неудачную попытку загрузки
+func (s *LinkPreviewStore) MarkFailed(ctx context.Context, id uint, holder, reason string, retryAt time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	p, err := s.db.claimedPreview(id, holder)
+	if err != nil {
+		return err
+	}
+	now := s.db.now()
+	p.Attempts++
+	p.LastError = reason
+	p.LockedBy = ""
+	p.LockedUntil = nil
+	p.UpdatedAt = now
+	if retryAt.IsZero() {
+		p.Status = models.LinkPreviewFailed
+		p.FetchedAt = &now
+	} else {
+		p.Status = models.LinkPreviewPending
+		p.LockedUntil = &retryAt
+	}
+	s.db.previews[id] = p
+	return nil
+}
+
+// claimedPreview возвращает превью, захваченное holder, или ErrPreviewClaimLost
+// Вызывается под db.mu
+func (db *DB) claimedPreview(id uint, holder string) (models.LinkPreview, error) {
+	p, ok := db.previews[id]
+	if !ok || p.Status != models.LinkPreviewFetching || p.LockedBy != holder {
+		return p, repository.ErrPreviewClaimLost
+	}
+	return p, nil
+}
+
+// Messages возвращает сообщения, получившие ссылку на превью не раньше since
+func (s *LinkPreviewStore) Messages(ctx context.Context, previewID uint, since time.Time, limit int) ([]models.Message, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	messages := []models.Message{}
+	for key, link := range s.db.links {
+		if key.preview != previewID || link.CreatedAt.Before(since) {
+			continue
+		}
+		if m, ok := s.db.messages[key.message]; ok {
+			messages = append(messages, models.Message{ID: m.ID, ChatID: m.ChatID})
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].ID > messages[j].ID
+	})
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
@@ -0,0 +1,454 @@
+// Package memory - потокобезопасная реализация хранилищ в памяти
+// Повторяет поведение GORM репозиториев (мягкое удаление, порядок, лимиты)
+// и используется в быстрых тестах без базы данных
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// DB - общее состояние хранилищ, аналог одной базы данных
+// Чаты и сообщения живут в одном DB, чтобы MessageStore мог проверять
+// существование чата так же, как внешний ключ в PostgreSQL
+type DB struct {
+	mu              sync.RWMutex
+	chats           map[uint]models.Chat
+	messages        map[uint]models.Message
+	idempotency     map[idempotencyID]models.IdempotencyKey
+	outbox          []models.OutboxEvent // по возрастанию ID
+	leases          map[string]models.OutboxLease
+	scheduled       map[uint]models.ScheduledMessage
+	pins            map[pinID]models.Pin
+	mentions        map[uint]models.MessageMention
+	previews        map[uint]models.LinkPreview
+	links           map[linkID]models.MessageLink
+	votes           map[voteID]models.PollVote
+	lastChatID      uint
+	lastMessageID   uint
+	lastOutboxID    uint
+	lastScheduledID uint
+	lastMentionID   uint
+	lastPreviewID   uint
+	now             func() time.Time
+}
+
+// New создает пустую базу в памяти
+func New() *DB {
+	return &DB{
+		chats:       make(map[uint]models.Chat),
+		messages:    make(map[uint]models.Message),
+		idempotency: make(map[idempotencyID]models.IdempotencyKey),
+		leases:      make(map[string]models.OutboxLease),
+		scheduled:   make(map[uint]models.ScheduledMessage),
+		pins:        make(map[pinID]models.Pin),
+		mentions:    make(map[uint]models.MessageMention),
+		previews:    make(map[uint]models.LinkPreview),
+		links:       make(map[linkID]models.MessageLink),
+		votes:       make(map[voteID]models.PollVote),
+		now:         time.Now,
+	}
+}
+
+// Chats возвращает хранилище чатов
+func (db *DB) Chats() *ChatStore {
+	return &ChatStore{db: db}
+}
+
+// Messages возвращает хранилище сообщений
+func (db *DB) Messages() *MessageStore {
+	return &MessageStore{db: db}
+}
+
+// Idempotency возвращает хранилище ключей идемпотентности
+func (db *DB) Idempotency() *IdempotencyStore {
+	return &IdempotencyStore{db: db}
+}
+
+// Outbox возвращает хранилище исходящих событий
+func (db *DB) Outbox() *OutboxStore {
+	return &OutboxStore{db: db}
+}
+
+// Scheduled возвращает хранилище запланированных сообщений
+func (db *DB) Scheduled() *ScheduledStore {
+	return &ScheduledStore{db: db}
+}
+
+// Pins возвращает хранилище закрепленных сообщений
+func (db *DB) Pins() *PinStore {
+	return &PinStore{db: db}
+}
+
+// Mentions возвращает хранилище уведомлений об упоминаниях
+func (db *DB) Mentions() *MentionStore {
+	return &MentionStore{db: db}
+}
+
+// LinkPreviews возвращает хранилище превью ссылок
+func (db *DB) LinkPreviews() *LinkPreviewStore {
+	return &LinkPreviewStore{db: db}
+}
+
+// Polls возвращает хранилище голосов в опросах
+func (db *DB) Polls() *PollStore {
+	return &PollStore{db: db}
+}
+
+// Проверка на этапе компиляции, что хранилища реализуют интерфейсы
+var (
+	_ repository.ChatStore        = (*ChatStore)(nil)
+	_ repository.MessageStore     = (*MessageStore)(nil)
+	_ repository.IdempotencyStore = (*IdempotencyStore)(nil)
+	_ repository.OutboxStore      = (*OutboxStore)(nil)
+	_ repository.ScheduledStore   = (*ScheduledStore)(nil)
+	_ repository.PinStore         = (*PinStore)(nil)
+	_ repository.MentionStore     = (*MentionStore)(nil)
+	_ repository.LinkPreviewStore = (*LinkPreviewStore)(nil)
+	_ repository.PollStore        = (*PollStore)(nil)
+)
+
+// ChatStore - хранилище чатов в памяти
+type ChatStore struct {
+	db *DB
+}
+
+// Create сохраняет новый чат
+func (s *ChatStore) Create(ctx context.Context, chat *models.Chat) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.lastChatID++
+	chat.ID = s.db.lastChatID
+	if chat.CreatedAt.IsZero() {
+		chat.CreatedAt = s.db.now()
+	}
+
+	stored := *chat
+	stored.Messages = nil // сообщения хранятся отдельно, как в таблице messages
+	s.db.chats[chat.ID] = stored
+	return nil
+}
+
+// GetByID находит чат по ID, мягко удаленные чаты не возвращаются
+func (s *ChatStore) GetByID(ctx context.Context, id uint) (*models.Chat, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	chat, ok := s.db.chats[id]
+	if !ok || chat.DeletedAt.Valid {
+		return nil, repository.ErrNotFound
+	}
+	return &chat, nil
+}
+
+// List возвращает страницу чатов в порядке ID
+func (s *ChatStore) List(ctx context.Context, afterID uint, limit int) ([]models.Chat, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	chats := make([]models.Chat, 0, limit)
+	for _, chat := range s.db.chats {
+		if chat.ID > afterID && !chat.DeletedAt.Valid {
+			chats = append(chats, chat)
+		}
+	}
+	sort.Slice(chats, func(i, j int) bool { return chats[i].ID < chats[j].ID })
+	if len(chats) > limit {
+		chats = chats[:limit]
+	}
+	return chats, nil
+}
+
+// Update сохраняет изменяемые поля чата
+func (s *ChatStore) Update(ctx context.Context, chat *models.Chat) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	stored, ok := s.db.chats[chat.ID]
+	if !ok || stored.DeletedAt.Valid {
+		return repository.ErrNotFound
+	}
+	stored.Title = chat.Title
+	stored.SlowModeSeconds = chat.SlowModeSeconds
+	stored.RetentionDays = chat.RetentionDays
+	s.db.chats[chat.ID] = stored
+	return nil
+}
+
+// Delete мягко удаляет чат
+func (s *ChatStore) Delete(ctx context.Context, id uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	chat, ok := s.db.chats[id]
+	if !ok || chat.DeletedAt.Valid {
+		return nil
+	}
+	chat.DeletedAt = gorm.DeletedAt{Time: s.db.now(), Valid: true}
+	s.db.chats[id] = chat
+	return s.db.appendOutbox(models.OutboxChatDeleted, id, nil, chat.DeletedAt.Time)
+}
+
+// MessageStore - хранилище сообщений в памяти
+type MessageStore struct {
+	db *DB
+}
+
+// Create сохраняет новое сообщение
+func (s *MessageStore) Create(ctx context.Context, message *models.Message) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	// Аналог внешнего ключа messages.chat_id → chats.id
+	// Как и в PostgreSQL, мягко удаленный чат строку не теряет
+	if _, ok := s.db.chats[message.ChatID]; !ok {
+		return fmt.Errorf("чат %d не существует: нарушение внешнего ключа", message.ChatID)
+	}
+	if message.Scheduled != nil {
+		if err := s.db.checkClaim(message.Scheduled); err != nil {
+			return err
+		}
+	}
+
+	s.db.fillMessage(message, s.db.now())
+	// Сообщение и событие пишутся под одной блокировкой - аналог транзакции
+	if err := s.db.appendOutbox(models.OutboxMessageCreated, message.ChatID, message, message.CreatedAt); err != nil {
+		return err
+	}
+	stored := *message
+	stored.Notify = nil // не хранятся, как и в таблице messages
+	stored.Previews = nil
+	if message.Poll != nil {
+		// Опрос хранится копией, как JSON в колонке poll: итоги у вызывающего не меняют его
+		poll := *message.Poll
+		poll.Options = slices.Clone(poll.Options)
+		stored.Poll = &poll
+	}
+	if message.ForwardedFrom != nil {
+		forward := *message.ForwardedFrom
+		stored.ForwardedFrom = &forward
+	}
+	s.db.messages[message.ID] = stored
+	if message.Scheduled != nil {
+		s.db.markScheduledSent(message.Scheduled, message.ID)
+	}
+	s.db.createMentions(message)
+	return nil
+}
+
+// fillMessage заполняет ID нового сообщения и поля, которые в БД задают значения
+// по умолчанию колонок (created_at, format), вызывается под db.mu
+func (db *DB) fillMessage(message *models.Message, now time.Time) {
+	db.lastMessageID++
+	message.ID = db.lastMessageID
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = now
+	}
+	if message.Format == "" {
+		message.Format = models.MessageFormatPlain
+	}
+}
+
+// CreateBatch сохраняет пачку сообщений без событий outbox
+// Как и INSERT в БД, при ошибке не сохраняется ни одно сообщение
+func (s *MessageStore) CreateBatch(ctx context.Context, messages []models.Message) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for _, m := range messages {
+		if _, ok := s.db.chats[m.ChatID]; !ok {
+			return fmt.Errorf("чат %d не существует: нарушение внешнего ключа", m.ChatID)
+		}
+	}
+	for i := range messages {
+		s.db.fillMessage(&messages[i], s.db.now())
+		s.db.messages[messages[i].ID] = messages[i]
+	}
+	return nil
+}
+
+// GetLastMessagesByChatID возвращает последние сообщения чата, новые первые
+func (s *MessageStore) GetLastMessagesByChatID(ctx context.Context, chatID uint, limit int) ([]models.Message, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	// Пустой, но не nil слайс - как у GORM Find (в JSON будет [], а не null)
+	messages := []models.Message{}
+	now := s.db.now()
+	for _, m := range s.db.messages {
+		if m.ChatID == chatID && !m.Expired(now) {
+			messages = append(messages, m)
+		}
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		return newerFirst(messages[i], messages[j])
+	})
+
+	// Как и LIMIT в GORM: отрицательный limit означает "без ограничения"
+	if limit >= 0 && len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// ListRange возвращает пачку сообщений чата в хронологическом порядке
+func (s *MessageStore) ListRange(ctx context.Context, chatID uint, r repository.MessageRange, limit int) ([]models.Message, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	cursor := models.Message{ID: r.AfterID, CreatedAt: r.AfterCreatedAt}
+	messages := []models.Message{}
+	now := s.db.now()
+	for _, m := range s.db.messages {
+		switch {
+		case m.ChatID != chatID:
+		case m.Expired(now):
+		case !r.From.IsZero() && m.CreatedAt.Before(r.From):
+		case !r.To.IsZero() && !m.CreatedAt.Before(r.To):
+		case r.AfterID != 0 && !newerFirst(m, cursor):
+		default:
+			messages = append(messages, m)
+		}
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		return newerFirst(messages[j], messages[i])
+	})
+	if limit >= 0 && len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// DeleteBefore удаляет не больше limit самых старых сообщений чата до before
+func (s *MessageStore) DeleteBefore(ctx context.Context, chatID uint, before time.Time, limit int) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var expired []models.Message
+	for _, m := range s.db.messages {
+		if m.ChatID == chatID && m.CreatedAt.Before(before) {
+			expired = append(expired, m)
+		}
+	}
+	// Как ORDER BY created_at, id в подзапросе GORM: сначала самые старые
+	sort.Slice(expired, func(i, j int) bool {
+		return newerFirst(expired[j], expired[i])
+	})
+	if limit >= 0 && len(expired) > limit {
+		expired = expired[:limit]
+	}
+	for _, m := range expired {
+		s.db.deleteMessage(m.ID)
+	}
+	return int64(len(expired)), nil
+}
+
+// CountBefore возвращает количество сообщений чата до before
+func (s *MessageStore) CountBefore(ctx context.Context, chatID uint, before time.Time) (int64, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var count int64
+	for _, m := range s.db.messages {
+		if m.ChatID == chatID && m.CreatedAt.Before(before) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// DeleteExpired удаляет пачку истекших сообщений и пишет события message.deleted
+func (s *MessageStore) DeleteExpired(ctx context.Context, now time.Time, limit int) ([]models.Message, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var expired []models.Message
+	for _, m := range s.db.messages {
+		if m.Expired(now) {
+			expired = append(expired, models.Message{ID: m.ID, ChatID: m.ChatID, ExpiresAt: m.ExpiresAt})
+		}
+	}
+	// Как ORDER BY expires_at, id в подзапросе GORM
+	sort.Slice(expired, func(i, j int) bool {
+		if !expired[i].ExpiresAt.Equal(*expired[j].ExpiresAt) {
+			return expired[i].ExpiresAt.Before(*expired[j].ExpiresAt)
+		}
+		return expired[i].ID < expired[j].ID
+	})
+	if limit >= 0 && len(expired) > limit {
+		expired = expired[:limit]
+	}
+	for i := range expired {
+		if err := s.db.appendOutbox(models.OutboxMessageDeleted, expired[i].ChatID, &expired[i], *expired[i].ExpiresAt); err != nil {
+			return nil, err
+		}
+		s.db.deleteMessage(expired[i].ID)
+		s.db.deleteCreatedEvent(expired[i].ID)
+	}
+	return expired, nil
+}
+
+// newerFirst - порядок "новые первые": по created_at, при равенстве по id
+func newerFirst(a, b models.Message) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.ID > b.ID
+}
+
+// GetByID возвращает сообщение чата по ID
+func (s *MessageStore) GetByID(ctx context.Context, chatID, id uint) (*models.Message, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	m, ok := s.db.messages[id]
+	if !ok || m.ChatID != chatID || m.Expired(s.db.now()) {
+		return nil, repository.ErrNotFound
+	}
+	return &m, nil
+}
+
+// ExistingIDs возвращает ID существующих сообщений из ids в неудаленных чатах
+func (s *MessageStore) ExistingIDs(ctx context.Context, ids []uint) ([]uint, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	existing := []uint{}
+	now := s.db.now()
+	for _, id := range ids {
+		m, ok := s.db.messages[id]
+		if !ok || m.Expired(now) || s.db.chats[m.ChatID].DeletedAt.Valid || slices.Contains(existing, id) {
+			continue
+		}
+		existing = append(existing, id)
+	}
+	return existing, nil
+}
+
+// Participants возвращает авторов сообщений чата без повторов
+func (s *MessageStore) Participants(ctx context.Context, chatID uint) ([]string, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	authors := []string{}
+	for _, m := range s.db.messages {
+		if m.ChatID == chatID && m.AuthorID != "" && !seen[m.AuthorID] {
+			seen[m.AuthorID] = true
+			authors = append(authors, m.AuthorID)
+		}
+	}
+	return authors, nil
+}
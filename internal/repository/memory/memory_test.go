@@ -0,0 +1,15 @@
+package memory
+
+import (
+	"testing"
+
+	"go-chat-app/internal/repository/repotest"
+)
+
+// TestContract прогоняет общий набор тестов хранилищ на реализации в памяти
+func TestContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Stores {
+		db := New()
+		return repotest.Stores{Chats: db.Chats(), Messages: db.Messages(), Idempotency: db.Idempotency(), Outbox: db.Outbox(), Scheduled: db.Scheduled(), Pins: db.Pins(), Mentions: db.Mentions(), Previews: db.LinkPreviews(), Polls: db.Polls()}
+	})
+}
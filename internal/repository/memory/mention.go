@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"go-chat-app/internal/models"
+)
+
+// MentionStore - уведомления об упоминаниях в памяти
+type MentionStore struct {
+	db *DB
+}
+
+// ListByUser возвращает уведомления пользователя, новые первыми
+func (s *MentionStore) ListByUser(ctx context.Context, userID string, unreadOnly bool, beforeID uint, limit int) ([]models.MessageMention, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	mentions := []models.MessageMention{}
+	for _, mention := range s.db.visibleMentions(userID, unreadOnly) {
+		if beforeID > 0 && mention.ID >= beforeID {
+			continue
+		}
+		message := s.db.messages[mention.MessageID]
+		mention.Message = &message
+		mentions = append(mentions, mention)
+	}
+	sort.Slice(mentions, func(i, j int) bool {
+		return mentions[i].ID > mentions[j].ID
+	})
+	if limit >= 0 && len(mentions) > limit {
+		mentions = mentions[:limit]
+	}
+	return mentions, nil
+}
+
+// CountUnread возвращает количество непрочитанных уведомлений пользователя
+func (s *MentionStore) CountUnread(ctx context.Context, userID string) (int64, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return int64(len(s.db.visibleMentions(userID, true))), nil
+}
+
+// MarkRead отмечает уведомления пользователя прочитанными
+func (s *MentionStore) MarkRead(ctx context.Context, userID string, ids []uint, at time.Time) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var marked int64
+	for id, mention := range s.db.mentions {
+		if mention.UserID != userID || mention.ReadAt != nil || (len(ids) > 0 && !slices.Contains(ids, id)) {
+			continue
+		}
+		readAt := at
+		mention.ReadAt = &readAt
+		s.db.mentions[id] = mention
+		marked++
+	}
+	return marked, nil
+}
+
+// visibleMentions возвращает уведомления пользователя о сообщениях, которые он может прочитать:
+// чат не удален, а сообщение не истекло. Вызывается под db.mu
+func (db *DB) visibleMentions(userID string, unreadOnly bool) []models.MessageMention {
+	var mentions []models.MessageMention
+	now := db.now()
+	for _, mention := range db.mentions {
+		if mention.UserID != userID || (unreadOnly && mention.ReadAt != nil) {
+			continue
+		}
+		if chat, ok := db.chats[mention.ChatID]; !ok || chat.DeletedAt.Valid {
+			continue
+		}
+		if message, ok := db.messages[mention.MessageID]; !ok || message.Expired(now) {
+			continue
+		}
+		mentions = append(mentions, mention)
+	}
+	return mentions
+}
+
+// createMentions сохраняет уведомления об упоминании для message.Notify, вызывается под db.mu
+func (db *DB) createMentions(message *models.Message) {
+	for _, userID := range message.Notify {
+		db.lastMentionID++
+		db.mentions[db.lastMentionID] = models.MessageMention{
+			ID:        db.lastMentionID,
+			UserID:    userID,
+			ChatID:    message.ChatID,
+			MessageID: message.ID,
+			AuthorID:  message.AuthorID,
+			CreatedAt: message.CreatedAt,
+		}
+	}
+}
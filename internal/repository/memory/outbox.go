@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+)
+
+// appendOutbox добавляет событие в outbox, вызывается под db.mu
+func (db *DB) appendOutbox(eventType string, chatID uint, message *models.Message, at time.Time) error {
+	event, err := repository.NewOutboxEvent(eventType, chatID, message, at)
+	if err != nil {
+		return err
+	}
+	db.lastOutboxID++
+	event.ID = db.lastOutboxID
+	event.CreatedAt = db.now()
+	db.outbox = append(db.outbox, *event)
+	return nil
+}
+
+// deleteCreatedEvent удаляет событие message.created сообщения messageID
+// (вызывается под db.mu, см. MessageStore.DeleteExpired)
+func (db *DB) deleteCreatedEvent(messageID uint) {
+	db.outbox = slices.DeleteFunc(db.outbox, func(e models.OutboxEvent) bool {
+		return e.Type == models.OutboxMessageCreated && e.MessageID != nil && *e.MessageID == messageID
+	})
+}
+
+// OutboxStore - хранилище исходящих событий в памяти
+type OutboxStore struct {
+	db *DB
+}
+
+// AcquireLease захватывает или продлевает аренду
+func (s *OutboxStore) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	now := s.db.now()
+	lease, ok := s.db.leases[name]
+	if ok && lease.Holder != holder && lease.ExpiresAt.After(now) {
+		return false, nil
+	}
+	s.db.leases[name] = models.OutboxLease{Name: name, Holder: holder, ExpiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+// Pending возвращает недоставленные события в порядке ID без чатов, событие которых ждет повтора
+func (s *OutboxStore) Pending(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	now := s.db.now()
+	waiting := make(map[uint]bool)
+	for _, e := range s.db.outbox {
+		if e.DeliveredAt == nil && e.FailedAt == nil && e.NextAttemptAt != nil && e.NextAttemptAt.After(now) {
+			waiting[e.ChatID] = true
+		}
+	}
+	events := []models.OutboxEvent{}
+	for _, e := range s.db.outbox {
+		if limit >= 0 && len(events) >= limit {
+			break
+		}
+		if e.DeliveredAt == nil && e.FailedAt == nil && !waiting[e.ChatID] {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// MarkDelivered отмечает события доставленными
+func (s *OutboxStore) MarkDelivered(ctx context.Context, ids []uint, at time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	delivered := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		delivered[id] = true
+	}
+	for i := range s.db.outbox {
+		if delivered[s.db.outbox[i].ID] {
+			s.db.outbox[i].DeliveredAt = &at
+		}
+	}
+	return nil
+}
+
+// MarkFailed увеличивает счетчик неудачных попыток; нулевой retryAt - событие брошено
+func (s *OutboxStore) MarkFailed(ctx context.Context, id uint, reason string, retryAt time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	for i := range s.db.outbox {
+		if e := &s.db.outbox[i]; e.ID == id {
+			e.Attempts++
+			e.LastError = reason
+			if retryAt.IsZero() {
+				now := s.db.now()
+				e.FailedAt, e.NextAttemptAt = &now, nil
+			} else {
+				e.NextAttemptAt = &retryAt
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteDelivered удаляет события, доставленные или брошенные раньше before
+func (s *OutboxStore) DeleteDelivered(ctx context.Context, before time.Time) (int64, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	kept := s.db.outbox[:0]
+	var deleted int64
+	for _, e := range s.db.outbox {
+		if (e.DeliveredAt != nil && e.DeliveredAt.Before(before)) || (e.FailedAt != nil && e.FailedAt.Before(before)) {
+			deleted++
+			continue
+		}
+		kept = append(kept, e)
+	}
+	s.db.outbox = kept
+	return deleted, nil
+}
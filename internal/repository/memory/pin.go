@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+)
+
+// pinID - первичный ключ закрепления (chat_id, message_id)
+type pinID struct {
+	chatID    uint
+	messageID uint
+}
+
+// PinStore - хранилище закрепленных сообщений в памяти
+type PinStore struct {
+	db *DB
+}
+
+// Pin закрепляет сообщение и сохраняет служебное сообщение о закреплении
+func (s *PinStore) Pin(ctx context.Context, pin *models.Pin, limit int, notice *models.Message) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if chat, ok := s.db.chats[pin.ChatID]; !ok || chat.DeletedAt.Valid {
+		return repository.ErrNotFound
+	}
+	now := s.db.now()
+	message, ok := s.db.messages[pin.MessageID]
+	if !ok || message.ChatID != pin.ChatID || message.Expired(now) {
+		return repository.ErrNotFound
+	}
+
+	// Закрепления истекших сообщений не считаются, как и в List
+	count := 0
+	for id := range s.db.pins {
+		if m, ok := s.db.messages[id.messageID]; id.chatID == pin.ChatID && ok && !m.Expired(now) {
+			count++
+		}
+	}
+	if _, ok := s.db.pins[pinID{pin.ChatID, pin.MessageID}]; ok {
+		return repository.ErrAlreadyPinned
+	}
+	if count >= limit {
+		return repository.ErrPinLimit
+	}
+
+	if pin.PinnedAt.IsZero() {
+		pin.PinnedAt = now
+	}
+	// Закрепление, сообщение и событие пишутся под одной блокировкой - аналог транзакции
+	if notice != nil {
+		s.db.fillMessage(notice, now)
+		if err := s.db.appendOutbox(models.OutboxMessageCreated, notice.ChatID, notice, notice.CreatedAt); err != nil {
+			return err
+		}
+		s.db.messages[notice.ID] = *notice
+	}
+	stored := *pin
+	stored.Message = nil // сообщение хранится отдельно, как в таблице messages
+	s.db.pins[pinID{pin.ChatID, pin.MessageID}] = stored
+	pin.Message = &message
+	return nil
+}
+
+// Unpin открепляет сообщение
+func (s *PinStore) Unpin(ctx context.Context, chatID, messageID uint) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	id := pinID{chatID, messageID}
+	if _, ok := s.db.pins[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(s.db.pins, id)
+	return nil
+}
+
+// List возвращает закрепленные сообщения чата, последние закрепленные первыми
+func (s *PinStore) List(ctx context.Context, chatID uint) ([]models.Pin, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	pins := []models.Pin{}
+	now := s.db.now()
+	for id, pin := range s.db.pins {
+		if id.chatID != chatID {
+			continue
+		}
+		message, ok := s.db.messages[id.messageID]
+		if !ok || message.Expired(now) {
+			continue
+		}
+		pin.Message = &message
+		pins = append(pins, pin)
+	}
+	sort.Slice(pins, func(i, j int) bool {
+		if !pins[i].PinnedAt.Equal(pins[j].PinnedAt) {
+			return pins[i].PinnedAt.After(pins[j].PinnedAt)
+		}
+		return pins[i].MessageID > pins[j].MessageID
+	})
+	return pins, nil
+}
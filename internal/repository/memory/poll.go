@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+)
+
+// voteID - первичный ключ голоса (message_id, user_id, option_index)
+type voteID struct {
+	message uint
+	user    string
+	option  int
+}
+
+// PollStore - голоса в опросах в памяти
+type PollStore struct {
+	db *DB
+}
+
+// Vote заменяет голоса пользователя в опросе
+func (s *PollStore) Vote(ctx context.Context, messageID uint, userID string, options []int, at time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	// Аналог внешнего ключа poll_votes.message_id → messages.id
+	if _, ok := s.db.messages[messageID]; !ok {
+		return repository.ErrNotFound
+	}
+	s.db.retract(messageID, userID)
+	for _, option := range options {
+		s.db.votes[voteID{messageID, userID, option}] = models.PollVote{
+			MessageID: messageID,
+			UserID:    userID,
+			Option:    option,
+			CreatedAt: at,
+		}
+	}
+	return nil
+}
+
+// Retract удаляет голоса пользователя в опросе
+func (s *PollStore) Retract(ctx context.Context, messageID uint, userID string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.retract(messageID, userID)
+	return nil
+}
+
+// Votes возвращает голоса в опросах сообщений в порядке голосования
+func (s *PollStore) Votes(ctx context.Context, ids []uint) ([]models.PollVote, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	votes := []models.PollVote{}
+	for _, vote := range s.db.votes {
+		if slices.Contains(ids, vote.MessageID) {
+			votes = append(votes, vote)
+		}
+	}
+	sort.Slice(votes, func(i, j int) bool {
+		a, b := votes[i], votes[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		return a.Option < b.Option
+	})
+	return votes, nil
+}
+
+// retract удаляет голоса пользователя в опросе, вызывается под db.mu
+func (db *DB) retract(messageID uint, userID string) {
+	for key := range db.votes {
+		if key.message == messageID && key.user == userID {
+			delete(db.votes, key)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+)
+
+// ScheduledStore - хранилище запланированных сообщений в памяти
+type ScheduledStore struct {
+	db *DB
+}
+
+// Create сохраняет новое запланированное сообщение
+func (s *ScheduledStore) Create(ctx context.Context, message *models.ScheduledMessage) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	// Аналог внешнего ключа scheduled_messages.chat_id → chats.id
+	if _, ok := s.db.chats[message.ChatID]; !ok {
+		return fmt.Errorf("чат %d не существует: нарушение внешнего ключа", message.ChatID)
+	}
+	s.db.lastScheduledID++
+	message.ID = s.db.lastScheduledID
+	message.Status = models.ScheduledPending
+	message.CreatedAt = s.db.now()
+	message.UpdatedAt = message.CreatedAt
+	s.db.scheduled[message.ID] = *message
+	return nil
+}
+
+// GetByID находит запланированное сообщение по ID
+func (s *ScheduledStore) GetByID(ctx context.Context, id uint) (*models.ScheduledMessage, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	message, ok := s.db.scheduled[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &message, nil
+}
+
+// ListByChat возвращает запланированные сообщения чата в порядке отправки
+func (s *ScheduledStore) ListByChat(ctx context.Context, chatID uint, status string, limit int) ([]models.ScheduledMessage, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	messages := []models.ScheduledMessage{}
+	for _, m := range s.db.scheduled {
+		if m.ChatID == chatID && (status == "" || m.Status == status) {
+			messages = append(messages, m)
+		}
+	}
+	sortBySendAt(messages)
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// Update сохраняет текст и время отправки ожидающего сообщения
+func (s *ScheduledStore) Update(ctx context.Context, message *models.ScheduledMessage) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	stored, err := s.db.pendingScheduled(message.ID)
+	if err != nil {
+		if stored != nil {
+			*message = *stored
+		}
+		return err
+	}
+	stored.Text = message.Text
+	stored.SendAt = message.SendAt
+	stored.LockedUntil = nil
+	stored.UpdatedAt = s.db.now()
+	s.db.scheduled[stored.ID] = *stored
+	*message = *stored
+	return nil
+}
+
+// Cancel отменяет ожидающее сообщение
+func (s *ScheduledStore) Cancel(ctx context.Context, id uint) (*models.ScheduledMessage, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	stored, err := s.db.pendingScheduled(id)
+	if err != nil {
+		return nil, err
+	}
+	stored.Status = models.ScheduledCanceled
+	stored.LockedUntil = nil
+	stored.UpdatedAt = s.db.now()
+	s.db.scheduled[id] = *stored
+	return stored, nil
+}
+
+// pendingScheduled возвращает сообщение и ErrNotPending, если оно уже не ожидает отправки
+// Вызывается под db.mu
+func (db *DB) pendingScheduled(id uint) (*models.ScheduledMessage, error) {
+	stored, ok := db.scheduled[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	if stored.Status != models.ScheduledPending {
+		return &stored, repository.ErrNotPending
+	}
+	return &stored, nil
+}
+
+// Claim захватывает пачку сообщений, которые пора отправить
+// Захват выполняется под одной блокировкой - аналог UPDATE ... FOR UPDATE SKIP LOCKED
+func (s *ScheduledStore) Claim(ctx context.Context, holder string, now time.Time, lease time.Duration, limit int) ([]models.ScheduledMessage, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	due := []models.ScheduledMessage{}
+	for _, m := range s.db.scheduled {
+		lockExpired := m.LockedUntil == nil || !m.LockedUntil.After(now)
+		if (m.Status == models.ScheduledPending && !m.SendAt.After(now) && lockExpired) ||
+			(m.Status == models.ScheduledSending && lockExpired) {
+			due = append(due, m)
+		}
+	}
+	sortBySendAt(due)
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	until := now.Add(lease)
+	for i := range due {
+		due[i].Status = models.ScheduledSending
+		due[i].LockedBy = holder
+		due[i].LockedUntil = &until
+		due[i].UpdatedAt = now
+		s.db.scheduled[due[i].ID] = due[i]
+	}
+	return due, nil
+}
+
+// MarkFailed записывает неудачную попытку отправки
+func (s *ScheduledStore) MarkFailed(ctx context.Context, id uint, holder, reason string, retryAt time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	m, ok := s.db.scheduled[id]
+	if !ok || m.Status != models.ScheduledSending || m.LockedBy != holder {
+		return repository.ErrClaimLost
+	}
+	m.Attempts++
+	m.LastError = reason
+	m.LockedBy = ""
+	m.LockedUntil = nil
+	m.UpdatedAt = s.db.now()
+	if retryAt.IsZero() {
+		m.Status = models.ScheduledFailed
+	} else {
+		m.Status = models.ScheduledPending
+		m.LockedUntil = &retryAt
+	}
+	s.db.scheduled[id] = m
+	return nil
+}
+
+// checkClaim проверяет, что захват запланированного сообщения все еще у обработчика
+// Вызывается под db.mu перед сохранением сообщения, которое его доставляет
+func (db *DB) checkClaim(claim *models.ScheduledClaim) error {
+	m, ok := db.scheduled[claim.ID]
+	if !ok || m.Status != models.ScheduledSending || m.LockedBy != claim.Holder {
+		return repository.ErrClaimLost
+	}
+	return nil
+}
+
+// markScheduledSent отмечает запланированное сообщение отправленным, вызывается под db.mu
+func (db *DB) markScheduledSent(claim *models.ScheduledClaim, messageID uint) {
+	m := db.scheduled[claim.ID]
+	m.Status = models.ScheduledSent
+	m.MessageID = &messageID
+	m.LockedBy = ""
+	m.LockedUntil = nil
+	m.UpdatedAt = db.now()
+	db.scheduled[claim.ID] = m
+}
+
+// deleteMessage удаляет сообщение, вызывается под db.mu
+// Аналог ON DELETE SET NULL: запланированное сообщение теряет ссылку на удаленное,
+// и ON DELETE CASCADE: закрепление, уведомления об упоминании, ссылки на превью и голоса в опросе удаляются вместе с ним
+func (db *DB) deleteMessage(id uint) {
+	if m, ok := db.messages[id]; ok {
+		delete(db.pins, pinID{m.ChatID, id})
+	}
+	for key := range db.links {
+		if key.message == id {
+			delete(db.links, key)
+		}
+	}
+	for key := range db.votes {
+		if key.message == id {
+			delete(db.votes, key)
+		}
+	}
+	for mid, mention := range db.mentions {
+		if mention.MessageID == id {
+			delete(db.mentions, mid)
+		}
+	}
+	delete(db.messages, id)
+	for sid, m := range db.scheduled {
+		if m.MessageID != nil && *m.MessageID == id {
+			m.MessageID = nil
+			db.scheduled[sid] = m
+		}
+	}
+}
+
+// sortBySendAt упорядочивает запланированные сообщения по send_at, при равенстве - по ID
+func sortBySendAt(messages []models.ScheduledMessage) {
+	sort.Slice(messages, func(i, j int) bool {
+		if !messages[i].SendAt.Equal(messages[j].SendAt) {
+			return messages[i].SendAt.Before(messages[j].SendAt)
+		}
+		return messages[i].ID < messages[j].ID
+	})
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-chat-app/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// MentionRepository отвечает за уведомления об упоминаниях
+// Создает их MessageRepository.Create в транзакции с сообщением (createMentions)
+type MentionRepository struct {
+	db *gorm.DB
+}
+
+// NewMentionRepository создает новый репозиторий уведомлений об упоминаниях
+func NewMentionRepository(db *gorm.DB) *MentionRepository {
+	return &MentionRepository{db: db}
+}
+
+// ListByUser возвращает уведомления пользователя, новые первыми
+func (r *MentionRepository) ListByUser(ctx context.Context, userID string, unreadOnly bool, beforeID uint, limit int) ([]models.MessageMention, error) {
+	ctx, span := tracer.Start(ctx, "MentionRepository.ListByUser")
+	defer span.End()
+
+	query := r.visible(ctx, userID, unreadOnly)
+	if beforeID > 0 {
+		query = query.Where("message_mentions.id < ?", beforeID)
+	}
+	mentions := make([]models.MessageMention, 0, limit)
+	err := query.Preload("Message").
+		Order("message_mentions.id DESC").
+		Limit(limit).
+		Find(&mentions).Error
+	if err != nil {
+		return nil, recordError(ctx, span, err)
+	}
+	return mentions, nil
+}
+
+// CountUnread возвращает количество непрочитанных уведомлений пользователя
+func (r *MentionRepository) CountUnread(ctx context.Context, userID string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "MentionRepository.CountUnread")
+	defer span.End()
+
+	var count int64
+	err := r.visible(ctx, userID, true).Count(&count).Error
+	return count, recordError(ctx, span, err)
+}
+
+// MarkRead отмечает уведомления пользователя прочитанными
+func (r *MentionRepository) MarkRead(ctx context.Context, userID string, ids []uint, at time.Time) (int64, error) {
+	ctx, span := tracer.Start(ctx, "MentionRepository.MarkRead")
+	defer span.End()
+
+	query := r.db.WithContext(ctx).Model(&models.MessageMention{}).
+		Where("user_id = ? AND read_at IS NULL", userID)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	res := query.Update("read_at", at.Local())
+	return res.RowsAffected, recordError(ctx, span, res.Error)
+}
+
+// visible - уведомления пользователя о сообщениях, которые он может прочитать:
+// чат не удален, а сообщение не истекло (даже если очистка его еще не удалила)
+func (r *MentionRepository) visible(ctx context.Context, userID string, unreadOnly bool) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&models.MessageMention{}).
+		Joins("JOIN chats ON chats.id = message_mentions.chat_id AND chats.deleted_at IS NULL").
+		Joins("JOIN messages ON messages.id = message_mentions.message_id").
+		Where("message_mentions.user_id = ?", userID).
+		Where("messages.expires_at IS NULL OR messages.expires_at > ?", time.Now().Local())
+	if unreadOnly {
+		query = query.Where("message_mentions.read_at IS NULL")
+	}
+	return query
+}
+
+// createMentions сохраняет уведомления об упоминании для message.Notify в транзакции tx
+// вместе с сообщением (message.ID уже заполнен)
+func createMentions(tx *gorm.DB, message *models.Message) error {
+	if len(message.Notify) == 0 {
+		return nil
+	}
+	mentions := make([]models.MessageMention, len(message.Notify))
+	for i, userID := range message.Notify {
+		mentions[i] = models.MessageMention{
+			UserID:    userID,
+			ChatID:    message.ChatID,
+			MessageID: message.ID,
+			AuthorID:  message.AuthorID,
+			CreatedAt: message.CreatedAt,
+		}
+	}
+	return tx.Create(&mentions).Error
+}
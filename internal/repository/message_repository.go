@@ -1,36 +1,252 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"go-chat-app/internal/models"
 
+	"go.opentelemetry.io/otel/attribute"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // MessageRepository отвечает за работу с сообщениями в базе данных
 type MessageRepository struct {
-	db *gorm.DB
+	db     *gorm.DB
+	outbox outboxWriter
 }
 
 // NewMessageRepository создает новый репозиторий для сообщений
-func NewMessageRepository(db *gorm.DB) *MessageRepository {
-	return &MessageRepository{db: db}
+func NewMessageRepository(db *gorm.DB, opts ...Option) *MessageRepository {
+	return &MessageRepository{db: db, outbox: newOutboxWriter(opts)}
 }
 
 // Create сохраняет новое сообщение в базу данных
-func (r *MessageRepository) Create(message *models.Message) error {
-	return r.db.Create(message).Error
+// Событие message.created пишется в outbox в той же транзакции,
+// там же запланированное сообщение (message.Scheduled) отмечается отправленным
+func (r *MessageRepository) Create(ctx context.Context, message *models.Message) error {
+	ctx, span := tracer.Start(ctx, "MessageRepository.Create")
+	defer span.End()
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+		if message.Scheduled != nil {
+			if err := markScheduledSent(tx, message.Scheduled, message.ID); err != nil {
+				return err
+			}
+		}
+		if err := createMentions(tx, message); err != nil {
+			return err
+		}
+		event, err := NewOutboxEvent(models.OutboxMessageCreated, message.ChatID, message, message.CreatedAt)
+		if err != nil {
+			return err
+		}
+		return r.outbox.write(tx, event)
+	})
+	return recordError(ctx, span, err)
+}
+
+// CreateBatch сохраняет пачку сообщений одним INSERT (без событий outbox)
+func (r *MessageRepository) CreateBatch(ctx context.Context, messages []models.Message) error {
+	ctx, span := tracer.Start(ctx, "MessageRepository.CreateBatch")
+	defer span.End()
+	span.SetAttributes(attribute.Int("messages.count", len(messages)))
+
+	if len(messages) == 0 {
+		return nil
+	}
+	// Один INSERT с несколькими VALUES атомарен и без явной транзакции
+	return recordError(ctx, span, r.db.WithContext(ctx).Create(&messages).Error)
 }
 
 // GetLastMessagesByChatID возвращает последние сообщения чата
 // limit - сколько сообщений вернуть, отсортированные по created_at (новые первые)
-func (r *MessageRepository) GetLastMessagesByChatID(chatID uint, limit int) ([]models.Message, error) {
+func (r *MessageRepository) GetLastMessagesByChatID(ctx context.Context, chatID uint, limit int) ([]models.Message, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetLastMessagesByChatID")
+	defer span.End()
+	span.SetAttributes(attribute.Int("chat.limit", limit))
+
 	var messages []models.Message
 
 	// Where - фильтр по chat_id
+	// Order - новые первые, id различает сообщения с одинаковым временем
 	// Limit - ограничение количества
-	err := r.db.Where("chat_id = ?", chatID).
+	err := r.db.WithContext(ctx).Where("chat_id = ?", chatID).
+		Scopes(notExpired).
+		Order("created_at DESC, id DESC").
 		Limit(limit).
 		Find(&messages).Error
 
-	return messages, err
+	return messages, recordError(ctx, span, err)
+}
+
+// ListRange возвращает пачку сообщений чата в хронологическом порядке
+func (r *MessageRepository) ListRange(ctx context.Context, chatID uint, rng MessageRange, limit int) ([]models.Message, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.ListRange")
+	defer span.End()
+	span.SetAttributes(attribute.Int("chat.limit", limit))
+
+	// Границы из запроса переводятся в локальное время: так GORM записывает created_at
+	// (TIMESTAMP без часового пояса в PostgreSQL, строка со смещением в SQLite)
+	// Курсор передается как есть - это значение, прочитанное из той же колонки
+	query := r.db.WithContext(ctx).Where("chat_id = ?", chatID).Scopes(notExpired)
+	if !rng.From.IsZero() {
+		query = query.Where("created_at >= ?", rng.From.Local())
+	}
+	if !rng.To.IsZero() {
+		query = query.Where("created_at < ?", rng.To.Local())
+	}
+	if rng.AfterID != 0 {
+		// Ключ (created_at, id): OFFSET пришлось бы пересчитывать с начала для каждой пачки
+		query = query.Where("created_at > ? OR (created_at = ? AND id > ?)",
+			rng.AfterCreatedAt, rng.AfterCreatedAt, rng.AfterID)
+	}
+
+	messages := make([]models.Message, 0, limit)
+	err := query.Order("created_at, id").Limit(limit).Find(&messages).Error
+	return messages, recordError(ctx, span, err)
+}
+
+// DeleteBefore удаляет пачку самых старых сообщений чата, созданных раньше before
+func (r *MessageRepository) DeleteBefore(ctx context.Context, chatID uint, before time.Time, limit int) (int64, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.DeleteBefore")
+	defer span.End()
+	span.SetAttributes(attribute.Int("chat.limit", limit))
+
+	// DELETE не поддерживает LIMIT ни в PostgreSQL, ни в SQLite по умолчанию,
+	// поэтому пачка выбирается подзапросом по индексу (chat_id, created_at, id)
+	batch := r.db.Model(&models.Message{}).
+		Select("id").
+		Where("chat_id = ? AND created_at < ?", chatID, before.Local()).
+		Order("created_at, id").
+		Limit(limit)
+	res := r.db.WithContext(ctx).Where("id IN (?)", batch).Delete(&models.Message{})
+	if res.Error != nil {
+		return 0, recordError(ctx, span, res.Error)
+	}
+	span.SetAttributes(attribute.Int64("messages.deleted", res.RowsAffected))
+	return res.RowsAffected, nil
+}
+
+// CountBefore считает сообщения чата, созданные раньше before
+func (r *MessageRepository) CountBefore(ctx context.Context, chatID uint, before time.Time) (int64, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.CountBefore")
+	defer span.End()
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Message{}).
+		Where("chat_id = ? AND created_at < ?", chatID, before.Local()).
+		Count(&count).Error
+	return count, recordError(ctx, span, err)
+}
+
+// DeleteExpired удаляет пачку истекших исчезающих сообщений и пишет события message.deleted
+// События message.created этих сообщений удаляются из outbox: в них текст, который не должен сохраниться
+func (r *MessageRepository) DeleteExpired(ctx context.Context, now time.Time, limit int) ([]models.Message, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.DeleteExpired")
+	defer span.End()
+
+	var expired []models.Message
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		batch := tx.Model(&models.Message{}).
+			Select("id").
+			Where("expires_at <= ?", now.Local()).
+			Order("expires_at, id").
+			Limit(limit)
+		// RETURNING отдает только строки, удаленные этим запросом: если две очистки
+		// выбрали одну пачку, вторая дождется блокировки и не получит уже удаленные строки,
+		// поэтому событие о каждом сообщении пишется один раз
+		err := tx.Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}, {Name: "chat_id"}, {Name: "expires_at"}}}).
+			Where("id IN (?)", batch).
+			Delete(&expired).Error
+		if err != nil || len(expired) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(expired))
+		for i := range expired {
+			ids[i] = expired[i].ID
+		}
+		err = tx.Where("event_type = ? AND message_id IN ?", models.OutboxMessageCreated, ids).
+			Delete(&models.OutboxEvent{}).Error
+		if err != nil {
+			return err
+		}
+
+		events := make([]*models.OutboxEvent, 0, len(expired))
+		for i := range expired {
+			event, err := NewOutboxEvent(models.OutboxMessageDeleted, expired[i].ChatID, &expired[i], *expired[i].ExpiresAt)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return r.outbox.write(tx, events...)
+	})
+	if err != nil {
+		return nil, recordError(ctx, span, err)
+	}
+	span.SetAttributes(attribute.Int("messages.deleted", len(expired)))
+	return expired, nil
+}
+
+// GetByID возвращает сообщение чата по ID (истекшие не возвращаются)
+func (r *MessageRepository) GetByID(ctx context.Context, chatID, id uint) (*models.Message, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetByID")
+	defer span.End()
+
+	var message models.Message
+	err := r.db.WithContext(ctx).Where("id = ? AND chat_id = ?", id, chatID).Scopes(notExpired).Take(&message).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, recordError(ctx, span, err)
+	}
+	return &message, nil
+}
+
+// ExistingIDs возвращает ID существующих сообщений из ids в неудаленных чатах
+func (r *MessageRepository) ExistingIDs(ctx context.Context, ids []uint) ([]uint, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.ExistingIDs")
+	defer span.End()
+
+	existing := []uint{}
+	if len(ids) == 0 {
+		return existing, nil
+	}
+	// Чаты удаляются мягко: сообщения удаленного чата остаются в таблице, но недоступны
+	err := r.db.WithContext(ctx).Model(&models.Message{}).
+		Joins("JOIN chats ON chats.id = messages.chat_id AND chats.deleted_at IS NULL").
+		Where("messages.id IN ?", ids).
+		Scopes(notExpired).
+		Pluck("messages.id", &existing).Error
+	if err != nil {
+		return nil, recordError(ctx, span, err)
+	}
+	return existing, nil
+}
+
+// Participants возвращает авторов сообщений чата без повторов
+func (r *MessageRepository) Participants(ctx context.Context, chatID uint) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.Participants")
+	defer span.End()
+
+	var authors []string
+	err := r.db.WithContext(ctx).Model(&models.Message{}).
+		Where("chat_id = ? AND author_id <> ''", chatID).
+		Distinct().
+		Pluck("author_id", &authors).Error
+	return authors, recordError(ctx, span, err)
+}
+
+// notExpired скрывает истекшие исчезающие сообщения
+// Время сравнивается в местном, как GORM записывает created_at и expires_at
+func notExpired(db *gorm.DB) *gorm.DB {
+	return db.Where("expires_at IS NULL OR expires_at > ?", time.Now().Local())
 }
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go-chat-app/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// outboxPayload - содержимое события в outbox
+// Формат совпадает с событиями потока GET /chats/{id}/events
+type outboxPayload struct {
+	Type       string          `json:"type"`
+	ChatID     uint            `json:"chat_id"`
+	Message    *models.Message `json:"message,omitempty"`
+	MessageID  uint            `json:"message_id,omitempty"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+// NewOutboxEvent собирает событие для outbox
+// message заполняется для message.created и message.deleted, для chat.deleted - nil
+// Для message.deleted в событие попадает только ID: текст исчезнувшего сообщения не должен сохраниться
+func NewOutboxEvent(eventType string, chatID uint, message *models.Message, at time.Time) (*models.OutboxEvent, error) {
+	p := outboxPayload{Type: eventType, ChatID: chatID, OccurredAt: at.UTC()}
+	if eventType == models.OutboxMessageDeleted && message != nil {
+		p.MessageID = message.ID
+	} else {
+		p.Message = message
+	}
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	event := &models.OutboxEvent{ChatID: chatID, Type: eventType, Payload: string(payload)}
+	if message != nil {
+		id := message.ID
+		event.MessageID = &id
+	}
+	return event, nil
+}
+
+// Option настраивает репозитории, которые пишут события outbox
+type Option func(*outboxWriter)
+
+// WithoutOutbox выключает запись событий outbox (OUTBOX_PUBLISHER=none): доставлять их
+// некому, а недоставленные события не удаляются и копились бы в таблице
+func WithoutOutbox() Option {
+	return func(w *outboxWriter) { w.disabled = true }
+}
+
+// outboxWriter пишет события outbox в транзакции изменения
+type outboxWriter struct {
+	disabled bool
+}
+
+// newOutboxWriter применяет опции репозитория
+func newOutboxWriter(opts []Option) outboxWriter {
+	var w outboxWriter
+	for _, opt := range opts {
+		opt(&w)
+	}
+	return w
+}
+
+// write сохраняет события в транзакции tx; при выключенном outbox ничего не делает
+func (w outboxWriter) write(tx *gorm.DB, events ...*models.OutboxEvent) error {
+	if w.disabled || len(events) == 0 {
+		return nil
+	}
+	return tx.Create(events).Error
+}
+
+// OutboxRepository отвечает за чтение и отметку событий outbox
+// События пишут сами MessageRepository и ChatRepository в своих транзакциях
+type OutboxRepository struct {
+	db *gorm.DB
+}
+
+// NewOutboxRepository создает новый репозиторий outbox
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// AcquireLease захватывает или продлевает аренду name для holder
+func (r *OutboxRepository) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	ctx, span := tracer.Start(ctx, "OutboxRepository.AcquireLease")
+	defer span.End()
+
+	now := time.Now()
+	db := r.db.WithContext(ctx)
+
+	// Первый запуск: строки аренды еще нет
+	err := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.OutboxLease{Name: name, Holder: holder, ExpiresAt: now.Add(ttl)}).Error
+	if err != nil {
+		return false, recordError(ctx, span, err)
+	}
+
+	// Продлеваем свою аренду или забираем истекшую - одним UPDATE,
+	// чтобы два инстанса не забрали ее одновременно
+	res := db.Model(&models.OutboxLease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, holder, now).
+		Updates(map[string]any{"holder": holder, "expires_at": now.Add(ttl)})
+	if res.Error != nil {
+		return false, recordError(ctx, span, res.Error)
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// Pending возвращает не больше limit недоставленных событий в порядке id
+// без чатов, событие которых ждет повтора
+func (r *OutboxRepository) Pending(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	ctx, span := tracer.Start(ctx, "OutboxRepository.Pending")
+	defer span.End()
+
+	db := r.db.WithContext(ctx)
+	// Ждать повтора может только первое недоставленное событие чата: следующие relay не
+	// публикует, пока оно не уйдет. Без этого фильтра чат со сбоями занимал бы весь пакет
+	waiting := db.Model(&models.OutboxEvent{}).
+		Select("chat_id").
+		Where("delivered_at IS NULL AND failed_at IS NULL AND next_attempt_at > ?", time.Now())
+
+	var events []models.OutboxEvent
+	err := db.
+		Where("delivered_at IS NULL AND failed_at IS NULL").
+		Where("chat_id NOT IN (?)", waiting).
+		Order("id").
+		Limit(limit).
+		Find(&events).Error
+	return events, recordError(ctx, span, err)
+}
+
+// MarkDelivered отмечает события доставленными
+func (r *OutboxRepository) MarkDelivered(ctx context.Context, ids []uint, at time.Time) error {
+	ctx, span := tracer.Start(ctx, "OutboxRepository.MarkDelivered")
+	defer span.End()
+
+	if len(ids) == 0 {
+		return nil
+	}
+	err := r.db.WithContext(ctx).Model(&models.OutboxEvent{}).
+		Where("id IN ?", ids).
+		Update("delivered_at", at).Error
+	return recordError(ctx, span, err)
+}
+
+// MarkFailed увеличивает счетчик попыток, сохраняет причину ошибки и время повтора
+// Нулевой retryAt - событие брошено (failed_at)
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id uint, reason string, retryAt time.Time) error {
+	ctx, span := tracer.Start(ctx, "OutboxRepository.MarkFailed")
+	defer span.End()
+
+	updates := map[string]any{
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": reason,
+	}
+	if retryAt.IsZero() {
+		updates["failed_at"] = time.Now()
+		updates["next_attempt_at"] = nil
+	} else {
+		updates["next_attempt_at"] = retryAt
+	}
+	err := r.db.WithContext(ctx).Model(&models.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(updates).Error
+	return recordError(ctx, span, err)
+}
+
+// DeleteDelivered удаляет события, доставленные или брошенные раньше before
+func (r *OutboxRepository) DeleteDelivered(ctx context.Context, before time.Time) (int64, error) {
+	ctx, span := tracer.Start(ctx, "OutboxRepository.DeleteDelivered")
+	defer span.End()
+
+	res := r.db.WithContext(ctx).
+		Where("(delivered_at IS NOT NULL AND delivered_at < ?) OR (failed_at IS NOT NULL AND failed_at < ?)", before, before).
+		Delete(&models.OutboxEvent{})
+	return res.RowsAffected, recordError(ctx, span, res.Error)
+}
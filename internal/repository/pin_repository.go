@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-chat-app/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PinRepository отвечает за хранение закрепленных сообщений
+type PinRepository struct {
+	db     *gorm.DB
+	outbox outboxWriter
+}
+
+// NewPinRepository создает новый репозиторий закрепленных сообщений
+func NewPinRepository(db *gorm.DB, opts ...Option) *PinRepository {
+	return &PinRepository{db: db, outbox: newOutboxWriter(opts)}
+}
+
+// Pin закрепляет сообщение и сохраняет служебное сообщение о закреплении в одной транзакции
+func (r *PinRepository) Pin(ctx context.Context, pin *models.Pin, limit int, notice *models.Message) error {
+	ctx, span := tracer.Start(ctx, "PinRepository.Pin")
+	defer span.End()
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Строка чата блокируется до конца транзакции: два одновременных закрепления
+		// не превысят limit. В SQLite запись и так идет под блокировкой всей базы
+		chat := tx.Model(&models.Chat{}).Select("id").Where("id = ?", pin.ChatID)
+		if tx.Dialector.Name() == "postgres" {
+			chat = chat.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		var chatIDs []uint
+		if err := chat.Find(&chatIDs).Error; err != nil {
+			return err
+		}
+		if len(chatIDs) == 0 {
+			return ErrNotFound
+		}
+
+		var message models.Message
+		err := tx.Where("id = ? AND chat_id = ?", pin.MessageID, pin.ChatID).Scopes(notExpired).Take(&message).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// Закрепления истекших сообщений не считаются, как и в List: очистка их еще не удалила,
+		// но в списке их уже нет, и они не должны занимать место в лимите
+		var pinned []uint
+		err = tx.Model(&models.Pin{}).
+			Joins("JOIN messages ON messages.id = pinned_messages.message_id").
+			Where("pinned_messages.chat_id = ?", pin.ChatID).
+			Where("messages.expires_at IS NULL OR messages.expires_at > ?", time.Now().Local()).
+			Pluck("pinned_messages.message_id", &pinned).Error
+		if err != nil {
+			return err
+		}
+		for _, id := range pinned {
+			if id == pin.MessageID {
+				return ErrAlreadyPinned
+			}
+		}
+		if len(pinned) >= limit {
+			return ErrPinLimit
+		}
+
+		if pin.PinnedAt.IsZero() {
+			pin.PinnedAt = time.Now()
+		}
+		if err := tx.Omit(clause.Associations).Create(pin).Error; err != nil {
+			return err
+		}
+		pin.Message = &message
+
+		if notice == nil {
+			return nil
+		}
+		if err := tx.Create(notice).Error; err != nil {
+			return err
+		}
+		event, err := NewOutboxEvent(models.OutboxMessageCreated, notice.ChatID, notice, notice.CreatedAt)
+		if err != nil {
+			return err
+		}
+		return r.outbox.write(tx, event)
+	})
+	return recordError(ctx, span, err)
+}
+
+// Unpin открепляет сообщение
+func (r *PinRepository) Unpin(ctx context.Context, chatID, messageID uint) error {
+	ctx, span := tracer.Start(ctx, "PinRepository.Unpin")
+	defer span.End()
+
+	res := r.db.WithContext(ctx).Where("chat_id = ? AND message_id = ?", chatID, messageID).Delete(&models.Pin{})
+	if res.Error != nil {
+		return recordError(ctx, span, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// List возвращает закрепленные сообщения чата, последние закрепленные первыми
+func (r *PinRepository) List(ctx context.Context, chatID uint) ([]models.Pin, error) {
+	ctx, span := tracer.Start(ctx, "PinRepository.List")
+	defer span.End()
+
+	// INNER JOIN отбрасывает закрепления истекших сообщений, которые очистка еще не удалила
+	var pins []models.Pin
+	err := r.db.WithContext(ctx).
+		Joins("JOIN messages ON messages.id = pinned_messages.message_id").
+		Where("pinned_messages.chat_id = ?", chatID).
+		Where("messages.expires_at IS NULL OR messages.expires_at > ?", time.Now().Local()).
+		Preload("Message").
+		Order("pinned_messages.pinned_at DESC, pinned_messages.message_id DESC").
+		Find(&pins).Error
+	if err != nil {
+		return nil, recordError(ctx, span, err)
+	}
+	return pins, nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-chat-app/internal/models"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PollRepository отвечает за голоса в опросах (таблица poll_votes)
+// Сам опрос хранится в сообщении и создается вместе с ним (MessageRepository.Create)
+type PollRepository struct {
+	db *gorm.DB
+}
+
+// NewPollRepository создает новый репозиторий голосов
+func NewPollRepository(db *gorm.DB) *PollRepository {
+	return &PollRepository{db: db}
+}
+
+// Vote заменяет голоса пользователя в опросе одной транзакцией
+// Повторное голосование не копит голоса: прежний выбор удаляется
+func (r *PollRepository) Vote(ctx context.Context, messageID uint, userID string, options []int, at time.Time) error {
+	ctx, span := tracer.Start(ctx, "PollRepository.Vote")
+	defer span.End()
+	span.SetAttributes(attribute.Int("message.id", int(messageID)))
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Строка сообщения блокируется до конца транзакции: одновременные голосования
+		// одного пользователя не смешивают выбор (в опросе с одним вариантом не окажется двух)
+		// В SQLite запись и так идет под блокировкой всей базы
+		message := tx.Model(&models.Message{}).Select("id").Where("id = ?", messageID)
+		if tx.Dialector.Name() == "postgres" {
+			message = message.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		var ids []uint
+		if err := message.Find(&ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return ErrNotFound
+		}
+		if err := tx.Where("message_id = ? AND user_id = ?", messageID, userID).Delete(&models.PollVote{}).Error; err != nil {
+			return err
+		}
+		if len(options) == 0 {
+			return nil
+		}
+		votes := make([]models.PollVote, len(options))
+		for i, option := range options {
+			votes[i] = models.PollVote{MessageID: messageID, UserID: userID, Option: option, CreatedAt: at.Local()}
+		}
+		return tx.Create(&votes).Error
+	})
+	return recordError(ctx, span, err)
+}
+
+// Retract удаляет голоса пользователя в опросе
+func (r *PollRepository) Retract(ctx context.Context, messageID uint, userID string) error {
+	ctx, span := tracer.Start(ctx, "PollRepository.Retract")
+	defer span.End()
+	span.SetAttributes(attribute.Int("message.id", int(messageID)))
+
+	err := r.db.WithContext(ctx).
+		Where("message_id = ? AND user_id = ?", messageID, userID).
+		Delete(&models.PollVote{}).Error
+	return recordError(ctx, span, err)
+}
+
+// Votes возвращает голоса в опросах сообщений в порядке голосования
+func (r *PollRepository) Votes(ctx context.Context, ids []uint) ([]models.PollVote, error) {
+	ctx, span := tracer.Start(ctx, "PollRepository.Votes")
+	defer span.End()
+	span.SetAttributes(attribute.Int("messages.count", len(ids)))
+
+	votes := []models.PollVote{}
+	if len(ids) == 0 {
+		return votes, nil
+	}
+	err := r.db.WithContext(ctx).
+		Where("message_id IN ?", ids).
+		Order("created_at, user_id, option_index").
+		Find(&votes).Error
+	return votes, recordError(ctx, span, err)
+}
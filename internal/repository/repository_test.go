@@ -0,0 +1,155 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-chat-app/internal/config"
+	"go-chat-app/internal/db/migrate"
+	"go-chat-app/internal/db/sqlite"
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+	"go-chat-app/internal/repository/repotest"
+
+	gormpostgres "gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestPostgresContract прогоняет общий набор тестов хранилищ на PostgreSQL
+// Нужна отдельная тестовая база, все данные в ней удаляются:
+//
+//	TEST_DATABASE_DSN="host=localhost user=chat_user password=chat_password dbname=chat_test sslmode=disable" go test ./internal/repository/
+func TestPostgresContract(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN не задан, тесты на PostgreSQL пропущены")
+	}
+
+	db, err := gorm.Open(gormpostgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Не удалось подключиться к тестовой БД: %v", err)
+	}
+	if err := migrate.Up(context.Background(), db); err != nil {
+		t.Fatalf("Не удалось применить миграции: %v", err)
+	}
+
+	repotest.Run(t, func(t *testing.T) repotest.Stores {
+		// Каждый подтест начинается с пустых таблиц (кроме таблицы версий goose)
+		var tables []string
+		db.Raw("SELECT tablename FROM pg_tables WHERE schemaname = 'public' AND tablename <> 'goose_db_version'").
+			Scan(&tables)
+		if err := db.Exec("TRUNCATE " + strings.Join(tables, ", ") + " RESTART IDENTITY CASCADE").Error; err != nil {
+			t.Fatalf("Не удалось очистить таблицы: %v", err)
+		}
+		return newStores(db)
+	})
+}
+
+// TestSQLiteContract прогоняет общий набор тестов хранилищ на SQLite
+// Каждый подтест получает новый файл базы во временной папке
+func TestSQLiteContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Stores {
+		return newStores(newSQLiteDB(t))
+	})
+}
+
+// TestSQLiteCascadeDelete проверяет, что ON DELETE CASCADE работает в SQLite
+// (внешние ключи в SQLite выключены, пока их не включит PRAGMA foreign_keys)
+func TestSQLiteCascadeDelete(t *testing.T) {
+	db := newSQLiteDB(t)
+	stores := newStores(db)
+	ctx := context.Background()
+
+	chat := &models.Chat{Title: "каскад"}
+	if err := stores.Chats.Create(ctx, chat); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.Messages.Create(ctx, &models.Message{ChatID: chat.ID, Text: "удалится вместе с чатом"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Физическое удаление строки чата (мягкое удаление строку не трогает)
+	if err := db.Unscoped().Delete(&models.Chat{}, chat.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var count int64
+	db.Model(&models.Message{}).Where("chat_id = ?", chat.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("Ожидалось каскадное удаление сообщений, осталось %d", count)
+	}
+}
+
+// TestWithoutOutbox проверяет, что с выключенным outbox репозитории не пишут события
+func TestWithoutOutbox(t *testing.T) {
+	db := newSQLiteDB(t)
+	chats := repository.NewChatRepository(db, repository.WithoutOutbox())
+	messages := repository.NewMessageRepository(db, repository.WithoutOutbox())
+	pins := repository.NewPinRepository(db, repository.WithoutOutbox())
+	ctx := context.Background()
+
+	chat := &models.Chat{Title: "без outbox"}
+	if err := chats.Create(ctx, chat); err != nil {
+		t.Fatal(err)
+	}
+	message := &models.Message{ChatID: chat.ID, Text: "привет"}
+	if err := messages.Create(ctx, message); err != nil {
+		t.Fatal(err)
+	}
+	notice := &models.Message{ChatID: chat.ID, Kind: models.MessageKindPin, Text: "закреплено"}
+	if err := pins.Pin(ctx, &models.Pin{ChatID: chat.ID, MessageID: message.ID}, 10, notice); err != nil {
+		t.Fatal(err)
+	}
+	if err := chats.Delete(ctx, chat.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := repository.NewOutboxRepository(db).Pending(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Ожидалось 0 событий outbox, получено %d", len(events))
+	}
+}
+
+// newStores создает GORM репозитории поверх подключения
+func newStores(db *gorm.DB) repotest.Stores {
+	return repotest.Stores{
+		Chats:       repository.NewChatRepository(db),
+		Messages:    repository.NewMessageRepository(db),
+		Idempotency: repository.NewIdempotencyRepository(db),
+		Outbox:      repository.NewOutboxRepository(db),
+		Scheduled:   repository.NewScheduledRepository(db),
+		Pins:        repository.NewPinRepository(db),
+		Mentions:    repository.NewMentionRepository(db),
+		Previews:    repository.NewLinkPreviewRepository(db),
+		Polls:       repository.NewPollRepository(db),
+	}
+}
+
+// newSQLiteDB создает пустую базу SQLite с примененными миграциями
+func newSQLiteDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	cfg := config.Default().DB
+	cfg.SQLitePath = filepath.Join(t.TempDir(), "chat.db")
+
+	db, err := sqlite.InitDB(cfg)
+	if err != nil {
+		t.Fatalf("Не удалось открыть SQLite: %v", err)
+	}
+	db.Logger = logger.Discard
+	if err := migrate.Up(context.Background(), db); err != nil {
+		t.Fatalf("Не удалось применить миграции: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
@@ -0,0 +1,1457 @@
+// Package repotest - общий набор контрактных тестов для реализаций хранилищ
+// Каждая реализация (GORM поверх PostgreSQL, память) обязана проходить эти тесты,
+// чтобы сервис вел себя одинаково независимо от хранилища
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+)
+
+// Stores - набор хранилищ, созданных поверх одной (пустой) базы
+type Stores struct {
+	Chats       repository.ChatStore
+	Messages    repository.MessageStore
+	Idempotency repository.IdempotencyStore
+	Outbox      repository.OutboxStore
+	Scheduled   repository.ScheduledStore
+	Pins        repository.PinStore
+	Mentions    repository.MentionStore
+	Previews    repository.LinkPreviewStore
+	Polls       repository.PollStore
+}
+
+// Factory создает новые хранилища с пустой базой для каждого подтеста
+type Factory func(t *testing.T) Stores
+
+// Run запускает все контрактные тесты
+func Run(t *testing.T, newStores Factory) {
+	t.Run("ChatStore", func(t *testing.T) { RunChatStoreTests(t, newStores) })
+	t.Run("MessageStore", func(t *testing.T) { RunMessageStoreTests(t, newStores) })
+	t.Run("IdempotencyStore", func(t *testing.T) { RunIdempotencyStoreTests(t, newStores) })
+	t.Run("OutboxStore", func(t *testing.T) { RunOutboxStoreTests(t, newStores) })
+	t.Run("ScheduledStore", func(t *testing.T) { RunScheduledStoreTests(t, newStores) })
+	t.Run("PinStore", func(t *testing.T) { RunPinStoreTests(t, newStores) })
+	t.Run("MentionStore", func(t *testing.T) { RunMentionStoreTests(t, newStores) })
+	t.Run("LinkPreviewStore", func(t *testing.T) { RunLinkPreviewStoreTests(t, newStores) })
+	t.Run("PollStore", func(t *testing.T) { RunPollStoreTests(t, newStores) })
+}
+
+// RunChatStoreTests проверяет контракт repository.ChatStore
+func RunChatStoreTests(t *testing.T, newStores Factory) {
+	ctx := context.Background()
+
+	t.Run("CreateAssignsIDAndCreatedAt", func(t *testing.T) {
+		s := newStores(t)
+		first := &models.Chat{Title: "первый"}
+		second := &models.Chat{Title: "второй"}
+		mustCreateChat(t, s, first)
+		mustCreateChat(t, s, second)
+
+		if first.ID == 0 || second.ID == 0 || first.ID == second.ID {
+			t.Errorf("Ожидались разные ненулевые ID, получены %d и %d", first.ID, second.ID)
+		}
+		if first.CreatedAt.IsZero() {
+			t.Error("CreatedAt не заполнен")
+		}
+
+		got, err := s.Chats.GetByID(ctx, first.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.Title != "первый" {
+			t.Errorf("Ожидался title %q, получен %q", "первый", got.Title)
+		}
+	})
+
+	t.Run("GetByIDMissing", func(t *testing.T) {
+		s := newStores(t)
+		_, err := s.Chats.GetByID(ctx, 424242)
+		if !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Ожидалась ErrNotFound, получено %v", err)
+		}
+	})
+
+	t.Run("DeleteIsSoft", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "удаляемый"}
+		mustCreateChat(t, s, chat)
+		mustCreateMessage(t, s, &models.Message{ChatID: chat.ID, Text: "останется в таблице"})
+
+		if err := s.Chats.Delete(ctx, chat.ID); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := s.Chats.GetByID(ctx, chat.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Удаленный чат не должен находиться, получено %v", err)
+		}
+
+		// Повторное удаление и удаление несуществующего чата - не ошибка
+		if err := s.Chats.Delete(ctx, chat.ID); err != nil {
+			t.Errorf("Повторный Delete: %v", err)
+		}
+		if err := s.Chats.Delete(ctx, 424242); err != nil {
+			t.Errorf("Delete несуществующего чата: %v", err)
+		}
+	})
+
+	t.Run("ListPagesByID", func(t *testing.T) {
+		s := newStores(t)
+		var ids []uint
+		for _, title := range []string{"a", "b", "c", "d"} {
+			chat := &models.Chat{Title: title}
+			mustCreateChat(t, s, chat)
+			ids = append(ids, chat.ID)
+		}
+		if err := s.Chats.Delete(ctx, ids[1]); err != nil {
+			t.Fatal(err)
+		}
+
+		page, err := s.Chats.List(ctx, 0, 2)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(page) != 2 || page[0].ID != ids[0] || page[1].ID != ids[2] {
+			t.Fatalf("Первая страница: ожидались чаты %d и %d без удаленного, получено %+v", ids[0], ids[2], page)
+		}
+		page, err = s.Chats.List(ctx, page[1].ID, 2)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(page) != 1 || page[0].ID != ids[3] || page[0].Title != "d" {
+			t.Errorf("Вторая страница: ожидался чат %d, получено %+v", ids[3], page)
+		}
+	})
+
+	t.Run("UpdateSettings", func(t *testing.T) {
+		s := newStores(t)
+		days := 30
+		chat := &models.Chat{Title: "настройки", SlowModeSeconds: 30}
+		mustCreateChat(t, s, chat)
+		if chat.RetentionDays != nil {
+			t.Errorf("Срок хранения нового чата - по умолчанию (nil), получено %d", *chat.RetentionDays)
+		}
+
+		// Нулевое значение тоже должно сохраняться (выключение медленного режима)
+		chat.Title = "новое название"
+		chat.SlowModeSeconds = 0
+		chat.RetentionDays = &days
+		if err := s.Chats.Update(ctx, chat); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		got, err := s.Chats.GetByID(ctx, chat.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.Title != "новое название" || got.SlowModeSeconds != 0 || got.RetentionDays == nil || *got.RetentionDays != 30 {
+			t.Errorf("Изменения не сохранены: %+v", got)
+		}
+
+		// nil возвращает срок хранения по умолчанию (NULL в БД)
+		chat.RetentionDays = nil
+		if err := s.Chats.Update(ctx, chat); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if got, _ := s.Chats.GetByID(ctx, chat.ID); got.RetentionDays != nil {
+			t.Errorf("Срок хранения не сброшен: %d", *got.RetentionDays)
+		}
+
+		if err := s.Chats.Delete(ctx, chat.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Chats.Update(ctx, chat); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Update удаленного чата: ожидалась ErrNotFound, получено %v", err)
+		}
+	})
+
+	t.Run("ConcurrentCreate", func(t *testing.T) {
+		s := newStores(t)
+		const n = 20
+		ids := make(chan uint, n)
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				chat := &models.Chat{Title: "параллельный"}
+				if err := s.Chats.Create(ctx, chat); err != nil {
+					t.Errorf("Create: %v", err)
+					return
+				}
+				ids <- chat.ID
+			}()
+		}
+		wg.Wait()
+		close(ids)
+
+		seen := map[uint]bool{}
+		for id := range ids {
+			if seen[id] {
+				t.Errorf("ID %d выдан дважды", id)
+			}
+			seen[id] = true
+		}
+	})
+}
+
+// RunMessageStoreTests проверяет контракт repository.MessageStore
+func RunMessageStoreTests(t *testing.T, newStores Factory) {
+	ctx := context.Background()
+
+	t.Run("CreateRequiresChat", func(t *testing.T) {
+		s := newStores(t)
+		err := s.Messages.Create(ctx, &models.Message{ChatID: 424242, Text: "в никуда"})
+		if err == nil {
+			t.Error("Ожидалась ошибка для несуществующего чата")
+		}
+	})
+
+	t.Run("FormatStoredWithRendering", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "разметка"}
+		mustCreateChat(t, s, chat)
+		plain := &models.Message{ChatID: chat.ID, Text: "просто"}
+		mustCreateMessage(t, s, plain)
+		rich := &models.Message{
+			ChatID:   chat.ID,
+			Text:     "**важно**",
+			Format:   models.MessageFormatMarkdown,
+			HTML:     "<strong>важно</strong>",
+			Entities: []models.Entity{{Type: models.EntityBold, Offset: 0, Length: 9}},
+		}
+		mustCreateMessage(t, s, rich)
+		batch := []models.Message{{ChatID: chat.ID, Text: "импорт"}}
+		if err := s.Messages.CreateBatch(ctx, batch); err != nil {
+			t.Fatalf("CreateBatch: %v", err)
+		}
+
+		// Без формата сообщение plain - и сразу после создания, и при чтении
+		if plain.Format != models.MessageFormatPlain || batch[0].Format != models.MessageFormatPlain {
+			t.Errorf("Ожидался формат по умолчанию plain: %q, %q", plain.Format, batch[0].Format)
+		}
+		got, _ := s.Messages.GetLastMessagesByChatID(ctx, chat.ID, 3)
+		if len(got) != 3 || got[2].Format != models.MessageFormatPlain || got[2].HTML != "" || got[2].Entities != nil {
+			t.Fatalf("Сообщение plain: %+v", got)
+		}
+		if got[1].Format != rich.Format || got[1].HTML != rich.HTML || len(got[1].Entities) != 1 || got[1].Entities[0] != rich.Entities[0] {
+			t.Errorf("Сообщение markdown сохранено неверно: %+v", got[1])
+		}
+	})
+
+	t.Run("LastMessagesNewestFirstWithLimit", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "с сообщениями"}
+		other := &models.Chat{Title: "другой"}
+		mustCreateChat(t, s, chat)
+		mustCreateChat(t, s, other)
+
+		base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+		// Сообщения создаются не по порядку времени, два - с одинаковым временем
+		for _, m := range []struct {
+			text string
+			at   time.Time
+		}{
+			{"второе", base.Add(2 * time.Minute)},
+			{"первое", base.Add(1 * time.Minute)},
+			{"третье-а", base.Add(3 * time.Minute)},
+			{"третье-б", base.Add(3 * time.Minute)},
+		} {
+			mustCreateMessage(t, s, &models.Message{ChatID: chat.ID, Text: m.text, CreatedAt: m.at})
+		}
+		mustCreateMessage(t, s, &models.Message{ChatID: other.ID, Text: "чужое", CreatedAt: base.Add(time.Hour)})
+
+		got, err := s.Messages.GetLastMessagesByChatID(ctx, chat.ID, 3)
+		if err != nil {
+			t.Fatalf("GetLastMessagesByChatID: %v", err)
+		}
+		want := []string{"третье-б", "третье-а", "второе"}
+		if len(got) != len(want) {
+			t.Fatalf("Ожидалось %d сообщений, получено %d", len(want), len(got))
+		}
+		for i := range want {
+			if got[i].Text != want[i] {
+				t.Errorf("Позиция %d: ожидалось %q, получено %q", i, want[i], got[i].Text)
+			}
+			if got[i].ChatID != chat.ID {
+				t.Errorf("Сообщение из чужого чата: %+v", got[i])
+			}
+		}
+	})
+
+	t.Run("ListRangeChronologicalPages", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "история"}
+		other := &models.Chat{Title: "другой"}
+		mustCreateChat(t, s, chat)
+		mustCreateChat(t, s, other)
+
+		base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+		for _, m := range []struct {
+			text string
+			at   time.Time
+		}{
+			{"3", base.Add(3 * time.Minute)},
+			{"1", base.Add(1 * time.Minute)},
+			{"2а", base.Add(2 * time.Minute)},
+			{"2б", base.Add(2 * time.Minute)},
+			{"4", base.Add(4 * time.Minute)},
+		} {
+			mustCreateMessage(t, s, &models.Message{ChatID: chat.ID, Text: m.text, AuthorID: "alice", CreatedAt: m.at})
+		}
+		mustCreateMessage(t, s, &models.Message{ChatID: other.ID, Text: "чужое", CreatedAt: base.Add(2 * time.Minute)})
+
+		// Диапазон [1 мин, 4 мин) пачками по 2
+		rng := repository.MessageRange{From: base.Add(time.Minute), To: base.Add(4 * time.Minute)}
+		var got []string
+		for {
+			page, err := s.Messages.ListRange(ctx, chat.ID, rng, 2)
+			if err != nil {
+				t.Fatalf("ListRange: %v", err)
+			}
+			for _, m := range page {
+				got = append(got, m.Text)
+				if m.AuthorID != "alice" {
+					t.Errorf("AuthorID не сохранен: %+v", m)
+				}
+			}
+			if len(page) < 2 {
+				break
+			}
+			rng = rng.After(page[len(page)-1])
+		}
+		want := []string{"1", "2а", "2б", "3"}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Ожидалось %v, получено %v", want, got)
+		}
+
+		all, err := s.Messages.ListRange(ctx, chat.ID, repository.MessageRange{}, 100)
+		if err != nil || len(all) != 5 {
+			t.Errorf("Без границ ожидалось 5 сообщений, получено %d (%v)", len(all), err)
+		}
+	})
+
+	t.Run("CreateBatchKeepsTimestamps", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "импорт"}
+		mustCreateChat(t, s, chat)
+
+		base := time.Date(2020, 5, 1, 8, 30, 0, 0, time.UTC)
+		batch := []models.Message{
+			{ChatID: chat.ID, Text: "старое", AuthorID: "slack:alice", CreatedAt: base},
+			{ChatID: chat.ID, Text: "новее", AuthorID: "slack:bob", CreatedAt: base.Add(time.Second)},
+		}
+		if err := s.Messages.CreateBatch(ctx, batch); err != nil {
+			t.Fatalf("CreateBatch: %v", err)
+		}
+		if batch[0].ID == 0 || batch[1].ID <= batch[0].ID {
+			t.Errorf("ID не заполнены по порядку: %d, %d", batch[0].ID, batch[1].ID)
+		}
+
+		got, err := s.Messages.ListRange(ctx, chat.ID, repository.MessageRange{}, 10)
+		if err != nil {
+			t.Fatalf("ListRange: %v", err)
+		}
+		if len(got) != 2 || !got[0].CreatedAt.Equal(base) || got[1].AuthorID != "slack:bob" {
+			t.Errorf("Время или автор не сохранены: %+v", got)
+		}
+		if events, _ := s.Outbox.Pending(ctx, 10); len(events) != 0 {
+			t.Errorf("Импорт не должен писать события outbox: %+v", events)
+		}
+
+		// Пачка с несуществующим чатом не сохраняется целиком
+		err = s.Messages.CreateBatch(ctx, []models.Message{
+			{ChatID: chat.ID, Text: "x"},
+			{ChatID: 424242, Text: "в никуда"},
+		})
+		if err == nil {
+			t.Error("Ожидалась ошибка для несуществующего чата")
+		}
+		if got, _ := s.Messages.ListRange(ctx, chat.ID, repository.MessageRange{}, 10); len(got) != 2 {
+			t.Errorf("Частично сохранена неудачная пачка: %d сообщений", len(got))
+		}
+	})
+
+	t.Run("DeleteBeforeInBatches", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "очистка"}
+		other := &models.Chat{Title: "соседний"}
+		mustCreateChat(t, s, chat)
+		mustCreateChat(t, s, other)
+
+		base := time.Date(2021, 3, 1, 12, 0, 0, 0, time.Local)
+		var batch []models.Message
+		for i := 0; i < 5; i++ {
+			batch = append(batch, models.Message{ChatID: chat.ID, Text: fmt.Sprintf("m%d", i), CreatedAt: base.Add(time.Duration(i) * time.Hour)})
+		}
+		batch = append(batch, models.Message{ChatID: other.ID, Text: "чужое", CreatedAt: base})
+		if err := s.Messages.CreateBatch(ctx, batch); err != nil {
+			t.Fatalf("CreateBatch: %v", err)
+		}
+
+		// Старше границы - m0, m1, m2 (created_at < before, m3 ровно на границе остается)
+		before := base.Add(3 * time.Hour)
+		if n, err := s.Messages.CountBefore(ctx, chat.ID, before); err != nil || n != 3 {
+			t.Fatalf("CountBefore: ожидалось 3, получено %d (%v)", n, err)
+		}
+		if n, err := s.Messages.DeleteBefore(ctx, chat.ID, before, 2); err != nil || n != 2 {
+			t.Fatalf("Первая пачка: ожидалось 2 удаленных, получено %d (%v)", n, err)
+		}
+		got, _ := s.Messages.ListRange(ctx, chat.ID, repository.MessageRange{}, 10)
+		if len(got) != 3 || got[0].Text != "m2" {
+			t.Fatalf("Удаляться должны самые старые сообщения: %+v", got)
+		}
+		if n, err := s.Messages.DeleteBefore(ctx, chat.ID, before, 2); err != nil || n != 1 {
+			t.Fatalf("Вторая пачка: ожидалось 1 удаленное, получено %d (%v)", n, err)
+		}
+		if n, _ := s.Messages.DeleteBefore(ctx, chat.ID, before, 2); n != 0 {
+			t.Errorf("Повторная очистка ничего не должна удалять, удалено %d", n)
+		}
+		if got, _ := s.Messages.ListRange(ctx, other.ID, repository.MessageRange{}, 10); len(got) != 1 {
+			t.Errorf("Сообщения другого чата не должны удаляться: %+v", got)
+		}
+	})
+
+	t.Run("ExpiredMessagesHiddenAndDeleted", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "секреты"}
+		mustCreateChat(t, s, chat)
+
+		now := time.Now().Local().Truncate(time.Second)
+		past, future := now.Add(-time.Minute), now.Add(time.Hour)
+		expired := &models.Message{ChatID: chat.ID, Text: "пароль", ExpiresAt: &past}
+		pending := &models.Message{ChatID: chat.ID, Text: "еще видно", ExpiresAt: &future}
+		plain := &models.Message{ChatID: chat.ID, Text: "обычное"}
+		for _, m := range []*models.Message{expired, pending, plain} {
+			mustCreateMessage(t, s, m)
+		}
+
+		// Истекшее сообщение не читается, хотя еще лежит в таблице
+		last, _ := s.Messages.GetLastMessagesByChatID(ctx, chat.ID, 10)
+		all, _ := s.Messages.ListRange(ctx, chat.ID, repository.MessageRange{}, 10)
+		for _, got := range [][]models.Message{last, all} {
+			if len(got) != 2 {
+				t.Fatalf("Ожидалось 2 видимых сообщения, получено %+v", got)
+			}
+			for _, m := range got {
+				if m.ID == expired.ID {
+					t.Errorf("Истекшее сообщение возвращено: %+v", m)
+				}
+			}
+		}
+		if got := last[len(last)-1]; got.ID != pending.ID || got.ExpiresAt == nil || !got.ExpiresAt.Equal(future) {
+			t.Errorf("Время исчезновения не сохранено: %+v", got)
+		}
+
+		deleted, err := s.Messages.DeleteExpired(ctx, now, 10)
+		if err != nil {
+			t.Fatalf("DeleteExpired: %v", err)
+		}
+		if len(deleted) != 1 || deleted[0].ID != expired.ID || deleted[0].ChatID != chat.ID {
+			t.Fatalf("Ожидалось удаление истекшего сообщения, получено %+v", deleted)
+		}
+		if again, _ := s.Messages.DeleteExpired(ctx, now, 10); len(again) != 0 {
+			t.Errorf("Повторная очистка ничего не должна удалять: %+v", again)
+		}
+
+		// Событие удаления содержит ID, но не текст, а событие создания с текстом удалено
+		events, _ := s.Outbox.Pending(ctx, 10)
+		if len(events) != 3 {
+			t.Fatalf("Ожидалось 3 события, получено %d", len(events))
+		}
+		ev := events[2]
+		if ev.Type != models.OutboxMessageDeleted || ev.ChatID != chat.ID ||
+			!strings.Contains(ev.Payload, fmt.Sprintf(`"message_id":%d`, expired.ID)) {
+			t.Errorf("Неверное событие удаления: %+v", ev)
+		}
+		for _, ev := range events {
+			if strings.Contains(ev.Payload, "пароль") {
+				t.Errorf("Текст исчезнувшего сообщения остался в outbox: %+v", ev)
+			}
+		}
+	})
+
+	t.Run("ExpiredTextRemovedFromDeliveredOutbox", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "секреты"}
+		mustCreateChat(t, s, chat)
+		soon := time.Now().Add(time.Minute)
+		secret := &models.Message{ChatID: chat.ID, Text: "пароль", ExpiresAt: &soon}
+		mustCreateMessage(t, s, secret)
+
+		// Событие создания уже доставлено и ждет очистки outbox
+		created, _ := s.Outbox.Pending(ctx, 10)
+		if len(created) != 1 {
+			t.Fatalf("Ожидалось событие создания, получено %+v", created)
+		}
+		if err := s.Outbox.MarkDelivered(ctx, []uint{created[0].ID}, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Messages.DeleteExpired(ctx, soon.Add(time.Second), 10); err != nil {
+			t.Fatalf("DeleteExpired: %v", err)
+		}
+		// Доставленных строк не осталось: событие с текстом удалено вместе с сообщением
+		if n, err := s.Outbox.DeleteDelivered(ctx, time.Now().Add(time.Hour)); err != nil || n != 0 {
+			t.Errorf("Событие с текстом исчезнувшего сообщения осталось в outbox: %d, %v", n, err)
+		}
+		if pending, _ := s.Outbox.Pending(ctx, 10); len(pending) != 1 || pending[0].Type != models.OutboxMessageDeleted {
+			t.Errorf("Ожидалось только событие удаления, получено %+v", pending)
+		}
+	})
+
+	t.Run("GetByIDInChat", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "свой"}
+		other := &models.Chat{Title: "чужой"}
+		mustCreateChat(t, s, chat)
+		mustCreateChat(t, s, other)
+		past := time.Now().Add(-time.Minute)
+		message := &models.Message{ChatID: chat.ID, Text: "найди меня", AuthorID: "alice"}
+		expired := &models.Message{ChatID: chat.ID, Text: "уже нет", ExpiresAt: &past}
+		mustCreateMessage(t, s, message)
+		mustCreateMessage(t, s, expired)
+
+		got, err := s.Messages.GetByID(ctx, chat.ID, message.ID)
+		if err != nil || got.Text != "найди меня" || got.AuthorID != "alice" {
+			t.Fatalf("GetByID: %+v, %v", got, err)
+		}
+		// Сообщение из другого чата, истекшее и несуществующее не находятся
+		for _, tt := range []struct{ chatID, id uint }{{other.ID, message.ID}, {chat.ID, expired.ID}, {chat.ID, 424242}} {
+			if _, err := s.Messages.GetByID(ctx, tt.chatID, tt.id); !errors.Is(err, repository.ErrNotFound) {
+				t.Errorf("GetByID(%d, %d): ожидалась ErrNotFound, получено %v", tt.chatID, tt.id, err)
+			}
+		}
+	})
+
+	t.Run("ExistingIDs", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "живой"}
+		deleted := &models.Chat{Title: "удаленный"}
+		mustCreateChat(t, s, chat)
+		mustCreateChat(t, s, deleted)
+		past := time.Now().Add(-time.Minute)
+		alive := &models.Message{ChatID: chat.ID, Text: "есть"}
+		expired := &models.Message{ChatID: chat.ID, Text: "истекло", ExpiresAt: &past}
+		orphan := &models.Message{ChatID: deleted.ID, Text: "в удаленном чате"}
+		mustCreateMessage(t, s, alive)
+		mustCreateMessage(t, s, expired)
+		mustCreateMessage(t, s, orphan)
+		if err := s.Chats.Delete(ctx, deleted.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.Messages.ExistingIDs(ctx, []uint{alive.ID, expired.ID, orphan.ID, 424242})
+		if err != nil || len(got) != 1 || got[0] != alive.ID {
+			t.Errorf("ExistingIDs: ожидалось [%d], получено %v, %v", alive.ID, got, err)
+		}
+		if got, err := s.Messages.ExistingIDs(ctx, nil); err != nil || got == nil || len(got) != 0 {
+			t.Errorf("ExistingIDs(nil): ожидался пустой слайс, получено %#v, %v", got, err)
+		}
+	})
+
+	t.Run("EmptyChatReturnsEmptySlice", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "пустой"}
+		mustCreateChat(t, s, chat)
+
+		got, err := s.Messages.GetLastMessagesByChatID(ctx, chat.ID, 20)
+		if err != nil {
+			t.Fatalf("GetLastMessagesByChatID: %v", err)
+		}
+		if got == nil || len(got) != 0 {
+			t.Errorf("Ожидался пустой не-nil слайс, получено %#v", got)
+		}
+	})
+}
+
+// RunIdempotencyStoreTests проверяет контракт repository.IdempotencyStore
+func RunIdempotencyStoreTests(t *testing.T, newStores Factory) {
+	ctx := context.Background()
+	newKey := func(key string, ttl time.Duration) *models.IdempotencyKey {
+		return &models.IdempotencyKey{
+			Scope:       "user:1 POST /chats",
+			Key:         key,
+			RequestHash: "hash-" + key,
+			ExpiresAt:   time.Now().Add(ttl),
+		}
+	}
+
+	t.Run("ClaimCompleteReplay", func(t *testing.T) {
+		s := newStores(t)
+
+		existing, claimed, err := s.Idempotency.Claim(ctx, newKey("k1", time.Hour))
+		if err != nil || !claimed || existing != nil {
+			t.Fatalf("Первый Claim: claimed=%v existing=%v err=%v", claimed, existing, err)
+		}
+
+		// Пока ответа нет, повторный Claim видит незавершенную запись
+		existing, claimed, err = s.Idempotency.Claim(ctx, newKey("k1", time.Hour))
+		if err != nil || claimed || existing == nil || existing.Completed() {
+			t.Fatalf("Повторный Claim до Complete: claimed=%v existing=%+v err=%v", claimed, existing, err)
+		}
+
+		body := []byte(`{"id":1}`)
+		if err := s.Idempotency.Complete(ctx, "user:1 POST /chats", "k1", 201, "application/json", body); err != nil {
+			t.Fatalf("Complete: %v", err)
+		}
+
+		existing, claimed, err = s.Idempotency.Claim(ctx, newKey("k1", time.Hour))
+		if err != nil || claimed || existing == nil {
+			t.Fatalf("Claim после Complete: claimed=%v existing=%v err=%v", claimed, existing, err)
+		}
+		if existing.StatusCode != 201 || string(existing.ResponseBody) != string(body) ||
+			existing.ContentType != "application/json" || existing.RequestHash != "hash-k1" {
+			t.Errorf("Сохраненный ответ не совпадает: %+v", existing)
+		}
+
+		// Тот же ключ в другом scope - независимая запись
+		other := newKey("k1", time.Hour)
+		other.Scope = "user:2 POST /chats"
+		if _, claimed, _ := s.Idempotency.Claim(ctx, other); !claimed {
+			t.Error("Ключи разных scope не должны пересекаться")
+		}
+	})
+
+	t.Run("ReleaseAllowsRetry", func(t *testing.T) {
+		s := newStores(t)
+		s.Idempotency.Claim(ctx, newKey("k2", time.Hour))
+		if err := s.Idempotency.Release(ctx, "user:1 POST /chats", "k2"); err != nil {
+			t.Fatalf("Release: %v", err)
+		}
+		if _, claimed, err := s.Idempotency.Claim(ctx, newKey("k2", time.Hour)); err != nil || !claimed {
+			t.Errorf("После Release ключ должен резервироваться заново: claimed=%v err=%v", claimed, err)
+		}
+	})
+
+	t.Run("ExpiredKeys", func(t *testing.T) {
+		s := newStores(t)
+		s.Idempotency.Claim(ctx, newKey("old", -time.Minute))
+		s.Idempotency.Claim(ctx, newKey("fresh", time.Hour))
+
+		// Истекший ключ резервируется заново
+		if _, claimed, err := s.Idempotency.Claim(ctx, newKey("old", time.Hour)); err != nil || !claimed {
+			t.Errorf("Истекший ключ должен резервироваться заново: claimed=%v err=%v", claimed, err)
+		}
+
+		s.Idempotency.Claim(ctx, newKey("old2", -time.Minute))
+		deleted, err := s.Idempotency.DeleteExpired(ctx, time.Now())
+		if err != nil {
+			t.Fatalf("DeleteExpired: %v", err)
+		}
+		if deleted != 1 {
+			t.Errorf("Ожидалось удаление 1 ключа, удалено %d", deleted)
+		}
+	})
+}
+
+// RunOutboxStoreTests проверяет контракт repository.OutboxStore
+// и запись событий в outbox при создании сообщений и удалении чатов
+func RunOutboxStoreTests(t *testing.T, newStores Factory) {
+	ctx := context.Background()
+
+	t.Run("EventsWrittenWithChanges", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "outbox"}
+		mustCreateChat(t, s, chat)
+		msg := &models.Message{ChatID: chat.ID, Text: "привет"}
+		mustCreateMessage(t, s, msg)
+
+		// Повторное удаление ничего не меняет и события не пишет
+		s.Chats.Delete(ctx, chat.ID)
+		s.Chats.Delete(ctx, chat.ID)
+
+		events, err := s.Outbox.Pending(ctx, 10)
+		if err != nil {
+			t.Fatalf("Pending: %v", err)
+		}
+		if len(events) != 2 {
+			t.Fatalf("Ожидалось 2 события, получено %d: %+v", len(events), events)
+		}
+		if events[0].Type != models.OutboxMessageCreated || events[0].ChatID != chat.ID ||
+			!strings.Contains(events[0].Payload, `"text":"привет"`) {
+			t.Errorf("Неверное событие сообщения: %+v", events[0])
+		}
+		if events[1].Type != models.OutboxChatDeleted || events[1].ChatID != chat.ID || events[1].ID <= events[0].ID {
+			t.Errorf("Неверное событие удаления: %+v", events[1])
+		}
+
+		// Сообщение в несуществующий чат не создается - и события нет
+		s.Messages.Create(ctx, &models.Message{ChatID: 999, Text: "x"})
+		if events, _ := s.Outbox.Pending(ctx, 10); len(events) != 2 {
+			t.Errorf("Неудачная вставка не должна оставлять событие, событий: %d", len(events))
+		}
+	})
+
+	t.Run("DeliveryLifecycle", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "outbox"}
+		mustCreateChat(t, s, chat)
+		for i := 0; i < 3; i++ {
+			mustCreateMessage(t, s, &models.Message{ChatID: chat.ID, Text: fmt.Sprintf("m%d", i)})
+		}
+
+		events, _ := s.Outbox.Pending(ctx, 2)
+		if len(events) != 2 {
+			t.Fatalf("Pending должен учитывать limit, получено %d", len(events))
+		}
+
+		// Время повтора уже наступило: событие снова в Pending
+		if err := s.Outbox.MarkFailed(ctx, events[0].ID, "недоступен", time.Now().Add(-time.Second)); err != nil {
+			t.Fatalf("MarkFailed: %v", err)
+		}
+		if err := s.Outbox.MarkDelivered(ctx, []uint{events[1].ID}, time.Now().Add(-time.Hour)); err != nil {
+			t.Fatalf("MarkDelivered: %v", err)
+		}
+
+		pending, _ := s.Outbox.Pending(ctx, 10)
+		if len(pending) != 2 || pending[0].ID != events[0].ID {
+			t.Fatalf("Ожидались 2 недоставленных события, первое - %d: %+v", events[0].ID, pending)
+		}
+		if pending[0].Attempts != 1 || pending[0].LastError != "недоступен" {
+			t.Errorf("Неудачная попытка не записана: %+v", pending[0])
+		}
+
+		deleted, err := s.Outbox.DeleteDelivered(ctx, time.Now())
+		if err != nil || deleted != 1 {
+			t.Errorf("DeleteDelivered: ожидалось 1, удалено %d, err=%v", deleted, err)
+		}
+	})
+
+	t.Run("RetryBackoffAndGiveUp", func(t *testing.T) {
+		s := newStores(t)
+		failing, healthy := &models.Chat{Title: "сбой"}, &models.Chat{Title: "норма"}
+		mustCreateChat(t, s, failing)
+		mustCreateChat(t, s, healthy)
+		for _, chatID := range []uint{failing.ID, failing.ID, failing.ID, healthy.ID} {
+			mustCreateMessage(t, s, &models.Message{ChatID: chatID, Text: "x"})
+		}
+		head, _ := s.Outbox.Pending(ctx, 2)
+		if len(head) != 2 || head[0].ChatID != failing.ID || head[1].ChatID != failing.ID {
+			t.Fatalf("Ожидались первые события чата со сбоем: %+v", head)
+		}
+
+		// Пока первое событие чата ждет повтора, события чата не занимают пакет
+		if err := s.Outbox.MarkFailed(ctx, head[0].ID, "недоступен", time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("MarkFailed: %v", err)
+		}
+		if pending, _ := s.Outbox.Pending(ctx, 2); len(pending) != 1 || pending[0].ChatID != healthy.ID {
+			t.Errorf("Ожидалось только событие другого чата: %+v", pending)
+		}
+
+		// Брошенное событие больше не читается, следующие события чата идут дальше
+		if err := s.Outbox.MarkFailed(ctx, head[0].ID, "недоступен", time.Time{}); err != nil {
+			t.Fatalf("MarkFailed: %v", err)
+		}
+		pending, _ := s.Outbox.Pending(ctx, 10)
+		if len(pending) != 3 || pending[0].ID != head[1].ID {
+			t.Errorf("Ожидались 3 события после брошенного: %+v", pending)
+		}
+		if deleted, err := s.Outbox.DeleteDelivered(ctx, time.Now().Add(time.Minute)); err != nil || deleted != 1 {
+			t.Errorf("Брошенное событие должно удаляться очисткой: удалено %d, err=%v", deleted, err)
+		}
+	})
+
+	t.Run("Lease", func(t *testing.T) {
+		s := newStores(t)
+
+		if ok, err := s.Outbox.AcquireLease(ctx, "relay", "a", time.Hour); err != nil || !ok {
+			t.Fatalf("Первый захват аренды: ok=%v err=%v", ok, err)
+		}
+		if ok, _ := s.Outbox.AcquireLease(ctx, "relay", "a", time.Hour); !ok {
+			t.Error("Владелец должен продлевать аренду")
+		}
+		if ok, _ := s.Outbox.AcquireLease(ctx, "relay", "b", time.Hour); ok {
+			t.Error("Чужая действующая аренда не должна захватываться")
+		}
+
+		// Истекшую аренду забирает другой инстанс
+		s.Outbox.AcquireLease(ctx, "relay", "a", -time.Second)
+		if ok, _ := s.Outbox.AcquireLease(ctx, "relay", "b", time.Hour); !ok {
+			t.Error("Истекшая аренда должна захватываться")
+		}
+	})
+}
+
+// RunScheduledStoreTests проверяет контракт repository.ScheduledStore
+func RunScheduledStoreTests(t *testing.T, newStores Factory) {
+	ctx := context.Background()
+	// Время в базе хранится с точностью до микросекунд
+	now := time.Now().Truncate(time.Second)
+
+	// schedule создает запланированное сообщение со временем отправки now+offset
+	schedule := func(t *testing.T, s Stores, chatID uint, text string, offset time.Duration) *models.ScheduledMessage {
+		t.Helper()
+		m := &models.ScheduledMessage{ChatID: chatID, Text: text, SendAt: now.Add(offset)}
+		if err := s.Scheduled.Create(ctx, m); err != nil {
+			t.Fatalf("Create scheduled: %v", err)
+		}
+		return m
+	}
+
+	t.Run("ListUpdateCancel", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "план"}
+		mustCreateChat(t, s, chat)
+		if err := s.Scheduled.Create(ctx, &models.ScheduledMessage{ChatID: 424242, Text: "x", SendAt: now}); err == nil {
+			t.Error("Запланированное сообщение в несуществующий чат должно отклоняться")
+		}
+
+		later := schedule(t, s, chat.ID, "позже", 2*time.Hour)
+		sooner := schedule(t, s, chat.ID, "раньше", time.Hour)
+		if later.ID == 0 || later.Status != models.ScheduledPending || later.CreatedAt.IsZero() {
+			t.Fatalf("Неверное запланированное сообщение: %+v", later)
+		}
+
+		list, err := s.Scheduled.ListByChat(ctx, chat.ID, "", 10)
+		if err != nil || len(list) != 2 || list[0].ID != sooner.ID || list[1].ID != later.ID {
+			t.Fatalf("ListByChat: ожидался порядок по send_at, получено %+v, %v", list, err)
+		}
+
+		later.Text = "изменено"
+		later.SendAt = now.Add(30 * time.Minute)
+		if err := s.Scheduled.Update(ctx, later); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		got, err := s.Scheduled.GetByID(ctx, later.ID)
+		if err != nil || got.Text != "изменено" || !got.SendAt.Equal(now.Add(30*time.Minute)) {
+			t.Errorf("Update не сохранился: %+v, %v", got, err)
+		}
+
+		canceled, err := s.Scheduled.Cancel(ctx, sooner.ID)
+		if err != nil || canceled.Status != models.ScheduledCanceled || canceled.Text != "раньше" {
+			t.Fatalf("Cancel: %+v, %v", canceled, err)
+		}
+		if _, err := s.Scheduled.Cancel(ctx, sooner.ID); !errors.Is(err, repository.ErrNotPending) {
+			t.Errorf("Повторная отмена: ожидалась ErrNotPending, получено %v", err)
+		}
+		sooner.Text = "поздно"
+		if err := s.Scheduled.Update(ctx, sooner); !errors.Is(err, repository.ErrNotPending) || sooner.Text != "раньше" {
+			t.Errorf("Изменение отмененного: ожидалась ErrNotPending и прежний текст, получено %v, %q", err, sooner.Text)
+		}
+		if _, err := s.Scheduled.Cancel(ctx, 424242); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Отмена несуществующего: ожидалась ErrNotFound, получено %v", err)
+		}
+		if _, err := s.Scheduled.GetByID(ctx, 424242); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("GetByID несуществующего: ожидалась ErrNotFound, получено %v", err)
+		}
+
+		pending, _ := s.Scheduled.ListByChat(ctx, chat.ID, models.ScheduledPending, 10)
+		if len(pending) != 1 || pending[0].ID != later.ID {
+			t.Errorf("Фильтр по статусу: %+v", pending)
+		}
+	})
+
+	t.Run("DeliveredExactlyOnce", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "план"}
+		mustCreateChat(t, s, chat)
+		due := schedule(t, s, chat.ID, "пора", -time.Minute)
+		schedule(t, s, chat.ID, "еще рано", time.Hour)
+
+		claimed, err := s.Scheduled.Claim(ctx, "a", now, time.Minute, 10)
+		if err != nil || len(claimed) != 1 || claimed[0].ID != due.ID || claimed[0].Status != models.ScheduledSending {
+			t.Fatalf("Claim: ожидалось одно наступившее сообщение, получено %+v, %v", claimed, err)
+		}
+		if again, _ := s.Scheduled.Claim(ctx, "b", now, time.Minute, 10); len(again) != 0 {
+			t.Errorf("Захваченное сообщение не должно достаться второму обработчику: %+v", again)
+		}
+		if err := s.Scheduled.Update(ctx, &models.ScheduledMessage{ID: due.ID, Text: "x", SendAt: now}); !errors.Is(err, repository.ErrNotPending) {
+			t.Errorf("Изменение захваченного: ожидалась ErrNotPending, получено %v", err)
+		}
+
+		// Чужой захват: сообщение не сохраняется
+		foreign := &models.Message{ChatID: chat.ID, Text: "пора", Scheduled: &models.ScheduledClaim{ID: due.ID, Holder: "b"}}
+		if err := s.Messages.Create(ctx, foreign); !errors.Is(err, repository.ErrClaimLost) {
+			t.Errorf("Чужой захват: ожидалась ErrClaimLost, получено %v", err)
+		}
+		msg := &models.Message{ChatID: chat.ID, Text: "пора", Scheduled: &models.ScheduledClaim{ID: due.ID, Holder: "a"}}
+		mustCreateMessage(t, s, msg)
+		// Повтор после отправки (например, обработчик не узнал о COMMIT) тоже отклоняется
+		retry := &models.Message{ChatID: chat.ID, Text: "пора", Scheduled: &models.ScheduledClaim{ID: due.ID, Holder: "a"}}
+		if err := s.Messages.Create(ctx, retry); !errors.Is(err, repository.ErrClaimLost) {
+			t.Errorf("Повторная отправка: ожидалась ErrClaimLost, получено %v", err)
+		}
+		if messages, _ := s.Messages.GetLastMessagesByChatID(ctx, chat.ID, 10); len(messages) != 1 {
+			t.Errorf("Ожидалось одно отправленное сообщение, получено %d", len(messages))
+		}
+
+		got, _ := s.Scheduled.GetByID(ctx, due.ID)
+		if got.Status != models.ScheduledSent || got.MessageID == nil || *got.MessageID != msg.ID {
+			t.Errorf("Ожидался статус sent со ссылкой на сообщение %d: %+v", msg.ID, got)
+		}
+		if err := s.Scheduled.MarkFailed(ctx, due.ID, "a", "поздно", time.Time{}); !errors.Is(err, repository.ErrClaimLost) {
+			t.Errorf("MarkFailed отправленного: ожидалась ErrClaimLost, получено %v", err)
+		}
+		if again, _ := s.Scheduled.Claim(ctx, "a", now.Add(2*time.Minute), time.Minute, 10); len(again) != 0 {
+			t.Errorf("Отправленное сообщение не должно захватываться: %+v", again)
+		}
+	})
+
+	t.Run("ExpiredClaimAndFailures", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "план"}
+		mustCreateChat(t, s, chat)
+		m := schedule(t, s, chat.ID, "пора", 0)
+
+		s.Scheduled.Claim(ctx, "a", now, time.Minute, 10)
+		// Обработчик "a" пропал: после истечения захвата сообщение забирает "b"
+		claimed, _ := s.Scheduled.Claim(ctx, "b", now.Add(2*time.Minute), time.Minute, 10)
+		if len(claimed) != 1 || claimed[0].ID != m.ID {
+			t.Fatalf("Истекший захват должен забираться: %+v", claimed)
+		}
+		if err := s.Scheduled.MarkFailed(ctx, m.ID, "a", "ошибка", time.Time{}); !errors.Is(err, repository.ErrClaimLost) {
+			t.Errorf("MarkFailed бывшим владельцем: ожидалась ErrClaimLost, получено %v", err)
+		}
+
+		// Ошибка с повтором: сообщение ждет retryAt
+		if err := s.Scheduled.MarkFailed(ctx, m.ID, "b", "нет связи", now.Add(5*time.Minute)); err != nil {
+			t.Fatalf("MarkFailed: %v", err)
+		}
+		got, _ := s.Scheduled.GetByID(ctx, m.ID)
+		if got.Status != models.ScheduledPending || got.Attempts != 1 || got.LastError != "нет связи" {
+			t.Errorf("После ошибки ожидался pending с попыткой: %+v", got)
+		}
+		if early, _ := s.Scheduled.Claim(ctx, "b", now.Add(3*time.Minute), time.Minute, 10); len(early) != 0 {
+			t.Errorf("До retryAt сообщение не должно захватываться: %+v", early)
+		}
+		if claimed, _ := s.Scheduled.Claim(ctx, "b", now.Add(6*time.Minute), time.Minute, 10); len(claimed) != 1 {
+			t.Fatalf("После retryAt сообщение должно захватываться: %+v", claimed)
+		}
+
+		// Ошибка без повтора
+		if err := s.Scheduled.MarkFailed(ctx, m.ID, "b", "чат удален", time.Time{}); err != nil {
+			t.Fatalf("MarkFailed: %v", err)
+		}
+		got, _ = s.Scheduled.GetByID(ctx, m.ID)
+		if got.Status != models.ScheduledFailed || got.Attempts != 2 || got.LastError != "чат удален" {
+			t.Errorf("Ожидался статус failed: %+v", got)
+		}
+	})
+
+	t.Run("ConcurrentClaim", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "план"}
+		mustCreateChat(t, s, chat)
+		const n = 20
+		for i := 0; i < n; i++ {
+			schedule(t, s, chat.ID, fmt.Sprintf("сообщение %d", i), -time.Minute)
+		}
+
+		ids := make(chan uint, n*4)
+		var wg sync.WaitGroup
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func(holder string) {
+				defer wg.Done()
+				for {
+					claimed, err := s.Scheduled.Claim(ctx, holder, now, time.Minute, 3)
+					if err != nil {
+						t.Errorf("Claim: %v", err)
+						return
+					}
+					if len(claimed) == 0 {
+						return
+					}
+					for _, m := range claimed {
+						ids <- m.ID
+					}
+				}
+			}(fmt.Sprintf("w%d", w))
+		}
+		wg.Wait()
+		close(ids)
+
+		seen := map[uint]bool{}
+		for id := range ids {
+			if seen[id] {
+				t.Errorf("Сообщение %d захвачено дважды", id)
+			}
+			seen[id] = true
+		}
+		if len(seen) != n {
+			t.Errorf("Ожидалось %d захваченных сообщений, получено %d", n, len(seen))
+		}
+	})
+}
+
+// RunPinStoreTests проверяет контракт repository.PinStore
+func RunPinStoreTests(t *testing.T, newStores Factory) {
+	ctx := context.Background()
+
+	t.Run("PinListUnpin", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "решения"}
+		other := &models.Chat{Title: "другой"}
+		mustCreateChat(t, s, chat)
+		mustCreateChat(t, s, other)
+		first := &models.Message{ChatID: chat.ID, Text: "релиз в пятницу"}
+		second := &models.Message{ChatID: chat.ID, Text: "код ревью обязательно"}
+		foreign := &models.Message{ChatID: other.ID, Text: "чужое"}
+		for _, m := range []*models.Message{first, second, foreign} {
+			mustCreateMessage(t, s, m)
+		}
+
+		now := time.Now().Truncate(time.Second)
+		pin := &models.Pin{ChatID: chat.ID, MessageID: first.ID, PinnedBy: "alice", PinnedAt: now}
+		notice := &models.Message{ChatID: chat.ID, Text: "закреплено", Kind: models.MessageKindPin}
+		if err := s.Pins.Pin(ctx, pin, 10, notice); err != nil {
+			t.Fatalf("Pin: %v", err)
+		}
+		if pin.Message == nil || pin.Message.Text != first.Text || notice.ID == 0 {
+			t.Fatalf("Pin должен заполнить сообщение и сохранить уведомление: %+v, %+v", pin, notice)
+		}
+		if err := s.Pins.Pin(ctx, &models.Pin{ChatID: chat.ID, MessageID: second.ID, PinnedAt: now.Add(time.Second)}, 10, nil); err != nil {
+			t.Fatalf("Pin: %v", err)
+		}
+
+		// Уведомление сохранено как сообщение с событием outbox
+		last, _ := s.Messages.GetLastMessagesByChatID(ctx, chat.ID, 1)
+		if len(last) != 1 || last[0].ID != notice.ID || last[0].Kind != models.MessageKindPin {
+			t.Errorf("Уведомление о закреплении не сохранено: %+v", last)
+		}
+		events, _ := s.Outbox.Pending(ctx, 10)
+		if len(events) != 4 || events[3].Type != models.OutboxMessageCreated {
+			t.Errorf("Ожидалось событие message.created уведомления, получено %+v", events)
+		}
+
+		for _, tc := range []struct {
+			name string
+			pin  models.Pin
+			want error
+		}{
+			{"повторно", models.Pin{ChatID: chat.ID, MessageID: first.ID}, repository.ErrAlreadyPinned},
+			{"сообщение другого чата", models.Pin{ChatID: chat.ID, MessageID: foreign.ID}, repository.ErrNotFound},
+			{"нет сообщения", models.Pin{ChatID: chat.ID, MessageID: 424242}, repository.ErrNotFound},
+			{"нет чата", models.Pin{ChatID: 424242, MessageID: first.ID}, repository.ErrNotFound},
+			{"предел", models.Pin{ChatID: chat.ID, MessageID: notice.ID}, repository.ErrPinLimit},
+		} {
+			if err := s.Pins.Pin(ctx, &tc.pin, 2, nil); !errors.Is(err, tc.want) {
+				t.Errorf("Pin %s: ожидалась %v, получено %v", tc.name, tc.want, err)
+			}
+		}
+
+		pins, err := s.Pins.List(ctx, chat.ID)
+		if err != nil || len(pins) != 2 || pins[0].MessageID != second.ID || pins[1].MessageID != first.ID {
+			t.Fatalf("List: ожидались последние закрепленные первыми, получено %+v, %v", pins, err)
+		}
+		if pins[1].PinnedBy != "alice" || !pins[1].PinnedAt.Equal(now) || pins[1].Message == nil || pins[1].Message.Text != first.Text {
+			t.Errorf("Неверное закрепление: %+v", pins[1])
+		}
+		if empty, err := s.Pins.List(ctx, other.ID); err != nil || empty == nil || len(empty) != 0 {
+			t.Errorf("Чат без закреплений: ожидался пустой слайс, получено %#v, %v", empty, err)
+		}
+
+		if err := s.Pins.Unpin(ctx, chat.ID, first.ID); err != nil {
+			t.Fatalf("Unpin: %v", err)
+		}
+		if err := s.Pins.Unpin(ctx, chat.ID, first.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Повторное открепление: ожидалась ErrNotFound, получено %v", err)
+		}
+		if pins, _ := s.Pins.List(ctx, chat.ID); len(pins) != 1 || pins[0].MessageID != second.ID {
+			t.Errorf("После открепления: %+v", pins)
+		}
+	})
+
+	t.Run("DeletedMessageUnpinned", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "решения"}
+		mustCreateChat(t, s, chat)
+		soon := time.Now().Add(time.Hour)
+		m := &models.Message{ChatID: chat.ID, Text: "временное", ExpiresAt: &soon}
+		mustCreateMessage(t, s, m)
+		if err := s.Pins.Pin(ctx, &models.Pin{ChatID: chat.ID, MessageID: m.ID}, 10, nil); err != nil {
+			t.Fatalf("Pin: %v", err)
+		}
+
+		// Удаленное сообщение пропадает из закрепленных и не занимает место
+		if _, err := s.Messages.DeleteExpired(ctx, soon, 10); err != nil {
+			t.Fatalf("DeleteExpired: %v", err)
+		}
+		if pins, _ := s.Pins.List(ctx, chat.ID); len(pins) != 0 {
+			t.Errorf("Закрепление удаленного сообщения осталось: %+v", pins)
+		}
+		other := &models.Message{ChatID: chat.ID, Text: "новое"}
+		mustCreateMessage(t, s, other)
+		if err := s.Pins.Pin(ctx, &models.Pin{ChatID: chat.ID, MessageID: other.ID}, 1, nil); err != nil {
+			t.Errorf("Закрепление удаленного сообщения не должно учитываться в пределе: %v", err)
+		}
+	})
+
+	t.Run("ExpiredMessageNotCounted", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "решения"}
+		mustCreateChat(t, s, chat)
+		soon := time.Now().Add(300 * time.Millisecond)
+		m := &models.Message{ChatID: chat.ID, Text: "временное", ExpiresAt: &soon}
+		mustCreateMessage(t, s, m)
+		if err := s.Pins.Pin(ctx, &models.Pin{ChatID: chat.ID, MessageID: m.ID}, 1, nil); err != nil {
+			t.Fatalf("Pin: %v", err)
+		}
+
+		// Сообщение истекло, но очистка еще не прошла: закрепление скрыто и не занимает место
+		time.Sleep(time.Until(soon) + 100*time.Millisecond)
+		other := &models.Message{ChatID: chat.ID, Text: "новое"}
+		mustCreateMessage(t, s, other)
+		if err := s.Pins.Pin(ctx, &models.Pin{ChatID: chat.ID, MessageID: other.ID}, 1, nil); err != nil {
+			t.Errorf("Закрепление истекшего сообщения не должно учитываться в пределе: %v", err)
+		}
+		if pins, _ := s.Pins.List(ctx, chat.ID); len(pins) != 1 || pins[0].MessageID != other.ID {
+			t.Errorf("Ожидалось одно закрепление нового сообщения: %+v", pins)
+		}
+	})
+}
+
+// RunMentionStoreTests проверяет контракт repository.MentionStore
+// и создание уведомлений в MessageStore.Create по message.Notify
+func RunMentionStoreTests(t *testing.T, newStores Factory) {
+	ctx := context.Background()
+
+	t.Run("CreateListMarkRead", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "команда"}
+		mustCreateChat(t, s, chat)
+		mentions := []models.Mention{{Offset: 0, Length: 4, Target: "bob"}}
+		var sent []*models.Message
+		for _, text := range []string{"@bob первое", "@bob второе", "@bob третье"} {
+			m := &models.Message{ChatID: chat.ID, AuthorID: "alice", Text: text, Mentions: mentions, Notify: []string{"bob", "carol"}}
+			mustCreateMessage(t, s, m)
+			sent = append(sent, m)
+		}
+
+		// Сущности упоминаний сохраняются вместе с сообщением
+		last, _ := s.Messages.GetLastMessagesByChatID(ctx, chat.ID, 1)
+		if len(last) != 1 || len(last[0].Mentions) != 1 || last[0].Mentions[0] != mentions[0] {
+			t.Errorf("Упоминания сообщения не сохранены: %+v", last)
+		}
+
+		page, err := s.Mentions.ListByUser(ctx, "bob", false, 0, 2)
+		if err != nil || len(page) != 2 || page[0].MessageID != sent[2].ID || page[1].MessageID != sent[1].ID {
+			t.Fatalf("ListByUser: ожидались новые первыми, получено %+v, %v", page, err)
+		}
+		if page[0].ChatID != chat.ID || page[0].AuthorID != "alice" || page[0].ReadAt != nil ||
+			page[0].Message == nil || page[0].Message.Text != sent[2].Text {
+			t.Errorf("Неверное уведомление: %+v", page[0])
+		}
+		next, _ := s.Mentions.ListByUser(ctx, "bob", false, page[1].ID, 2)
+		if len(next) != 1 || next[0].MessageID != sent[0].ID {
+			t.Errorf("Следующая страница: %+v", next)
+		}
+		if empty, err := s.Mentions.ListByUser(ctx, "dave", false, 0, 10); err != nil || empty == nil || len(empty) != 0 {
+			t.Errorf("Без уведомлений: ожидался пустой слайс, получено %#v, %v", empty, err)
+		}
+
+		// Отмечаются только свои непрочитанные уведомления
+		at := time.Now().Truncate(time.Second)
+		carol, _ := s.Mentions.ListByUser(ctx, "carol", false, 0, 10)
+		if n, err := s.Mentions.MarkRead(ctx, "bob", []uint{page[0].ID, carol[0].ID, 424242}, at); err != nil || n != 1 {
+			t.Fatalf("MarkRead: ожидалось 1, получено %d, %v", n, err)
+		}
+		if n, _ := s.Mentions.MarkRead(ctx, "bob", []uint{page[0].ID}, at); n != 0 {
+			t.Errorf("Повторная отметка: ожидалось 0, получено %d", n)
+		}
+		if count, err := s.Mentions.CountUnread(ctx, "bob"); err != nil || count != 2 {
+			t.Errorf("CountUnread: ожидалось 2, получено %d, %v", count, err)
+		}
+		unread, _ := s.Mentions.ListByUser(ctx, "bob", true, 0, 10)
+		if len(unread) != 2 || unread[0].MessageID != sent[1].ID {
+			t.Errorf("Непрочитанные: %+v", unread)
+		}
+		all, _ := s.Mentions.ListByUser(ctx, "bob", false, 0, 1)
+		if len(all) != 1 || all[0].ReadAt == nil || !all[0].ReadAt.Equal(at) {
+			t.Errorf("Прочитанное уведомление: %+v", all)
+		}
+		if n, _ := s.Mentions.MarkRead(ctx, "bob", nil, at); n != 2 {
+			t.Errorf("MarkRead всех: ожидалось 2, получено %d", n)
+		}
+		if count, _ := s.Mentions.CountUnread(ctx, "carol"); count != 3 {
+			t.Errorf("Уведомления другого пользователя не должны меняться: %d", count)
+		}
+	})
+
+	t.Run("HiddenWithMessageAndChat", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "команда"}
+		other := &models.Chat{Title: "другой"}
+		mustCreateChat(t, s, chat)
+		mustCreateChat(t, s, other)
+		soon := time.Now().Add(time.Hour)
+		temporary := &models.Message{ChatID: chat.ID, Text: "@bob временное", ExpiresAt: &soon, Notify: []string{"bob"}}
+		mustCreateMessage(t, s, temporary)
+		mustCreateMessage(t, s, &models.Message{ChatID: other.ID, Text: "@bob в другом чате", Notify: []string{"bob"}})
+		if count, _ := s.Mentions.CountUnread(ctx, "bob"); count != 2 {
+			t.Fatalf("CountUnread: ожидалось 2, получено %d", count)
+		}
+
+		// Уведомление об удаленном сообщении удаляется вместе с ним
+		if _, err := s.Messages.DeleteExpired(ctx, soon, 10); err != nil {
+			t.Fatalf("DeleteExpired: %v", err)
+		}
+		// Уведомления из удаленного чата скрываются
+		if err := s.Chats.Delete(ctx, other.ID); err != nil {
+			t.Fatalf("Delete chat: %v", err)
+		}
+		if list, _ := s.Mentions.ListByUser(ctx, "bob", false, 0, 10); len(list) != 0 {
+			t.Errorf("Уведомления удаленных сообщений и чатов должны скрываться: %+v", list)
+		}
+		if count, _ := s.Mentions.CountUnread(ctx, "bob"); count != 0 {
+			t.Errorf("CountUnread: ожидалось 0, получено %d", count)
+		}
+	})
+}
+
+// RunLinkPreviewStoreTests проверяет контракт repository.LinkPreviewStore
+func RunLinkPreviewStoreTests(t *testing.T, newStores Factory) {
+	ctx := context.Background()
+	const (
+		first  = "https://example.com/a"
+		second = "https://example.com/b"
+	)
+
+	t.Run("AttachCacheAndStale", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "ссылки"}
+		mustCreateChat(t, s, chat)
+		m1 := &models.Message{ChatID: chat.ID, Text: first + " " + second}
+		m2 := &models.Message{ChatID: chat.ID, Text: second}
+		plain := &models.Message{ChatID: chat.ID, Text: "без ссылок"}
+		mustCreateMessage(t, s, m1)
+		mustCreateMessage(t, s, m2)
+		mustCreateMessage(t, s, plain)
+
+		previews, err := s.Previews.Attach(ctx, m1.ID, []string{first, second}, time.Now().Add(-time.Hour))
+		if err != nil || len(previews) != 2 || previews[0].URL != first || previews[1].URL != second ||
+			previews[0].Status != models.LinkPreviewPending || previews[0].ID == 0 {
+			t.Fatalf("Attach: ожидались два ожидающих превью в порядке ссылок, получено %+v, %v", previews, err)
+		}
+		// Тот же адрес в другом сообщении - то же превью, в очередь повторно не ставится
+		again, err := s.Previews.Attach(ctx, m2.ID, []string{second}, time.Now().Add(-time.Hour))
+		if err != nil || len(again) != 1 || again[0].ID != previews[1].ID {
+			t.Fatalf("Attach известного адреса: %+v, %v", again, err)
+		}
+		if _, err := s.Previews.Attach(ctx, 424242, []string{first}, time.Now()); err == nil {
+			t.Error("Ссылка несуществующего сообщения должна отклоняться")
+		}
+
+		byMessage, err := s.Previews.ListByMessages(ctx, []uint{m1.ID, m2.ID, plain.ID})
+		if err != nil || len(byMessage) != 2 || len(byMessage[m1.ID]) != 2 || byMessage[m1.ID][0].URL != first ||
+			byMessage[m1.ID][1].URL != second || len(byMessage[m2.ID]) != 1 {
+			t.Fatalf("ListByMessages: %+v, %v", byMessage, err)
+		}
+
+		claimed, err := s.Previews.Claim(ctx, "a", time.Now(), time.Minute, 10)
+		if err != nil || len(claimed) != 2 {
+			t.Fatalf("Claim: ожидалось два превью, получено %+v, %v", claimed, err)
+		}
+		ready := claimed[0]
+		ready.Title = "Пример"
+		ready.Description = "Описание страницы"
+		ready.ImageURL = "https://example.com/a.png"
+		ready.SiteName = "Example"
+		at := time.Now().Truncate(time.Second)
+		if err := s.Previews.Complete(ctx, &ready, "a", at); err != nil || ready.Status != models.LinkPreviewReady {
+			t.Fatalf("Complete: %+v, %v", ready, err)
+		}
+
+		// Свежий кэш отдается сразу, устаревший снова ставится в очередь с прежними данными
+		fresh := &models.Message{ChatID: chat.ID, Text: first}
+		mustCreateMessage(t, s, fresh)
+		cached, _ := s.Previews.Attach(ctx, fresh.ID, []string{first}, at.Add(-time.Hour))
+		if len(cached) != 1 || cached[0].Status != models.LinkPreviewReady || cached[0].Title != "Пример" ||
+			cached[0].FetchedAt == nil || !cached[0].FetchedAt.Equal(at) {
+			t.Errorf("Свежий кэш: %+v", cached)
+		}
+		stale := &models.Message{ChatID: chat.ID, Text: first}
+		mustCreateMessage(t, s, stale)
+		requeued, _ := s.Previews.Attach(ctx, stale.ID, []string{first}, at.Add(time.Second))
+		if len(requeued) != 1 || requeued[0].Status != models.LinkPreviewPending || requeued[0].Title != "Пример" {
+			t.Errorf("Устаревший кэш: ожидалось pending с прежними данными, получено %+v", requeued)
+		}
+
+		// Ссылки удаленного сообщения удаляются вместе с ним
+		if err := s.Chats.Delete(ctx, chat.ID); err != nil {
+			t.Fatalf("Delete chat: %v", err)
+		}
+		if _, err := s.Messages.DeleteBefore(ctx, chat.ID, time.Now().Add(time.Hour), 100); err != nil {
+			t.Fatalf("DeleteBefore: %v", err)
+		}
+		if left, _ := s.Previews.ListByMessages(ctx, []uint{m1.ID, m2.ID}); len(left) != 0 {
+			t.Errorf("Ссылки удаленных сообщений должны удаляться: %+v", left)
+		}
+	})
+
+	t.Run("ClaimFailAndNotify", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "ссылки"}
+		mustCreateChat(t, s, chat)
+		var sent []*models.Message
+		for i := 0; i < 3; i++ {
+			m := &models.Message{ChatID: chat.ID, Text: first}
+			mustCreateMessage(t, s, m)
+			if _, err := s.Previews.Attach(ctx, m.ID, []string{first}, time.Now().Add(-time.Hour)); err != nil {
+				t.Fatalf("Attach: %v", err)
+			}
+			sent = append(sent, m)
+		}
+
+		now := time.Now()
+		claimed, err := s.Previews.Claim(ctx, "a", now, time.Minute, 10)
+		if err != nil || len(claimed) != 1 || claimed[0].Status != models.LinkPreviewFetching {
+			t.Fatalf("Claim: %+v, %v", claimed, err)
+		}
+		p := claimed[0]
+		if again, _ := s.Previews.Claim(ctx, "b", now, time.Minute, 10); len(again) != 0 {
+			t.Errorf("Захваченное превью не должно достаться второму обработчику: %+v", again)
+		}
+
+		notify, err := s.Previews.Messages(ctx, p.ID, p.QueuedAt, 2)
+		if err != nil || len(notify) != 2 || notify[0].ID != sent[2].ID || notify[1].ID != sent[1].ID || notify[0].ChatID != chat.ID {
+			t.Errorf("Messages: ожидались последние сообщения со ссылкой, получено %+v, %v", notify, err)
+		}
+
+		// Неудача с повтором: до retryAt превью не захватывается
+		if err := s.Previews.MarkFailed(ctx, p.ID, "b", "чужой", time.Time{}); !errors.Is(err, repository.ErrPreviewClaimLost) {
+			t.Errorf("MarkFailed чужого захвата: ожидалась ErrPreviewClaimLost, получено %v", err)
+		}
+		if err := s.Previews.MarkFailed(ctx, p.ID, "a", "503", now.Add(time.Minute)); err != nil {
+			t.Fatalf("MarkFailed: %v", err)
+		}
+		if early, _ := s.Previews.Claim(ctx, "a", now, time.Minute, 10); len(early) != 0 {
+			t.Errorf("Превью не должно захватываться до времени повтора: %+v", early)
+		}
+
+		// Истекший захват забирает другой обработчик, прежний уже не может завершить загрузку
+		s.Previews.Claim(ctx, "a", now.Add(time.Minute), time.Minute, 10)
+		taken, _ := s.Previews.Claim(ctx, "b", now.Add(3*time.Minute), time.Minute, 10)
+		if len(taken) != 1 || taken[0].Attempts != 1 {
+			t.Fatalf("Истекший захват: %+v", taken)
+		}
+		if err := s.Previews.Complete(ctx, &p, "a", now); !errors.Is(err, repository.ErrPreviewClaimLost) {
+			t.Errorf("Complete после потери захвата: ожидалась ErrPreviewClaimLost, получено %v", err)
+		}
+		if err := s.Previews.MarkFailed(ctx, p.ID, "b", "404", time.Time{}); err != nil {
+			t.Fatalf("MarkFailed без повтора: %v", err)
+		}
+		list, _ := s.Previews.ListByMessages(ctx, []uint{sent[0].ID})
+		if got := list[sent[0].ID]; len(got) != 1 || got[0].Status != models.LinkPreviewFailed || got[0].FetchedAt == nil {
+			t.Errorf("Ожидался статус failed с временем загрузки: %+v", got)
+		}
+		if rest, _ := s.Previews.Claim(ctx, "a", now.Add(time.Hour), time.Minute, 10); len(rest) != 0 {
+			t.Errorf("Неудачное превью не должно захватываться: %+v", rest)
+		}
+	})
+}
+
+// RunPollStoreTests проверяет контракт repository.PollStore и хранение опроса в сообщении
+func RunPollStoreTests(t *testing.T, newStores Factory) {
+	ctx := context.Background()
+
+	t.Run("StoredVoteRetract", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "голосование"}
+		mustCreateChat(t, s, chat)
+		closesAt := time.Now().Local().Truncate(time.Second).Add(time.Hour)
+		poll := &models.Message{ChatID: chat.ID, Kind: models.MessageKindPoll, Text: "Куда идем?", Poll: &models.Poll{
+			Options:  []models.PollOption{{Text: "Кино"}, {Text: "Театр"}, {Text: "Домой"}},
+			Multiple: true,
+			ClosesAt: &closesAt,
+		}}
+		other := &models.Message{ChatID: chat.ID, Kind: models.MessageKindPoll, Text: "Когда?", Poll: &models.Poll{
+			Options:   []models.PollOption{{Text: "Сегодня"}, {Text: "Завтра"}},
+			Anonymous: true,
+		}}
+		mustCreateMessage(t, s, poll)
+		mustCreateMessage(t, s, other)
+
+		got, err := s.Messages.GetByID(ctx, chat.ID, poll.ID)
+		if err != nil || got.Poll == nil || len(got.Poll.Options) != 3 || got.Poll.Options[1].Text != "Театр" ||
+			!got.Poll.Multiple || got.Poll.Anonymous || got.Poll.ClosesAt == nil || !got.Poll.ClosesAt.Equal(closesAt) {
+			t.Fatalf("Опрос не сохранен: %+v, %v", got, err)
+		}
+		if last, _ := s.Messages.GetLastMessagesByChatID(ctx, chat.ID, 10); last[0].Poll == nil || !last[0].Poll.Anonymous {
+			t.Errorf("Опрос не читается со списком сообщений: %+v", last)
+		}
+
+		start := time.Now()
+		steps := []struct {
+			message uint
+			user    string
+			options []int
+		}{
+			{poll.ID, "alice", []int{0, 2}},
+			{poll.ID, "bob", []int{1}},
+			{other.ID, "alice", []int{1}},
+			{poll.ID, "alice", []int{2}}, // переголосовала: прежний выбор заменяется
+		}
+		for i, step := range steps {
+			if err := s.Polls.Vote(ctx, step.message, step.user, step.options, start.Add(time.Duration(i)*time.Second)); err != nil {
+				t.Fatalf("Vote %+v: %v", step, err)
+			}
+		}
+		votes, err := s.Polls.Votes(ctx, []uint{poll.ID})
+		if err != nil {
+			t.Fatalf("Votes: %v", err)
+		}
+		var summary []string
+		for _, v := range votes {
+			summary = append(summary, fmt.Sprintf("%s:%d", v.UserID, v.Option))
+		}
+		if strings.Join(summary, " ") != "bob:1 alice:2" {
+			t.Errorf("Ожидались голоса в порядке голосования bob:1 alice:2, получено %v", summary)
+		}
+
+		if err := s.Polls.Retract(ctx, poll.ID, "bob"); err != nil {
+			t.Fatalf("Retract: %v", err)
+		}
+		if err := s.Polls.Retract(ctx, poll.ID, "bob"); err != nil {
+			t.Errorf("Повторный отзыв не должен быть ошибкой: %v", err)
+		}
+		if votes, _ := s.Polls.Votes(ctx, []uint{poll.ID, other.ID}); len(votes) != 2 {
+			t.Errorf("После отзыва ожидалось 2 голоса в двух опросах, получено %+v", votes)
+		}
+		if err := s.Polls.Vote(ctx, 424242, "alice", []int{0}, start); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Голос в несуществующем сообщении: ожидалась ErrNotFound, получено %v", err)
+		}
+	})
+
+	t.Run("VotesDeletedWithMessage", func(t *testing.T) {
+		s := newStores(t)
+		chat := &models.Chat{Title: "временный опрос"}
+		mustCreateChat(t, s, chat)
+		soon := time.Now().Add(time.Hour)
+		poll := &models.Message{ChatID: chat.ID, Kind: models.MessageKindPoll, Text: "Да?", ExpiresAt: &soon, Poll: &models.Poll{
+			Options: []models.PollOption{{Text: "Да"}, {Text: "Нет"}},
+		}}
+		mustCreateMessage(t, s, poll)
+		if err := s.Polls.Vote(ctx, poll.ID, "alice", []int{0}, time.Now()); err != nil {
+			t.Fatalf("Vote: %v", err)
+		}
+		if _, err := s.Messages.DeleteExpired(ctx, soon, 10); err != nil {
+			t.Fatalf("DeleteExpired: %v", err)
+		}
+		if votes, _ := s.Polls.Votes(ctx, []uint{poll.ID}); len(votes) != 0 {
+			t.Errorf("Голоса удаленного опроса должны удаляться: %+v", votes)
+		}
+	})
+}
+
+// mustCreateChat создает чат или останавливает тест
+func mustCreateChat(t *testing.T, s Stores, chat *models.Chat) {
+	t.Helper()
+	if err := s.Chats.Create(context.Background(), chat); err != nil {
+		t.Fatalf("Create chat: %v", err)
+	}
+}
+
+// mustCreateMessage создает сообщение или останавливает тест
+func mustCreateMessage(t *testing.T, s Stores, message *models.Message) {
+	t.Helper()
+	if err := s.Messages.Create(context.Background(), message); err != nil {
+		t.Fatalf("Create message: %v", err)
+	}
+}
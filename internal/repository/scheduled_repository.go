@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"go-chat-app/internal/models"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ScheduledRepository отвечает за хранение запланированных сообщений
+// Отправленными их отмечает MessageRepository.Create в транзакции с самим сообщением
+type ScheduledRepository struct {
+	db *gorm.DB
+}
+
+// NewScheduledRepository создает новый репозиторий запланированных сообщений
+func NewScheduledRepository(db *gorm.DB) *ScheduledRepository {
+	return &ScheduledRepository{db: db}
+}
+
+// Create сохраняет новое запланированное сообщение
+func (r *ScheduledRepository) Create(ctx context.Context, message *models.ScheduledMessage) error {
+	ctx, span := tracer.Start(ctx, "ScheduledRepository.Create")
+	defer span.End()
+
+	message.Status = models.ScheduledPending
+	// Время в местном, как GORM записывает created_at: колонки сравниваются с текущим временем без часового пояса
+	message.SendAt = message.SendAt.Local()
+	return recordError(ctx, span, r.db.WithContext(ctx).Create(message).Error)
+}
+
+// GetByID находит запланированное сообщение по ID
+func (r *ScheduledRepository) GetByID(ctx context.Context, id uint) (*models.ScheduledMessage, error) {
+	ctx, span := tracer.Start(ctx, "ScheduledRepository.GetByID")
+	defer span.End()
+
+	var message models.ScheduledMessage
+	err := r.db.WithContext(ctx).First(&message, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, recordError(ctx, span, err)
+	}
+	return &message, nil
+}
+
+// ListByChat возвращает запланированные сообщения чата в порядке отправки
+func (r *ScheduledRepository) ListByChat(ctx context.Context, chatID uint, status string, limit int) ([]models.ScheduledMessage, error) {
+	ctx, span := tracer.Start(ctx, "ScheduledRepository.ListByChat")
+	defer span.End()
+
+	messages := make([]models.ScheduledMessage, 0, limit)
+	query := r.db.WithContext(ctx).Where("chat_id = ?", chatID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("send_at, id").Limit(limit).Find(&messages).Error
+	if err != nil {
+		return nil, recordError(ctx, span, err)
+	}
+	return messages, nil
+}
+
+// Update сохраняет текст и время отправки, если сообщение еще ожидает отправки
+// Ожидание повтора после ошибки (locked_until) сбрасывается: новое время - новая попытка
+func (r *ScheduledRepository) Update(ctx context.Context, message *models.ScheduledMessage) error {
+	ctx, span := tracer.Start(ctx, "ScheduledRepository.Update")
+	defer span.End()
+
+	message.SendAt = message.SendAt.Local()
+	return recordError(ctx, span, r.updatePending(ctx, message.ID, map[string]any{
+		"text":         message.Text,
+		"send_at":      message.SendAt,
+		"locked_until": nil,
+		"updated_at":   time.Now(),
+	}, message))
+}
+
+// Cancel отменяет ожидающее сообщение
+func (r *ScheduledRepository) Cancel(ctx context.Context, id uint) (*models.ScheduledMessage, error) {
+	ctx, span := tracer.Start(ctx, "ScheduledRepository.Cancel")
+	defer span.End()
+
+	var message models.ScheduledMessage
+	err := r.updatePending(ctx, id, map[string]any{
+		"status":       models.ScheduledCanceled,
+		"locked_until": nil,
+		"updated_at":   time.Now(),
+	}, &message)
+	if err != nil {
+		return nil, recordError(ctx, span, err)
+	}
+	return &message, nil
+}
+
+// updatePending изменяет сообщение, только если оно в статусе pending, и перечитывает его в out
+// Условие на статус в самом UPDATE: сообщение, которое обработчик успел захватить, не изменится
+func (r *ScheduledRepository) updatePending(ctx context.Context, id uint, values map[string]any, out *models.ScheduledMessage) error {
+	db := r.db.WithContext(ctx)
+	res := db.Model(&models.ScheduledMessage{}).
+		Where("id = ? AND status = ?", id, models.ScheduledPending).
+		Updates(values)
+	if res.Error != nil {
+		return res.Error
+	}
+	err := db.First(out, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotPending
+	}
+	return nil
+}
+
+// Claim захватывает пачку сообщений, которые пора отправить
+func (r *ScheduledRepository) Claim(ctx context.Context, holder string, now time.Time, lease time.Duration, limit int) ([]models.ScheduledMessage, error) {
+	ctx, span := tracer.Start(ctx, "ScheduledRepository.Claim")
+	defer span.End()
+
+	now = now.Local()
+	db := r.db.WithContext(ctx)
+	due := db.Model(&models.ScheduledMessage{}).
+		Select("id").
+		Where("(status = ? AND send_at <= ? AND (locked_until IS NULL OR locked_until <= ?)) OR (status = ? AND locked_until <= ?)",
+			models.ScheduledPending, now, now, models.ScheduledSending, now).
+		Order("send_at, id").
+		Limit(limit)
+	// PostgreSQL: строки, которые уже захватывает другой инстанс, пропускаются, а не ждут его COMMIT
+	// SQLite выполняет запись целиком под блокировкой базы, FOR UPDATE там нет и не нужен
+	if db.Dialector.Name() == "postgres" {
+		due = due.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+	}
+
+	// Один UPDATE ... WHERE id IN (SELECT ... FOR UPDATE SKIP LOCKED) RETURNING:
+	// строка достается только тому, чей запрос ее обновил
+	var claimed []models.ScheduledMessage
+	err := db.Model(&claimed).
+		Clauses(clause.Returning{}).
+		Where("id IN (?)", due).
+		Updates(map[string]any{
+			"status":       models.ScheduledSending,
+			"locked_by":    holder,
+			"locked_until": now.Add(lease),
+			"updated_at":   now,
+		}).Error
+	if err != nil {
+		return nil, recordError(ctx, span, err)
+	}
+	// RETURNING не гарантирует порядок строк
+	sort.Slice(claimed, func(i, j int) bool {
+		if !claimed[i].SendAt.Equal(claimed[j].SendAt) {
+			return claimed[i].SendAt.Before(claimed[j].SendAt)
+		}
+		return claimed[i].ID < claimed[j].ID
+	})
+	span.SetAttributes(attribute.Int("scheduled.claimed", len(claimed)))
+	return claimed, nil
+}
+
+// MarkFailed записывает неудачную попытку отправки
+func (r *ScheduledRepository) MarkFailed(ctx context.Context, id uint, holder, reason string, retryAt time.Time) error {
+	ctx, span := tracer.Start(ctx, "ScheduledRepository.MarkFailed")
+	defer span.End()
+
+	values := map[string]any{
+		"attempts":     gorm.Expr("attempts + 1"),
+		"last_error":   reason,
+		"locked_by":    "",
+		"locked_until": nil,
+		"updated_at":   time.Now(),
+	}
+	if retryAt.IsZero() {
+		values["status"] = models.ScheduledFailed
+	} else {
+		values["status"] = models.ScheduledPending
+		values["locked_until"] = retryAt.Local()
+	}
+	res := r.db.WithContext(ctx).Model(&models.ScheduledMessage{}).
+		Where("id = ? AND status = ? AND locked_by = ?", id, models.ScheduledSending, holder).
+		Updates(values)
+	if res.Error != nil {
+		return recordError(ctx, span, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrClaimLost
+	}
+	return nil
+}
+
+// markScheduledSent отмечает запланированное сообщение отправленным в транзакции tx
+// вместе с созданием сообщения; ErrClaimLost откатывает транзакцию
+func markScheduledSent(tx *gorm.DB, claim *models.ScheduledClaim, messageID uint) error {
+	res := tx.Model(&models.ScheduledMessage{}).
+		Where("id = ? AND status = ? AND locked_by = ?", claim.ID, models.ScheduledSending, claim.Holder).
+		Updates(map[string]any{
+			"status":       models.ScheduledSent,
+			"message_id":   messageID,
+			"locked_by":    "",
+			"locked_until": nil,
+			"updated_at":   time.Now(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrClaimLost
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-chat-app/internal/models"
+)
+
+// ErrNotFound возвращается, когда запись не найдена (или мягко удалена)
+var ErrNotFound = errors.New("запись не найдена")
+
+// ErrNotPending возвращается при изменении запланированного сообщения, которое уже
+// отправляется, отправлено, отменено или не удалось отправить
+var ErrNotPending = errors.New("запланированное сообщение уже не ожидает отправки")
+
+// ErrClaimLost возвращается MessageStore.Create, если захват запланированного сообщения
+// (models.Message.Scheduled) больше не принадлежит обработчику: сообщение не сохраняется
+var ErrClaimLost = errors.New("захват запланированного сообщения потерян")
+
+// ErrPreviewClaimLost возвращается LinkPreviewStore.Complete и MarkFailed, если захват
+// превью больше не принадлежит обработчику (истек и перешел к другому)
+var ErrPreviewClaimLost = errors.New("захват превью ссылки потерян")
+
+// ErrAlreadyPinned возвращается PinStore.Pin, если сообщение уже закреплено в чате
+var ErrAlreadyPinned = errors.New("сообщение уже закреплено")
+
+// ErrPinLimit возвращается PinStore.Pin, если в чате уже максимум закрепленных сообщений
+var ErrPinLimit = errors.New("достигнут предел закрепленных сообщений чата")
+
+// ChatStore - хранилище чатов
+// Реализации: ChatRepository (GORM) и memory.ChatStore (в памяти, для тестов)
+// Общие требования проверяются набором тестов repotest.RunChatStoreTests
+type ChatStore interface {
+	// Create сохраняет чат и заполняет ID и CreatedAt
+	Create(ctx context.Context, chat *models.Chat) error
+	// GetByID возвращает чат или ErrNotFound, если чата нет или он удален
+	GetByID(ctx context.Context, id uint) (*models.Chat, error)
+	// List возвращает не больше limit чатов с ID больше afterID в порядке ID (удаленные пропускаются)
+	// Постраничный обход: afterID следующей страницы - ID последнего чата предыдущей
+	List(ctx context.Context, afterID uint, limit int) ([]models.Chat, error)
+	// Update сохраняет изменяемые поля чата (title и настройки)
+	// Возвращает ErrNotFound, если чата нет или он удален
+	Update(ctx context.Context, chat *models.Chat) error
+	// Delete мягко удаляет чат (заполняет DeletedAt), удаление несуществующего чата - не ошибка
+	// В той же транзакции пишет в outbox событие chat.deleted (если чат действительно удален)
+	Delete(ctx context.Context, id uint) error
+}
+
+// MessageStore - хранилище сообщений
+type MessageStore interface {
+	// Create сохраняет сообщение и заполняет ID и CreatedAt
+	// Чат с ChatID должен существовать, иначе возвращается ошибка
+	// В той же транзакции пишет в outbox событие message.created, уведомления об упоминании
+	// для пользователей из message.Notify, а если задан message.Scheduled - отмечает
+	// запланированное сообщение отправленным (ErrClaimLost, если захват уже не его)
+	Create(ctx context.Context, message *models.Message) error
+	// CreateBatch сохраняет сообщения одной вставкой и заполняет их ID (в порядке слайса)
+	// Заданный CreatedAt сохраняется как есть (нулевой - текущее время): так переносится история
+	// Вставка атомарна: при ошибке (например, нет чата) не сохраняется ни одно сообщение
+	// События в outbox не пишутся - импорт истории не означает новых сообщений
+	CreateBatch(ctx context.Context, messages []models.Message) error
+	// GetLastMessagesByChatID возвращает не больше limit последних сообщений чата,
+	// новые первые (по created_at, при равенстве - по id)
+	// Истекшие исчезающие сообщения (expires_at <= сейчас) не возвращаются, даже если очистка их еще не удалила
+	GetLastMessagesByChatID(ctx context.Context, chatID uint, limit int) ([]models.Message, error)
+	// ListRange возвращает не больше limit сообщений чата в хронологическом порядке
+	// (по created_at, при равенстве - по id) в пределах r; истекшие сообщения пропускаются
+	ListRange(ctx context.Context, chatID uint, r MessageRange, limit int) ([]models.Message, error)
+	// DeleteBefore удаляет не больше limit самых старых сообщений чата с created_at < before
+	// и возвращает количество удаленных. Очистка вызывает его пачками, пока не вернется
+	// меньше limit: одно большое удаление надолго заблокировало бы таблицу messages
+	// События в outbox не пишутся - это плановая очистка, а не действие пользователя
+	DeleteBefore(ctx context.Context, chatID uint, before time.Time, limit int) (int64, error)
+	// CountBefore возвращает количество сообщений чата с created_at < before
+	// (пробный запуск очистки: сколько было бы удалено)
+	CountBefore(ctx context.Context, chatID uint, before time.Time) (int64, error)
+	// DeleteExpired удаляет не больше limit исчезающих сообщений с expires_at <= now
+	// и возвращает удаленные (заполнены ID, ChatID и ExpiresAt, текст не читается)
+	// Каждое сообщение возвращается ровно одному вызову, даже если очистка идет на нескольких инстансах
+	// В той же транзакции пишет в outbox события message.deleted
+	DeleteExpired(ctx context.Context, now time.Time, limit int) ([]models.Message, error)
+	// GetByID возвращает сообщение id чата chatID
+	// ErrNotFound - сообщения нет, оно в другом чате или истекло (даже если очистка его еще не удалила)
+	GetByID(ctx context.Context, chatID, id uint) (*models.Message, error)
+	// ExistingIDs возвращает ID из ids, сообщения с которыми еще можно прочитать: не удалены,
+	// не истекли и их чат не удален (порядок не определен). Так проверяются оригиналы пересланных сообщений
+	ExistingIDs(ctx context.Context, ids []uint) ([]uint, error)
+	// Participants возвращает авторов сообщений чата без повторов и без пустого автора
+	// (кому уведомление об упоминании @all), порядок не определен
+	Participants(ctx context.Context, chatID uint) ([]string, error)
+}
+
+// MessageRange - диапазон и курсор хронологического чтения сообщений
+// Историю читают пачками: курсор следующей пачки - последнее сообщение предыдущей (MessageRange.After)
+type MessageRange struct {
+	From time.Time // created_at >= From (нулевое - без ограничения)
+	To   time.Time // created_at < To (нулевое - без ограничения)
+
+	// Курсор: сообщения строго после (AfterCreatedAt, AfterID); AfterID == 0 - с начала диапазона
+	AfterCreatedAt time.Time
+	AfterID        uint
+}
+
+// After возвращает диапазон, продолжающийся после сообщения last
+func (r MessageRange) After(last models.Message) MessageRange {
+	r.AfterCreatedAt = last.CreatedAt
+	r.AfterID = last.ID
+	return r
+}
+
+// IdempotencyStore - хранилище ключей идемпотентности
+type IdempotencyStore interface {
+	// Claim резервирует ключ (StatusCode == 0 - "выполняется")
+	// Если ключ уже есть и не истек, возвращает сохраненную запись и claimed == false
+	// Истекший ключ заменяется новым
+	Claim(ctx context.Context, key *models.IdempotencyKey) (existing *models.IdempotencyKey, claimed bool, err error)
+	// Complete сохраняет ответ для зарезервированного ключа
+	Complete(ctx context.Context, scope, key string, statusCode int, contentType string, body []byte) error
+	// Release удаляет резерв (например, после ошибки сервера, чтобы клиент мог повторить запрос)
+	Release(ctx context.Context, scope, key string) error
+	// DeleteExpired удаляет ключи, истекшие к моменту now, и возвращает их количество
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// OutboxStore - чтение и отметка исходящих событий (transactional outbox)
+type OutboxStore interface {
+	// AcquireLease захватывает аренду name для holder или продлевает ее на ttl
+	// Возвращает false, если аренда у другого владельца и еще не истекла
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	// Pending возвращает не больше limit недоставленных и не брошенных событий в порядке id
+	// События чатов, где недоставленное событие ждет повтора (NextAttemptAt в будущем), пропускаются
+	// целиком: порядок в чате сохраняется, а чат со сбоями не занимает пакеты других чатов
+	Pending(ctx context.Context, limit int) ([]models.OutboxEvent, error)
+	// MarkDelivered отмечает события доставленными
+	MarkDelivered(ctx context.Context, ids []uint, at time.Time) error
+	// MarkFailed увеличивает счетчик неудачных попыток события и сохраняет причину
+	// Событие повторяется не раньше retryAt; нулевой retryAt - попытки кончились, событие брошено (FailedAt)
+	MarkFailed(ctx context.Context, id uint, reason string, retryAt time.Time) error
+	// DeleteDelivered удаляет события, доставленные или брошенные раньше before, и возвращает их количество
+	DeleteDelivered(ctx context.Context, before time.Time) (int64, error)
+}
+
+// ScheduledStore - хранилище запланированных сообщений
+type ScheduledStore interface {
+	// Create сохраняет запланированное сообщение (status = pending) и заполняет ID и временные метки
+	// Чат с ChatID должен существовать, иначе возвращается ошибка
+	Create(ctx context.Context, message *models.ScheduledMessage) error
+	// GetByID возвращает запланированное сообщение или ErrNotFound
+	GetByID(ctx context.Context, id uint) (*models.ScheduledMessage, error)
+	// ListByChat возвращает не больше limit сообщений чата в порядке send_at (при равенстве - id)
+	// status - фильтр по статусу, "" - все
+	ListByChat(ctx context.Context, chatID uint, status string, limit int) ([]models.ScheduledMessage, error)
+	// Update сохраняет текст и время отправки ожидающего сообщения
+	// ErrNotFound - сообщения нет, ErrNotPending - оно уже не ожидает отправки
+	Update(ctx context.Context, message *models.ScheduledMessage) error
+	// Cancel отменяет ожидающее сообщение и возвращает его с новым статусом
+	// ErrNotFound - сообщения нет, ErrNotPending - оно уже не ожидает отправки
+	Cancel(ctx context.Context, id uint) (*models.ScheduledMessage, error)
+	// Claim захватывает для holder на lease не больше limit сообщений, которые пора отправить:
+	// ожидающие с send_at <= now (и наступившим временем повтора) и захваченные другим
+	// обработчиком, чей захват истек. Возвращает их со status = sending в порядке send_at
+	// Одно сообщение не достается двум обработчикам одновременно
+	Claim(ctx context.Context, holder string, now time.Time, lease time.Duration, limit int) ([]models.ScheduledMessage, error)
+	// MarkFailed записывает неудачную попытку сообщения, захваченного holder:
+	// retryAt - время следующей попытки (status = pending), нулевое - попыток больше не будет (status = failed)
+	// ErrClaimLost, если захват уже не принадлежит holder
+	MarkFailed(ctx context.Context, id uint, holder, reason string, retryAt time.Time) error
+}
+
+// PinStore - хранилище закрепленных сообщений
+type PinStore interface {
+	// Pin закрепляет сообщение pin.MessageID в чате pin.ChatID и заполняет PinnedAt и Message
+	// ErrNotFound - чата нет (или он удален) либо сообщения нет в этом чате (или оно истекло),
+	// ErrAlreadyPinned - сообщение уже закреплено, ErrPinLimit - в чате уже limit закрепленных
+	// notice (если не nil) - служебное сообщение о закреплении: сохраняется в той же транзакции
+	// вместе с событием outbox message.created, как в MessageStore.Create
+	Pin(ctx context.Context, pin *models.Pin, limit int, notice *models.Message) error
+	// Unpin открепляет сообщение, ErrNotFound - оно не закреплено в чате
+	Unpin(ctx context.Context, chatID, messageID uint) error
+	// List возвращает закрепленные сообщения чата вместе с сообщениями (Message),
+	// последние закрепленные первыми (по pinned_at, при равенстве - по message_id)
+	// Истекшие исчезающие сообщения пропускаются, даже если очистка их еще не удалила
+	List(ctx context.Context, chatID uint) ([]models.Pin, error)
+}
+
+// MentionStore - уведомления об упоминаниях (создает MessageStore.Create по message.Notify)
+type MentionStore interface {
+	// ListByUser возвращает не больше limit уведомлений пользователя вместе с сообщениями (Message),
+	// новые первыми (по id); beforeID > 0 - только с id < beforeID (следующая страница),
+	// unreadOnly - только непрочитанные. Уведомления об истекших сообщениях и из удаленных чатов пропускаются
+	ListByUser(ctx context.Context, userID string, unreadOnly bool, beforeID uint, limit int) ([]models.MessageMention, error)
+	// CountUnread возвращает количество непрочитанных уведомлений пользователя (по тем же правилам, что ListByUser)
+	CountUnread(ctx context.Context, userID string) (int64, error)
+	// MarkRead отмечает прочитанными в момент at непрочитанные уведомления пользователя с ID из ids
+	// (пустой ids - все) и возвращает количество отмеченных; чужие и неизвестные ID пропускаются
+	MarkRead(ctx context.Context, userID string, ids []uint, at time.Time) (int64, error)
+}
+
+// PollStore - голоса в опросах (сами опросы хранятся в сообщениях, см. models.Message.Poll)
+type PollStore interface {
+	// Vote заменяет голоса пользователя в опросе messageID вариантами options (номера без повторов)
+	// ErrNotFound - сообщения нет (удалено); проверка вариантов - дело вызывающего
+	Vote(ctx context.Context, messageID uint, userID string, options []int, at time.Time) error
+	// Retract удаляет голоса пользователя в опросе; если их нет - не ошибка
+	Retract(ctx context.Context, messageID uint, userID string) error
+	// Votes возвращает голоса в опросах с ID из ids в порядке голосования (по created_at,
+	// при равенстве - по пользователю и варианту)
+	Votes(ctx context.Context, ids []uint) ([]models.PollVote, error)
+}
+
+// LinkPreviewStore - превью ссылок (кэш по адресу) и ссылки сообщений на них
+type LinkPreviewStore interface {
+	// Attach связывает сообщение с превью адресов urls (без повторов, в порядке ссылок в тексте)
+	// и возвращает превью в том же порядке. Адрес без превью ставится в очередь (status = pending),
+	// а загруженный (ready или failed) раньше staleBefore - снова в очередь: кэш устарел
+	Attach(ctx context.Context, messageID uint, urls []string, staleBefore time.Time) ([]models.LinkPreview, error)
+	// ListByMessages возвращает превью сообщений с ID из ids в порядке ссылок в тексте
+	// Сообщения без ссылок в результат не попадают
+	ListByMessages(ctx context.Context, ids []uint) (map[uint][]models.LinkPreview, error)
+	// Claim захватывает для holder на lease не больше limit превью, которые пора загрузить:
+	// ожидающие (с наступившим временем повтора) и захваченные другим обработчиком, чей захват
+	// истек. Возвращает их со status = fetching в порядке queued_at
+	// Одно превью не достается двум обработчикам одновременно
+	Claim(ctx context.Context, holder string, now time.Time, lease time.Duration, limit int) ([]models.LinkPreview, error)
+	// Complete сохраняет загруженные метаданные превью, захваченного holder
+	// (status = ready, fetched_at = at); ErrPreviewClaimLost, если захват уже не его
+	Complete(ctx context.Context, preview *models.LinkPreview, holder string, at time.Time) error
+	// MarkFailed записывает неудачную попытку загрузки превью, захваченного holder:
+	// retryAt - время следующей попытки (status = pending), нулевое - попыток больше не будет
+	// (status = failed, fetched_at = сейчас); ErrPreviewClaimLost, если захват уже не его
+	MarkFailed(ctx context.Context, id uint, holder, reason string, retryAt time.Time) error
+	// Messages возвращает не больше limit сообщений (заполнены ID и ChatID), получивших ссылку
+	// на превью не раньше since, новые первыми - кому сообщить о загрузке
+	Messages(ctx context.Context, previewID uint, since time.Time, limit int) ([]models.Message, error)
+}
+
+// Проверка на этапе компиляции, что GORM репозитории реализуют интерфейсы
+var (
+	_ ChatStore        = (*ChatRepository)(nil)
+	_ MessageStore     = (*MessageRepository)(nil)
+	_ IdempotencyStore = (*IdempotencyRepository)(nil)
+	_ OutboxStore      = (*OutboxRepository)(nil)
+	_ ScheduledStore   = (*ScheduledRepository)(nil)
+	_ PinStore         = (*PinRepository)(nil)
+	_ MentionStore     = (*MentionRepository)(nil)
+	_ LinkPreviewStore = (*LinkPreviewRepository)(nil)
+	_ PollStore        = (*PollRepository)(nil)
+)
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// tracer создает спаны слоя репозиториев
+var tracer = otel.Tracer("go-chat-app/internal/repository")
+
+// recordError помечает спан как ошибочный, пишет ошибку в лог и возвращает ее же
+// gorm.ErrRecordNotFound ошибкой не считается - это штатный ответ "не найдено"
+func recordError(ctx context.Context, span trace.Span, err error) error {
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		slog.DebugContext(ctx, "ошибка запроса к БД", slog.Any("error", err))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
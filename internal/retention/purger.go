@@ -0,0 +1,232 @@
+// Package retention - плановая очистка сообщений по сроку хранения чатов
+//
+// У каждого чата есть срок хранения (models.Chat.RetentionDays): nil - срок по умолчанию
+// сервера, 0 - хранить всегда. Purger периодически обходит чаты и удаляет сообщения
+// старше срока пачками по BatchSize: одно большое удаление держало бы блокировки на
+// messages все время выполнения и мешало бы отправке сообщений. В пробном режиме
+// (DryRun) сообщения только считаются - отчет показывает, что было бы удалено
+//
+// Счетчики публикуются через expvar (переменная "retention", GET /admin/metrics)
+package retention
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go-chat-app/internal/lease"
+	"go-chat-app/internal/repository"
+)
+
+// leaseName - имя аренды очистки в таблице outbox_leases
+const leaseName = "retention"
+
+// chatPageSize - сколько чатов читать за раз при обходе
+const chatPageSize = 100
+
+// metrics - счетчики очистки:
+//
+//	runs              - завершенных проходов
+//	errors            - проходов, прерванных ошибкой
+//	purged_messages   - удалено сообщений всего
+//	dry_run_messages  - сообщений, которые удалил бы пробный запуск
+//	last_run_unix     - время окончания последнего прохода
+//	last_run_messages - удалено (или посчитано) за последний проход
+var (
+	metrics         = expvar.NewMap("retention")
+	lastRunUnix     = new(expvar.Int)
+	lastRunMessages = new(expvar.Int)
+)
+
+func init() {
+	metrics.Set("last_run_unix", lastRunUnix)
+	metrics.Set("last_run_messages", lastRunMessages)
+}
+
+// Config - настройки очистки
+type Config struct {
+	DefaultDays int           // срок хранения для чатов без своего (0 - хранить всегда)
+	BatchSize   int           // сообщений за одно удаление
+	BatchPause  time.Duration // пауза между пачками, чтобы не занимать БД целиком
+	Interval    time.Duration // пауза между проходами
+	DryRun      bool          // только считать сообщения, ничего не удаляя
+}
+
+// withDefaults подставляет значения по умолчанию для незаданных полей
+func (c Config) withDefaults() Config {
+	if c.BatchSize <= 0 {
+		c.BatchSize = 1000
+	}
+	if c.BatchPause <= 0 {
+		c.BatchPause = 50 * time.Millisecond
+	}
+	if c.Interval <= 0 {
+		c.Interval = time.Hour
+	}
+	return c
+}
+
+// Leaser - аренда фоновой задачи (repository.OutboxStore)
+// На нескольких инстансах очистку выполняет только владелец аренды
+type Leaser interface {
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+}
+
+// ChatReport - результат очистки одного чата
+type ChatReport struct {
+	ChatID        uint      `json:"chat_id"`
+	Title         string    `json:"title"`
+	RetentionDays int       `json:"retention_days"` // действующий срок с учетом значения по умолчанию
+	Before        time.Time `json:"before"`         // удалялись сообщения старше этого момента
+	Messages      int64     `json:"messages"`       // удалено (при DryRun - было бы удалено)
+}
+
+// Report - результат прохода очистки
+// В Chats попадают только чаты, в которых нашлись устаревшие сообщения
+type Report struct {
+	DryRun   bool         `json:"dry_run"`
+	Chats    []ChatReport `json:"chats"`
+	Messages int64        `json:"messages"`
+}
+
+// Purger удаляет сообщения старше срока хранения чата
+type Purger struct {
+	chats    repository.ChatStore
+	messages repository.MessageStore
+	leases   Leaser // nil - без аренды (разовый запуск из командной строки)
+	cfg      Config
+	holder   string
+	now      func() time.Time
+}
+
+// NewPurger создает очистку
+// leases может быть nil: тогда проход выполняется без аренды
+func NewPurger(chats repository.ChatStore, messages repository.MessageStore, leases Leaser, cfg Config) *Purger {
+	return &Purger{
+		chats:    chats,
+		messages: messages,
+		leases:   leases,
+		cfg:      cfg.withDefaults(),
+		holder:   lease.HolderID(),
+		now:      time.Now,
+	}
+}
+
+// Run выполняет очистку сразу и затем каждые Interval до отмены ctx
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		report, err := p.RunOnce(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			slog.WarnContext(ctx, "ошибка очистки старых сообщений", slog.Any("error", err))
+		case report != nil && report.Messages > 0:
+			slog.InfoContext(ctx, "очистка старых сообщений завершена",
+				slog.Bool("dry_run", report.DryRun),
+				slog.Int("chats", len(report.Chats)),
+				slog.Int64("messages", report.Messages),
+			)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce выполняет один проход по всем чатам
+// Возвращает nil отчет без ошибки, если аренда у другого инстанса
+// При ошибке отчет содержит то, что успело удалиться
+func (p *Purger) RunOnce(ctx context.Context) (*Report, error) {
+	if p.leases != nil {
+		// Аренда на весь интервал: другой инстанс возьмет очистку, только если этот пропал
+		ok, err := p.leases.AcquireLease(ctx, leaseName, p.holder, p.cfg.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("аренда очистки: %w", err)
+		}
+		if !ok {
+			return nil, nil
+		}
+	}
+
+	report := &Report{DryRun: p.cfg.DryRun, Chats: []ChatReport{}}
+	err := p.purgeAll(ctx, report)
+	lastRunUnix.Set(p.now().Unix())
+	lastRunMessages.Set(report.Messages)
+	if err != nil {
+		metrics.Add("errors", 1)
+		return report, err
+	}
+	metrics.Add("runs", 1)
+	return report, nil
+}
+
+// purgeAll обходит чаты по страницам и очищает каждый
+func (p *Purger) purgeAll(ctx context.Context, report *Report) error {
+	now := p.now()
+	var afterID uint
+	for {
+		chats, err := p.chats.List(ctx, afterID, chatPageSize)
+		if err != nil {
+			return fmt.Errorf("чтение чатов: %w", err)
+		}
+		for _, chat := range chats {
+			days := p.cfg.DefaultDays
+			if chat.RetentionDays != nil {
+				days = *chat.RetentionDays
+			}
+			if days <= 0 {
+				continue // хранить всегда
+			}
+
+			cr := ChatReport{ChatID: chat.ID, Title: chat.Title, RetentionDays: days, Before: now.AddDate(0, 0, -days)}
+			err := p.purgeChat(ctx, &cr)
+			if cr.Messages > 0 {
+				report.Chats = append(report.Chats, cr)
+				report.Messages += cr.Messages
+			}
+			if err != nil {
+				return fmt.Errorf("очистка чата %d: %w", chat.ID, err)
+			}
+		}
+		if len(chats) < chatPageSize {
+			return nil
+		}
+		afterID = chats[len(chats)-1].ID
+	}
+}
+
+// purgeChat удаляет сообщения чата старше cr.Before пачками (или считает их при DryRun)
+func (p *Purger) purgeChat(ctx context.Context, cr *ChatReport) error {
+	if p.cfg.DryRun {
+		count, err := p.messages.CountBefore(ctx, cr.ChatID, cr.Before)
+		if err != nil {
+			return err
+		}
+		cr.Messages = count
+		metrics.Add("dry_run_messages", count)
+		return nil
+	}
+
+	for {
+		deleted, err := p.messages.DeleteBefore(ctx, cr.ChatID, cr.Before, p.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+		cr.Messages += deleted
+		metrics.Add("purged_messages", deleted)
+		if deleted < int64(p.cfg.BatchSize) {
+			return nil
+		}
+		// Между пачками даем пройти запросам, ждущим блокировок на messages
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.cfg.BatchPause):
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package retention
+
+import (
+	"context"
+	"expvar"
+	"testing"
+	"time"
+
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+	"go-chat-app/internal/repository/memory"
+)
+
+// seed создает чат со сроком хранения days (nil - по умолчанию)
+// и по одному сообщению возрастом 1, 10 и 100 дней
+func seed(t *testing.T, db *memory.DB, now time.Time, days *int) uint {
+	t.Helper()
+	ctx := context.Background()
+	chat := &models.Chat{Title: "чат", RetentionDays: days}
+	if err := db.Chats().Create(ctx, chat); err != nil {
+		t.Fatal(err)
+	}
+	var batch []models.Message
+	for _, age := range []int{100, 10, 1} {
+		batch = append(batch, models.Message{ChatID: chat.ID, Text: "x", CreatedAt: now.AddDate(0, 0, -age)})
+	}
+	if err := db.Messages().CreateBatch(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+	return chat.ID
+}
+
+// counter читает счетчик из expvar
+func counter(name string) int64 {
+	if v, ok := metrics.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+// TestPurge проверяет сроки хранения чатов, пачки и пробный запуск
+func TestPurge(t *testing.T) {
+	db := memory.New()
+	ctx := context.Background()
+	now := time.Now()
+	week, forever := 7, 0
+
+	byDefault := seed(t, db, now, nil) // срок сервера - 30 дней: удаляется 1 сообщение
+	weekly := seed(t, db, now, &week)  // 7 дней: удаляются 2 сообщения
+	keep := seed(t, db, now, &forever) // хранить всегда
+	deleted := seed(t, db, now, &week) // удаленный чат не обходится
+	db.Chats().Delete(ctx, deleted)
+
+	remaining := func(chatID uint) int {
+		messages, _ := db.Messages().ListRange(ctx, chatID, repository.MessageRange{}, 10)
+		return len(messages)
+	}
+
+	// Пробный запуск только считает
+	dryRunBefore := counter("dry_run_messages")
+	report, err := NewPurger(db.Chats(), db.Messages(), nil, Config{DefaultDays: 30, DryRun: true}).RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if !report.DryRun || report.Messages != 3 || len(report.Chats) != 2 {
+		t.Errorf("Неверный отчет пробного запуска: %+v", report)
+	}
+	if remaining(byDefault) != 3 || remaining(weekly) != 3 {
+		t.Error("Пробный запуск не должен удалять сообщения")
+	}
+	if got := counter("dry_run_messages") - dryRunBefore; got != 3 {
+		t.Errorf("dry_run_messages: ожидалось +3, получено %+d", got)
+	}
+
+	// Пачка по одному сообщению: удаление в недельном чате идет в несколько запросов
+	purgedBefore := counter("purged_messages")
+	purger := NewPurger(db.Chats(), db.Messages(), nil, Config{DefaultDays: 30, BatchSize: 1, BatchPause: time.Millisecond})
+	report, err = purger.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if report.Messages != 3 || report.Chats[0].ChatID != byDefault || report.Chats[0].RetentionDays != 30 ||
+		report.Chats[1].ChatID != weekly || report.Chats[1].Messages != 2 {
+		t.Errorf("Неверный отчет: %+v", report)
+	}
+	for chatID, want := range map[uint]int{byDefault: 2, weekly: 1, keep: 3, deleted: 3} {
+		if got := remaining(chatID); got != want {
+			t.Errorf("Чат %d: ожидалось %d сообщений, осталось %d", chatID, want, got)
+		}
+	}
+	if got := counter("purged_messages") - purgedBefore; got != 3 {
+		t.Errorf("purged_messages: ожидалось +3, получено %+d", got)
+	}
+	if counter("last_run_messages") != 3 || counter("last_run_unix") == 0 {
+		t.Errorf("Не обновлены счетчики последнего прохода: %s", metrics.String())
+	}
+
+	// Повторный проход ничего не находит
+	if report, _ := purger.RunOnce(ctx); report.Messages != 0 || len(report.Chats) != 0 {
+		t.Errorf("Повторный проход: %+v", report)
+	}
+}
+
+// TestPurgeLease проверяет, что при нескольких инстансах очистку выполняет один
+func TestPurgeLease(t *testing.T) {
+	db := memory.New()
+	ctx := context.Background()
+	seed(t, db, time.Now(), nil)
+
+	cfg := Config{DefaultDays: 30, Interval: time.Hour}
+	first := NewPurger(db.Chats(), db.Messages(), db.Outbox(), cfg)
+	second := NewPurger(db.Chats(), db.Messages(), db.Outbox(), cfg)
+
+	if report, err := first.RunOnce(ctx); err != nil || report == nil || report.Messages != 1 {
+		t.Fatalf("Первый инстанс: %+v, %v", report, err)
+	}
+	if report, err := second.RunOnce(ctx); err != nil || report != nil {
+		t.Errorf("Аренда у первого инстанса, второй не должен чистить: %+v, %v", report, err)
+	}
+}
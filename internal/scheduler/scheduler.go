@@ -0,0 +1,186 @@
+// Package scheduler - отправка запланированных сообщений
+//
+// Worker периодически захватывает запланированные сообщения, время которых наступило
+// (repository.ScheduledStore.Claim), и отправляет их через ChatService.SendMessage -
+// с проверками, outbox и событиями потока, как обычные сообщения. Захват строк идет
+// с FOR UPDATE SKIP LOCKED, поэтому на нескольких инстансах сообщение достается одному
+// обработчику. Сообщение сохраняется в одной транзакции с отметкой "отправлено" и только
+// если захват все еще принадлежит этому инстансу: если обработчик упадет после отправки,
+// повтор не создаст второе сообщение, а если захват истек и перешел к другому инстансу,
+// отправит только один из них. Так каждое сообщение отправляется ровно один раз
+//
+// Неудачные попытки записываются (attempts, last_error) и повторяются с растущей паузой;
+// после MaxAttempts или если чат удален сообщение получает статус failed
+//
+// Счетчики публикуются через expvar (переменная "scheduler", GET /admin/metrics)
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go-chat-app/internal/auth"
+	"go-chat-app/internal/db/service"
+	"go-chat-app/internal/lease"
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+)
+
+// metrics - счетчики отправки:
+//
+//	sent       - отправлено сообщений
+//	retried    - неудачных попыток, после которых будет повтор
+//	failed     - сообщений, отправка которых прекращена (status = failed)
+//	claim_lost - захватов, перешедших к другому обработчику до отправки
+//	errors     - проходов, прерванных ошибкой хранилища
+var metrics = expvar.NewMap("scheduler")
+
+// Config - настройки отправки
+type Config struct {
+	Interval    time.Duration // пауза между проходами, если наступивших сообщений нет
+	BatchSize   int           // сообщений за один захват
+	Lease       time.Duration // захват: если инстанс пропал, через Lease сообщение отправит другой
+	MaxAttempts int           // попыток до статуса failed
+	RetryDelay  time.Duration // пауза перед первым повтором, дальше удваивается (не больше часа)
+}
+
+// withDefaults подставляет значения по умолчанию для незаданных полей
+func (c Config) withDefaults() Config {
+	if c.Interval <= 0 {
+		c.Interval = time.Second
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.Lease <= 0 {
+		c.Lease = time.Minute
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 5
+	}
+	if c.RetryDelay <= 0 {
+		c.RetryDelay = 30 * time.Second
+	}
+	return c
+}
+
+// Sender отправляет сообщение (service.ChatService)
+type Sender interface {
+	SendMessage(ctx context.Context, chatID uint, text string, opts ...service.MessageOption) (*models.Message, error)
+}
+
+// Worker отправляет запланированные сообщения
+type Worker struct {
+	store  repository.ScheduledStore
+	sender Sender
+	cfg    Config
+	holder string // идентификатор этого инстанса в захватах
+	now    func() time.Time
+}
+
+// NewWorker создает обработчик запланированных сообщений
+func NewWorker(store repository.ScheduledStore, sender Sender, cfg Config) *Worker {
+	return &Worker{
+		store:  store,
+		sender: sender,
+		cfg:    cfg.withDefaults(),
+		holder: lease.HolderID(),
+		now:    time.Now,
+	}
+}
+
+// Run отправляет наступившие сообщения до отмены ctx
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		claimed, err := w.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "ошибка отправки запланированных сообщений", slog.Any("error", err))
+		}
+
+		// Полный захват - скорее всего, наступивших сообщений больше: продолжаем без паузы
+		if err == nil && claimed == w.cfg.BatchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce захватывает до BatchSize наступивших сообщений и отправляет их
+// Возвращает количество захваченных сообщений (отправленных и неудачных)
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	claimed, err := w.store.Claim(ctx, w.holder, w.now(), w.cfg.Lease, w.cfg.BatchSize)
+	if err != nil {
+		metrics.Add("errors", 1)
+		return 0, fmt.Errorf("захват запланированных сообщений: %w", err)
+	}
+	for i, m := range claimed {
+		// Остановка сервера не прерывает начатую отправку, но следующие сообщения
+		// остаются захваченными и после Lease достанутся другому инстансу
+		if ctx.Err() != nil {
+			return i, ctx.Err()
+		}
+		w.deliver(context.WithoutCancel(ctx), m)
+	}
+	return len(claimed), nil
+}
+
+// deliver отправляет одно захваченное сообщение и записывает результат
+func (w *Worker) deliver(ctx context.Context, m models.ScheduledMessage) {
+	// Сообщение отправляется от имени того, кто его запланировал
+	if m.AuthorID != "" {
+		ctx = auth.WithUser(ctx, m.AuthorID)
+	}
+	claim := models.ScheduledClaim{ID: m.ID, Holder: w.holder}
+	msg, err := w.sender.SendMessage(ctx, m.ChatID, m.Text, service.WithScheduledClaim(claim))
+	switch {
+	case err == nil:
+		metrics.Add("sent", 1)
+		slog.InfoContext(ctx, "запланированное сообщение отправлено",
+			slog.Uint64("scheduled_id", uint64(m.ID)),
+			slog.Uint64("chat_id", uint64(m.ChatID)),
+			slog.Uint64("message_id", uint64(msg.ID)),
+			slog.Duration("delay", w.now().Sub(m.SendAt)),
+		)
+		return
+	case errors.Is(err, repository.ErrClaimLost):
+		// Захват истек и перешел к другому инстансу (или сообщение уже отправлено) - не наше
+		metrics.Add("claim_lost", 1)
+		slog.WarnContext(ctx, "захват запланированного сообщения потерян", slog.Uint64("scheduled_id", uint64(m.ID)))
+		return
+	}
+
+	// Удаленный чат не вернется, остальные ошибки повторяем с растущей паузой
+	var retryAt time.Time
+	attempt := m.Attempts + 1
+	if !errors.Is(err, service.ErrChatNotFound) && attempt < w.cfg.MaxAttempts {
+		retryAt = w.now().Add(lease.RetryDelay(w.cfg.RetryDelay, attempt))
+	}
+	if retryAt.IsZero() {
+		metrics.Add("failed", 1)
+	} else {
+		metrics.Add("retried", 1)
+	}
+	slog.WarnContext(ctx, "запланированное сообщение не отправлено",
+		slog.Uint64("scheduled_id", uint64(m.ID)),
+		slog.Uint64("chat_id", uint64(m.ChatID)),
+		slog.Int("attempt", attempt),
+		slog.Bool("retry", !retryAt.IsZero()),
+		slog.Any("error", err),
+	)
+	if err := w.store.MarkFailed(ctx, m.ID, w.holder, err.Error(), retryAt); err != nil {
+		slog.WarnContext(ctx, "не удалось записать ошибку отправки", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,132 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go-chat-app/internal/db/service"
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository/memory"
+)
+
+// due создает запланированное сообщение, время которого уже наступило
+func due(t *testing.T, db *memory.DB, chatID uint, text string) *models.ScheduledMessage {
+	t.Helper()
+	m := &models.ScheduledMessage{ChatID: chatID, AuthorID: "alice", Text: text, SendAt: time.Now().Add(-time.Second)}
+	if err := db.Scheduled().Create(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// TestDeliver проверяет отправку, автора и неудачу для удаленного чата
+func TestDeliver(t *testing.T) {
+	db := memory.New()
+	ctx := context.Background()
+	svc := service.NewChatService(db.Chats(), db.Messages())
+	chat, _ := svc.CreateChat(ctx, "Общий")
+	gone, _ := svc.CreateChat(ctx, "Удаленный")
+
+	sent := due(t, db, chat.ID, "стендап через 5 минут")
+	failed := due(t, db, gone.ID, "никто не прочтет")
+	svc.DeleteChat(ctx, gone.ID)
+
+	w := NewWorker(db.Scheduled(), svc, Config{})
+	if n, err := w.RunOnce(ctx); err != nil || n != 2 {
+		t.Fatalf("RunOnce: %d, %v", n, err)
+	}
+
+	messages, _ := db.Messages().GetLastMessagesByChatID(ctx, chat.ID, 10)
+	if len(messages) != 1 || messages[0].Text != "стендап через 5 минут" || messages[0].AuthorID != "alice" {
+		t.Fatalf("Ожидалось одно сообщение от alice, получено %+v", messages)
+	}
+	got, _ := db.Scheduled().GetByID(ctx, sent.ID)
+	if got.Status != models.ScheduledSent || got.MessageID == nil || *got.MessageID != messages[0].ID {
+		t.Errorf("Ожидался статус sent: %+v", got)
+	}
+	got, _ = db.Scheduled().GetByID(ctx, failed.ID)
+	if got.Status != models.ScheduledFailed || got.Attempts != 1 || got.LastError == "" {
+		t.Errorf("Сообщение в удаленный чат должно получить статус failed без повторов: %+v", got)
+	}
+
+	if n, _ := w.RunOnce(ctx); n != 0 {
+		t.Errorf("Повторный проход ничего не должен отправлять, захвачено %d", n)
+	}
+}
+
+// TestExactlyOnce проверяет, что несколько инстансов не отправляют сообщение дважды
+func TestExactlyOnce(t *testing.T) {
+	db := memory.New()
+	ctx := context.Background()
+	svc := service.NewChatService(db.Chats(), db.Messages())
+	chat, _ := svc.CreateChat(ctx, "Общий")
+	const n = 30
+	for i := 0; i < n; i++ {
+		due(t, db, chat.ID, "напоминание")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		w := NewWorker(db.Scheduled(), svc, Config{BatchSize: 4})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if claimed, err := w.RunOnce(ctx); err != nil || claimed == 0 {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if messages, _ := db.Messages().GetLastMessagesByChatID(ctx, chat.ID, 100); len(messages) != n {
+		t.Errorf("Ожидалось %d сообщений, отправлено %d", n, len(messages))
+	}
+}
+
+// TestRetry проверяет повтор после ошибки и статус failed после MaxAttempts
+func TestRetry(t *testing.T) {
+	db := memory.New()
+	ctx := context.Background()
+	chat := &models.Chat{Title: "Общий"}
+	db.Chats().Create(ctx, chat)
+	m := due(t, db, chat.ID, "x")
+
+	broken := senderFunc(func(ctx context.Context, chatID uint, text string, opts ...service.MessageOption) (*models.Message, error) {
+		return nil, errors.New("база недоступна")
+	})
+	w := NewWorker(db.Scheduled(), broken, Config{MaxAttempts: 2, RetryDelay: time.Minute})
+	now := time.Now()
+	w.now = func() time.Time { return now }
+
+	w.RunOnce(ctx)
+	got, _ := db.Scheduled().GetByID(ctx, m.ID)
+	if got.Status != models.ScheduledPending || got.Attempts != 1 || got.LastError != "база недоступна" {
+		t.Fatalf("После первой ошибки ожидался повтор: %+v", got)
+	}
+
+	// До паузы повтора сообщение не захватывается
+	if n, _ := w.RunOnce(ctx); n != 0 {
+		t.Errorf("Повтор раньше RetryDelay: захвачено %d", n)
+	}
+	now = now.Add(2 * time.Minute)
+	if n, _ := w.RunOnce(ctx); n != 1 {
+		t.Fatalf("После RetryDelay ожидался повтор, захвачено %d", n)
+	}
+	got, _ = db.Scheduled().GetByID(ctx, m.ID)
+	if got.Status != models.ScheduledFailed || got.Attempts != 2 {
+		t.Errorf("После MaxAttempts ожидался статус failed: %+v", got)
+	}
+}
+
+// senderFunc позволяет использовать функцию как Sender
+type senderFunc func(ctx context.Context, chatID uint, text string, opts ...service.MessageOption) (*models.Message, error)
+
+// SendMessage вызывает f
+func (f senderFunc) SendMessage(ctx context.Context, chatID uint, text string, opts ...service.MessageOption) (*models.Message, error) {
+	return f(ctx, chatID, text, opts...)
+}
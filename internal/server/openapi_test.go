@@ -0,0 +1,448 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-chat-app/api"
+	"go-chat-app/internal/db/service"
+	"go-chat-app/internal/handler"
+	"go-chat-app/internal/importer"
+	"go-chat-app/internal/ratelimit"
+	"go-chat-app/internal/repository/memory"
+)
+
+// TestOpenAPIResponses выполняет запросы к настоящему роутеру и проверяет, что каждый
+// ответ описан в api/openapi.json: статус, Content-Type и JSON тело по схеме
+// Заодно проверяется, что тест вызвал все операции спецификации
+func TestOpenAPIResponses(t *testing.T) {
+	spec := loadSpec(t)
+	covered := make(map[string]bool)
+
+	db := memory.New()
+	svc := service.NewChatService(db.Chats(), db.Messages(), service.WithScheduledStore(db.Scheduled()), service.WithPins(db.Pins(), 1), service.WithMentions(db.Mentions()), service.WithPolls(db.Polls()))
+	health := handler.NewHealthHandler(time.Second)
+	router := NewRouter(svc, health, Options{
+		UserHeader:       "X-User-ID",
+		IdempotencyStore: db.Idempotency(),
+		RateLimitStore:   ratelimit.NewMemoryStore(),
+		RateLimits:       ratelimit.Rules{Reads: ratelimit.Limit{Requests: 1, Period: time.Hour, Burst: 12}},
+		AdminToken:       "openapi-admin-token",
+		Importer:         importer.New(db.Chats(), db.Messages()),
+		ImportMaxBytes:   1 << 20,
+	})
+
+	do := func(method, path, body string, want int, headers ...string) {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("%s %s: ожидался статус %d, получен %d (%s)", method, path, want, rr.Code, rr.Body.String())
+		}
+		spec.checkResponse(t, method, req.URL.Path, rr, covered)
+	}
+
+	// Чаты
+	do("POST", "/chats", `{"title":"Общий"}`, 201, "Idempotency-Key", "k1", "X-User-ID", "alice")
+	do("POST", "/chats", `{"title":"Общий"}`, 201, "Idempotency-Key", "k1", "X-User-ID", "alice") // повтор из хранилища
+	do("POST", "/chats", `{"title":"Другой"}`, 422, "Idempotency-Key", "k1", "X-User-ID", "alice")
+	do("POST", "/chats", `{"title":"  "}`, 400)
+	do("GET", "/chats?limit=1", "", 200)
+	do("GET", "/chats?after=x", "", 400)
+	do("PATCH", "/chats/1", `{"slow_mode_seconds":0}`, 200)
+	do("PATCH", "/chats/1", `{"retention_days":30}`, 200)
+	do("PATCH", "/chats/1", `{"retention_days":null}`, 200)
+	do("PATCH", "/chats/1", `{"retention_days":3651}`, 400)
+	do("PATCH", "/chats/1", `{}`, 400)
+	do("PATCH", "/chats/999", `{"slow_mode_seconds":1}`, 404)
+
+	// Сообщения
+	do("POST", "/chats/1/messages", `{"text":"привет","client_msg_id":"m1"}`, 201)
+	do("POST", "/chats/1/messages", `{"text":"исчезнет","ttl_seconds":3600}`, 201)
+	do("POST", "/chats/1/messages", `{"text":"**код**: `+"`go test`"+`","format":"markdown"}`, 201)
+	do("POST", "/chats/1/messages", `{"text":"x","format":"html"}`, 400)
+	do("POST", "/chats/1/messages", `{"text":"x","ttl_seconds":0}`, 400)
+	do("POST", "/chats/1/messages", `{"text":""}`, 400)
+	do("POST", "/chats/abc/messages", `{"text":"x"}`, 400)
+	do("POST", "/chats/999/messages", `{"text":"x"}`, 404)
+
+	// Упоминания: запросы пользователя считаются в его лимит, а не в лимит IP
+	do("POST", "/chats/1/messages", `{"text":"@bob, @all: релиз"}`, 201, "X-User-ID", "alice")
+	do("GET", "/me/mentions?limit=1", "", 200, "X-User-ID", "bob")
+	do("GET", "/me/mentions?unread=true", "", 200, "X-User-ID", "bob")
+	do("GET", "/me/mentions?before=x", "", 400, "X-User-ID", "bob")
+	do("POST", "/me/mentions/read", `{"ids":[1]}`, 200, "X-User-ID", "bob")
+	do("POST", "/me/mentions/read", `{`, 400, "X-User-ID", "bob")
+	do("POST", "/me/mentions/read", `{}`, 401)
+
+	// Опросы: сообщение 5
+	do("POST", "/chats/1/messages", `{"text":"Релиз в пятницу?","poll":{"options":["Да","Нет"],"anonymous":true}}`, 201, "X-User-ID", "alice")
+	do("POST", "/chats/1/messages", `{"text":"Один вариант?","poll":{"options":["Да"]}}`, 400)
+	do("POST", "/chats/1/messages/5/votes", `{"options":[0]}`, 200, "X-User-ID", "bob")
+	do("POST", "/chats/1/messages/5/votes", `{"options":[0,1]}`, 400, "X-User-ID", "bob")
+	do("POST", "/chats/1/messages/1/votes", `{"options":[0]}`, 400, "X-User-ID", "bob") // не опрос
+	do("POST", "/chats/1/messages/999/votes", `{"options":[0]}`, 404, "X-User-ID", "bob")
+	do("POST", "/chats/1/messages/5/votes", `{"options":[0]}`, 401)
+	do("DELETE", "/chats/1/messages/5/votes", "", 200, "X-User-ID", "bob")
+	do("DELETE", "/chats/1/messages/x/votes", "", 400, "X-User-ID", "bob")
+
+	// Пересылка: сообщение 6 из чата 2 в чат 1
+	do("POST", "/chats", `{"title":"Команда"}`, 201)
+	do("POST", "/chats/2/messages", `{"text":"план на неделю"}`, 201, "X-User-ID", "alice")
+	do("POST", "/chats/2/messages/6/forward", `{"chat_id":1}`, 201, "X-User-ID", "bob")
+	do("POST", "/chats/2/messages/6/forward", `{"chat_id":2}`, 400)
+	do("POST", "/chats/2/messages/6/forward", `{}`, 400)
+	do("POST", "/chats/2/messages/6/forward", `{"chat_id":1}`, 401)
+	do("POST", "/chats/2/messages/6/forward", `{"chat_id":999}`, 404, "X-User-ID", "bob")
+	do("POST", "/chats/2/messages/999/forward", `{"chat_id":1}`, 404, "X-User-ID", "bob")
+	do("POST", "/chats/2/messages/x/forward", `{"chat_id":1}`, 400)
+
+	// Закрепленные сообщения (предел - одно в чате)
+	do("POST", "/chats/1/pins", `{"message_id":1}`, 201)
+	do("POST", "/chats/1/pins", `{"message_id":1}`, 409) // уже закреплено
+	do("POST", "/chats/1/pins", `{"message_id":2}`, 409) // предел
+	do("POST", "/chats/1/pins", `{"message_id":999}`, 404)
+	do("POST", "/chats/1/pins", `{}`, 400)
+	do("DELETE", "/chats/1/pins/2", "", 404)
+	do("DELETE", "/chats/1/pins/x", "", 400)
+
+	// Запланированные сообщения
+	sendAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	do("POST", "/chats/1/scheduled-messages", `{"text":"позже","send_at":"`+sendAt+`"}`, 201)
+	do("POST", "/chats/1/scheduled-messages", `{"text":"вчера","send_at":"2020-01-01T00:00:00Z"}`, 400)
+	do("POST", "/chats/999/scheduled-messages", `{"text":"x","send_at":"`+sendAt+`"}`, 404)
+	do("PATCH", "/chats/1/scheduled-messages/1", `{"text":"чуть позже"}`, 200)
+	do("PATCH", "/chats/1/scheduled-messages/1", `{}`, 400)
+	do("PATCH", "/chats/1/scheduled-messages/999", `{"text":"x"}`, 404)
+	do("DELETE", "/chats/1/scheduled-messages/1", "", 204)
+	do("DELETE", "/chats/1/scheduled-messages/1", "", 409) // уже отменено
+
+	// Чтение: лимит - 12 запросов (вместе со списком чатов), тринадцатый получает 429
+	do("GET", "/chats/1/pins", "", 200)
+	do("GET", "/chats/1/scheduled-messages?status=canceled", "", 200)
+	do("GET", "/chats/1/scheduled-messages?status=unknown", "", 400)
+	do("GET", "/chats/1?limit=5", "", 200)
+	do("GET", "/chats/999", "", 404)
+	do("GET", "/chats/999/events", "", 404)
+	do("GET", "/chats/1/export", "", 200)
+	do("GET", "/chats/1/export?format=csv&from=2020-01-01", "", 200)
+	do("GET", "/chats/1/export?format=xml", "", 400)
+	do("GET", "/chats/999/export", "", 404)
+	do("GET", "/chats/1", "", 429)
+
+	// Удаление
+	do("DELETE", "/chats/1/pins/1", "", 204)
+	do("DELETE", "/chats/1", "", 204)
+	do("DELETE", "/chats/1", "", 404) // уже удален
+	do("DELETE", "/chats/x", "", 400)
+
+	// Импорт истории
+	export := `{"name":"Команда","type":"private_group","id":7,"messages":[` +
+		`{"id":1,"type":"service","action":"create_group","date_unixtime":"1704103200"},` +
+		`{"id":2,"type":"message","date_unixtime":"1704103260","from_id":"user1","text":"привет"}]}`
+	admin := []string{"Authorization", "Bearer openapi-admin-token"}
+	do("POST", "/admin/import?source=telegram&dry_run=true", export, 200, admin...)
+	do("POST", "/admin/import?source=telegram", export, 200, admin...)
+	do("POST", "/admin/import?source=slack", export, 400, admin...)
+	do("POST", "/admin/import?source=telegram", export, 401)
+	do("GET", "/admin/metrics", "", 200, admin...)
+	do("GET", "/admin/metrics", "", 401)
+
+	// Пробы и документация
+	do("GET", "/health", "", 200)
+	do("GET", "/livez", "", 200)
+	do("GET", "/readyz", "", 200)
+	do("GET", "/openapi.json", "", 200)
+	do("GET", "/docs/", "", 200)
+
+	// Поток событий открываем через настоящий сервер: ответ не заканчивается,
+	// проверяем статус и заголовки. Запросы httptest.NewRequest приходят с адреса 192.0.2.1,
+	// а этот - с 127.0.0.1, поэтому исчерпанный лимит чтения на него не действует
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+	chat, _ := svc.CreateChat(context.Background(), "поток")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/chats/%d/events", srv.URL, chat.ID), nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Поток событий: %v", err)
+	}
+	cancel()
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Поток событий: ожидался статус 200, получен %d", resp.StatusCode)
+	}
+	spec.checkStatus(t, "GET", req.URL.Path, resp.StatusCode, resp.Header.Get("Content-Type"), covered)
+
+	health.SetShuttingDown()
+	do("GET", "/readyz", "", 503)
+
+	for _, op := range spec.operations() {
+		if !covered[op] {
+			t.Errorf("Операция %s не проверена тестом", op)
+		}
+	}
+}
+
+// openAPISpec - разобранный api/openapi.json
+type openAPISpec struct {
+	doc map[string]any
+}
+
+// loadSpec разбирает встроенную спецификацию
+func loadSpec(t *testing.T) *openAPISpec {
+	t.Helper()
+	var doc map[string]any
+	if err := json.Unmarshal(api.Spec, &doc); err != nil {
+		t.Fatalf("openapi.json - некорректный JSON: %v", err)
+	}
+	if v, _ := doc["openapi"].(string); !strings.HasPrefix(v, "3.1") {
+		t.Fatalf("Ожидалась версия OpenAPI 3.1, получена %q", v)
+	}
+	return &openAPISpec{doc: doc}
+}
+
+// operations возвращает все операции спецификации в виде "GET /chats/{id}"
+func (s *openAPISpec) operations() []string {
+	var ops []string
+	for path, item := range s.doc["paths"].(map[string]any) {
+		for method := range item.(map[string]any) {
+			if method != "parameters" {
+				ops = append(ops, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+	return ops
+}
+
+// findOperation ищет операцию по методу и фактическому пути
+func (s *openAPISpec) findOperation(method, path string) (string, map[string]any) {
+	for template, item := range s.doc["paths"].(map[string]any) {
+		pattern := "^" + regexp.MustCompile(`\\{[^}]+\\}`).ReplaceAllString(regexp.QuoteMeta(template), `[^/]+`) + "$"
+		if !regexp.MustCompile(pattern).MatchString(path) {
+			continue
+		}
+		if op, ok := item.(map[string]any)[strings.ToLower(method)].(map[string]any); ok {
+			return strings.ToUpper(method) + " " + template, op
+		}
+	}
+	return "", nil
+}
+
+// checkResponse проверяет ответ роутера по спецификации
+func (s *openAPISpec) checkResponse(t *testing.T, method, path string, rr *httptest.ResponseRecorder, covered map[string]bool) {
+	t.Helper()
+	media := s.checkStatus(t, method, path, rr.Code, rr.Header().Get("Content-Type"), covered)
+	if media == nil {
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(rr.Header().Get("Content-Type"))
+	if mediaType != "application/json" {
+		return
+	}
+	var body any
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Errorf("%s %s: тело не JSON: %v", method, path, err)
+		return
+	}
+	schema, _ := media["schema"].(map[string]any)
+	for _, err := range s.validate(schema, body, "$") {
+		t.Errorf("%s %s (%d): %s", method, path, rr.Code, err)
+	}
+}
+
+// checkStatus проверяет, что статус и Content-Type описаны в спецификации,
+// и возвращает описание содержимого ответа (nil, если тело не описано)
+func (s *openAPISpec) checkStatus(t *testing.T, method, path string, status int, contentType string, covered map[string]bool) map[string]any {
+	t.Helper()
+	name, op := s.findOperation(method, path)
+	if op == nil {
+		t.Errorf("%s %s: операция не описана в спецификации", method, path)
+		return nil
+	}
+	covered[name] = true
+
+	responses := op["responses"].(map[string]any)
+	response, ok := responses[strconv.Itoa(status)].(map[string]any)
+	if !ok {
+		t.Errorf("%s: статус %d не описан в спецификации", name, status)
+		return nil
+	}
+	response = s.resolve(response)
+
+	content, _ := response["content"].(map[string]any)
+	if len(content) == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	media, ok := content[mediaType].(map[string]any)
+	if !ok {
+		t.Errorf("%s (%d): Content-Type %q не описан в спецификации", name, status, contentType)
+		return nil
+	}
+	return media
+}
+
+// resolve раскрывает $ref вида "#/components/..."
+func (s *openAPISpec) resolve(node map[string]any) map[string]any {
+	for {
+		ref, ok := node["$ref"].(string)
+		if !ok {
+			return node
+		}
+		var cur any = s.doc
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			cur = cur.(map[string]any)[part]
+		}
+		node = cur.(map[string]any)
+	}
+}
+
+// validate проверяет значение по JSON Schema (подмножество, которое использует спецификация:
+// type, enum, properties, required, additionalProperties, items, minimum/maximum,
+// minLength/maxLength, format date-time)
+func (s *openAPISpec) validate(schema map[string]any, value any, at string) []string {
+	if schema == nil {
+		return nil
+	}
+	schema = s.resolve(schema)
+	var errs []string
+	fail := func(format string, args ...any) {
+		errs = append(errs, at+": "+fmt.Sprintf(format, args...))
+	}
+
+	if types, ok := schemaTypes(schema["type"]); ok && !matchesType(types, value) {
+		fail("ожидался тип %v, получено %T", types, value)
+		return errs
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if e == value {
+				found = true
+			}
+		}
+		if !found {
+			fail("значение %v не из %v", value, enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		for _, name := range asSlice(schema["required"]) {
+			if _, ok := v[name.(string)]; !ok {
+				fail("нет обязательного поля %q", name)
+			}
+		}
+		for name, field := range v {
+			if p, ok := props[name].(map[string]any); ok {
+				errs = append(errs, s.validate(p, field, at+"."+name)...)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					fail("поле %q не описано в схеме", name)
+				}
+			case map[string]any:
+				errs = append(errs, s.validate(extra, field, at+"."+name)...)
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				errs = append(errs, s.validate(items, item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && v < min {
+			fail("%v меньше минимума %v", v, min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && v > max {
+			fail("%v больше максимума %v", v, max)
+		}
+	case string:
+		length := len([]rune(v))
+		if min, ok := schema["minLength"].(float64); ok && length < int(min) {
+			fail("длина %d меньше %v", length, min)
+		}
+		if max, ok := schema["maxLength"].(float64); ok && length > int(max) {
+			fail("длина %d больше %v", length, max)
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+				fail("не date-time: %q", v)
+			}
+		}
+	}
+	return errs
+}
+
+// schemaTypes возвращает список типов из "type" (строка или массив в OpenAPI 3.1)
+func schemaTypes(t any) ([]string, bool) {
+	switch t := t.(type) {
+	case string:
+		return []string{t}, true
+	case []any:
+		var types []string
+		for _, x := range t {
+			types = append(types, x.(string))
+		}
+		return types, true
+	}
+	return nil, false
+}
+
+// matchesType проверяет JSON значение на соответствие одному из типов
+func matchesType(types []string, value any) bool {
+	for _, t := range types {
+		switch v := value.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == float64(int64(v))) {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case []any:
+			if t == "array" {
+				return true
+			}
+		case map[string]any:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// asSlice приводит значение к []any (nil, если это не массив)
+func asSlice(v any) []any {
+	s, _ := v.([]any)
+	return s
+}
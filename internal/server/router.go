@@ -1,83 +1,155 @@
 package server
 
 import (
-	"log"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
+	"runtime/debug"
+	"strconv"
 	"strings"
+	"time"
 
+	"go-chat-app/internal/auth"
 	"go-chat-app/internal/db/service"
 	"go-chat-app/internal/handler"
+	"go-chat-app/internal/idempotency"
+	"go-chat-app/internal/importer"
+	"go-chat-app/internal/logger"
+	"go-chat-app/internal/ratelimit"
+	"go-chat-app/internal/repository"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
+// requestIDHeader - заголовок, в котором передается ID запроса
+const requestIDHeader = "X-Request-ID"
+
+// Options - необязательные настройки роутера
+type Options struct {
+	UserHeader     string          // заголовок с ID пользователя от доверенного шлюза ("" - выключено)
+	TrustProxy     bool            // брать IP клиента из X-Forwarded-For (только за доверенным прокси)
+	RateLimits     ratelimit.Rules // лимиты запросов, нулевой Limit - без ограничения
+	RateLimitStore ratelimit.Store // хранилище лимитов (nil - без ограничений)
+
+	IdempotencyStore repository.IdempotencyStore // хранилище ключей идемпотентности (nil - выключено)
+	IdempotencyTTL   time.Duration               // время хранения ключа идемпотентности
+
+	AdminToken     string             // токен служебных эндпоинтов /admin ("" - выключены)
+	Importer       *importer.Importer // импорт истории для POST /admin/import
+	ImportMaxBytes int64              // максимальный размер файла выгрузки
+}
+
 // Router обрабатывает маршрутизацию HTTP запросов
 type Router struct {
-	chatHandler *handler.ChatHandler
+	chatHandler   *handler.ChatHandler
+	healthHandler *handler.HealthHandler
+	docsHandler   *handler.DocsHandler
+	adminHandler  *handler.AdminHandler // nil - служебные эндпоинты выключены
+	opts          Options
+	handler       http.Handler // готовая цепочка middleware
 }
 
 // NewRouter создает новый роутер с привязкой хендлеров
-func NewRouter(chatService *service.ChatService) *Router {
-	return &Router{
-		chatHandler: handler.NewChatHandler(chatService),
+func NewRouter(chatService *service.ChatService, healthHandler *handler.HealthHandler, opts Options) *Router {
+	r := &Router{
+		chatHandler:   handler.NewChatHandler(chatService),
+		healthHandler: healthHandler,
+		docsHandler:   handler.NewDocsHandler(),
+		opts:          opts,
+	}
+	if opts.AdminToken != "" && opts.Importer != nil {
+		r.adminHandler = handler.NewAdminHandler(opts.Importer, opts.AdminToken, opts.ImportMaxBytes)
 	}
+
+	// Собираем цепочку middleware один раз (снаружи внутрь):
+	// 1. Трассировка (принимает заголовок traceparent)
+	// 2. ID запроса
+	// 3. Логирование
+	// 4. Recovery (обработка паник) - внутри логирования, чтобы в лог попал статус 500
+	// 5. Пользователь из заголовка шлюза
+	// 6. Ограничение частоты запросов
+	// 7. Ключи идемпотентности (после лимитов: им нужен ключ клиента из контекста)
+	// 8. Основной обработчик
+	inner := auth.Middleware(opts.UserHeader,
+		r.rateLimitMiddleware(idempotency.Middleware(opts.IdempotencyStore, opts.IdempotencyTTL, r.mainHandler)))
+	r.handler = otelhttp.NewHandler(
+		r.requestIDMiddleware(r.loggingMiddleware(r.recoveryMiddleware(inner.ServeHTTP))),
+		"chat-api",
+	)
+	return r
 }
 
 // ServeHTTP обрабатывает все входящие HTTP запросы
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	// Добавляем middleware
-	// 1. Логирование
-	// 2. Recovery (обработка паник)
-	// 3. Основной обработчик
-	handler := r.recoveryMiddleware(r.loggingMiddleware(r.mainHandler))
-	handler.ServeHTTP(w, req)
+	r.handler.ServeHTTP(w, req)
 }
 
 // mainHandler определяет какой хендлер вызвать в зависимости от пути
 func (r *Router) mainHandler(w http.ResponseWriter, req *http.Request) {
-	path := req.URL.Path
-
-	// Простая маршрутизация без сторонних библиотек
 	switch {
-	// POST /chats/
-	case path == "/chats/" && req.Method == http.MethodPost:
-		r.chatHandler.CreateChat(w, req)
-
-	// GET /chats/{id}
-	case strings.HasPrefix(path, "/chats/") && req.Method == http.MethodGet:
-		// Проверяем что после /chats/ идет число (ID чата)
-		if isChatIDPath(path) {
-			r.chatHandler.GetChat(w, req)
-		} else {
-			http.NotFound(w, req)
-		}
+	// GET /livez - liveness проба
+	case req.URL.Path == "/livez" && req.Method == http.MethodGet:
+		r.healthHandler.Live(w, req)
 
-	// DELETE /chats/{id}
-	case strings.HasPrefix(path, "/chats/") && req.Method == http.MethodDelete:
-		if isChatIDPath(path) {
-			r.chatHandler.DeleteChat(w, req)
-		} else {
-			http.NotFound(w, req)
-		}
+	// GET /readyz - readiness проба
+	// Старый GET /health - ее псевдоним: кто еще опрашивает его, видит те же проверки и остановку
+	case (req.URL.Path == "/readyz" || req.URL.Path == "/health") && req.Method == http.MethodGet:
+		r.healthHandler.Ready(w, req)
+
+	// GET /openapi.json - спецификация API
+	case req.URL.Path == "/openapi.json" && req.Method == http.MethodGet:
+		r.docsHandler.Spec(w, req)
 
-	// POST /chats/{id}/messages/
-	case strings.HasSuffix(path, "/messages/") && req.Method == http.MethodPost:
-		if isChatMessagesPath(path) {
-			r.chatHandler.SendMessage(w, req)
-		} else {
+	// GET /docs/ - Swagger UI
+	case (req.URL.Path == "/docs" || strings.HasPrefix(req.URL.Path, "/docs/")) && req.Method == http.MethodGet:
+		r.docsHandler.UI(w, req)
+
+	// /admin/* - служебные эндпоинты, без токена в настройках их как будто нет
+	case strings.HasPrefix(req.URL.Path, "/admin/"):
+		if r.adminHandler == nil {
 			http.NotFound(w, req)
+			return
 		}
+		r.adminHandler.ServeHTTP(w, req)
 
-	// Все остальные пути - 404
+	// Остальные пути хендлер чатов разбирает сам
 	default:
-		http.NotFound(w, req)
+		r.chatHandler.ServeHTTP(w, req)
 	}
 }
 
-// loggingMiddleware логирует все запросы
+// requestIDMiddleware берет ID запроса из заголовка X-Request-ID или генерирует новый
+// ID сохраняется в контексте (для логов всех слоев) и возвращается клиенту
+func (r *Router) requestIDMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get(requestIDHeader)
+		if !isValidRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next(w, req.WithContext(logger.WithRequestID(req.Context(), id)))
+	}
+}
+
+// loggingMiddleware логирует все запросы со статусом и временем выполнения
 func (r *Router) loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		// Простое логирование в консоль
-		log.Printf("%s %s from %s", req.Method, req.URL.Path, req.RemoteAddr)
-		next(w, req)
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next(rec, req)
+
+		slog.InfoContext(req.Context(), "HTTP запрос",
+			slog.String("method", req.Method),
+			slog.String("path", req.URL.Path),
+			slog.String("remote_addr", req.RemoteAddr),
+			slog.Int("status", rec.status),
+			slog.Int("bytes", rec.bytes),
+			slog.Duration("duration", time.Since(start)),
+		)
 	}
 }
 
@@ -86,8 +158,16 @@ func (r *Router) recoveryMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				// Логируем панику
-				log.Printf("PANIC: %v", err)
+				// http.ErrAbortHandler - штатный способ оборвать ответ (например, выгрузку
+				// при ошибке посреди потока): передаем его серверу, он закроет соединение молча
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				// Логируем панику вместе со стеком вызовов
+				slog.ErrorContext(req.Context(), "PANIC",
+					slog.Any("error", err),
+					slog.String("stack", string(debug.Stack())),
+				)
 				// Возвращаем 500 ошибку
 				http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 			}
@@ -96,34 +176,145 @@ func (r *Router) recoveryMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// Вспомогательные функции для проверки путей
+// rateLimitMiddleware ограничивает частоту запросов клиента (пользователя или IP)
+// Отдельные лимиты на отправку сообщений, создание чатов и чтение
+func (r *Router) rateLimitMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		client := r.clientKey(req)
+		ctx := ratelimit.WithClient(req.Context(), client)
+		req = req.WithContext(ctx)
+
+		class, limit := r.classify(req)
+		if r.opts.RateLimitStore == nil || !limit.Enabled() {
+			next(w, req)
+			return
+		}
 
-// isChatIDPath проверяет что путь вида /chats/123 (где 123 - число)
-func isChatIDPath(path string) bool {
-	// Убираем "/chats/" в начале
-	if !strings.HasPrefix(path, "/chats/") {
-		return false
+		res, err := r.opts.RateLimitStore.Take(ctx, class+":"+client, limit)
+		if err != nil {
+			// Недоступное хранилище лимитов не должно останавливать API
+			slog.WarnContext(ctx, "не удалось проверить лимит запросов", slog.Any("error", err))
+			next(w, req)
+			return
+		}
+
+		// Заголовки RateLimit-* (draft-ietf-httpapi-ratelimit-headers)
+		w.Header().Set("RateLimit-Policy", limit.String())
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+		if !res.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			http.Error(w, "Слишком много запросов", http.StatusTooManyRequests) // 429
+			return
+		}
+		next(w, req)
 	}
+}
 
-	rest := path[len("/chats/"):]
-	// Должен остаться только ID (число)
-	// Проверяем что в строке только цифры
-	for _, char := range rest {
-		if char < '0' || char > '9' {
-			return false
+// classify определяет класс запроса и его лимит
+// Любая запись, кроме создания чата (сообщения, голоса, закрепления, настройки, отметки
+// /me/mentions/read), считается по лимиту сообщений: новый эндпоинт записи не останется без ограничения
+// Пробы /livez, /readyz, /health не ограничиваются
+func (r *Router) classify(req *http.Request) (string, ratelimit.Limit) {
+	path := req.URL.Path
+	switch {
+	case req.Method == http.MethodPost && (path == "/chats" || path == "/chats/"):
+		return "chats", r.opts.RateLimits.Chats
+	case isWrite(req.Method):
+		return "messages", r.opts.RateLimits.Messages
+	case req.Method == http.MethodGet && (strings.HasPrefix(path, "/chats") || strings.HasPrefix(path, "/me/")):
+		return "reads", r.opts.RateLimits.Reads
+	default:
+		return "", ratelimit.Limit{}
+	}
+}
+
+// isWrite сообщает, изменяет ли запрос с методом method данные
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// clientKey возвращает ключ клиента: ID пользователя, если он известен, иначе IP
+func (r *Router) clientKey(req *http.Request) string {
+	if userID, ok := auth.UserID(req.Context()); ok {
+		return "user:" + userID
+	}
+	return "ip:" + r.clientIP(req)
+}
+
+// clientIP возвращает IP клиента
+// X-Forwarded-For учитывается только с TrustProxy, иначе его может подделать сам клиент
+func (r *Router) clientIP(req *http.Request) string {
+	if r.opts.TrustProxy {
+		if forwarded := req.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
 		}
 	}
-	return rest != "" // не пустая строка
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// statusRecorder запоминает статус код и размер ответа для логирования
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// WriteHeader сохраняет статус код и передает его дальше
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Write считает количество отправленных байт
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+// Unwrap дает http.ResponseController доступ к исходному ResponseWriter
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Вспомогательные функции для ID запроса
+
+// newRequestID генерирует случайный ID из 16 байт в hex
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ceilSeconds округляет длительность вверх до целых секунд (для заголовков)
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
 }
 
-// isChatMessagesPath проверяет что путь вида /chats/123/messages/
-func isChatMessagesPath(path string) bool {
-	if !strings.HasSuffix(path, "/messages/") {
+// isValidRequestID проверяет ID от клиента: не пустой, не длиннее 128 символов
+// и только из безопасных символов (чтобы его можно было писать в логи и заголовки)
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
 		return false
 	}
-
-	// Убираем "/messages/" в конце
-	pathWithoutMessages := path[:len(path)-len("/messages/")]
-	// Теперь проверяем что осталось /chats/123
-	return isChatIDPath(pathWithoutMessages + "/")
+	for _, char := range id {
+		isLetter := (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+		isDigit := char >= '0' && char <= '9'
+		if !isLetter && !isDigit && char != '-' && char != '_' && char != '.' {
+			return false
+		}
+	}
+	return true
 }
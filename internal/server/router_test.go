@@ -0,0 +1,135 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-chat-app/internal/handler"
+	"go-chat-app/internal/logger"
+)
+
+// TestRequestIDMiddleware проверяет, что ID запроса берется из заголовка
+// или генерируется, если клиент его не прислал (или прислал некорректный)
+func TestRequestIDMiddleware(t *testing.T) {
+	r := &Router{}
+
+	tests := []struct {
+		name     string
+		incoming string
+		wantSame bool // ожидаем ли, что ID клиента сохранится
+	}{
+		{"ID от клиента", "abc-123", true},
+		{"без заголовка", "", false},
+		{"недопустимые символы", "bad id\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			h := r.requestIDMiddleware(func(w http.ResponseWriter, req *http.Request) {
+				seen = logger.RequestID(req.Context())
+			})
+
+			req := httptest.NewRequest("GET", "/health", nil)
+			if tt.incoming != "" {
+				req.Header.Set(requestIDHeader, tt.incoming)
+			}
+			rr := httptest.NewRecorder()
+			h(rr, req)
+
+			if seen == "" {
+				t.Fatal("ID запроса не попал в контекст")
+			}
+			if got := rr.Header().Get(requestIDHeader); got != seen {
+				t.Errorf("В ответе ID %q, в контексте %q", got, seen)
+			}
+			if tt.wantSame && seen != tt.incoming {
+				t.Errorf("Ожидался ID %q, получен %q", tt.incoming, seen)
+			}
+			if !tt.wantSame && seen == tt.incoming {
+				t.Errorf("Некорректный ID %q не должен использоваться", tt.incoming)
+			}
+		})
+	}
+}
+
+// TestRecoveryMiddleware проверяет, что паника превращается в ответ 500
+func TestRecoveryMiddleware(t *testing.T) {
+	r := &Router{}
+	h := r.loggingMiddleware(r.recoveryMiddleware(func(w http.ResponseWriter, req *http.Request) {
+		panic("тестовая паника")
+	}))
+
+	rr := httptest.NewRecorder()
+	h(rr, httptest.NewRequest("GET", "/chats/1", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Ожидался статус 500, получен %d", rr.Code)
+	}
+}
+
+// TestAdminDisabledWithoutToken проверяет, что без токена служебные эндпоинты не видны
+func TestAdminDisabledWithoutToken(t *testing.T) {
+	r := &Router{}
+	req := httptest.NewRequest("POST", "/admin/import?source=slack", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rr := httptest.NewRecorder()
+	r.mainHandler(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Ожидался статус 404, получен %d", rr.Code)
+	}
+}
+
+// TestLegacyHealth проверяет, что старый /health отвечает как /readyz, в том числе при остановке
+func TestLegacyHealth(t *testing.T) {
+	health := handler.NewHealthHandler(time.Second)
+	r := &Router{healthHandler: health}
+
+	for _, tt := range []struct {
+		name     string
+		shutdown bool
+		want     int
+	}{
+		{"сервер работает", false, http.StatusOK},
+		{"идет остановка", true, http.StatusServiceUnavailable},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.shutdown {
+				health.SetShuttingDown()
+			}
+			rr := httptest.NewRecorder()
+			r.mainHandler(rr, httptest.NewRequest("GET", "/health", nil))
+			if rr.Code != tt.want {
+				t.Errorf("Ожидался статус %d, получен %d", tt.want, rr.Code)
+			}
+		})
+	}
+}
+
+// TestClassify проверяет, что все изменения (в чатах и в /me/) попадают под лимит сообщений
+func TestClassify(t *testing.T) {
+	r := &Router{}
+	tests := []struct {
+		method, path, want string
+	}{
+		{"POST", "/chats", "chats"},
+		{"POST", "/chats/1/messages", "messages"},
+		{"POST", "/chats/1/messages/5/votes", "messages"},
+		{"DELETE", "/chats/1/messages/5/votes", "messages"},
+		{"POST", "/chats/1/pins", "messages"},
+		{"DELETE", "/chats/1/pins/5", "messages"},
+		{"PATCH", "/chats/1", "messages"},
+		{"PUT", "/chats/1/settings", "messages"},
+		{"GET", "/chats/1", "reads"},
+		{"GET", "/me/mentions", "reads"},
+		{"POST", "/me/mentions/read", "messages"},
+		{"GET", "/livez", ""},
+	}
+	for _, tt := range tests {
+		if got, _ := r.classify(httptest.NewRequest(tt.method, tt.path, nil)); got != tt.want {
+			t.Errorf("classify(%s %s) = %q, ожидалось %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
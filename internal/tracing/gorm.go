@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// spanKey - ключ, под которым спан запроса хранится в gorm.DB на время выполнения
+const spanKey = "tracing:span"
+
+// GormPlugin создает спан на каждый SQL запрос GORM
+// Спан становится дочерним для контекста, переданного через db.WithContext
+type GormPlugin struct{}
+
+// Name возвращает имя плагина (реализует интерфейс gorm.Plugin)
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+// Initialize регистрирует колбэки до и после каждой операции GORM
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	tracer := otel.Tracer("go-chat-app/internal/tracing/gorm")
+
+	before := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			ctx, span := tracer.Start(tx.Statement.Context, "gorm."+operation,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attribute.String("db.system", tx.Dialector.Name())),
+			)
+			tx.Statement.Context = ctx
+			tx.InstanceSet(spanKey, span)
+		}
+	}
+
+	after := func(tx *gorm.DB) {
+		value, ok := tx.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		defer span.End()
+
+		// Текст запроса с плейсхолдерами, без значений параметров
+		span.SetAttributes(
+			attribute.String("db.statement", tx.Statement.SQL.String()),
+			attribute.String("db.sql.table", tx.Statement.Table),
+			attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+		)
+		if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			span.RecordError(tx.Error)
+			span.SetStatus(codes.Error, tx.Error.Error())
+		}
+	}
+
+	cb := db.Callback()
+	hooks := []struct {
+		name           string
+		registerBefore func(name string, fn func(*gorm.DB)) error
+		registerAfter  func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, h := range hooks {
+		if err := h.registerBefore("tracing:before_"+h.name, before(h.name)); err != nil {
+			return err
+		}
+		if err := h.registerAfter("tracing:after_"+h.name, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Поддерживаемые значения TRACE_EXPORTER
+const (
+	ExporterAuto   = "auto"   // OTLP если задан OTEL_EXPORTER_OTLP_ENDPOINT, иначе stdout
+	ExporterOTLP   = "otlp"   // OTLP по HTTP (адрес берется из стандартных OTEL_* переменных)
+	ExporterStdout = "stdout" // печать спанов в консоль, удобно для локальной отладки
+	ExporterNone   = "none"   // трассировка выключена
+)
+
+// Setup настраивает глобальный TracerProvider и propagator W3C Trace Context
+// Возвращает функцию остановки, которая досылает накопленные спаны
+func Setup(ctx context.Context, serviceName, exporterName string) (func(context.Context) error, error) {
+	// Propagator нужен всегда: даже с выключенным экспортом мы должны
+	// принимать заголовок traceparent и передавать его дальше
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if exporterName == "" || exporterName == ExporterAuto {
+		exporterName = ExporterStdout
+		if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+			exporterName = ExporterOTLP
+		}
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch exporterName {
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	default:
+		return nil, fmt.Errorf("неизвестный экспортер трассировки: %q", exporterName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать экспортер трассировки: %w", err)
+	}
+
+	// Ресурс без схемы: схема semconv может отличаться от схемы resource.Default()
+	// той версии SDK, и тогда Merge вернул бы ошибку "conflicting Schema URL"
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось описать ресурс трассировки: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	log.Printf("Трассировка включена, экспортер: %s", exporterName)
+	return provider.Shutdown, nil
+}
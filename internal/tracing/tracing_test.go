@@ -0,0 +1,26 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestSetup проверяет, что каждый экспортер настраивается без ошибки
+func TestSetup(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	for _, exporter := range []string{ExporterAuto, ExporterOTLP, ExporterStdout, ExporterNone} {
+		shutdown, err := Setup(context.Background(), "chat-test", exporter)
+		if err != nil {
+			t.Errorf("Setup(%q): %v", exporter, err)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_ = shutdown(ctx) // спанов нет, OTLP никуда не подключается
+		cancel()
+	}
+	if _, err := Setup(context.Background(), "chat-test", "jaeger"); err == nil {
+		t.Error("Ожидалась ошибка для неизвестного экспортера")
+	}
+}
@@ -0,0 +1,288 @@
+package unfurl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"go-chat-app/internal/markdown"
+)
+
+// Ограничения метаданных: длиннее обрезаются (как колонки link_previews)
+const (
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+	maxSiteNameLength    = 200
+)
+
+// maxRedirects - сколько перенаправлений проходит загрузка страницы
+const maxRedirects = 5
+
+// userAgent - так сервер представляется сайтам при загрузке превью
+const userAgent = "go-chat-app-unfurl/1.0 (+link preview)"
+
+// Ошибки загрузки, после которых повторять бессмысленно
+var (
+	// ErrBlocked - адрес ведет во внутреннюю сеть (защита от SSRF)
+	ErrBlocked = errors.New("адрес во внутренней сети запрещен")
+	// ErrUnsupported - страница не подходит для превью: не HTML, ответ 4xx или нет метаданных
+	ErrUnsupported = errors.New("страница не подходит для превью")
+)
+
+// Metadata - метаданные страницы для превью
+type Metadata struct {
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+}
+
+// empty сообщает, что показывать нечего
+func (m Metadata) empty() bool {
+	return m.Title == "" && m.Description == "" && m.ImageURL == ""
+}
+
+// FetcherConfig - настройки загрузки страниц
+type FetcherConfig struct {
+	Timeout  time.Duration // на всю загрузку страницы, включая перенаправления
+	MaxBytes int64         // читается не больше MaxBytes ответа: метаданные - в начале страницы
+
+	// AllowPrivate разрешает адреса внутренней сети (loopback, частные диапазоны)
+	// Только для разработки и тестов: иначе через превью можно обращаться к внутренним сервисам
+	AllowPrivate bool
+}
+
+// withDefaults подставляет значения по умолчанию для незаданных полей
+func (c FetcherConfig) withDefaults() FetcherConfig {
+	if c.Timeout <= 0 {
+		c.Timeout = 5 * time.Second
+	}
+	if c.MaxBytes <= 0 {
+		c.MaxBytes = 1 << 20
+	}
+	return c
+}
+
+// HTTPFetcher загружает OpenGraph и oEmbed метаданные страниц по HTTP
+//
+// Защита от SSRF: адрес проверяется при каждом соединении, уже после разрешения имени
+// (net.Dialer.Control), поэтому не помогают ни DNS, указывающий во внутреннюю сеть,
+// ни перенаправление на внутренний адрес. Прокси из окружения не используется -
+// иначе проверялся бы адрес прокси, а не сайта
+type HTTPFetcher struct {
+	client *http.Client
+	cfg    FetcherConfig
+}
+
+// NewFetcher создает загрузчик метаданных
+func NewFetcher(cfg FetcherConfig) *HTTPFetcher {
+	cfg = cfg.withDefaults()
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivate {
+		dialer.Control = denyPrivate
+	}
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("%w: больше %d перенаправлений", ErrUnsupported, maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("%w: перенаправление на %s", ErrUnsupported, req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	return &HTTPFetcher{client: client, cfg: cfg}
+}
+
+// Fetch загружает страницу и возвращает ее метаданные: OpenGraph (og:title, og:description,
+// og:image, og:site_name), а если их не хватает - oEmbed (ссылка из <link type="application/json+oembed">),
+// <title> и <meta name="description">
+// Ошибки ErrBlocked и ErrUnsupported окончательные, остальные (сеть, таймаут, 5xx) можно повторить
+func (f *HTTPFetcher) Fetch(ctx context.Context, rawURL string) (Metadata, error) {
+	base, err := url.Parse(rawURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return Metadata{}, fmt.Errorf("%w: некорректный адрес", ErrUnsupported)
+	}
+
+	body, finalURL, contentType, err := f.get(ctx, rawURL)
+	if err != nil {
+		return Metadata{}, err
+	}
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Metadata{}, fmt.Errorf("%w: Content-Type %q", ErrUnsupported, contentType)
+	}
+
+	page := parseHTML(body, finalURL)
+	meta := page.meta
+	if page.oembed != "" && (meta.Title == "" || meta.ImageURL == "" || meta.SiteName == "") {
+		// oEmbed только дополняет страницу: его ошибка не отменяет превью
+		if embed, err := f.oembed(ctx, page.oembed); err == nil {
+			meta = fill(meta, embed)
+		}
+	}
+	meta = fill(meta, page.fallback)
+	meta = clean(meta)
+	if meta.empty() {
+		return Metadata{}, fmt.Errorf("%w: нет метаданных", ErrUnsupported)
+	}
+	return meta, nil
+}
+
+// get загружает не больше MaxBytes ответа и возвращает тело, итоговый адрес (после
+// перенаправлений) и Content-Type
+func (f *HTTPFetcher) get(ctx context.Context, rawURL string) ([]byte, *url.URL, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/json;q=0.9,*/*;q=0.1")
+
+	// Client.Do оборачивает ошибки соединения и CheckRedirect в *url.Error:
+	// ErrBlocked и ErrUnsupported по-прежнему находятся через errors.Is
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return nil, nil, "", fmt.Errorf("ответ %d", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return nil, nil, "", fmt.Errorf("%w: ответ %d", ErrUnsupported, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.cfg.MaxBytes))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return body, resp.Request.URL, resp.Header.Get("Content-Type"), nil
+}
+
+// oembedResponse - поля ответа oEmbed, которые нужны превью
+type oembedResponse struct {
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name"`
+	ProviderName string `json:"provider_name"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+// oembed загружает oEmbed описание страницы (JSON)
+func (f *HTTPFetcher) oembed(ctx context.Context, rawURL string) (Metadata, error) {
+	body, _, _, err := f.get(ctx, rawURL)
+	if err != nil {
+		return Metadata{}, err
+	}
+	var resp oembedResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return Metadata{}, fmt.Errorf("%w: oEmbed: %v", ErrUnsupported, err)
+	}
+	meta := Metadata{Title: resp.Title, ImageURL: resp.ThumbnailURL, SiteName: resp.ProviderName}
+	if meta.Title == "" {
+		meta.Title = resp.AuthorName
+	}
+	return meta, nil
+}
+
+// fill дополняет пустые поля meta значениями из extra
+func fill(meta, extra Metadata) Metadata {
+	if meta.Title == "" {
+		meta.Title = extra.Title
+	}
+	if meta.Description == "" {
+		meta.Description = extra.Description
+	}
+	if meta.ImageURL == "" {
+		meta.ImageURL = extra.ImageURL
+	}
+	if meta.SiteName == "" {
+		meta.SiteName = extra.SiteName
+	}
+	return meta
+}
+
+// clean нормализует пробелы, обрезает длинные значения и убирает небезопасную картинку:
+// клиент покажет ее по адресу, поэтому допускаются только http(s) адреса
+func clean(meta Metadata) Metadata {
+	meta.Title = truncate(collapseSpaces(meta.Title), maxTitleLength)
+	meta.Description = truncate(collapseSpaces(meta.Description), maxDescriptionLength)
+	meta.SiteName = truncate(collapseSpaces(meta.SiteName), maxSiteNameLength)
+	if !markdown.SafeURL(meta.ImageURL) || !strings.HasPrefix(strings.ToLower(meta.ImageURL), "http") {
+		meta.ImageURL = ""
+	}
+	return meta
+}
+
+// collapseSpaces заменяет серии пробельных символов одним пробелом
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// truncate обрезает строку до max рун (с многоточием) и убирает некорректный UTF-8
+func truncate(s string, max int) string {
+	s = strings.ToValidUTF8(s, "")
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
+
+// denyPrivate - net.Dialer.Control: запрещает соединения с адресами внутренней сети
+// Вызывается для каждого соединения с уже разрешенным IP адресом
+func denyPrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlocked, address)
+	}
+	if !publicAddr(ip) {
+		return fmt.Errorf("%w: %s", ErrBlocked, ip)
+	}
+	return nil
+}
+
+// sharedAddressSpace - 100.64.0.0/10 (CGNAT), не входит в netip.Addr.IsPrivate
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddr сообщает, что адрес в публичном интернете: не loopback, не частная сеть
+// (10/8, 172.16/12, 192.168/16, fc00::/7, 100.64/10), не link-local (169.254/16 - в том числе
+// метаданные облака), не multicast и не 0.0.0.0
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap() // ::ffff:127.0.0.1 - это 127.0.0.1
+	return ip.IsValid() &&
+		!ip.IsUnspecified() &&
+		!ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!sharedAddressSpace.Contains(ip) &&
+		!(ip.Is4() && ip.As4()[0] == 0)
+}
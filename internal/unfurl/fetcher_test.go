@@ -0,0 +1,129 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// origin - тестовый сайт: страницы с OpenGraph, oEmbed, перенаправлением и медленным ответом
+func origin(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/og", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<!doctype html><html><head>
+<title>Запасной заголовок</title>
+<meta property="og:title" content="Релиз   2.0 &amp; заметки">
+<meta property="og:description" content="Что нового">
+<meta property="og:image" content="/cover.png">
+<meta property="og:site_name" content="Пример">
+</head><body><meta property="og:title" content="из тела не читается"></body></html>`))
+	})
+	mux.HandleFunc("/oembed-page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Видео</title><meta name="description" content="Описание">
+<link rel="alternate" type="application/json+oembed" href="/oembed.json"></head></html>`))
+	})
+	mux.HandleFunc("/oembed.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"type":"video","title":"Видео из oEmbed","provider_name":"Видеохостинг","thumbnail_url":"javascript:alert(1)"}`))
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><!--` + strings.Repeat("x", 4096) + `--><meta property="og:title" content="далеко"></head></html>`))
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG"))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	})
+	mux.HandleFunc("/down", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	mux.Handle("/moved", http.RedirectHandler("/og", http.StatusFound))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestFetch проверяет разбор OpenGraph и oEmbed, ограничения размера и времени и ошибки
+func TestFetch(t *testing.T) {
+	srv := origin(t)
+	f := NewFetcher(FetcherConfig{AllowPrivate: true, Timeout: 500 * time.Millisecond, MaxBytes: 2048})
+	ctx := context.Background()
+
+	meta, err := f.Fetch(ctx, srv.URL+"/moved")
+	want := Metadata{Title: "Релиз 2.0 & заметки", Description: "Что нового", ImageURL: srv.URL + "/cover.png", SiteName: "Пример"}
+	if err != nil || meta != want {
+		t.Errorf("OpenGraph: ожидалось %+v, получено %+v, %v", want, meta, err)
+	}
+
+	// oEmbed дополняет страницу, небезопасная картинка отбрасывается
+	meta, err = f.Fetch(ctx, srv.URL+"/oembed-page")
+	want = Metadata{Title: "Видео из oEmbed", Description: "Описание", SiteName: "Видеохостинг"}
+	if err != nil || meta != want {
+		t.Errorf("oEmbed: ожидалось %+v, получено %+v, %v", want, meta, err)
+	}
+
+	for _, tt := range []struct {
+		path      string
+		permanent bool
+	}{
+		{"/big", true},       // метаданные дальше MaxBytes
+		{"/image.png", true}, // не HTML
+		{"/missing", true},   // 404
+		{"/down", false},     // 503 - можно повторить
+		{"/slow", false},     // таймаут - можно повторить
+	} {
+		_, err := f.Fetch(ctx, srv.URL+tt.path)
+		if err == nil || errors.Is(err, ErrUnsupported) != tt.permanent {
+			t.Errorf("%s: ожидалась ошибка (окончательная: %v), получено %v", tt.path, tt.permanent, err)
+		}
+	}
+}
+
+// TestFetchBlocksPrivate проверяет защиту от SSRF: внутренние адреса не загружаются,
+// в том числе через перенаправление с внешнего адреса
+func TestFetchBlocksPrivate(t *testing.T) {
+	srv := origin(t)
+	f := NewFetcher(FetcherConfig{Timeout: time.Second})
+	ctx := context.Background()
+
+	for _, u := range []string{srv.URL + "/og", "http://localhost" + strings.TrimPrefix(srv.URL, "http://127.0.0.1")} {
+		if _, err := f.Fetch(ctx, u); !errors.Is(err, ErrBlocked) {
+			t.Errorf("%s: ожидалась ErrBlocked, получено %v", u, err)
+		}
+	}
+
+	for addr, public := range map[string]bool{
+		"8.8.8.8":          true,
+		"2001:4860::8888":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::1":              false,
+		"fd00::1":          false,
+		"fe80::1":          false,
+		"::ffff:127.0.0.1": false,
+		"224.0.0.1":        false,
+	} {
+		if got := publicAddr(netip.MustParseAddr(addr)); got != public {
+			t.Errorf("publicAddr(%s) = %v, ожидалось %v", addr, got, public)
+		}
+	}
+}
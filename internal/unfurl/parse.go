@@ -0,0 +1,117 @@
+package unfurl
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// page - то, что нашлось в <head> страницы
+type page struct {
+	meta     Metadata // OpenGraph
+	fallback Metadata // <title> и <meta name="description">: если OpenGraph нет
+	oembed   string   // абсолютный адрес oEmbed описания (JSON), пусто - нет
+}
+
+// parseHTML разбирает заголовок страницы; base - адрес страницы для относительных ссылок
+// Разбор заканчивается на <body>: метаданные в <head>, а тело может быть большим
+func parseHTML(body []byte, base *url.URL) page {
+	var p page
+	z := html.NewTokenizer(bytes.NewReader(body))
+	inTitle := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return p
+		case html.TextToken:
+			if inTitle && p.fallback.Title == "" {
+				p.fallback.Title = string(z.Text())
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); atom.Lookup(name) == atom.Title {
+				inTitle = false
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Body:
+				return p
+			case atom.Title:
+				inTitle = true
+			case atom.Meta:
+				if hasAttr {
+					p.readMeta(attributes(z), base)
+				}
+			case atom.Link:
+				if hasAttr {
+					p.readLink(attributes(z), base)
+				}
+			}
+		}
+	}
+}
+
+// readMeta учитывает тег <meta>: property="og:*" (или name="og:*") и name="description"
+func (p *page) readMeta(attrs map[string]string, base *url.URL) {
+	key := strings.ToLower(attrs["property"])
+	if key == "" {
+		key = strings.ToLower(attrs["name"])
+	}
+	content := attrs["content"]
+	set := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	switch key {
+	case "og:title":
+		set(&p.meta.Title, content)
+	case "og:description":
+		set(&p.meta.Description, content)
+	case "og:image", "og:image:url", "og:image:secure_url":
+		set(&p.meta.ImageURL, resolve(base, content))
+	case "og:site_name":
+		set(&p.meta.SiteName, content)
+	case "description":
+		set(&p.fallback.Description, content)
+	}
+}
+
+// readLink учитывает <link rel="alternate" type="application/json+oembed" href="...">
+func (p *page) readLink(attrs map[string]string, base *url.URL) {
+	if p.oembed != "" || !strings.EqualFold(attrs["type"], "application/json+oembed") {
+		return
+	}
+	if !strings.Contains(strings.ToLower(attrs["rel"]), "alternate") {
+		return
+	}
+	p.oembed = resolve(base, attrs["href"])
+}
+
+// attributes возвращает атрибуты текущего тега (имена в нижнем регистре)
+func attributes(z *html.Tokenizer) map[string]string {
+	attrs := make(map[string]string)
+	for {
+		key, value, more := z.TagAttr()
+		attrs[string(key)] = string(value)
+		if !more {
+			return attrs
+		}
+	}
+}
+
+// resolve превращает ссылку страницы в абсолютный http(s) адрес; пусто - ссылка некорректна
+func resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.String()
+}
@@ -0,0 +1,206 @@
+// Package unfurl - превью ссылок из сообщений
+//
+// ChatService.SendMessage находит ссылки в тексте и ставит их в очередь (repository.LinkPreviewStore.Attach),
+// а Worker в фоне захватывает ожидающие превью (Claim), загружает метаданные страниц через
+// HTTPFetcher (OpenGraph и oEmbed, с таймаутом, ограничением размера и защитой от SSRF)
+// и сохраняет их. Превью - это кэш по адресу: одна загрузка на адрес для всех сообщений,
+// пока кэш не устареет. О загруженном превью подписчики чатов узнают событием message.preview
+//
+// Захват строк как у запланированных сообщений (internal/scheduler): на нескольких инстансах
+// страница загружается одним обработчиком, а если он пропал - после Lease ее заберет другой.
+// Сетевые ошибки и ответы 5xx повторяются с растущей паузой, после MaxAttempts (или сразу для
+// ErrBlocked и ErrUnsupported) превью получает статус failed
+//
+// Счетчики публикуются через expvar (переменная "unfurl", GET /admin/metrics)
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go-chat-app/internal/lease"
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository"
+)
+
+// metrics - счетчики загрузки:
+//
+//	fetched    - загружено превью
+//	retried    - неудачных попыток, после которых будет повтор
+//	failed     - превью, загрузка которых прекращена (status = failed)
+//	claim_lost - захватов, перешедших к другому обработчику до сохранения
+//	errors     - проходов, прерванных ошибкой хранилища
+var metrics = expvar.NewMap("unfurl")
+
+// Config - настройки обработчика
+type Config struct {
+	Interval    time.Duration // пауза между проходами, если ожидающих превью нет
+	BatchSize   int           // превью за один захват, они загружаются параллельно
+	Lease       time.Duration // захват: если инстанс пропал, через Lease превью загрузит другой
+	MaxAttempts int           // попыток до статуса failed
+	RetryDelay  time.Duration // пауза перед первым повтором, дальше удваивается (не больше часа)
+}
+
+// withDefaults подставляет значения по умолчанию для незаданных полей
+func (c Config) withDefaults() Config {
+	if c.Interval <= 0 {
+		c.Interval = time.Second
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 10
+	}
+	if c.Lease <= 0 {
+		c.Lease = time.Minute
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 3
+	}
+	if c.RetryDelay <= 0 {
+		c.RetryDelay = time.Minute
+	}
+	return c
+}
+
+// Fetcher загружает метаданные страницы (HTTPFetcher)
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) (Metadata, error)
+}
+
+// Notifier сообщает сообщениям со ссылкой о загруженном превью (service.ChatService)
+type Notifier interface {
+	PublishLinkPreview(ctx context.Context, preview models.LinkPreview)
+}
+
+// Worker загружает превью ссылок
+type Worker struct {
+	store    repository.LinkPreviewStore
+	fetcher  Fetcher
+	notifier Notifier
+	cfg      Config
+	holder   string // идентификатор этого инстанса в захватах
+	now      func() time.Time
+}
+
+// NewWorker создает обработчик превью ссылок
+func NewWorker(store repository.LinkPreviewStore, fetcher Fetcher, notifier Notifier, cfg Config) *Worker {
+	return &Worker{
+		store:    store,
+		fetcher:  fetcher,
+		notifier: notifier,
+		cfg:      cfg.withDefaults(),
+		holder:   lease.HolderID(),
+		now:      time.Now,
+	}
+}
+
+// Run загружает ожидающие превью до отмены ctx
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		claimed, err := w.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.WarnContext(ctx, "ошибка загрузки превью ссылок", slog.Any("error", err))
+		}
+
+		// Полный захват - скорее всего, ожидающих превью больше: продолжаем без паузы
+		if err == nil && claimed == w.cfg.BatchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce захватывает до BatchSize ожидающих превью и параллельно загружает их
+// Возвращает количество захваченных превью (загруженных и неудачных)
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	claimed, err := w.store.Claim(ctx, w.holder, w.now(), w.cfg.Lease, w.cfg.BatchSize)
+	if err != nil {
+		metrics.Add("errors", 1)
+		return 0, fmt.Errorf("захват превью ссылок: %w", err)
+	}
+	var wg sync.WaitGroup
+	for _, p := range claimed {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.unfurl(ctx, p)
+		}()
+	}
+	wg.Wait()
+	return len(claimed), nil
+}
+
+// unfurl загружает одно захваченное превью и записывает результат
+// Остановка сервера прерывает загрузку: превью остается захваченным и после Lease
+// достанется другому инстансу, попытка не засчитывается
+func (w *Worker) unfurl(ctx context.Context, p models.LinkPreview) {
+	meta, err := w.fetcher.Fetch(ctx, p.URL)
+	if ctx.Err() != nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	if err == nil {
+		p.Title = meta.Title
+		p.Description = meta.Description
+		p.ImageURL = meta.ImageURL
+		p.SiteName = meta.SiteName
+		switch err := w.store.Complete(ctx, &p, w.holder, w.now()); {
+		case errors.Is(err, repository.ErrPreviewClaimLost):
+			metrics.Add("claim_lost", 1)
+			slog.WarnContext(ctx, "захват превью ссылки потерян", slog.Uint64("preview_id", uint64(p.ID)))
+			return
+		case err != nil:
+			metrics.Add("errors", 1)
+			slog.WarnContext(ctx, "не удалось сохранить превью ссылки", slog.Any("error", err))
+			return
+		}
+		metrics.Add("fetched", 1)
+		slog.DebugContext(ctx, "превью ссылки загружено", slog.Uint64("preview_id", uint64(p.ID)), slog.String("url", p.URL))
+		w.notifier.PublishLinkPreview(ctx, p)
+		return
+	}
+
+	// Внутренний адрес и неподходящая страница не изменятся, остальные ошибки повторяем
+	var retryAt time.Time
+	attempt := p.Attempts + 1
+	if !errors.Is(err, ErrBlocked) && !errors.Is(err, ErrUnsupported) && attempt < w.cfg.MaxAttempts {
+		retryAt = w.now().Add(lease.RetryDelay(w.cfg.RetryDelay, attempt))
+	}
+	slog.InfoContext(ctx, "превью ссылки не загружено",
+		slog.Uint64("preview_id", uint64(p.ID)),
+		slog.String("url", p.URL),
+		slog.Int("attempt", attempt),
+		slog.Bool("retry", !retryAt.IsZero()),
+		slog.Any("error", err),
+	)
+	switch err := w.store.MarkFailed(ctx, p.ID, w.holder, err.Error(), retryAt); {
+	case errors.Is(err, repository.ErrPreviewClaimLost):
+		metrics.Add("claim_lost", 1)
+		return
+	case err != nil:
+		metrics.Add("errors", 1)
+		slog.WarnContext(ctx, "не удалось записать ошибку загрузки превью", slog.Any("error", err))
+		return
+	}
+	if !retryAt.IsZero() {
+		metrics.Add("retried", 1)
+		return
+	}
+	// Клиенты перестают ждать превью
+	metrics.Add("failed", 1)
+	p.Status = models.LinkPreviewFailed
+	w.notifier.PublishLinkPreview(ctx, p)
+}
@@ -0,0 +1,65 @@
+package unfurl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-chat-app/internal/db/service"
+	"go-chat-app/internal/models"
+	"go-chat-app/internal/repository/memory"
+)
+
+// TestWorker проверяет загрузку превью с тестового сайта: ready, повтор после 503,
+// окончательную неудачу и события message.preview
+func TestWorker(t *testing.T) {
+	srv := origin(t)
+	db := memory.New()
+	ctx := context.Background()
+	svc := service.NewChatService(db.Chats(), db.Messages(), service.WithLinkPreviews(db.LinkPreviews(), time.Hour))
+	chat, _ := svc.CreateChat(ctx, "ссылки")
+	events, cancel, _ := svc.Subscribe(ctx, chat.ID)
+	defer cancel()
+
+	m, err := svc.SendMessage(ctx, chat.ID, "смотри "+srv.URL+"/og, "+srv.URL+"/down и "+srv.URL+"/image.png")
+	if err != nil || len(m.Previews) != 3 {
+		t.Fatalf("SendMessage: %+v, %v", m, err)
+	}
+	<-events // message.created
+
+	w := NewWorker(db.LinkPreviews(), NewFetcher(FetcherConfig{AllowPrivate: true, Timeout: time.Second}), svc, Config{MaxAttempts: 2})
+	if n, err := w.RunOnce(ctx); err != nil || n != 3 {
+		t.Fatalf("RunOnce: %d, %v", n, err)
+	}
+
+	// Загруженное и окончательно неудачное превью приходят событиями, 503 ждет повтора
+	got := map[string]string{}
+	for range 2 {
+		event := <-events
+		if event.Type != service.EventMessagePreview || event.MessageID != m.ID {
+			t.Fatalf("Ожидалось message.preview, получено %+v", event)
+		}
+		got[event.Preview.URL] = event.Preview.Status
+	}
+	if got[srv.URL+"/og"] != models.LinkPreviewReady || got[srv.URL+"/image.png"] != models.LinkPreviewFailed {
+		t.Errorf("События превью: %v", got)
+	}
+
+	_, messages, _ := svc.GetChatWithMessages(ctx, chat.ID, 10)
+	previews := messages[0].Previews
+	if len(previews) != 3 || previews[0].Title != "Релиз 2.0 & заметки" || previews[1].Status != models.LinkPreviewPending {
+		t.Fatalf("Превью сообщения: %+v", previews)
+	}
+	if n, _ := w.RunOnce(ctx); n != 0 {
+		t.Errorf("До времени повтора превью не должно захватываться, захвачено %d", n)
+	}
+
+	// Вторая неудача - последняя попытка
+	w.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if n, _ := w.RunOnce(ctx); n != 1 {
+		t.Fatalf("Повтор: захвачено %d", n)
+	}
+	if event := <-events; event.Preview.URL != srv.URL+"/down" || event.Preview.Status != models.LinkPreviewFailed {
+		t.Errorf("Ожидалась окончательная неудача /down, получено %+v", event.Preview)
+	}
+}
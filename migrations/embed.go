@@ -0,0 +1,24 @@
+// Package migrations содержит SQL миграции, встроенные в бинарник
+// Для каждого диалекта своя папка, номера версий в них совпадают
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+)
+
+// files - встроенные файлы миграций goose
+//
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// FS возвращает миграции для диалекта ("postgres" или "sqlite")
+func FS(dialect string) (fs.FS, error) {
+	switch dialect {
+	case "postgres", "sqlite":
+		return fs.Sub(files, dialect)
+	default:
+		return nil, fmt.Errorf("нет миграций для диалекта %q", dialect)
+	}
+}
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Медленный режим чата: один пользователь может писать не чаще раза в N секунд
+-- 0 - медленный режим выключен
+ALTER TABLE chats ADD COLUMN slow_mode_seconds INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE chats DROP COLUMN slow_mode_seconds;
+-- +goose StatementEnd
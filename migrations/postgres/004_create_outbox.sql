@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Исходящие события (transactional outbox)
+-- Строка пишется в той же транзакции, что и само изменение (сообщение, удаление чата),
+-- поэтому событие не теряется, даже если процесс упадет сразу после COMMIT
+-- Внешнего ключа на chats нет: событие должно пережить удаление чата
+CREATE TABLE outbox (
+                        id BIGSERIAL PRIMARY KEY,              -- порядок событий
+                        chat_id INTEGER NOT NULL,              -- события одного чата доставляются по порядку id
+                        event_type VARCHAR(50) NOT NULL,       -- message.created, chat.deleted
+                        payload JSONB NOT NULL,
+                        attempts INTEGER NOT NULL DEFAULT 0,   -- неудачные попытки доставки
+                        last_error TEXT NOT NULL DEFAULT '',
+                        created_at TIMESTAMP DEFAULT NOW(),
+                        delivered_at TIMESTAMP                 -- NULL - еще не доставлено
+);
+
+-- Частичный индекс: relay читает только недоставленные события
+CREATE INDEX idx_outbox_pending ON outbox(id) WHERE delivered_at IS NULL;
+-- Индекс для очистки доставленных событий
+CREATE INDEX idx_outbox_delivered_at ON outbox(delivered_at);
+
+-- Аренда relay: события публикует только один инстанс, иначе нарушится порядок в чате
+CREATE TABLE outbox_leases (
+                               name VARCHAR(50) PRIMARY KEY,
+                               holder TEXT NOT NULL,          -- идентификатор инстанса
+                               expires_at TIMESTAMP NOT NULL  -- после этого аренду может забрать другой инстанс
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS outbox_leases;
+DROP TABLE IF EXISTS outbox;
+-- +goose StatementEnd
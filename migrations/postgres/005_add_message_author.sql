@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Автор сообщения: ID пользователя из заголовка шлюза (AUTH_USER_HEADER)
+-- Пустая строка - сообщение отправлено анонимно или до появления колонки
+ALTER TABLE messages ADD COLUMN author_id VARCHAR(128) NOT NULL DEFAULT '';
+
+-- Хронологическое чтение истории чата (выгрузка) идет по (created_at, id) внутри чата:
+-- индекс позволяет читать пачками по ключу без сортировки всей истории
+CREATE INDEX idx_messages_chat_created_id ON messages(chat_id, created_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_messages_chat_created_id;
+ALTER TABLE messages DROP COLUMN author_id;
+-- +goose StatementEnd
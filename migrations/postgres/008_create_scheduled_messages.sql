@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Запланированные сообщения: фоновый обработчик отправляет их в чат в момент send_at
+-- Строка остается после отправки (status = sent, message_id) или отмены - это история для клиента
+CREATE TABLE scheduled_messages (
+                                    id SERIAL PRIMARY KEY,
+                                    chat_id INTEGER NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+                                    author_id VARCHAR(128) NOT NULL DEFAULT '',  -- станет автором отправленного сообщения
+                                    text TEXT NOT NULL,
+                                    send_at TIMESTAMP NOT NULL,
+                                    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, sending, sent, failed, canceled
+                                    attempts INTEGER NOT NULL DEFAULT 0,         -- неудачные попытки отправки
+                                    last_error TEXT NOT NULL DEFAULT '',
+                                    message_id INTEGER REFERENCES messages(id) ON DELETE SET NULL, -- отправленное сообщение
+                                    locked_by TEXT NOT NULL DEFAULT '',          -- инстанс, который отправляет сообщение
+                                    locked_until TIMESTAMP,                      -- после этого захват может забрать другой инстанс
+                                    created_at TIMESTAMP DEFAULT NOW(),
+                                    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Частичный индекс: обработчик ищет только ожидающие и захваченные сообщения
+CREATE INDEX idx_scheduled_messages_due ON scheduled_messages(send_at, id) WHERE status IN ('pending', 'sending');
+-- Список запланированных сообщений чата
+CREATE INDEX idx_scheduled_messages_chat ON scheduled_messages(chat_id, send_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS scheduled_messages;
+-- +goose StatementEnd
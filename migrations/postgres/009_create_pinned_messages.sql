@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Вид служебного сообщения, созданного сервером (pin - сообщение о закреплении)
+-- Пустая строка - обычное сообщение пользователя
+ALTER TABLE messages ADD COLUMN kind VARCHAR(20) NOT NULL DEFAULT '';
+
+-- Закрепленные сообщения: сообщение закрепляется в чате не больше одного раза
+-- Удаление сообщения (очистка, исчезающие сообщения) открепляет его
+CREATE TABLE pinned_messages (
+                                 chat_id INTEGER NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+                                 message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+                                 pinned_by VARCHAR(128) NOT NULL DEFAULT '', -- пользователь, закрепивший сообщение
+                                 pinned_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                 PRIMARY KEY (chat_id, message_id)
+);
+
+-- Список закрепленных сообщений чата: последние закрепленные первыми
+CREATE INDEX idx_pinned_messages_chat ON pinned_messages(chat_id, pinned_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS pinned_messages;
+ALTER TABLE messages DROP COLUMN kind;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Формат текста сообщения: plain - показывается как есть, markdown - с разметкой
+ALTER TABLE messages ADD COLUMN format VARCHAR(20) NOT NULL DEFAULT 'plain';
+
+-- Безопасный HTML markdown сообщения, его строит сервер при отправке
+-- Пустая строка - сообщение plain, HTML нет
+ALTER TABLE messages ADD COLUMN html TEXT NOT NULL DEFAULT '';
+
+-- Элементы разметки для клиентов без HTML: JSON массив
+-- [{"type": "bold", "offset": 0, "length": 8}]; NULL - разметки нет
+ALTER TABLE messages ADD COLUMN entities TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE messages DROP COLUMN entities;
+ALTER TABLE messages DROP COLUMN html;
+ALTER TABLE messages DROP COLUMN format;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Сообщение события message.created / message.deleted (NULL - chat.deleted и события,
+-- записанные до этой миграции). По нему очистка исчезающих сообщений удаляет событие
+-- message.created вместе с сообщением: текст исчезнувшего сообщения не должен остаться в outbox
+ALTER TABLE outbox ADD COLUMN message_id INTEGER;
+CREATE INDEX idx_outbox_message_id ON outbox(message_id) WHERE message_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_outbox_message_id;
+ALTER TABLE outbox DROP COLUMN message_id;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Версия для SQLite: та же схема, что и в migrations/postgres/001_create_tables.sql
+-- Отличия диалекта: INTEGER PRIMARY KEY AUTOINCREMENT вместо SERIAL,
+-- DATETIME и CURRENT_TIMESTAMP вместо TIMESTAMP и NOW()
+
+-- Создаем таблицу "чаты" для хранения информации о чатах
+CREATE TABLE chats (
+                       id INTEGER PRIMARY KEY AUTOINCREMENT, -- Уникальный идентификатор, автоматически увеличивается
+                       title VARCHAR(200) NOT NULL,          -- Заголовок чата, обязательное поле, максимум 200 символов
+                       created_at DATETIME DEFAULT CURRENT_TIMESTAMP, -- Дата и время создания
+                       deleted_at DATETIME
+);
+
+-- Создаем таблицу "сообщения" для хранения текста сообщений
+-- ON DELETE CASCADE работает, только если включен PRAGMA foreign_keys (см. internal/db/sqlite)
+CREATE TABLE messages (
+                          id INTEGER PRIMARY KEY AUTOINCREMENT, -- Уникальный идентификатор сообщения
+                          chat_id INTEGER NOT NULL REFERENCES chats(id) ON DELETE CASCADE, -- Ссылка на чат
+                          text TEXT NOT NULL,                   -- Текст сообщения, обязательное поле
+                          created_at DATETIME DEFAULT CURRENT_TIMESTAMP -- Дата и время создания сообщения
+);
+
+-- Создаем индекс для ускорения поиска сообщений по ID чата
+CREATE INDEX idx_messages_chat_id ON messages(chat_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS chats;
+-- +goose StatementEnd
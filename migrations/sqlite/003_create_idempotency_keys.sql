@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Ключи идемпотентности (заголовок Idempotency-Key или client_msg_id)
+-- Повторный POST с тем же ключом получает сохраненный ответ, а не создает дубликат
+CREATE TABLE idempotency_keys (
+                                  scope TEXT NOT NULL,                -- клиент и endpoint: ключи разных клиентов не пересекаются
+                                  idempotency_key TEXT NOT NULL,      -- ключ от клиента
+                                  request_hash TEXT NOT NULL,         -- хеш запроса: тот же ключ с другим телом - ошибка 422
+                                  status_code INTEGER NOT NULL DEFAULT 0, -- 0 - запрос еще выполняется
+                                  response_body BLOB,
+                                  content_type TEXT NOT NULL DEFAULT '',
+                                  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                                  expires_at DATETIME NOT NULL,       -- после этого ключ можно использовать заново
+                                  PRIMARY KEY (scope, idempotency_key)
+);
+
+-- Индекс для очистки устаревших ключей
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS idempotency_keys;
+-- +goose StatementEnd
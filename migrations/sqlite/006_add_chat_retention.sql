@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Срок хранения сообщений чата в днях: сообщения старше удаляет фоновая очистка
+-- NULL - срок по умолчанию сервера (retention.default_days), 0 - хранить всегда
+-- Очистка ищет старые сообщения чата по индексу idx_messages_chat_created_id
+ALTER TABLE chats ADD COLUMN retention_days INTEGER;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE chats DROP COLUMN retention_days;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Исчезающие сообщения: после expires_at сообщение скрывается из чтения,
+-- а фоновая очистка удаляет его и сообщает подписчикам (message.deleted)
+-- NULL - обычное сообщение, хранится по сроку хранения чата
+ALTER TABLE messages ADD COLUMN expires_at DATETIME;
+
+-- Частичный индекс: очистка ищет только исчезающие сообщения, обычных он не содержит
+CREATE INDEX idx_messages_expires_at ON messages(expires_at) WHERE expires_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_messages_expires_at;
+ALTER TABLE messages DROP COLUMN expires_at;
+-- +goose StatementEnd
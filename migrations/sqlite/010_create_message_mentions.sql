@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Упоминания в тексте сообщения для отображения клиентом: JSON массив
+-- [{"offset": 0, "length": 6, "target": "alice"}]; NULL - упоминаний нет
+ALTER TABLE messages ADD COLUMN mentions TEXT;
+
+-- Уведомления об упоминаниях: одна строка на упомянутого пользователя
+-- Удаление сообщения удаляет и уведомления о нем
+CREATE TABLE message_mentions (
+                                  id INTEGER PRIMARY KEY AUTOINCREMENT,
+                                  user_id VARCHAR(128) NOT NULL,               -- упомянутый пользователь
+                                  chat_id INTEGER NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+                                  message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+                                  author_id VARCHAR(128) NOT NULL DEFAULT '',  -- кто упомянул
+                                  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                                  read_at DATETIME,                            -- NULL - не прочитано
+                                  UNIQUE (message_id, user_id)
+);
+
+-- GET /me/mentions: уведомления пользователя, новые первыми
+CREATE INDEX idx_message_mentions_user ON message_mentions(user_id, id);
+-- Частичный индекс: непрочитанные уведомления и их количество
+CREATE INDEX idx_message_mentions_unread ON message_mentions(user_id, id) WHERE read_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS message_mentions;
+ALTER TABLE messages DROP COLUMN mentions;
+-- +goose StatementEnd
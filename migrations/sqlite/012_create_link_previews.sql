@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Превью ссылок: кэш метаданных страницы (OpenGraph/oEmbed) по адресу
+-- Одна строка на адрес, ее используют все сообщения с этой ссылкой; загружает фоновый обработчик
+CREATE TABLE link_previews (
+                               id INTEGER PRIMARY KEY AUTOINCREMENT,
+                               url VARCHAR(2048) NOT NULL UNIQUE,
+                               status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, fetching, ready, failed
+                               title VARCHAR(300) NOT NULL DEFAULT '',
+                               description TEXT NOT NULL DEFAULT '',
+                               image_url VARCHAR(2048) NOT NULL DEFAULT '',
+                               site_name VARCHAR(200) NOT NULL DEFAULT '',
+                               attempts INTEGER NOT NULL DEFAULT 0,         -- неудачные попытки загрузки
+                               last_error TEXT NOT NULL DEFAULT '',
+                               locked_by TEXT NOT NULL DEFAULT '',          -- инстанс, который загружает страницу
+                               locked_until DATETIME,                       -- конец захвата или время повтора
+                               queued_at DATETIME NOT NULL,                 -- когда адрес (снова) поставлен в очередь
+                               fetched_at DATETIME,                         -- последняя завершенная загрузка (ready или failed)
+                               created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                               updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Частичный индекс: обработчик ищет только ожидающие и захваченные адреса
+CREATE INDEX idx_link_previews_due ON link_previews(queued_at, id) WHERE status IN ('pending', 'fetching');
+
+-- Ссылки сообщений: position - порядок ссылок в тексте
+-- Удаление сообщения удаляет и его ссылки, превью остается в кэше
+CREATE TABLE message_links (
+                               message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+                               preview_id INTEGER NOT NULL REFERENCES link_previews(id) ON DELETE CASCADE,
+                               position INTEGER NOT NULL,
+                               created_at DATETIME NOT NULL,
+                               PRIMARY KEY (message_id, preview_id)
+);
+
+-- Кому сообщить о загруженном превью: сообщения с этой ссылкой
+CREATE INDEX idx_message_links_preview ON message_links(preview_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS message_links;
+DROP TABLE IF EXISTS link_previews;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Опрос (сообщение kind = 'poll', вопрос - текст сообщения): JSON объект
+-- {"options": [{"text": "Да"}, {"text": "Нет"}], "multiple": false, "anonymous": true, "closes_at": "..."}
+-- NULL - обычное сообщение. Итоги в колонке не обновляются: их считают по poll_votes при чтении
+ALTER TABLE messages ADD COLUMN poll TEXT;
+
+-- Голоса: одна строка на выбранный пользователем вариант (в опросе с несколькими
+-- вариантами - несколько строк). Итоги считаются по этой таблице при чтении
+-- Удаление сообщения удаляет и голоса
+CREATE TABLE poll_votes (
+                            message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+                            user_id VARCHAR(128) NOT NULL,   -- проголосовавший пользователь
+                            option_index INTEGER NOT NULL,   -- номер варианта в poll.options, с 0
+                            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                            PRIMARY KEY (message_id, user_id, option_index)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS poll_votes;
+ALTER TABLE messages DROP COLUMN poll;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Пересланное сообщение: откуда оно переслано, JSON объект
+-- {"chat_id": 1, "chat_title": "Общий", "message_id": 42, "author_id": "alice", "sent_at": "..."}
+-- NULL - сообщение написано в этом чате. Ссылка не внешний ключ: оригинал может быть удален
+-- (срок хранения, исчезающие сообщения, удаление чата), а пересланная копия остается
+ALTER TABLE messages ADD COLUMN forwarded_from TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE messages DROP COLUMN forwarded_from;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Повторы доставки outbox: после неудачи событие ждет next_attempt_at (пауза растет с каждой
+-- попыткой), а после outbox.max_attempts relay его бросает (failed_at). Пока первое событие
+-- чата ждет повтора, relay не читает события этого чата и доставляет события других чатов
+ALTER TABLE outbox ADD COLUMN next_attempt_at DATETIME;
+ALTER TABLE outbox ADD COLUMN failed_at DATETIME;
+
+DROP INDEX IF EXISTS idx_outbox_pending;
+CREATE INDEX idx_outbox_pending ON outbox(id) WHERE delivered_at IS NULL AND failed_at IS NULL;
+CREATE INDEX idx_outbox_retry ON outbox(next_attempt_at) WHERE delivered_at IS NULL AND failed_at IS NULL AND next_attempt_at IS NOT NULL;
+-- Индекс для очистки брошенных событий
+CREATE INDEX idx_outbox_failed_at ON outbox(failed_at) WHERE failed_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_outbox_failed_at;
+DROP INDEX IF EXISTS idx_outbox_retry;
+DROP INDEX IF EXISTS idx_outbox_pending;
+CREATE INDEX idx_outbox_pending ON outbox(id) WHERE delivered_at IS NULL;
+ALTER TABLE outbox DROP COLUMN failed_at;
+ALTER TABLE outbox DROP COLUMN next_attempt_at;
+-- +goose StatementEnd
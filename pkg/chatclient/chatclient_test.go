@@ -0,0 +1,521 @@
+package chatclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-chat-app/internal/db/service"
+	"go-chat-app/internal/handler"
+	"go-chat-app/internal/repository/memory"
+	"go-chat-app/internal/server"
+)
+
+// newTestServer запускает настоящий роутер API поверх хранилища в памяти
+// wrap позволяет вмешаться в ответы (имитировать сбои), nil - без вмешательства
+func newTestServer(t *testing.T, wrap func(http.Handler) http.Handler) (*httptest.Server, *memory.DB) {
+	t.Helper()
+	db := memory.New()
+	svc := service.NewChatService(db.Chats(), db.Messages(), service.WithScheduledStore(db.Scheduled()), service.WithPins(db.Pins(), 0), service.WithMentions(db.Mentions()), service.WithPolls(db.Polls()))
+	var h http.Handler = server.NewRouter(svc, handler.NewHealthHandler(time.Second), server.Options{
+		UserHeader:       "X-User-ID",
+		IdempotencyStore: db.Idempotency(),
+	})
+	if wrap != nil {
+		h = wrap(h)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv, db
+}
+
+// fastRetries - короткие задержки, чтобы тесты с повторами шли быстро
+var fastRetries = WithRetries(4, time.Millisecond, 10*time.Millisecond)
+
+// TestChatLifecycle проверяет все операции с чатом через настоящие обработчики
+func TestChatLifecycle(t *testing.T) {
+	srv, _ := newTestServer(t, nil)
+	c := New(srv.URL, fastRetries)
+	ctx := context.Background()
+
+	chat, err := c.CreateChat(ctx, "  Общий  ")
+	if err != nil {
+		t.Fatalf("CreateChat: %v", err)
+	}
+	if chat.ID == 0 || chat.Title != "Общий" || chat.CreatedAt.IsZero() {
+		t.Fatalf("Неверный чат: %+v", chat)
+	}
+
+	for _, text := range []string{"первое", "второе", "третье"} {
+		msg, err := c.SendMessage(ctx, chat.ID, text)
+		if err != nil {
+			t.Fatalf("SendMessage: %v", err)
+		}
+		if msg.ChatID != chat.ID || msg.Text != text {
+			t.Fatalf("Неверное сообщение: %+v", msg)
+		}
+	}
+
+	messages, err := c.ListMessages(ctx, chat.ID, 2)
+	if err != nil {
+		t.Fatalf("ListMessages: %v", err)
+	}
+	if len(messages) != 2 || messages[0].Text != "третье" || messages[1].Text != "второе" {
+		t.Errorf("Ожидались 2 последних сообщения от новых к старым, получено %+v", messages)
+	}
+
+	second, _ := c.CreateChat(ctx, "Второй")
+	page, err := c.ListChats(ctx, 0, 1)
+	if err != nil || len(page.Chats) != 1 || page.Chats[0].ID != chat.ID || page.NextAfter != chat.ID {
+		t.Fatalf("ListChats: первая страница %+v, %v", page, err)
+	}
+	page, err = c.ListChats(ctx, page.NextAfter, 1)
+	if err != nil || len(page.Chats) != 1 || page.Chats[0].ID != second.ID {
+		t.Fatalf("ListChats: вторая страница %+v, %v", page, err)
+	}
+
+	updated, err := c.SetSlowMode(ctx, chat.ID, 30)
+	if err != nil || updated.SlowModeSeconds != 30 {
+		t.Fatalf("SetSlowMode: %+v, %v", updated, err)
+	}
+	days := 90
+	if updated, err := c.SetRetention(ctx, chat.ID, &days); err != nil || updated.RetentionDays == nil || *updated.RetentionDays != 90 {
+		t.Fatalf("SetRetention: %+v, %v", updated, err)
+	}
+	got, err := c.GetChat(ctx, chat.ID, 0)
+	if err != nil || got.Chat.SlowModeSeconds != 30 || len(got.Messages) != 3 {
+		t.Fatalf("GetChat: %+v, %v", got, err)
+	}
+	if updated, err := c.SetRetention(ctx, chat.ID, nil); err != nil || updated.RetentionDays != nil || updated.SlowModeSeconds != 30 {
+		t.Fatalf("SetRetention(nil): %+v, %v", updated, err)
+	}
+
+	if err := c.DeleteChat(ctx, chat.ID); err != nil {
+		t.Fatalf("DeleteChat: %v", err)
+	}
+	_, err = c.GetChat(ctx, chat.ID, 0)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Ожидалась ErrNotFound, получено %v", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "Чат не найден" || apiErr.RequestID == "" {
+		t.Errorf("Неверная APIError: %+v", apiErr)
+	}
+}
+
+// TestEphemeralMessage проверяет отправку исчезающих сообщений
+func TestEphemeralMessage(t *testing.T) {
+	srv, _ := newTestServer(t, nil)
+	c := New(srv.URL, fastRetries)
+	ctx := context.Background()
+	chat, _ := c.CreateChat(ctx, "Общий")
+
+	before := time.Now()
+	msg, err := c.SendMessage(ctx, chat.ID, "исчезнет", MessageTTL(90*time.Minute))
+	if err != nil || msg.ExpiresAt == nil || msg.ExpiresAt.Before(before.Add(90*time.Minute)) {
+		t.Fatalf("MessageTTL: %+v, %v", msg, err)
+	}
+	at := time.Now().Add(time.Hour).Truncate(time.Second)
+	if msg, err = c.SendMessage(ctx, chat.ID, "тоже", MessageExpiresAt(at)); err != nil || msg.ExpiresAt == nil || !msg.ExpiresAt.Equal(at) {
+		t.Fatalf("MessageExpiresAt: %+v, %v", msg, err)
+	}
+	if msg, _ = c.SendMessage(ctx, chat.ID, "обычное"); msg.ExpiresAt != nil {
+		t.Errorf("Обычное сообщение не должно исчезать: %+v", msg)
+	}
+	if _, err := c.SendMessage(ctx, chat.ID, "x", MessageExpiresAt(time.Now().Add(-time.Minute))); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Время в прошлом: ожидалась ErrBadRequest, получено %v", err)
+	}
+}
+
+// TestMarkdown проверяет отправку сообщения с разметкой
+func TestMarkdown(t *testing.T) {
+	srv, _ := newTestServer(t, nil)
+	c := New(srv.URL, fastRetries)
+	ctx := context.Background()
+	chat, _ := c.CreateChat(ctx, "Общий")
+
+	msg, err := c.SendMessage(ctx, chat.ID, "см. [доку](https://example.com)", Markdown())
+	if err != nil || msg.Format != FormatMarkdown || len(msg.Entities) != 1 || msg.Entities[0].Type != EntityLink {
+		t.Fatalf("SendMessage: %+v, %v", msg, err)
+	}
+	if !strings.Contains(msg.HTML, `<a href="https://example.com"`) {
+		t.Errorf("Неверный HTML: %q", msg.HTML)
+	}
+	if plain, _ := c.SendMessage(ctx, chat.ID, "просто"); plain.Format != FormatPlain || plain.HTML != "" {
+		t.Errorf("Сообщение без разметки: %+v", plain)
+	}
+}
+
+// TestPins проверяет закрепление и открепление сообщения
+func TestPins(t *testing.T) {
+	srv, _ := newTestServer(t, nil)
+	c := New(srv.URL, fastRetries)
+	ctx := context.Background()
+	chat, _ := c.CreateChat(ctx, "Общий")
+	msg, _ := c.SendMessage(ctx, chat.ID, "релиз в пятницу")
+
+	pin, err := c.PinMessage(ctx, chat.ID, msg.ID)
+	if err != nil || pin.MessageID != msg.ID || pin.Message.Text != msg.Text {
+		t.Fatalf("PinMessage: %+v, %v", pin, err)
+	}
+	if _, err := c.PinMessage(ctx, chat.ID, msg.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("Повторное закрепление: ожидалась ErrConflict, получено %v", err)
+	}
+	got, err := c.GetChat(ctx, chat.ID, 10)
+	if err != nil || len(got.PinnedMessageIDs) != 1 || got.PinnedMessageIDs[0] != msg.ID {
+		t.Fatalf("GetChat: %+v, %v", got, err)
+	}
+	if got.Messages[0].Kind != MessageKindPin {
+		t.Errorf("Ожидалось служебное сообщение о закреплении: %+v", got.Messages[0])
+	}
+
+	if err := c.UnpinMessage(ctx, chat.ID, msg.ID); err != nil {
+		t.Fatalf("UnpinMessage: %v", err)
+	}
+	if pins, err := c.ListPins(ctx, chat.ID); err != nil || len(pins) != 0 {
+		t.Errorf("ListPins после открепления: %+v, %v", pins, err)
+	}
+}
+
+// TestPolls проверяет создание опроса, голосование и итоги в GetChat
+func TestPolls(t *testing.T) {
+	srv, _ := newTestServer(t, nil)
+	alice := New(srv.URL, fastRetries, WithHeader("X-User-ID", "alice"))
+	bob := New(srv.URL, fastRetries, WithHeader("X-User-ID", "bob"))
+	ctx := context.Background()
+	chat, _ := alice.CreateChat(ctx, "Общий")
+
+	msg, err := alice.SendMessage(ctx, chat.ID, "Что чинить?", AsPoll([]string{"Поиск", "Экспорт", "Вход"}, PollSettings{Multiple: true}))
+	if err != nil || msg.Kind != MessageKindPoll || len(msg.Poll.Options) != 3 {
+		t.Fatalf("SendMessage: %+v, %v", msg, err)
+	}
+	if _, err := alice.SendMessage(ctx, chat.ID, "?", AsPoll([]string{"Да"}, PollSettings{})); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Один вариант: ожидалась ErrBadRequest, получено %v", err)
+	}
+
+	if _, err := alice.Vote(ctx, chat.ID, msg.ID, 0, 2); err != nil {
+		t.Fatalf("Vote: %v", err)
+	}
+	poll, err := bob.Vote(ctx, chat.ID, msg.ID, 2)
+	if err != nil || poll.TotalVoters != 2 || poll.Options[2].Votes != 2 || len(poll.MyVotes) != 1 || poll.MyVotes[0] != 2 {
+		t.Fatalf("Vote: %+v, %v", poll, err)
+	}
+	if _, err := bob.Vote(ctx, chat.ID, msg.ID, 5); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Несуществующий вариант: ожидалась ErrBadRequest, получено %v", err)
+	}
+	if poll, err := bob.RetractVote(ctx, chat.ID, msg.ID); err != nil || poll.TotalVoters != 1 || poll.MyVotes != nil {
+		t.Errorf("RetractVote: %+v, %v", poll, err)
+	}
+
+	got, err := alice.GetChat(ctx, chat.ID, 10)
+	if err != nil || got.Messages[0].Poll == nil {
+		t.Fatalf("GetChat: %+v, %v", got, err)
+	}
+	if poll := got.Messages[0].Poll; poll.Options[0].Voters[0] != "alice" || len(poll.MyVotes) != 2 {
+		t.Errorf("Итоги в GetChat: %+v", poll)
+	}
+}
+
+// TestForwardMessage проверяет пересылку сообщения в другой чат
+func TestForwardMessage(t *testing.T) {
+	srv, _ := newTestServer(t, nil)
+	c := New(srv.URL, fastRetries, WithHeader("X-User-ID", "alice"))
+	ctx := context.Background()
+	source, _ := c.CreateChat(ctx, "Команда")
+	target, _ := c.CreateChat(ctx, "Общий")
+	msg, _ := c.SendMessage(ctx, source.ID, "план на неделю")
+
+	fwd, err := c.ForwardMessage(ctx, source.ID, msg.ID, target.ID)
+	if err != nil || fwd.ChatID != target.ID || fwd.Text != msg.Text || fwd.Forwarded == nil {
+		t.Fatalf("ForwardMessage: %+v, %v", fwd, err)
+	}
+	if f := fwd.Forwarded; f.ChatID != source.ID || f.ChatTitle != "Команда" || f.MessageID != msg.ID || f.AuthorID != "alice" {
+		t.Errorf("Ссылка на оригинал: %+v", f)
+	}
+	if _, err := c.ForwardMessage(ctx, source.ID, msg.ID, source.ID); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("В тот же чат: ожидалась ErrBadRequest, получено %v", err)
+	}
+	if _, err := New(srv.URL).ForwardMessage(ctx, source.ID, msg.ID, target.ID); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Без пользователя ожидалась ErrUnauthorized, получено %v", err)
+	}
+	if _, err := c.ForwardMessage(ctx, source.ID, msg.ID, 999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("В несуществующий чат: ожидалась ErrNotFound, получено %v", err)
+	}
+
+	// Чат оригинала удален: копия остается, ссылка отмечена удаленной
+	if err := c.DeleteChat(ctx, source.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.GetChat(ctx, target.ID, 10)
+	if err != nil || !got.Messages[0].Forwarded.Deleted {
+		t.Errorf("GetChat: ожидалась ссылка на удаленный оригинал, получено %+v, %v", got, err)
+	}
+}
+
+// TestMentions проверяет сущности упоминаний в сообщении и уведомления упомянутого пользователя
+func TestMentions(t *testing.T) {
+	srv, _ := newTestServer(t, nil)
+	alice := New(srv.URL, fastRetries, WithHeader("X-User-ID", "alice"))
+	bob := New(srv.URL, fastRetries, WithHeader("X-User-ID", "bob"))
+	ctx := context.Background()
+	chat, _ := alice.CreateChat(ctx, "Общий")
+
+	msg, err := alice.SendMessage(ctx, chat.ID, "Привет, @bob!")
+	if err != nil || len(msg.Mentions) != 1 || msg.Mentions[0] != (Mention{Offset: 8, Length: 4, Target: "bob"}) {
+		t.Fatalf("SendMessage: %+v, %v", msg, err)
+	}
+	page, err := bob.ListMentions(ctx, true, 0, 0)
+	if err != nil || len(page.Mentions) != 1 || page.UnreadCount != 1 || page.Mentions[0].Message.ID != msg.ID {
+		t.Fatalf("ListMentions: %+v, %v", page, err)
+	}
+	if marked, err := bob.MarkMentionsRead(ctx, page.Mentions[0].ID); err != nil || marked != 1 {
+		t.Fatalf("MarkMentionsRead: %d, %v", marked, err)
+	}
+	if page, _ := bob.ListMentions(ctx, true, 0, 0); len(page.Mentions) != 0 || page.UnreadCount != 0 {
+		t.Errorf("После прочтения: %+v", page)
+	}
+	if _, err := New(srv.URL).ListMentions(ctx, false, 0, 0); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Без пользователя ожидалась ErrUnauthorized, получено %v", err)
+	}
+}
+
+// TestScheduledMessage проверяет планирование, перенос и отмену сообщения
+func TestScheduledMessage(t *testing.T) {
+	srv, _ := newTestServer(t, nil)
+	c := New(srv.URL, fastRetries)
+	ctx := context.Background()
+	chat, _ := c.CreateChat(ctx, "Общий")
+
+	at := time.Now().Add(time.Hour).Truncate(time.Second)
+	msg, err := c.ScheduleMessage(ctx, chat.ID, "стендап", at)
+	if err != nil || msg.Status != ScheduledPending || !msg.SendAt.Equal(at) {
+		t.Fatalf("ScheduleMessage: %+v, %v", msg, err)
+	}
+	at = at.Add(time.Hour)
+	if msg, err = c.RescheduleMessage(ctx, chat.ID, msg.ID, "", at); err != nil || msg.Text != "стендап" || !msg.SendAt.Equal(at) {
+		t.Fatalf("RescheduleMessage: %+v, %v", msg, err)
+	}
+	if err := c.CancelScheduled(ctx, chat.ID, msg.ID); err != nil {
+		t.Fatalf("CancelScheduled: %v", err)
+	}
+	// Отмененное сообщение не меняется: 409 без ключа идемпотентности не повторяется
+	if err := c.CancelScheduled(ctx, chat.ID, msg.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("Повторная отмена: ожидалась ErrConflict, получено %v", err)
+	}
+	list, err := c.ListScheduled(ctx, chat.ID, ScheduledCanceled, 0)
+	if err != nil || len(list) != 1 || list[0].ID != msg.ID {
+		t.Errorf("ListScheduled: %+v, %v", list, err)
+	}
+	if _, err := c.ScheduleMessage(ctx, chat.ID, "x", time.Now().Add(-time.Minute)); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Время в прошлом: ожидалась ErrBadRequest, получено %v", err)
+	}
+}
+
+// TestErrorsNotRetried проверяет, что ошибки клиента (4xx) возвращаются сразу
+func TestErrorsNotRetried(t *testing.T) {
+	var requests atomic.Int32
+	srv, _ := newTestServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			next.ServeHTTP(w, r)
+		})
+	})
+	c := New(srv.URL, fastRetries)
+	ctx := context.Background()
+
+	if _, err := c.CreateChat(ctx, " "); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Пустое название: ожидалась ErrBadRequest, получено %v", err)
+	}
+	if _, err := c.SendMessage(ctx, 42, "x"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Несуществующий чат: ожидалась ErrNotFound, получено %v", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Ошибки 4xx не должны повторяться: %d запросов вместо 2", n)
+	}
+}
+
+// TestRetryIsIdempotent проверяет повтор после потерянного ответа: сервер создал чат,
+// но клиент получил 502 - повтор с тем же ключом не должен создать второй чат
+func TestRetryIsIdempotent(t *testing.T) {
+	var requests atomic.Int32
+	srv, db := newTestServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) == 1 {
+				next.ServeHTTP(httptest.NewRecorder(), r) // ответ "теряется" по дороге
+				http.Error(w, "bad gateway", http.StatusBadGateway)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	c := New(srv.URL, fastRetries)
+
+	chat, err := c.CreateChat(context.Background(), "Общий")
+	if err != nil {
+		t.Fatalf("CreateChat должен пережить 502: %v", err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("Ожидалось 2 запроса, выполнено %d", requests.Load())
+	}
+	if _, err := db.Chats().GetByID(context.Background(), chat.ID+1); err == nil {
+		t.Error("Повтор создал второй чат")
+	}
+}
+
+// TestRetryLimits проверяет исчерпание попыток, отмену контекста и длинный Retry-After
+func TestRetryLimits(t *testing.T) {
+	var requests atomic.Int32
+	var retryAfter atomic.Value
+	retryAfter.Store("")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if v := retryAfter.Load().(string); v != "" {
+			w.Header().Set("Retry-After", v)
+			http.Error(w, "Слишком много запросов", http.StatusTooManyRequests)
+			return
+		}
+		http.Error(w, "Ошибка сервера", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	_, err := New(srv.URL, fastRetries).GetChat(ctx, 1, 0)
+	if !errors.Is(err, ErrServer) || requests.Load() != 4 {
+		t.Errorf("Ожидалась ErrServer после 4 попыток: %v, попыток %d", err, requests.Load())
+	}
+
+	slow := New(srv.URL, WithRetries(10, time.Second, time.Second))
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := slow.GetChat(timeout, 1, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Ожидалась отмена по контексту, получено %v", err)
+	}
+
+	// Сервер просит ждать дольше максимальной задержки клиента - ошибка сразу
+	retryAfter.Store("60")
+	requests.Store(0)
+	_, err = New(srv.URL, fastRetries).SendMessage(ctx, 1, "x")
+	var apiErr *APIError
+	if !errors.Is(err, ErrRateLimited) || !errors.As(err, &apiErr) || apiErr.RetryAfter != time.Minute {
+		t.Errorf("Ожидалась ErrRateLimited с RetryAfter 1m: %v", err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Запрос с долгим Retry-After не должен повторяться: %d попыток", requests.Load())
+	}
+}
+
+// TestExportChat проверяет выгрузку истории: порядок, период и ошибки
+func TestExportChat(t *testing.T) {
+	srv, _ := newTestServer(t, nil)
+	c := New(srv.URL, fastRetries)
+	ctx := context.Background()
+
+	chat, _ := c.CreateChat(ctx, "архив")
+	first, _ := c.SendMessage(ctx, chat.ID, "первое")
+	second, _ := c.SendMessage(ctx, chat.ID, "второе")
+
+	body, err := c.ExportChat(ctx, chat.ID, ExportOptions{})
+	if err != nil {
+		t.Fatalf("ExportChat: %v", err)
+	}
+	var doc struct {
+		Messages     []Message `json:"messages"`
+		MessageCount int       `json:"message_count"`
+	}
+	err = json.NewDecoder(body).Decode(&doc)
+	body.Close()
+	if err != nil || doc.MessageCount != 2 || doc.Messages[0].ID != first.ID || doc.Messages[1].ID != second.ID {
+		t.Fatalf("Ожидались оба сообщения по порядку: %+v, %v", doc, err)
+	}
+
+	// Период после второго сообщения пуст
+	body, err = c.ExportChat(ctx, chat.ID, ExportOptions{Format: ExportCSV, From: second.CreatedAt.Add(time.Second)})
+	if err != nil {
+		t.Fatalf("ExportChat csv: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Errorf("Ожидался только заголовок CSV, получено строк: %d\n%s", lines, data)
+	}
+
+	if _, err := c.ExportChat(ctx, chat.ID, ExportOptions{Format: "xml"}); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Неизвестный формат: ожидалась ErrBadRequest, получено %v", err)
+	}
+	if _, err := c.ExportChat(ctx, 404, ExportOptions{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Несуществующий чат: ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+// TestSubscribe проверяет поток событий: новое сообщение, удаление чата и конец потока
+func TestSubscribe(t *testing.T) {
+	srv, _ := newTestServer(t, nil)
+	c := New(srv.URL, fastRetries)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := c.Subscribe(ctx, 404); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Подписка на несуществующий чат: ожидалась ErrNotFound, получено %v", err)
+	}
+
+	chat, _ := c.CreateChat(ctx, "поток")
+	sub, err := c.Subscribe(ctx, chat.ID)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Close()
+
+	if _, err := c.SendMessage(ctx, chat.ID, "привет"); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	event, err := sub.Next()
+	if err != nil || event.Type != EventMessageCreated || event.Message == nil || event.Message.Text != "привет" {
+		t.Fatalf("Ожидалось message.created: %+v, %v", event, err)
+	}
+
+	if err := c.DeleteChat(ctx, chat.ID); err != nil {
+		t.Fatalf("DeleteChat: %v", err)
+	}
+	if event, err := sub.Next(); err != nil || event.Type != EventChatDeleted || event.ChatID != chat.ID {
+		t.Fatalf("Ожидалось chat.deleted: %+v, %v", event, err)
+	}
+	if _, err := sub.Next(); err != io.EOF {
+		t.Errorf("После удаления чата поток должен закончиться io.EOF, получено %v", err)
+	}
+}
+
+// TestSubscriptionClose проверяет, что Close прерывает ожидающий Next
+func TestSubscriptionClose(t *testing.T) {
+	srv, _ := newTestServer(t, nil)
+	c := New(srv.URL)
+	ctx := context.Background()
+
+	chat, _ := c.CreateChat(ctx, "поток")
+	sub, err := c.Subscribe(ctx, chat.ID)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := sub.Next()
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	sub.Close()
+
+	select {
+	case err := <-done:
+		if err != io.EOF {
+			t.Errorf("После Close ожидался io.EOF, получено %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Next не завершился после Close")
+	}
+}
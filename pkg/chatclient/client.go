@@ -0,0 +1,574 @@
+// Package chatclient - Go клиент API чатов
+//
+// Клиент повторяет запросы при сетевых ошибках и ответах 5xx, 429 и 409
+// (запрос с тем же ключом идемпотентности еще выполняется) с экспоненциальной
+// задержкой и учетом Retry-After. Создание чата и отправка сообщения повторяются
+// безопасно: клиент один раз генерирует Idempotency-Key и отправляет его при каждой
+// попытке, поэтому сервер не создаст дубликат
+//
+//	c := chatclient.New("http://localhost:8080")
+//	chat, err := c.CreateChat(ctx, "Общий")
+//	msg, err := c.SendMessage(ctx, chat.ID, "привет")
+//	if errors.Is(err, chatclient.ErrNotFound) { ... }
+package chatclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorBody - сколько байт тела ответа с ошибкой читать в APIError.Message
+const maxErrorBody = 4 << 10
+
+// Client - клиент API чатов, безопасен для использования из нескольких горутин
+type Client struct {
+	baseURL     string
+	httpClient  *http.Client
+	headers     http.Header
+	maxAttempts int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+}
+
+// Option настраивает Client
+type Option func(*Client)
+
+// WithHTTPClient задает HTTP клиент (таймауты, транспорт, трассировка)
+// Для потока событий Client.Timeout должен быть нулевым, иначе поток оборвется по таймауту
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithHeader добавляет заголовок ко всем запросам (например, ID пользователя для шлюза)
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.headers.Set(key, value)
+	}
+}
+
+// WithRetries задает число попыток запроса (1 - без повторов) и границы задержки между ними
+// Задержка растет экспоненциально от minBackoff до maxBackoff со случайным разбросом
+// Если сервер просит подождать (Retry-After) дольше maxBackoff, запрос не повторяется
+func WithRetries(maxAttempts int, minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxAttempts = max(maxAttempts, 1)
+		c.minBackoff = minBackoff
+		c.maxBackoff = max(maxBackoff, minBackoff)
+	}
+}
+
+// New создает клиент для сервера baseURL (например, "http://localhost:8080")
+// По умолчанию: 4 попытки, задержка от 100мс до 5с
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		httpClient:  http.DefaultClient,
+		headers:     make(http.Header),
+		maxAttempts: 4,
+		minBackoff:  100 * time.Millisecond,
+		maxBackoff:  5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// CreateChat создает чат
+func (c *Client) CreateChat(ctx context.Context, title string) (*Chat, error) {
+	var chat Chat
+	body := map[string]string{"title": title}
+	if err := c.doJSON(ctx, http.MethodPost, "/chats", body, newIdempotencyKey(), &chat); err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+// ListChats возвращает страницу чатов в порядке ID после afterID (0 - с начала)
+// limit 0 - размер страницы сервера по умолчанию; следующая страница - ListChats(ctx, page.NextAfter, limit)
+func (c *Client) ListChats(ctx context.Context, afterID uint, limit int) (*ChatPage, error) {
+	query := url.Values{}
+	if afterID > 0 {
+		query.Set("after", strconv.FormatUint(uint64(afterID), 10))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	path := "/chats"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var page ChatPage
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, "", &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// GetChat возвращает чат и его последние limit сообщений (0 - значение сервера по умолчанию)
+func (c *Client) GetChat(ctx context.Context, chatID uint, limit int) (*ChatWithMessages, error) {
+	path := chatPath(chatID, "")
+	if limit > 0 {
+		path += "?limit=" + strconv.Itoa(limit)
+	}
+	var res ChatWithMessages
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, "", &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListMessages возвращает последние limit сообщений чата, от новых к старым
+func (c *Client) ListMessages(ctx context.Context, chatID uint, limit int) ([]Message, error) {
+	res, err := c.GetChat(ctx, chatID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return res.Messages, nil
+}
+
+// ExportChat выгружает историю чата файлом (JSON, CSV, HTML или текст) в хронологическом порядке
+// Тело отдается потоком и может быть большим: вызывающий читает и закрывает его
+// Если сервер оборвал выгрузку, чтение завершится ошибкой (io.ErrUnexpectedEOF),
+// а не io.EOF. Таймаут HTTP клиента ограничивает и чтение тела
+func (c *Client) ExportChat(ctx context.Context, chatID uint, opts ExportOptions) (io.ReadCloser, error) {
+	query := url.Values{}
+	if opts.Format != "" {
+		query.Set("format", opts.Format)
+	}
+	if !opts.From.IsZero() {
+		query.Set("from", opts.From.Format(time.RFC3339Nano))
+	}
+	if !opts.To.IsZero() {
+		query.Set("to", opts.To.Format(time.RFC3339Nano))
+	}
+	path := chatPath(chatID, "/export")
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	resp, err := c.do(ctx, http.MethodGet, path, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// SetSlowMode включает медленный режим чата (seconds = 0 - выключает)
+func (c *Client) SetSlowMode(ctx context.Context, chatID uint, seconds int) (*Chat, error) {
+	var chat Chat
+	body := map[string]int{"slow_mode_seconds": seconds}
+	if err := c.doJSON(ctx, http.MethodPatch, chatPath(chatID, ""), body, "", &chat); err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+// SetRetention задает срок хранения сообщений чата в днях (0 - хранить всегда)
+// days == nil возвращает срок по умолчанию сервера
+// Старые сообщения удаляет фоновая очистка сервера, а не этот вызов
+func (c *Client) SetRetention(ctx context.Context, chatID uint, days *int) (*Chat, error) {
+	var chat Chat
+	body := map[string]*int{"retention_days": days}
+	if err := c.doJSON(ctx, http.MethodPatch, chatPath(chatID, ""), body, "", &chat); err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+// DeleteChat удаляет чат вместе с сообщениями
+func (c *Client) DeleteChat(ctx context.Context, chatID uint) error {
+	return c.doJSON(ctx, http.MethodDelete, chatPath(chatID, ""), nil, "", nil)
+}
+
+// SendOption - необязательный параметр отправки сообщения
+type SendOption func(*sendMessageRequest)
+
+// sendMessageRequest - тело POST /chats/{id}/messages
+type sendMessageRequest struct {
+	Text       string       `json:"text"`
+	TTLSeconds *int         `json:"ttl_seconds,omitempty"`
+	ExpiresAt  *time.Time   `json:"expires_at,omitempty"`
+	Format     string       `json:"format,omitempty"`
+	Poll       *pollRequest `json:"poll,omitempty"`
+}
+
+// pollRequest - опрос в теле POST /chats/{id}/messages
+type pollRequest struct {
+	Options   []string   `json:"options"`
+	Multiple  bool       `json:"multiple,omitempty"`
+	Anonymous bool       `json:"anonymous,omitempty"`
+	ClosesAt  *time.Time `json:"closes_at,omitempty"`
+}
+
+// PollSettings - необязательные параметры опроса (см. AsPoll)
+type PollSettings struct {
+	Multiple  bool      // можно выбрать несколько вариантов
+	Anonymous bool      // не показывать, кто как голосовал
+	ClosesAt  time.Time // когда закончится голосование; нулевое - опрос не закрывается
+}
+
+// MessageTTL делает сообщение исчезающим: оно удалится через ttl (округляется вверх до секунды)
+// Сервер принимает от 1 секунды до 30 дней
+func MessageTTL(ttl time.Duration) SendOption {
+	return func(r *sendMessageRequest) {
+		seconds := int((ttl + time.Second - 1) / time.Second)
+		r.TTLSeconds = &seconds
+	}
+}
+
+// MessageExpiresAt делает сообщение исчезающим: оно удалится в момент at
+// Момент должен быть в будущем и не позднее чем через 30 дней
+func MessageExpiresAt(at time.Time) SendOption {
+	return func(r *sendMessageRequest) {
+		r.ExpiresAt = &at
+	}
+}
+
+// Markdown отправляет текст с разметкой: сервер вернет безопасный HTML (Message.HTML)
+// и элементы разметки (Message.Entities)
+func Markdown() SendOption {
+	return func(r *sendMessageRequest) {
+		r.Format = FormatMarkdown
+	}
+}
+
+// AsPoll делает сообщение опросом с вариантами options (от 2 до 10, без повторов),
+// текст сообщения - вопрос; голосовать - Vote
+func AsPoll(options []string, settings PollSettings) SendOption {
+	return func(r *sendMessageRequest) {
+		r.Poll = &pollRequest{Options: options, Multiple: settings.Multiple, Anonymous: settings.Anonymous}
+		if !settings.ClosesAt.IsZero() {
+			r.Poll.ClosesAt = &settings.ClosesAt
+		}
+	}
+}
+
+// SendMessage отправляет сообщение в чат
+// При медленном режиме сервер отвечает 429 с Retry-After: если ждать дольше
+// максимальной задержки клиента, вернется ошибка ErrRateLimited с APIError.RetryAfter
+func (c *Client) SendMessage(ctx context.Context, chatID uint, text string, opts ...SendOption) (*Message, error) {
+	var msg Message
+	body := sendMessageRequest{Text: text}
+	for _, opt := range opts {
+		opt(&body)
+	}
+	if err := c.doJSON(ctx, http.MethodPost, chatPath(chatID, "/messages"), body, newIdempotencyKey(), &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// ForwardMessage пересылает сообщение messageID чата chatID в чат targetChatID
+// Копия приходит с Message.Forwarded - ссылкой на оригинал
+// ErrUnauthorized - пользователь не определен; ErrNotFound - нет одного из чатов или оригинал
+// удален; ErrBadRequest - тот же чат, служебное сообщение или опрос
+func (c *Client) ForwardMessage(ctx context.Context, chatID, messageID, targetChatID uint) (*Message, error) {
+	var msg Message
+	body := map[string]uint{"chat_id": targetChatID}
+	path := chatPath(chatID, "/messages/"+strconv.FormatUint(uint64(messageID), 10)+"/forward")
+	if err := c.doJSON(ctx, http.MethodPost, path, body, newIdempotencyKey(), &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// PinMessage закрепляет сообщение чата; в чат придет служебное сообщение о закреплении
+// ErrConflict - сообщение уже закреплено или в чате уже максимум закрепленных
+func (c *Client) PinMessage(ctx context.Context, chatID, messageID uint) (*Pin, error) {
+	var pin Pin
+	body := map[string]uint{"message_id": messageID}
+	if err := c.doJSON(ctx, http.MethodPost, chatPath(chatID, "/pins"), body, "", &pin); err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
+// UnpinMessage открепляет сообщение чата
+func (c *Client) UnpinMessage(ctx context.Context, chatID, messageID uint) error {
+	path := chatPath(chatID, "/pins/"+strconv.FormatUint(uint64(messageID), 10))
+	return c.doJSON(ctx, http.MethodDelete, path, nil, "", nil)
+}
+
+// ListPins возвращает закрепленные сообщения чата, последние закрепленные первыми
+func (c *Client) ListPins(ctx context.Context, chatID uint) ([]Pin, error) {
+	var page struct {
+		Pins []Pin `json:"pins"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, chatPath(chatID, "/pins"), nil, "", &page); err != nil {
+		return nil, err
+	}
+	return page.Pins, nil
+}
+
+// Vote голосует в опросе за варианты options (номера с 0) от имени пользователя (см. WithHeader):
+// повторный вызов заменяет прежний выбор. Возвращает итоги с выбором пользователя (Poll.MyVotes)
+// ErrBadRequest - сообщение не опрос или варианты неверны, ErrConflict - опрос закрыт
+func (c *Client) Vote(ctx context.Context, chatID, messageID uint, options ...int) (*Poll, error) {
+	var poll Poll
+	body := map[string][]int{"options": options}
+	if err := c.doJSON(ctx, http.MethodPost, votesPath(chatID, messageID), body, "", &poll); err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+// RetractVote отзывает голоса пользователя в опросе
+// ErrConflict - опрос закрыт
+func (c *Client) RetractVote(ctx context.Context, chatID, messageID uint) (*Poll, error) {
+	var poll Poll
+	if err := c.doJSON(ctx, http.MethodDelete, votesPath(chatID, messageID), nil, "", &poll); err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+// votesPath - путь голосов опроса
+func votesPath(chatID, messageID uint) string {
+	return chatPath(chatID, "/messages/"+strconv.FormatUint(uint64(messageID), 10)+"/votes")
+}
+
+// ListMentions возвращает страницу упоминаний пользователя (его задает шлюз, см. WithHeader),
+// новые первыми; unreadOnly - только непрочитанные, limit <= 0 - значение по умолчанию сервера
+// Следующая страница - ListMentions(ctx, unreadOnly, page.NextBefore, limit)
+// ErrUnauthorized - пользователь не определен
+func (c *Client) ListMentions(ctx context.Context, unreadOnly bool, beforeID uint, limit int) (*MentionPage, error) {
+	query := url.Values{}
+	if unreadOnly {
+		query.Set("unread", "true")
+	}
+	if beforeID > 0 {
+		query.Set("before", strconv.FormatUint(uint64(beforeID), 10))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	path := "/me/mentions"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var page MentionPage
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, "", &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// MarkMentionsRead отмечает упоминания с ID из ids прочитанными (без ids - все)
+// и возвращает, сколько отмечено этим вызовом
+func (c *Client) MarkMentionsRead(ctx context.Context, ids ...uint) (int, error) {
+	var res struct {
+		Marked int `json:"marked"`
+	}
+	body := map[string][]uint{"ids": ids}
+	if err := c.doJSON(ctx, http.MethodPost, "/me/mentions/read", body, "", &res); err != nil {
+		return 0, err
+	}
+	return res.Marked, nil
+}
+
+// ScheduleMessage планирует отправку сообщения в момент sendAt (в будущем, не позднее чем через год)
+// Повтор запроса после сбоя не создает второе запланированное сообщение
+func (c *Client) ScheduleMessage(ctx context.Context, chatID uint, text string, sendAt time.Time) (*ScheduledMessage, error) {
+	var msg ScheduledMessage
+	body := map[string]any{"text": text, "send_at": sendAt}
+	if err := c.doJSON(ctx, http.MethodPost, chatPath(chatID, "/scheduled-messages"), body, newIdempotencyKey(), &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// ListScheduled возвращает запланированные сообщения чата в порядке отправки
+// status - ScheduledPending, ScheduledSent и т.д. (пусто - все); limit <= 0 - значение по умолчанию сервера
+func (c *Client) ListScheduled(ctx context.Context, chatID uint, status string, limit int) ([]ScheduledMessage, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", status)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	path := chatPath(chatID, "/scheduled-messages")
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var page struct {
+		ScheduledMessages []ScheduledMessage `json:"scheduled_messages"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, path, nil, "", &page); err != nil {
+		return nil, err
+	}
+	return page.ScheduledMessages, nil
+}
+
+// RescheduleMessage меняет время отправки и, если text не пустой, текст запланированного сообщения
+// Только до начала отправки, иначе ErrConflict
+func (c *Client) RescheduleMessage(ctx context.Context, chatID, id uint, text string, sendAt time.Time) (*ScheduledMessage, error) {
+	var msg ScheduledMessage
+	body := map[string]any{"send_at": sendAt}
+	if text != "" {
+		body["text"] = text
+	}
+	if err := c.doJSON(ctx, http.MethodPatch, scheduledPath(chatID, id), body, "", &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// CancelScheduled отменяет запланированное сообщение
+// Только до начала отправки, иначе ErrConflict
+func (c *Client) CancelScheduled(ctx context.Context, chatID, id uint) error {
+	return c.doJSON(ctx, http.MethodDelete, scheduledPath(chatID, id), nil, "", nil)
+}
+
+// doJSON выполняет запрос с JSON телом in и разбирает JSON ответ в out (nil - ответ не нужен)
+func (c *Client) doJSON(ctx context.Context, method, path string, in any, idempotencyKey string, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("chatclient: кодирование запроса: %w", err)
+		}
+	}
+	resp, err := c.do(ctx, method, path, body, idempotencyKey)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("chatclient: разбор ответа %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// do выполняет запрос с повторами и возвращает успешный (2xx) ответ
+// Тело ответа закрывает вызывающий
+func (c *Client) do(ctx context.Context, method, path string, body []byte, idempotencyKey string) (*http.Response, error) {
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, method, path, body, idempotencyKey)
+		if err == nil && resp.StatusCode < 300 {
+			return resp, nil
+		}
+
+		var wait time.Duration
+		if err != nil {
+			// Сетевая ошибка: при отмене контекста повторять бессмысленно
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = fmt.Errorf("chatclient: %s %s: %w", method, path, err)
+		} else {
+			apiErr := readAPIError(resp)
+			lastErr = apiErr
+			if !retryable(apiErr.StatusCode, idempotencyKey != "") {
+				return nil, apiErr
+			}
+			wait = apiErr.RetryAfter
+		}
+
+		if attempt >= c.maxAttempts {
+			return nil, lastErr
+		}
+		if wait == 0 {
+			wait = c.backoff(attempt)
+		} else if wait > c.maxBackoff {
+			return nil, lastErr // сервер просит ждать дольше, чем мы готовы
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// send выполняет одну попытку запроса
+func (c *Client) send(ctx context.Context, method, path string, body []byte, idempotencyKey string) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.headers {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	return c.httpClient.Do(req)
+}
+
+// backoff - задержка перед попыткой attempt+1: экспонента с разбросом от половины до полного значения
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.minBackoff << min(attempt-1, 30)
+	if d > c.maxBackoff || d <= 0 {
+		d = c.maxBackoff
+	}
+	return d/2 + mathrand.N(d/2+1)
+}
+
+// retryable сообщает, имеет ли смысл повторять запрос с таким ответом
+// 409 повторяется только с ключом идемпотентности: без ключа это окончательный конфликт
+// (например, запланированное сообщение уже отправлено)
+func retryable(status int, withKey bool) bool {
+	return status >= 500 || status == http.StatusTooManyRequests || (status == http.StatusConflict && withKey)
+}
+
+// readAPIError читает ответ с ошибкой и закрывает его тело
+func readAPIError(resp *http.Response) *APIError {
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // соединение вернется в пул
+
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(msg)),
+		RequestID:  resp.Header.Get("X-Request-ID"),
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
+
+// chatPath возвращает путь /chats/{id}{suffix}
+func chatPath(chatID uint, suffix string) string {
+	return "/chats/" + strconv.FormatUint(uint64(chatID), 10) + suffix
+}
+
+// scheduledPath возвращает путь запланированного сообщения
+func scheduledPath(chatID, id uint) string {
+	return chatPath(chatID, "/scheduled-messages/"+strconv.FormatUint(uint64(id), 10))
+}
+
+// newIdempotencyKey - случайный ключ идемпотентности для одного вызова
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,65 @@
+package chatclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Ошибки API по кодам ответа сервера
+// Проверяются через errors.Is: errors.Is(err, chatclient.ErrNotFound)
+var (
+	ErrBadRequest          = errors.New("неверный запрос")                                    // 400
+	ErrUnauthorized        = errors.New("пользователь не определен")                          // 401
+	ErrNotFound            = errors.New("чат не найден")                                      // 404
+	ErrConflict            = errors.New("запрос с этим ключом идемпотентности выполняется")   // 409
+	ErrPayloadTooLarge     = errors.New("слишком большое тело запроса")                       // 413
+	ErrIdempotencyMismatch = errors.New("ключ идемпотентности использован с другим запросом") // 422
+	ErrRateLimited         = errors.New("слишком много запросов")                             // 429
+	ErrServer              = errors.New("ошибка сервера")                                     // 5xx
+)
+
+// APIError - ответ сервера с кодом ошибки
+type APIError struct {
+	StatusCode int           // HTTP статус
+	Message    string        // текст ошибки от сервера
+	RetryAfter time.Duration // из заголовка Retry-After (0 - не задан)
+	RequestID  string        // X-Request-ID ответа, по нему ищутся логи сервера
+}
+
+// Error возвращает текст ошибки сервера
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("chatclient: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("chatclient: %d %s", e.StatusCode, e.Message)
+}
+
+// Is сопоставляет ошибку с ErrNotFound, ErrRateLimited и т.д. по коду ответа
+func (e *APIError) Is(target error) bool {
+	return statusError(e.StatusCode) == target
+}
+
+// statusError возвращает ошибку-маркер для HTTP статуса (nil, если маркера нет)
+func statusError(status int) error {
+	switch {
+	case status == http.StatusBadRequest:
+		return ErrBadRequest
+	case status == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case status == http.StatusNotFound:
+		return ErrNotFound
+	case status == http.StatusConflict:
+		return ErrConflict
+	case status == http.StatusRequestEntityTooLarge:
+		return ErrPayloadTooLarge
+	case status == http.StatusUnprocessableEntity:
+		return ErrIdempotencyMismatch
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status >= 500:
+		return ErrServer
+	}
+	return nil
+}
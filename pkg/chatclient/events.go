@@ -0,0 +1,78 @@
+package chatclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// Subscription - подписка на поток событий чата (Server-Sent Events)
+// Next и Close можно вызывать из разных горутин
+type Subscription struct {
+	body   io.ReadCloser
+	lines  *bufio.Reader
+	closed atomic.Bool
+}
+
+// Subscribe подписывается на события чата
+// Подключение повторяется так же, как обычные запросы; ErrNotFound - чата нет
+// Поток живет до отмены ctx, вызова Close или закрытия сервером (после chat.deleted
+// или при остановке сервера). Пропущенные за время переподключения сообщения
+// дочитываются через GetChat
+func (c *Client) Subscribe(ctx context.Context, chatID uint) (*Subscription, error) {
+	resp, err := c.do(ctx, http.MethodGet, chatPath(chatID, "/events"), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		resp.Body.Close()
+		return nil, fmt.Errorf("chatclient: ожидался text/event-stream, получен %q", ct)
+	}
+	return &Subscription{body: resp.Body, lines: bufio.NewReader(resp.Body)}, nil
+}
+
+// Next ждет следующее событие
+// io.EOF - поток закончился (сервер закрыл его или вызван Close)
+func (s *Subscription) Next() (Event, error) {
+	var data strings.Builder
+	for {
+		line, err := s.lines.ReadString('\n')
+		if err != nil {
+			if s.closed.Load() || err == io.EOF {
+				return Event{}, io.EOF
+			}
+			return Event{}, fmt.Errorf("chatclient: чтение потока событий: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "":
+			// Пустая строка завершает событие; комментарии (": ping") событий не дают
+			if data.Len() == 0 {
+				continue
+			}
+			var event Event
+			if err := json.Unmarshal([]byte(data.String()), &event); err != nil {
+				return Event{}, fmt.Errorf("chatclient: разбор события: %w", err)
+			}
+			return event, nil
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+		// Поле event: дублирует type из JSON, прочие поля и комментарии пропускаем
+	}
+}
+
+// Close закрывает поток; ожидающий Next вернет io.EOF
+func (s *Subscription) Close() error {
+	s.closed.Store(true)
+	return s.body.Close()
+}
@@ -0,0 +1,220 @@
+package chatclient
+
+import "time"
+
+// Chat - чат
+type Chat struct {
+	ID              uint      `json:"id"`
+	Title           string    `json:"title"`
+	SlowModeSeconds int       `json:"slow_mode_seconds"` // 0 - медленный режим выключен
+	RetentionDays   *int      `json:"retention_days"`    // срок хранения сообщений в днях: 0 - всегда, nil - по умолчанию сервера
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// Message - сообщение чата
+type Message struct {
+	ID        uint          `json:"id"`
+	ChatID    uint          `json:"chat_id"`
+	AuthorID  string        `json:"author_id,omitempty"` // пусто, если на сервере выключена идентификация
+	Text      string        `json:"text"`
+	Format    string        `json:"format"`             // FormatPlain или FormatMarkdown
+	HTML      string        `json:"html,omitempty"`     // безопасный HTML сообщения markdown
+	Entities  []Entity      `json:"entities,omitempty"` // элементы разметки сообщения markdown
+	Mentions  []Mention     `json:"mentions,omitempty"` // упоминания @user и @all в тексте
+	CreatedAt time.Time     `json:"created_at"`
+	ExpiresAt *time.Time    `json:"expires_at,omitempty"`     // исчезающее сообщение: после этого момента удаляется
+	Kind      string        `json:"kind,omitempty"`           // MessageKindPin или MessageKindPoll, пусто - обычное
+	Poll      *Poll         `json:"poll,omitempty"`           // опрос (Kind = MessageKindPoll), вопрос - Text
+	Forwarded *Forward      `json:"forwarded_from,omitempty"` // откуда переслано (ForwardMessage)
+	Previews  []LinkPreview `json:"previews,omitempty"`       // превью ссылок из текста
+}
+
+// Forward - откуда переслано сообщение ("переслано из"), снимок на момент пересылки
+type Forward struct {
+	ChatID    uint      `json:"chat_id"`
+	ChatTitle string    `json:"chat_title"`
+	MessageID uint      `json:"message_id"`
+	AuthorID  string    `json:"author_id,omitempty"`
+	SentAt    time.Time `json:"sent_at"`
+	Deleted   bool      `json:"deleted,omitempty"` // оригинал или его чат удален
+}
+
+// Poll - опрос с итогами на момент ответа сервера
+type Poll struct {
+	Options     []PollOption `json:"options"`
+	Multiple    bool         `json:"multiple"`            // можно выбрать несколько вариантов
+	Anonymous   bool         `json:"anonymous"`           // PollOption.Voters не заполняется
+	ClosesAt    *time.Time   `json:"closes_at,omitempty"` // после этого момента голосовать нельзя
+	Closed      bool         `json:"closed"`
+	TotalVoters int          `json:"total_voters"`       // проголосовавших пользователей, а не голосов
+	MyVotes     []int        `json:"my_votes,omitempty"` // варианты пользователя запроса (номера с 0); в событиях нет
+}
+
+// PollOption - вариант ответа в опросе
+type PollOption struct {
+	Text   string   `json:"text"`
+	Votes  int      `json:"votes"`
+	Voters []string `json:"voters,omitempty"` // в порядке голосования, в анонимном опросе пусто
+}
+
+// Статусы превью ссылки (LinkPreview.Status)
+const (
+	PreviewPending  = "pending"
+	PreviewFetching = "fetching"
+	PreviewReady    = "ready"
+	PreviewFailed   = "failed"
+)
+
+// LinkPreview - превью ссылки из сообщения
+// Пока статус PreviewPending или PreviewFetching, страница загружается: результат
+// придет событием EventMessagePreview
+type LinkPreview struct {
+	URL         string     `json:"url"`
+	Status      string     `json:"status"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	ImageURL    string     `json:"image_url,omitempty"`
+	SiteName    string     `json:"site_name,omitempty"`
+	FetchedAt   *time.Time `json:"fetched_at,omitempty"`
+}
+
+// Виды сообщений (Message.Kind)
+const (
+	MessageKindPin  = "pin"  // служебное сообщение о закреплении
+	MessageKindPoll = "poll" // опрос
+)
+
+// Форматы текста сообщения (Message.Format)
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+)
+
+// Виды элементов разметки (Entity.Type)
+const (
+	EntityBold   = "bold"
+	EntityItalic = "italic"
+	EntityCode   = "code"
+	EntityPre    = "pre"
+	EntityLink   = "link"
+)
+
+// Entity - элемент разметки сообщения markdown
+// Offset и Length считаются в символах Unicode исходного текста вместе с разметкой
+type Entity struct {
+	Type     string `json:"type"`
+	Offset   int    `json:"offset"`
+	Length   int    `json:"length"`
+	URL      string `json:"url,omitempty"`      // адрес ссылки (EntityLink)
+	Language string `json:"language,omitempty"` // язык блока кода (EntityPre)
+}
+
+// MentionAll - цель упоминания @all (Mention.Target): все участники чата
+const MentionAll = "all"
+
+// Mention - упоминание в тексте сообщения
+// Offset и Length считаются в символах Unicode: []rune(msg.Text)[m.Offset : m.Offset+m.Length]
+type Mention struct {
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	Target string `json:"target"` // ID упомянутого пользователя или MentionAll
+}
+
+// MessageMention - уведомление об упоминании пользователя в сообщении
+type MessageMention struct {
+	ID        uint       `json:"id"`
+	ChatID    uint       `json:"chat_id"`
+	MessageID uint       `json:"message_id"`
+	AuthorID  string     `json:"author_id,omitempty"` // кто упомянул
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at"` // nil - не прочитано
+	Message   Message    `json:"message"`
+}
+
+// MentionPage - страница уведомлений об упоминаниях
+type MentionPage struct {
+	Mentions    []MessageMention `json:"mentions"`
+	UnreadCount int              `json:"unread_count"` // всего непрочитанных
+	NextBefore  uint             `json:"next_before"`  // 0 - страница последняя
+}
+
+// Pin - закрепленное сообщение чата
+type Pin struct {
+	ChatID    uint      `json:"chat_id"`
+	MessageID uint      `json:"message_id"`
+	PinnedBy  string    `json:"pinned_by,omitempty"`
+	PinnedAt  time.Time `json:"pinned_at"`
+	Message   Message   `json:"message"`
+}
+
+// Статусы запланированного сообщения
+const (
+	ScheduledPending  = "pending"  // ждет отправки (после ошибки - повтора)
+	ScheduledSending  = "sending"  // отправляется сейчас
+	ScheduledSent     = "sent"     // отправлено, MessageID - созданное сообщение
+	ScheduledFailed   = "failed"   // попытки исчерпаны, причина в LastError
+	ScheduledCanceled = "canceled" // отменено до отправки
+)
+
+// ScheduledMessage - сообщение, которое сервер отправит в чат в момент SendAt
+type ScheduledMessage struct {
+	ID        uint      `json:"id"`
+	ChatID    uint      `json:"chat_id"`
+	AuthorID  string    `json:"author_id,omitempty"`
+	Text      string    `json:"text"`
+	SendAt    time.Time `json:"send_at"`
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`             // неудачных попыток отправки
+	LastError string    `json:"last_error,omitempty"` // причина последней неудачи
+	MessageID uint      `json:"message_id,omitempty"` // отправленное сообщение; 0 - еще не отправлено
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ChatPage - страница списка чатов
+type ChatPage struct {
+	Chats     []Chat `json:"chats"`
+	NextAfter uint   `json:"next_after"` // 0 - страница последняя
+}
+
+// ChatWithMessages - ответ GET /chats/{id}: чат и его последние сообщения
+type ChatWithMessages struct {
+	Chat             Chat      `json:"chat"`
+	Messages         []Message `json:"messages"`
+	PinnedMessageIDs []uint    `json:"pinned_message_ids"` // закрепленные, последние закрепленные первыми
+}
+
+// Форматы выгрузки истории чата
+const (
+	ExportJSON = "json"
+	ExportCSV  = "csv"
+	ExportHTML = "html"
+	ExportText = "txt"
+)
+
+// ExportOptions - параметры выгрузки истории чата
+type ExportOptions struct {
+	Format string    // ExportJSON, ExportCSV, ExportHTML или ExportText; пусто - JSON
+	From   time.Time // начало периода включительно; нулевое - с начала истории
+	To     time.Time // конец периода не включительно; нулевое - до конца истории
+}
+
+// Типы событий потока
+const (
+	EventMessageCreated = "message.created"
+	EventMessageDeleted = "message.deleted"
+	EventMessagePreview = "message.preview"
+	EventPollUpdated    = "poll.updated"
+	EventChatDeleted    = "chat.deleted"
+)
+
+// Event - событие из потока GET /chats/{id}/events
+type Event struct {
+	Type       string       `json:"type"`
+	ChatID     uint         `json:"chat_id"`
+	Message    *Message     `json:"message,omitempty"`    // только для message.created
+	MessageID  uint         `json:"message_id,omitempty"` // только для message.deleted, message.preview и poll.updated
+	Preview    *LinkPreview `json:"preview,omitempty"`    // только для message.preview
+	Poll       *Poll        `json:"poll,omitempty"`       // только для poll.updated: итоги без MyVotes
+	OccurredAt time.Time    `json:"occurred_at"`
+}